@@ -17,6 +17,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -43,6 +44,7 @@ import (
 	"github.com/pkg/profile"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 )
 
 // TODO: Improve one-liner description.
@@ -65,6 +67,31 @@ var (
 	builtBy  string // build owner
 )
 
+//
+// sysbox-fs reload handler goroutine. Upon SIGUSR1 arrival, the handlers
+// disabled at runtime are brought in line with the emu-attrs-config file.
+//
+func reloadHandler(
+	signalChan chan os.Signal,
+	hds domain.HandlerServiceIface,
+	path string) {
+
+	for range signalChan {
+		cfg, err := handler.LoadEmuResourceAttrConfig(path)
+		if err != nil {
+			logrus.Warnf("Unable to reload emulated-nodes attributes: %v", err)
+			continue
+		}
+
+		if err := hds.SetDisabledHandlers(cfg.DisabledHandlers); err != nil {
+			logrus.Warnf("Unable to reload disabled handlers: %v", err)
+			continue
+		}
+
+		logrus.Infof("Disabled handlers reloaded from %s", path)
+	}
+}
+
 //
 // sysbox-fs exit handler goroutine.
 //
@@ -164,6 +191,33 @@ func runProfiler(ctx *cli.Context) (interface{ Stop() }, error) {
 //
 // sysbox-fs main function
 //
+// Mounts a tmpfs at the given location (unless already present, e.g. upon
+// sysbox-fs restart), so that the per-container mountpoints can be created
+// even if the location is placed on a read-only file-system. Notice that the
+// location itself must exist in that case.
+func setupTmpfsMountpoint(path string) error {
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return err
+	}
+	if st.Type == unix.TMPFS_MAGIC {
+		return nil
+	}
+
+	return unix.Mount(
+		"sysboxfs",
+		path,
+		"tmpfs",
+		unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC,
+		"mode=0755",
+	)
+}
+
 func main() {
 
 	app := cli.NewApp()
@@ -177,6 +231,15 @@ func main() {
 			Value: "/var/lib/sysboxfs",
 			Usage: "mount-point location",
 		},
+		cli.StringFlag{
+			Name:  "mountpoint-layout",
+			Value: fuse.MountpointLayout,
+			Usage: "layout of the per-container mount-points within the mount-point location, where {id} stands for the container id; must match the one expected by sysbox-runc",
+		},
+		cli.BoolFlag{
+			Name:  "mountpoint-tmpfs",
+			Usage: "back the mount-point location with a tmpfs, for hosts where it's placed on a read-only file-system (default: \"false\")",
+		},
 		cli.BoolFlag{
 			Name:  "allow-immutable-remounts",
 			Usage: "sys container's initial mounts are considered immutable; this option allows them to be remounted from within the container (default: \"false\")",
@@ -190,6 +253,76 @@ func main() {
 			Value: "proc-exit",
 			Usage: "Policy to close syscall interception handles; allowed values are \"proc-exit\" and \"cont-exit\" (default = \"proc-exit\")",
 		},
+		cli.BoolFlag{
+			Name:  "seccomp-extra-traps",
+			Usage: "handle the fsopen, openat2, _sysctl, sethostname, setdomainname, init_module and finit_module syscalls; requires a sysbox-runc whose seccomp-notify filter traps them (default: \"false\")",
+		},
+		cli.StringSliceFlag{
+			Name:  "emulated-modules",
+			Usage: "kernel modules whose loads are reported as successful inside sys containers; can be repeated (default: docker / kubernetes networking & storage modules)",
+		},
+		cli.StringFlag{
+			Name:  "emu-attrs-config",
+			Value: "",
+			Usage: "json file with the default modes / ownership to apply to the emulated nodes; its disabled handlers are re-applied upon SIGUSR1 (default: \"\")",
+		},
+		cli.StringFlag{
+			Name:  "handlers-config",
+			Value: "",
+			Usage: "json file declaring additional (fixed, stored or clamped) emulated nodes (default: \"\")",
+		},
+		cli.StringFlag{
+			Name:  "plugins-config",
+			Value: "",
+			Usage: "json file declaring the handler plugins (go or process based) to load (default: \"\")",
+		},
+		cli.DurationFlag{
+			Name:  "fuse-attr-ttl",
+			Usage: "time during which the kernel caches the attributes of the emulated nodes; can be overridden per handler through the emu-attrs-config file (default: 0s, i.e. no caching)",
+		},
+		cli.DurationFlag{
+			Name:  "fuse-entry-ttl",
+			Usage: "time during which the kernel caches the dentries of the emulated nodes; can be overridden per handler through the emu-attrs-config file (default: unlimited)",
+		},
+		cli.IntFlag{
+			Name:  "fuse-max-workers",
+			Value: fuse.MaxWorkers,
+			Usage: "maximum number of requests concurrently served for each sys container (default: 8 per CPU; 0 stands for unlimited)",
+		},
+		cli.IntFlag{
+			Name:  "fuse-max-background",
+			Value: 0,
+			Usage: "maximum number of background requests queued by the kernel on each FUSE connection (default: 0, i.e. kernel default)",
+		},
+		cli.IntFlag{
+			Name:  "fuse-congestion-threshold",
+			Value: 0,
+			Usage: "number of queued background requests upon which the kernel considers a FUSE connection congested (default: 0, i.e. kernel default)",
+		},
+		cli.IntFlag{
+			Name:  "max-concurrent-preregistrations",
+			Value: 0,
+			Usage: "maximum number of sys container pre-registrations (i.e. fuse-server creations) processed concurrently (default: 0, i.e. unlimited)",
+		},
+		cli.DurationFlag{
+			Name:  "preregistration-slot-timeout",
+			Value: state.PreRegistrationSlotTimeout,
+			Usage: "maximum time a sys container pre-registration waits for its turn when max-concurrent-preregistrations is reached; pre-registrations timing out are failed",
+		},
+		cli.DurationFlag{
+			Name:  "fuse-watchdog-interval",
+			Usage: "interval between the health checks of each sys container's FUSE server; failed checks are logged and reported in the container's kernel log (default: 0s, i.e. disabled)",
+		},
+		cli.DurationFlag{
+			Name:  "fuse-watchdog-timeout",
+			Value: fuse.WatchdogTimeout,
+			Usage: "time within which FUSE servers must answer the health checks",
+		},
+		cli.DurationFlag{
+			Name:  "nsenter-timeout",
+			Value: nsenter.RequestTimeout,
+			Usage: "deadline of the operations carried out within the sys containers' namespaces; processes of timed-out operations are killed (0 disables it)",
+		},
 		cli.StringFlag{
 			Name:  "log",
 			Value: "",
@@ -244,6 +377,19 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:  "catalog",
+			Usage: "Dump the catalog of emulated resources (json format)",
+			Action: func(c *cli.Context) error {
+				data, err := json.MarshalIndent(
+					handler.Catalog(handler.DefaultHandlers), "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			},
+		},
 	}
 
 	// Define 'debug' and 'log' settings.
@@ -336,6 +482,111 @@ func main() {
 		}
 		logrus.Infof("FUSE dir = %s", ctx.GlobalString("mountpoint"))
 
+		if ctx.GlobalIsSet("mountpoint-layout") {
+			if err := fuse.SetMountpointLayout(ctx.GlobalString("mountpoint-layout")); err != nil {
+				logrus.Fatalf("Invalid mountpoint layout: %v", err)
+			}
+			logrus.Infof("FUSE per-container dir layout = %s", fuse.MountpointLayout)
+		}
+
+		if ctx.GlobalBool("mountpoint-tmpfs") {
+			if err := setupTmpfsMountpoint(ctx.GlobalString("mountpoint")); err != nil {
+				logrus.Fatalf("Unable to setup tmpfs at %s: %v",
+					ctx.GlobalString("mountpoint"), err)
+			}
+			logrus.Infof("FUSE dir backed by tmpfs")
+		}
+
+		// Kernel-side cache TTLs of the emulated nodes.
+		if ctx.GlobalIsSet("fuse-attr-ttl") {
+			fuse.AttrCacheTimeout = int64(ctx.GlobalDuration("fuse-attr-ttl"))
+			logrus.Infof("FUSE attributes cache TTL = %v", ctx.GlobalDuration("fuse-attr-ttl"))
+		}
+		if ctx.GlobalIsSet("fuse-entry-ttl") {
+			fuse.DentryCacheTimeout = int64(ctx.GlobalDuration("fuse-entry-ttl"))
+			logrus.Infof("FUSE dentries cache TTL = %v", ctx.GlobalDuration("fuse-entry-ttl"))
+		}
+		if ctx.GlobalIsSet("fuse-max-workers") {
+			fuse.MaxWorkers = ctx.GlobalInt("fuse-max-workers")
+			logrus.Infof("FUSE max workers = %v", fuse.MaxWorkers)
+		}
+		if ctx.GlobalIsSet("fuse-max-background") {
+			fuse.MaxBackground = ctx.GlobalInt("fuse-max-background")
+			logrus.Infof("FUSE max background requests = %v", fuse.MaxBackground)
+		}
+		if ctx.GlobalIsSet("fuse-congestion-threshold") {
+			fuse.CongestionThreshold = ctx.GlobalInt("fuse-congestion-threshold")
+			logrus.Infof("FUSE congestion threshold = %v", fuse.CongestionThreshold)
+		}
+		if ctx.GlobalIsSet("fuse-watchdog-interval") {
+			fuse.WatchdogInterval = ctx.GlobalDuration("fuse-watchdog-interval")
+			fuse.WatchdogTimeout = ctx.GlobalDuration("fuse-watchdog-timeout")
+			logrus.Infof("FUSE watchdog interval = %v (timeout = %v)",
+				fuse.WatchdogInterval, fuse.WatchdogTimeout)
+		}
+
+		if ctx.GlobalIsSet("nsenter-timeout") {
+			nsenter.RequestTimeout = ctx.GlobalDuration("nsenter-timeout")
+			logrus.Infof("Nsenter requests timeout = %v", nsenter.RequestTimeout)
+		}
+
+		if ctx.GlobalIsSet("max-concurrent-preregistrations") {
+			state.MaxConcurrentPreRegistrations = ctx.GlobalInt("max-concurrent-preregistrations")
+			logrus.Infof("Max concurrent pre-registrations = %v",
+				state.MaxConcurrentPreRegistrations)
+		}
+		if ctx.GlobalIsSet("preregistration-slot-timeout") {
+			state.PreRegistrationSlotTimeout = ctx.GlobalDuration("preregistration-slot-timeout")
+			logrus.Infof("Pre-registration slot timeout = %v",
+				state.PreRegistrationSlotTimeout)
+		}
+
+		if ctx.GlobalBool("seccomp-extra-traps") {
+			seccomp.ExtraSyscallTraps = true
+			logrus.Info("Handling of the extra seccomp-notify traps enabled")
+		}
+
+		if ctx.GlobalIsSet("emulated-modules") {
+			seccomp.EmulatedModules = ctx.GlobalStringSlice("emulated-modules")
+			logrus.Infof("Emulated kernel modules = %v", seccomp.EmulatedModules)
+		}
+
+		// Load the operator-defined attributes of the emulated nodes (if any).
+		var emuAttrsConfig *domain.EmuResourceAttrConfig
+		if path := ctx.GlobalString("emu-attrs-config"); path != "" {
+			cfg, err := handler.LoadEmuResourceAttrConfig(path)
+			if err != nil {
+				logrus.Fatalf("Unable to load emulated-nodes attributes: %v", err)
+			}
+			emuAttrsConfig = cfg
+			state.ContainerAttrRules = cfg.Containers
+			logrus.Infof("Emulated-nodes attributes loaded from %s", path)
+		}
+
+		// Load the operator-declared emulated nodes (if any).
+		var handlers = handler.DefaultHandlers
+		if path := ctx.GlobalString("handlers-config"); path != "" {
+			hdlrs, err := handler.LoadDeclarativeHandlers(path)
+			if err != nil {
+				logrus.Fatalf("Unable to load declared handlers: %v", err)
+			}
+			handlers = append(
+				append([]domain.HandlerIface{}, handler.DefaultHandlers...),
+				hdlrs...)
+			logrus.Infof("%d declared handlers loaded from %s", len(hdlrs), path)
+		}
+
+		// Load the operator-provided handler plugins (if any).
+		if path := ctx.GlobalString("plugins-config"); path != "" {
+			hdlrs, err := handler.LoadHandlerPlugins(path)
+			if err != nil {
+				logrus.Fatalf("Unable to load handler plugins: %v", err)
+			}
+			handlers = append(
+				append([]domain.HandlerIface{}, handlers...),
+				hdlrs...)
+		}
+
 		// Construct sysbox-fs services.
 		var nsenterService = nsenter.NewNSenterService()
 		var ioService = sysio.NewIOService(domain.IOOsFileService)
@@ -353,12 +604,13 @@ func main() {
 		nsenterService.Setup(processService, nil)
 
 		handlerService.Setup(
-			handler.DefaultHandlers,
+			handlers,
 			ctx.Bool("ignore-handler-errors"),
 			containerStateService,
 			nsenterService,
 			processService,
 			ioService,
+			emuAttrsConfig,
 		)
 
 		fuseServerService.Setup(
@@ -370,6 +622,7 @@ func main() {
 
 		containerStateService.Setup(
 			fuseServerService,
+			handlerService,
 			processService,
 			ioService,
 			mountService,
@@ -399,6 +652,14 @@ func main() {
 			ctx.GlobalString("mountpoint"),
 		)
 
+		// Remove the mountpoints left behind by a previous (crashed) instance.
+		stale, err := fuseServerService.CleanupStaleMountpoints()
+		if err != nil {
+			logrus.Warnf("Unable to cleanup stale mountpoints: %v", err)
+		} else if len(stale) > 0 {
+			logrus.Infof("Removed %d stale mountpoints: %v", len(stale), stale)
+		}
+
 		// If requested, launch cpu/mem profiling collection.
 		profile, err := runProfiler(ctx)
 		if err != nil {
@@ -417,6 +678,14 @@ func main() {
 			syscall.SIGQUIT)
 		go exitHandler(exitChan, fuseServerService, profile)
 
+		// Launch the handlers' reload goroutine (re-applies the handlers
+		// disabled through the emu-attrs-config file).
+		if path := ctx.GlobalString("emu-attrs-config"); path != "" {
+			var reloadChan = make(chan os.Signal, 1)
+			signal.Notify(reloadChan, syscall.SIGUSR1)
+			go reloadHandler(reloadChan, handlerService, path)
+		}
+
 		// TODO: Consider adding sync.Workgroups to ensure that all goroutines
 		// are done with their in-fly tasks before exit()ing.
 
@@ -0,0 +1,146 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+//
+// Per-handler read cache.
+//
+// Handlers serving expensive reads can be paired with a ReadCache (see
+// HandlerBase.Cache), in which case the content of their nodes is kept per sys
+// container during the cache's TTL. Entries are invalidated upon writes to the
+// matching node, as well as upon container updates (e.g. cgroup changes) and
+// unregistrations.
+//
+
+// ReadCacheHolderIface gives access to the read cache of the handlers embedding
+// HandlerBase.
+type ReadCacheHolderIface interface {
+	GetReadCache() *ReadCache
+	SetReadCache(c *ReadCache)
+}
+
+type readCacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+type ReadCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[string]map[string]readCacheEntry // indexed by container & path
+}
+
+// ReadCache constructor.
+func NewReadCache(ttl time.Duration) *ReadCache {
+	return &ReadCache{
+		ttl:     ttl,
+		entries: make(map[string]map[string]readCacheEntry),
+	}
+}
+
+func (rc *ReadCache) TTL() time.Duration {
+	return rc.ttl
+}
+
+// Get method returns the cached content of the given node for the given
+// container, if present and not expired.
+func (rc *ReadCache) Get(cntrId, path string) ([]byte, bool) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	entry, ok := rc.entries[cntrId][path]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(rc.entries[cntrId], path)
+		return nil, false
+	}
+
+	return entry.data, true
+}
+
+// Set method caches the content of the given node for the given container.
+func (rc *ReadCache) Set(cntrId, path string, data []byte) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	cntrEntries, ok := rc.entries[cntrId]
+	if !ok {
+		cntrEntries = make(map[string]readCacheEntry)
+		rc.entries[cntrId] = cntrEntries
+	}
+
+	cntrEntries[path] = readCacheEntry{
+		data:    append([]byte(nil), data...),
+		expires: time.Now().Add(rc.ttl),
+	}
+}
+
+// Invalidate method drops the cached content of the given node for the given
+// container.
+func (rc *ReadCache) Invalidate(cntrId, path string) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	delete(rc.entries[cntrId], path)
+}
+
+// InvalidateContainer method drops all the content cached for the given
+// container.
+func (rc *ReadCache) InvalidateContainer(cntrId string) {
+	rc.Lock()
+	defer rc.Unlock()
+
+	delete(rc.entries, cntrId)
+}
+
+//
+// Kernel-side attribute & entry caching.
+//
+// FUSE responses carry the time during which the kernel can cache the
+// attributes (attr_valid) and dentries (entry_valid) of the nodes being
+// served, sparing the Getattr / Lookup round-trips of hot nodes (e.g.
+// /proc/meminfo polled by monitoring agents). Handlers can be paired with
+// their own TTLs (see HandlerBase.FuseTTLs); the global ones apply otherwise.
+//
+// Nodes are served with direct-io, as their sizes aren't known upfront, which
+// prevents them from being mmap()ed. Handlers can opt into having the read-only
+// opens of their nodes served through the page-cache instead.
+//
+
+// FuseCacheHolderIface gives access to the kernel-side cache TTLs of the
+// handlers embedding HandlerBase.
+type FuseCacheHolderIface interface {
+	GetFuseCacheTTLs() *FuseCacheTTLs
+	SetFuseCacheTTLs(t *FuseCacheTTLs)
+}
+
+// FuseCacheTTLs holds the kernel-side cache settings of a handler's nodes. Nil
+// TTLs stand for the global ones. Mmap enables the page-cache for read-only
+// opens.
+type FuseCacheTTLs struct {
+	Attr  *time.Duration
+	Entry *time.Duration
+	Mmap  bool
+}
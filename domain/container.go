@@ -17,6 +17,7 @@
 package domain
 
 import (
+	"path/filepath"
 	"time"
 )
 
@@ -46,11 +47,15 @@ type ContainerIface interface {
 	IsImmutableMountpoint(mp string) bool
 	IsImmutableRoMountpoint(mp string) bool
 	IsImmutableOverlapMountpoint(mp string) bool
+	HandlerOverride(path string) (*HandlerOverride, bool)
+	KernelLog() *KernelLog
+	SwapTable() *SwapTable
 	//
 	// Setters
 	//
 	SetData(path string, name string, data string)
 	SetInitProc(pid, uid, gid uint32) error
+	SetHandlerOverrides(overrides []HandlerOverride)
 	//
 	// Locks for read-modify-write operations on container data via the Data()
 	// and SetData() methods.
@@ -59,6 +64,100 @@ type ContainerIface interface {
 	Unlock()
 }
 
+//
+// Per-container handler overrides.
+//
+// Sys containers can override the default behavior of the handlers through the
+// "containers" section of the emu-attrs-config file (see ContainerAttrRule),
+// which is applied at registration time. Its overrides are HandlerOverride
+// entries, each one keyed by the path of the handler being overridden. Example:
+//
+//   "containers": [
+//     { "id": "<container-id>",
+//       "overrides": [
+//         { "path": "/proc/cpuinfo", "passthrough": true },
+//         { "path": "/proc/sys/kernel", "values": { "pid_max": "32768" } } ] } ]
+//
+
+// DMI identifiers that can be set through ContainerAttrRule.DmiId.
+var DmiIdFields = map[string]struct{}{
+	"product_uuid": {},
+	"board_serial": {},
+	"sys_vendor":   {},
+}
+
+// HandlerOverride defines the per-container settings overriding the behavior of
+// the handler associated to Path: Passthrough serves its nodes straight from
+// the host, while Values exposes fixed values for some of them (indexed by their
+// path relative to the handler's one).
+type HandlerOverride struct {
+	Path        string            `json:"path"`
+	Passthrough bool              `json:"passthrough,omitempty"`
+	Values      map[string]string `json:"values,omitempty"`
+}
+
+// ContainerHandlerOverrides function returns the handler overrides defined by
+// the given rules for the sys container with the given ID.
+func ContainerHandlerOverrides(rules []ContainerAttrRule, id string) []HandlerOverride {
+
+	var overrides []HandlerOverride
+
+	for _, rule := range rules {
+		if ok, _ := filepath.Match(rule.Id, id); !ok {
+			continue
+		}
+
+		for _, ov := range rule.Overrides {
+			overrides = mergeHandlerOverride(overrides, ov)
+		}
+
+		if rule.KernelRelease != "" {
+			overrides = mergeHandlerOverride(overrides, HandlerOverride{
+				Path:   "/proc/sys/kernel",
+				Values: map[string]string{"osrelease": rule.KernelRelease},
+			})
+		}
+
+		if len(rule.DmiId) > 0 {
+			overrides = mergeHandlerOverride(overrides, HandlerOverride{
+				Path:   "/sys/devices/virtual/dmi/id",
+				Values: rule.DmiId,
+			})
+		}
+	}
+
+	return overrides
+}
+
+// Merges the given override into the given list, where it prevails over the
+// override of the same handler path (if any). Values are copied, so that the
+// given override is kept untouched.
+func mergeHandlerOverride(
+	overrides []HandlerOverride,
+	ov HandlerOverride) []HandlerOverride {
+
+	i := 0
+	for i < len(overrides) && overrides[i].Path != ov.Path {
+		i++
+	}
+	if i == len(overrides) {
+		overrides = append(overrides, HandlerOverride{Path: ov.Path})
+	}
+
+	if ov.Passthrough {
+		overrides[i].Passthrough = true
+	}
+
+	for name, value := range ov.Values {
+		if overrides[i].Values == nil {
+			overrides[i].Values = make(map[string]string)
+		}
+		overrides[i].Values[name] = value
+	}
+
+	return overrides
+}
+
 //
 // Auxiliary types to deal with the per-container-state associated to all the
 // emulated resources.
@@ -73,6 +172,7 @@ type StateData = map[string]string
 type ContainerStateServiceIface interface {
 	Setup(
 		fss FuseServerServiceIface,
+		hds HandlerServiceIface,
 		prs ProcessServiceIface,
 		ios IOServiceIface,
 		mts MountServiceIface)
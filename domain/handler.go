@@ -17,6 +17,7 @@
 package domain
 
 import (
+	"context"
 	"os"
 	"sync"
 )
@@ -50,6 +51,29 @@ type HandlerBase struct {
 
 	// Pointer to the parent handler service.
 	Service HandlerServiceIface
+
+	// Optional cache of the content served by this handler (see ReadCache).
+	Cache *ReadCache
+
+	// Optional kernel-side cache TTLs of this handler's nodes (see
+	// FuseCacheTTLs).
+	FuseTTLs *FuseCacheTTLs
+}
+
+func (h *HandlerBase) GetReadCache() *ReadCache {
+	return h.Cache
+}
+
+func (h *HandlerBase) SetReadCache(c *ReadCache) {
+	h.Cache = c
+}
+
+func (h *HandlerBase) GetFuseCacheTTLs() *FuseCacheTTLs {
+	return h.FuseTTLs
+}
+
+func (h *HandlerBase) SetFuseCacheTTLs(t *FuseCacheTTLs) {
+	h.FuseTTLs = t
 }
 
 type EmuResourceType int
@@ -60,6 +84,48 @@ const (
 	FileEmuResource
 )
 
+// EmuResourcePolicy describes how sysbox-fs processes the values written into
+// an emulated resource.
+type EmuResourcePolicy string
+
+const (
+	// Value is kept within the sys container state; the host is left untouched.
+	StateOnlyPolicy EmuResourcePolicy = "state-only"
+
+	// Value is written through to the kernel (e.g. within the requester's netns).
+	WriteThroughPolicy EmuResourcePolicy = "write-through"
+
+	// Value is kept within the sys container state, and the largest (smallest)
+	// one across all sys containers is pushed to the host kernel.
+	PooledMaxPolicy EmuResourcePolicy = "pooled-max"
+	PooledMinPolicy EmuResourcePolicy = "pooled-min"
+
+	// Value is kept within the sys container state, and can't exceed the host
+	// one.
+	HostClampedPolicy EmuResourcePolicy = "host-clamped"
+
+	// Writes are either ignored or rejected.
+	ReadOnlyPolicy EmuResourcePolicy = "read-only"
+)
+
+// EmuResourceFormat describes the content of an emulated resource.
+type EmuResourceFormat string
+
+const (
+	IntFormat    EmuResourceFormat = "int"
+	StringFormat EmuResourceFormat = "string"
+
+	// Multi-line / non-scalar content (e.g. /proc/uptime).
+	TextFormat EmuResourceFormat = "text"
+)
+
+// EmuResourceBounds defines the range of values accepted by an (integer)
+// emulated resource.
+type EmuResourceBounds struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
 // EmuResource represents the nodes being emulated by sysbox-fs.
 //
 // The "mutex" variable is utilized to synchronize access among concurrent i/o
@@ -68,24 +134,244 @@ const (
 // same sysbox-fs emulated resource). By relying on a per-resource "mutex", and
 // not a per-handler one, we are maximizing the level of concurrency that can be
 // attained.
+//
+// The "uid" and "gid" variables represent the ownership of the emulated node as
+// seen from within the sys container (i.e. zero stands for the root user of the
+// container's user-namespace).
+//
+// The "policy", "format" and "bounds" variables describe the emulation being
+// carried out, and are mainly utilized for documentation purposes (see
+// HandlerCatalogEntry). Resources listing a set of "policies" allow operators
+// to pick any of them in replacement of the default one.
 type EmuResource struct {
-	Kind    EmuResourceType
-	Mode    os.FileMode
-	Enabled bool
-	Mutex   sync.Mutex
+	Kind     EmuResourceType
+	Mode     os.FileMode
+	Uid      uint32
+	Gid      uint32
+	Policy   EmuResourcePolicy
+	Policies []EmuResourcePolicy
+	Format   EmuResourceFormat
+	Bounds   *EmuResourceBounds
+	Enabled  bool
+	Mutex    sync.Mutex
+}
+
+// HandlerCatalogEntry describes every resource emulated by sysbox-fs. The full
+// catalog serves as the source of truth for documentation, the list of paths
+// to be mounted over by sysbox-runc, and for any user tooling.
+type HandlerCatalogEntry struct {
+	Path     string              `json:"path"`
+	Handler  string              `json:"handler"`
+	Kind     string              `json:"kind"`
+	Mode     string              `json:"mode"`
+	Enabled  bool                `json:"enabled"`
+	Policy   EmuResourcePolicy   `json:"policy,omitempty"`
+	Policies []EmuResourcePolicy `json:"policies,omitempty"`
+	Format   EmuResourceFormat   `json:"format,omitempty"`
+	Bounds   *EmuResourceBounds  `json:"bounds,omitempty"`
+}
+
+// EmuResourceAttrRule defines the attributes to enforce over all the emulated
+// resources placed at (or under) a given path. Unset fields leave the handler's
+// default value untouched. Mode is expressed in octal notation (e.g. "0444").
+//
+// Policy can only be set for resources that support multiple emulation policies
+// (see EmuResource.Policies).
+type EmuResourceAttrRule struct {
+	Path   string            `json:"path"`
+	Mode   string            `json:"mode,omitempty"`
+	Uid    *uint32           `json:"uid,omitempty"`
+	Gid    *uint32           `json:"gid,omitempty"`
+	Policy EmuResourcePolicy `json:"policy,omitempty"`
 }
 
-// HandlerRequest represents a request to be processed by a handler
+// EmuResourceAttrConfig holds the deployment-wide overrides of the attributes
+// of sysbox-fs' emulated resources. When multiple rules match a resource, the
+// one with the longest path prevails. Resources placed at (or under) any of the
+// exception paths are left with their default attributes.
+//
+// Sysctls holds the policy table enforced over the /proc/sys nodes lacking a
+// dedicated emulation (see SysctlPolicyRule), and ReadCache the handlers whose
+// content is to be cached (see ReadCacheRule). FuseCache sets the kernel-side
+// cache TTLs of the handlers' nodes (see FuseCacheRule). Filesystems overrides
+// the list of file-system types displayed through /proc/filesystems, and
+// ModuleParams the module parameters exposed under /sys/module (see
+// ModuleParamRule). DisabledHandlers lists the handlers to be bypassed (see
+// DisabledHandlerRule); unlike the rest of the settings, it's re-applied
+// whenever sysbox-fs receives a SIGUSR1 signal. Containers holds the settings
+// of specific sys containers (see ContainerAttrRule).
+type EmuResourceAttrConfig struct {
+	Rules            []EmuResourceAttrRule `json:"rules"`
+	Exceptions       []string              `json:"exceptions"`
+	Sysctls          []SysctlPolicyRule    `json:"sysctls"`
+	ReadCache        []ReadCacheRule       `json:"readCache"`
+	FuseCache        []FuseCacheRule       `json:"fuseCache"`
+	Filesystems      []string              `json:"filesystems"`
+	ModuleParams     []ModuleParamRule     `json:"moduleParams"`
+	DisabledHandlers []DisabledHandlerRule `json:"disabledHandlers"`
+	Containers       []ContainerAttrRule   `json:"containers"`
+}
+
+// ContainerAttrRule holds the handler overrides (see HandlerOverride) of the
+// sys containers whose ID matches a given pattern (see filepath.Match), e.g.
+// "*" for all of them. Overrides are applied as containers register; when
+// multiple rules match a container, later ones prevail.
+//
+// KernelRelease presents the containers with a kernel release string other
+// than the host's one, through both /proc/sys/kernel/osrelease and
+// /proc/version (e.g. "5.4.0-generic"). It's a shorthand for an "osrelease"
+// override of the /proc/sys/kernel handler.
+//
+// DmiId presents the containers with their own DMI identifiers through
+// /sys/devices/virtual/dmi/id, indexed by any of DmiIdFields (e.g. so that the
+// nodes of nested Kubernetes clusters don't collide). It's a shorthand for the
+// matching overrides of the /sys/devices/virtual/dmi/id handler.
+type ContainerAttrRule struct {
+	Id            string            `json:"id"`
+	Overrides     []HandlerOverride `json:"overrides,omitempty"`
+	KernelRelease string            `json:"kernelRelease,omitempty"`
+	DmiId         map[string]string `json:"dmiId,omitempty"`
+}
+
+// DisabledHandlerRule disables the handler placed at a given path, either for
+// all sys containers or for the ones whose IDs are listed. Nodes of disabled
+// handlers are served by the passthrough handler.
+type DisabledHandlerRule struct {
+	Path       string   `json:"path"`
+	Containers []string `json:"containers,omitempty"`
+}
+
+// ReadCacheRule enables the read cache of the handlers placed at (or under) a
+// given path. TTL is expressed as a duration string (e.g. "500ms", "2s").
+type ReadCacheRule struct {
+	Path string `json:"path"`
+	TTL  string `json:"ttl"`
+}
+
+// FuseCacheRule sets the kernel-side attribute and entry cache TTLs of the
+// handlers placed at (or under) a given path. TTLs are expressed as duration
+// strings; omitted ones are left with their global value. Mmap serves the
+// read-only opens of the handlers' nodes through the page-cache, so that they
+// can be mmap()ed; content is then fixed for the duration of each open.
+type FuseCacheRule struct {
+	Path     string `json:"path"`
+	AttrTTL  string `json:"attrTtl,omitempty"`
+	EntryTTL string `json:"entryTtl,omitempty"`
+	Mmap     bool   `json:"mmap,omitempty"`
+}
+
+// SysctlPolicy describes how sysbox-fs handles the accesses to a non-emulated
+// /proc/sys node.
+type SysctlPolicy string
+
+const (
+	// Reads are carried out within the requester's namespaces; writes are
+	// ignored.
+	SysctlReadPassthrough SysctlPolicy = "read-passthrough"
+
+	// Writes are kept within the sys container state; the host is left
+	// untouched.
+	SysctlWriteVirtual SysctlPolicy = "write-virtual"
+
+	// Writes are rejected.
+	SysctlWriteDeny SysctlPolicy = "write-deny"
+
+	// Reads and writes are carried out within the requester's namespaces
+	// (default behavior).
+	SysctlNetnsExec SysctlPolicy = "netns-exec"
+)
+
+// SysctlPolicyRule associates a policy to the non-emulated /proc/sys nodes
+// matching a given path, either literally, as a parent directory, or as a
+// glob pattern (e.g. "/proc/sys/net/sctp/*"). When multiple rules match a
+// node, the one with the longest path prevails.
+type SysctlPolicyRule struct {
+	Path   string       `json:"path"`
+	Policy SysctlPolicy `json:"policy"`
+}
+
+// ModuleParamPolicy describes how sysbox-fs exposes a kernel module parameter
+// (i.e. /sys/module/<module>/parameters/<param>) to sys containers.
+type ModuleParamPolicy string
+
+const (
+	// Parameter is displayed as per the host; writes are rejected.
+	ModuleParamReadOnly ModuleParamPolicy = "read-only"
+
+	// Writes are kept within the sys container state; the host is left
+	// untouched.
+	ModuleParamWriteVirtual ModuleParamPolicy = "write-virtual"
+)
+
+// ModuleParamRule exposes the module parameters matching a given path, either
+// literally or as a glob pattern (e.g. "/sys/module/nf_conntrack/parameters/*").
+// When multiple rules match a parameter, the one with the longest path
+// prevails. Parameters not matching any rule are hidden.
+type ModuleParamRule struct {
+	Path   string            `json:"path"`
+	Policy ModuleParamPolicy `json:"policy"`
+}
+
+// DeclarativeNodeType describes the emulation carried out over a node declared
+// through the handlers config file.
+type DeclarativeNodeType string
+
+const (
+	// Node exposing a fixed value; writes are rejected.
+	FixedNode DeclarativeNodeType = "fixed"
+
+	// Node exposing a per-container (string) value.
+	StoredNode DeclarativeNodeType = "stored"
+
+	// Node exposing a per-container integer, clamped to the node's bounds.
+	ClampedNode DeclarativeNodeType = "clamped"
+)
+
+// DeclarativeNode defines a simple emulated node to be served by sysbox-fs
+// without a dedicated handler implementation. Value is the one initially
+// presented to sys containers (the host one if unset, except for fixed nodes).
+// Mode is expressed in octal notation (e.g. "0444").
+type DeclarativeNode struct {
+	Path   string              `json:"path"`
+	Type   DeclarativeNodeType `json:"type"`
+	Value  string              `json:"value,omitempty"`
+	Mode   string              `json:"mode,omitempty"`
+	Bounds *EmuResourceBounds  `json:"bounds,omitempty"`
+}
+
+// DeclarativeConfig holds the nodes declared through the handlers config file.
+type DeclarativeConfig struct {
+	Nodes []DeclarativeNode `json:"nodes"`
+}
+
+// HandlerRequest represents a request to be processed by a handler. Ctx is
+// the context of the originating FUSE request, which is cancelled upon request
+// interruption, and which handlers are expected to honor during long-running
+// operations (e.g. interactions with external processes). Handle identifies
+// the open() (i.e. file descriptor) on whose behalf the request is issued, if
+// any; it's shared by the open, read, write and close requests of each open.
 type HandlerRequest struct {
 	ID        uint64
+	Ctx       context.Context
 	Pid       uint32
 	Uid       uint32
 	Gid       uint32
 	Offset    int64
 	Data      []byte
+	Handle    uint64
 	Container ContainerIface
 }
 
+// Context method returns the request's context, or a non-cancellable one if
+// none was provided (e.g. internally-generated requests).
+func (r *HandlerRequest) Context() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+
+	return r.Ctx
+}
+
 // HandlerIface is the interface that each handler must implement
 type HandlerIface interface {
 	// FS operations.
@@ -103,9 +389,49 @@ type HandlerIface interface {
 	GetService() HandlerServiceIface
 	SetService(hs HandlerServiceIface)
 	GetResourcesList() []string
+	GetResourceMap() map[string]*EmuResource
 	GetResourceMutex(node IOnodeIface) *sync.Mutex
 }
 
+// DirHandlerIface is implemented by the handlers allowing directories to be
+// created and removed within their path (e.g. cgroup hierarchies).
+type DirHandlerIface interface {
+	Mkdir(node IOnodeIface, req *HandlerRequest) error
+	Rmdir(node IOnodeIface, req *HandlerRequest) error
+}
+
+// CloseHandlerIface is implemented by the handlers keeping per-open state of
+// their nodes (see HandlerRequest.Handle), which is released upon the last
+// close() of each open.
+type CloseHandlerIface interface {
+	Close(node IOnodeIface, req *HandlerRequest) error
+}
+
+// XattrHandlerIface is implemented by the handlers serving extended attributes
+// over their nodes. Nodes of the remaining handlers lack them altogether.
+type XattrHandlerIface interface {
+	Getxattr(node IOnodeIface, name string, req *HandlerRequest) ([]byte, error)
+	Listxattr(node IOnodeIface, req *HandlerRequest) ([]string, error)
+	Setxattr(node IOnodeIface, name string, value []byte, flags uint32, req *HandlerRequest) error
+	Removexattr(node IOnodeIface, name string, req *HandlerRequest) error
+}
+
+// IoctlHandlerIface is implemented by the handlers serving ioctl() requests
+// over their nodes (e.g. device-like nodes). Requests over the nodes of the
+// remaining handlers fail with ENOTTY, same as for regular files.
+type IoctlHandlerIface interface {
+	// Returns the data to be copied back to the requester (if any).
+	Ioctl(node IOnodeIface, cmd uint32, arg []byte, req *HandlerRequest) ([]byte, error)
+}
+
+// PollHandlerIface is implemented by the handlers whose nodes deliver events
+// (e.g. PSI triggers), so that these can be waited on through select / poll /
+// epoll. Nodes of the remaining handlers are reported as always ready.
+type PollHandlerIface interface {
+	// Returns the events (i.e. POLLIN, POLLOUT, etc) the node is ready for.
+	Poll(node IOnodeIface, req *HandlerRequest) (uint32, error)
+}
+
 type HandlerServiceIface interface {
 	Setup(
 		hdlrs []HandlerIface,
@@ -113,17 +439,23 @@ type HandlerServiceIface interface {
 		css ContainerStateServiceIface,
 		nss NSenterServiceIface,
 		prs ProcessServiceIface,
-		ios IOServiceIface)
+		ios IOServiceIface,
+		attrCfg *EmuResourceAttrConfig)
 
 	RegisterHandler(h HandlerIface) error
 	UnregisterHandler(h HandlerIface) error
 	LookupHandler(i IOnodeIface) (HandlerIface, bool)
+	LookupContainerHandler(i IOnodeIface, c ContainerIface) (HandlerIface, bool)
 	FindHandler(s string) (HandlerIface, bool)
-	EnableHandler(path string) error
-	DisableHandler(path string) error
+	EnableHandler(path string, cntrId string) error
+	DisableHandler(path string, cntrId string) error
+	SetDisabledHandlers(rules []DisabledHandlerRule) error
+	ReconcileEmuResources(c ContainerIface)
+	InvalidateReadCaches(c ContainerIface)
 
 	// getters/setters
 	HandlersResourcesList() []string
+	HandlersCatalog() []HandlerCatalogEntry
 	GetPassThroughHandler() HandlerIface
 	StateService() ContainerStateServiceIface
 	SetStateService(css ContainerStateServiceIface)
@@ -0,0 +1,94 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package domain
+
+import (
+	"fmt"
+	"sync"
+)
+
+//
+// Per-container kernel log.
+//
+// Sys containers are not given access to the host's kernel log; instead, each
+// one is paired with a KernelLog ring buffer, which is served through the
+// /proc/kmsg emulation and can be fed by sysbox-fs with the events relevant to
+// the container (e.g. its registration).
+//
+
+// Number of records retained by each container's kernel log.
+const KernelLogSize = 512
+
+// Syslog levels of the kernel log records.
+const (
+	KernelLogErr    = 3
+	KernelLogNotice = 5
+	KernelLogInfo   = 6
+)
+
+type KernelLog struct {
+	sync.Mutex
+	size    int
+	records []string // retained records, oldest first
+	first   uint64   // sequence number of records[0]
+	next    uint64   // sequence number of the next record to consume
+}
+
+// KernelLog constructor.
+func NewKernelLog(size int) *KernelLog {
+	return &KernelLog{
+		size: size,
+	}
+}
+
+// Append method adds a record to the log, dropping the oldest one if full.
+func (kl *KernelLog) Append(level int, msg string) {
+	kl.Lock()
+	defer kl.Unlock()
+
+	kl.records = append(kl.records, fmt.Sprintf("<%d>%s\n", level, msg))
+
+	if len(kl.records) > kl.size {
+		kl.records = kl.records[1:]
+		kl.first++
+	}
+}
+
+// Consume method returns the records not consumed yet, up to 'max' bytes (at
+// least one record is returned if any is pending), as /proc/kmsg readers do.
+func (kl *KernelLog) Consume(max int) []byte {
+	kl.Lock()
+	defer kl.Unlock()
+
+	// Records dropped before being consumed are lost.
+	if kl.next < kl.first {
+		kl.next = kl.first
+	}
+
+	var data []byte
+
+	for kl.next < kl.first+uint64(len(kl.records)) {
+		record := kl.records[kl.next-kl.first]
+		if len(data) > 0 && len(data)+len(record) > max {
+			break
+		}
+		data = append(data, record...)
+		kl.next++
+	}
+
+	return data
+}
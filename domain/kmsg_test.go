@@ -0,0 +1,80 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKernelLog(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		size    int
+		records []string
+		max     int      // size of each read
+		want    []string // content of each read
+	}{
+		// Records are consumed as read.
+		{"1", 4, []string{"a", "b"}, 64, []string{"<6>a\n<6>b\n", ""}},
+
+		// Reads are bounded by the given size...
+		{"2", 4, []string{"a", "b", "c"}, 8, []string{"<6>a\n", "<6>b\n", "<6>c\n", ""}},
+
+		// ... though at least one record is returned.
+		{"3", 4, []string{"abcdefgh"}, 4, []string{"<6>abcdefgh\n", ""}},
+
+		// Oldest records are dropped once the log is full.
+		{"4", 2, []string{"a", "b", "c"}, 64, []string{"<6>b\n<6>c\n", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kl := NewKernelLog(tt.size)
+			for _, r := range tt.records {
+				kl.Append(KernelLogInfo, r)
+			}
+
+			var got []string
+			for range tt.want {
+				got = append(got, string(kl.Consume(tt.max)))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KernelLog.Consume() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKernelLog_dropsUnconsumed(t *testing.T) {
+
+	kl := NewKernelLog(2)
+
+	kl.Append(KernelLogInfo, "a")
+	kl.Consume(64)
+
+	// Records dropped before being consumed are lost.
+	kl.Append(KernelLogInfo, "b")
+	kl.Append(KernelLogErr, "c")
+	kl.Append(KernelLogNotice, "d")
+
+	if got, want := string(kl.Consume(64)), "<3>c\n<5>d\n"; got != want {
+		t.Errorf("KernelLog.Consume() = %q, want %q", got, want)
+	}
+}
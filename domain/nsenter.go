@@ -16,6 +16,11 @@
 
 package domain
 
+import (
+	"context"
+	"syscall"
+)
+
 // Aliases to leverage strong-typing.
 type NStype = string
 type NSenterMsgType = string
@@ -59,6 +64,10 @@ var AllNSsButUser = []NStype{
 	string(NStypeUts),
 }
 
+var NetNSOnly = []NStype{
+	string(NStypeNet),
+}
+
 //
 // NSenterEvent types. Define all possible messages that can be handled
 // by nsenterEvent class.
@@ -70,6 +79,8 @@ const (
 	OpenFileResponse      NSenterMsgType = "openFileResponse"
 	ReadFileRequest       NSenterMsgType = "readFileRequest"
 	ReadFileResponse      NSenterMsgType = "readFileResponse"
+	ReadFilesRequest      NSenterMsgType = "readFilesRequest"
+	ReadFilesResponse     NSenterMsgType = "readFilesResponse"
 	WriteFileRequest      NSenterMsgType = "writeFileRequest"
 	WriteFileResponse     NSenterMsgType = "writeFileResponse"
 	ReadDirRequest        NSenterMsgType = "readDirRequest"
@@ -88,6 +99,13 @@ const (
 	MountInodeResponse    NSenterMsgType = "mountInodeResponse"
 	SleepRequest          NSenterMsgType = "sleepRequest"
 	SleepResponse         NSenterMsgType = "sleepResponse"
+	SignalProcsRequest    NSenterMsgType = "signalProcsRequest"
+	SignalProcsResponse   NSenterMsgType = "signalProcsResponse"
+	NetSysfsRequest       NSenterMsgType = "netSysfsRequest"
+	MkdirRequest          NSenterMsgType = "mkdirRequest"
+	MkdirResponse         NSenterMsgType = "mkdirResponse"
+	RmdirRequest          NSenterMsgType = "rmdirRequest"
+	RmdirResponse         NSenterMsgType = "rmdirResponse"
 	ErrorResponse         NSenterMsgType = "errorResponse"
 )
 
@@ -140,6 +158,16 @@ type NSenterMessage struct {
 	// Message type being exchanged.
 	Type NSenterMsgType `json:"message"`
 
+	// ID of the sysbox-fs request (if any) on whose behalf this message is
+	// exchanged. Allows requests to be traced across sysbox-fs' main instance
+	// and its nsenter helpers.
+	ReqID uint64 `json:"reqId,omitempty"`
+
+	// Context of the sysbox-fs request (if any) on whose behalf this message
+	// is exchanged. Nsenter processes serving requests that get interrupted
+	// (e.g. FUSE_INTERRUPT) are killed. Not transferred to nsenter processes.
+	Ctx context.Context `json:"-"`
+
 	// Message payload.
 	Payload interface{} `json:"payload"`
 }
@@ -168,6 +196,19 @@ type ReadFilePayload struct {
 	Content string `json:"content"`
 }
 
+// Files to read within a single namespace entry (e.g. all the attributes of a
+// network interface). Responses carry the content of each file, or the errno
+// obtained while reading it, so that a failure doesn't spoil the whole batch.
+type ReadFilesPayload struct {
+	Files []string `json:"files"`
+}
+
+type ReadFilesResult struct {
+	File    string        `json:"file"`
+	Content string        `json:"content"`
+	Errno   syscall.Errno `json:"errno"`
+}
+
 type WriteFilePayload struct {
 	File    string `json:"file"`
 	Content string `json:"content"`
@@ -177,6 +218,15 @@ type ReadDirPayload struct {
 	Dir string `json:"dir"`
 }
 
+type MkdirPayload struct {
+	Dir  string `json:"dir"`
+	Mode string `json:"mode"`
+}
+
+type RmdirPayload struct {
+	Dir string `json:"dir"`
+}
+
 type MountSyscallPayload struct {
 	Header NSenterMsgHeader
 	Mount
@@ -207,3 +257,17 @@ type MountInodeRespPayload struct {
 type SleepReqPayload struct {
 	Ival string `json:"attr"`
 }
+
+// Signal to deliver to all the processes of the pid-ns (but its init).
+type SignalProcsReqPayload struct {
+	Signal int `json:"signal"`
+}
+
+// Lookup, read-file or read-dir operation (as indicated by 'Op') to perform
+// within a sysfs instance mounted in the network namespace of the nsenter
+// process, so that its network devices are the ones displayed. Responses are
+// those of the matching regular requests (e.g. ReadFileResponse).
+type NetSysfsReqPayload struct {
+	Op   NSenterMsgType `json:"op"`
+	Path string         `json:"path"`
+}
@@ -0,0 +1,106 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package domain
+
+//
+// Handler plugins API.
+//
+// Third parties can extend the set of emulated nodes through two kinds of
+// plugins, both registered with the HandlerService at startup time:
+//
+// * Go plugins: shared objects built with "go build -buildmode=plugin", which
+// must export a HandlerPluginSymbol function of type HandlerPluginFunc. The
+// handlers returned by this one are registered as any other handler.
+//
+// * Process plugins: external processes serving the nodes placed under one or
+// more paths. These ones are reached through a unix socket, over which
+// sysbox-fs issues json-rpc calls to the HandlerPluginService methods (i.e.,
+// "HandlerPlugin.Lookup", "HandlerPlugin.Open", etc), each one taking a
+// HandlerPluginRequest and returning a HandlerPluginResponse.
+//
+
+// Version of the plugins API. Plugins are expected to report it through the
+// "HandlerPlugin.Version" call (process plugins) or HandlerPluginVersionSymbol
+// (Go plugins), and are refused if not matching.
+const HandlerPluginAPIVersion = 1
+
+const (
+	HandlerPluginSymbol        = "Handlers"
+	HandlerPluginVersionSymbol = "APIVersion"
+	HandlerPluginServiceName   = "HandlerPlugin"
+)
+
+// HandlerPluginFunc is the signature of the function exported by Go plugins.
+type HandlerPluginFunc func() []HandlerIface
+
+type HandlerPluginKind string
+
+const (
+	GoHandlerPlugin      HandlerPluginKind = "go"
+	ProcessHandlerPlugin HandlerPluginKind = "process"
+)
+
+// HandlerPluginSpec describes a plugin to be loaded at startup time. Path
+// refers to the shared object of Go plugins, or to the unix socket of process
+// plugins. Nodes holds the paths served by process plugins.
+type HandlerPluginSpec struct {
+	Name  string            `json:"name"`
+	Kind  HandlerPluginKind `json:"kind"`
+	Path  string            `json:"path"`
+	Nodes []string          `json:"nodes,omitempty"`
+}
+
+// HandlerPluginConfig holds the plugins declared through the plugins config
+// file.
+type HandlerPluginConfig struct {
+	Plugins []HandlerPluginSpec `json:"plugins"`
+}
+
+// HandlerPluginRequest represents a request forwarded to a process plugin.
+type HandlerPluginRequest struct {
+	Path      string `json:"path"`
+	Name      string `json:"name"`
+	Flags     int    `json:"flags,omitempty"`
+	Pid       uint32 `json:"pid"`
+	Uid       uint32 `json:"uid"`
+	Gid       uint32 `json:"gid"`
+	Container string `json:"container"`
+	Offset    int64  `json:"offset,omitempty"`
+	Size      int    `json:"size,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+}
+
+// HandlerPluginFileInfo represents the attributes of a node served by a
+// process plugin.
+type HandlerPluginFileInfo struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Mode  uint32 `json:"mode"`
+	IsDir bool   `json:"isDir"`
+}
+
+// HandlerPluginResponse represents the response of a process plugin. A
+// non-zero Errno is returned to the sys container as the outcome of the
+// operation.
+type HandlerPluginResponse struct {
+	Errno   int                     `json:"errno,omitempty"`
+	Size    int                     `json:"size,omitempty"`
+	Data    []byte                  `json:"data,omitempty"`
+	Info    *HandlerPluginFileInfo  `json:"info,omitempty"`
+	Entries []HandlerPluginFileInfo `json:"entries,omitempty"`
+	Version int                     `json:"version,omitempty"`
+}
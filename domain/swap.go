@@ -0,0 +1,121 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package domain
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+)
+
+//
+// Per-container swap table.
+//
+// Sys containers can't enable or disable swapping on the host; instead, the
+// swapon / swapoff syscalls are emulated over a per-container SwapTable, which
+// is served through the /proc/swaps emulation. This keeps init systems calling
+// "swapoff -a" during boot (as well as the swap-management tools) happy,
+// without any effect on the host.
+//
+
+// Swap flags of the swapon(2) syscall.
+const (
+	SwapFlagPrefer    = 0x8000 // set if swap priority specified
+	SwapFlagPrioMask  = 0x7fff
+	SwapFlagPrioShift = 0
+)
+
+type SwapEntry struct {
+	Filename string
+	Type     string // "partition" or "file"
+	Size     uint64 // in KiB
+	Used     uint64 // in KiB
+	Priority int
+}
+
+type SwapTable struct {
+	sync.Mutex
+	entries  []SwapEntry
+	lastPrio int // priority of the last entry without an explicit one
+}
+
+// SwapTable constructor.
+func NewSwapTable() *SwapTable {
+	return &SwapTable{}
+}
+
+// Swapon method adds the given area to the table. As the kernel does, areas
+// without an explicit priority get decreasing negative ones.
+func (st *SwapTable) Swapon(filename, kind string, size uint64, flags int) error {
+	st.Lock()
+	defer st.Unlock()
+
+	for _, e := range st.entries {
+		if e.Filename == filename {
+			return syscall.EBUSY
+		}
+	}
+
+	var prio int
+
+	if flags&SwapFlagPrefer == SwapFlagPrefer {
+		prio = (flags & SwapFlagPrioMask) >> SwapFlagPrioShift
+	} else {
+		st.lastPrio--
+		prio = st.lastPrio
+	}
+
+	st.entries = append(st.entries, SwapEntry{
+		Filename: filename,
+		Type:     kind,
+		Size:     size,
+		Priority: prio,
+	})
+
+	return nil
+}
+
+// Swapoff method removes the given area from the table.
+func (st *SwapTable) Swapoff(filename string) error {
+	st.Lock()
+	defer st.Unlock()
+
+	for i, e := range st.entries {
+		if e.Filename == filename {
+			st.entries = append(st.entries[:i], st.entries[i+1:]...)
+			return nil
+		}
+	}
+
+	return syscall.EINVAL
+}
+
+// Format method returns the table in /proc/swaps format.
+func (st *SwapTable) Format() string {
+	st.Lock()
+	defer st.Unlock()
+
+	result := fmt.Sprintf("%-39s %-15s %-7s %-7s %s\n",
+		"Filename", "Type", "Size", "Used", "Priority")
+
+	for _, e := range st.entries {
+		result += fmt.Sprintf("%-39s %-15s %-7d %-7d %d\n",
+			e.Filename, e.Type, e.Size, e.Used, e.Priority)
+	}
+
+	return result
+}
@@ -22,7 +22,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 
 	"github.com/nestybox/sysbox-fs/domain"
 
@@ -37,6 +37,12 @@ import (
 // infinite ideally; we set it to the max allowed value
 var DentryCacheTimeout int64 = 0x7fffffffffffffff
 
+// Default attribute-cache-timeout interval: Amount of time that VFS will hold
+// on to the attributes of the nodes before forwarding getattr() operations to
+// FUSE server. Defaults to zero (no caching) as emulated nodes' attributes
+// may change at any time.
+var AttrCacheTimeout int64 = 0
+
 //
 // Dir struct serves as a FUSE-friendly abstraction to represent directories
 // present in the host FS.
@@ -76,7 +82,10 @@ func (d *Dir) Lookup(
 	req *fuse.LookupRequest,
 	resp *fuse.LookupResponse) (fs.Node, error) {
 
-	logrus.Debugf("Requested Lookup() operation for entry %v (req ID=%#x)", req.Name, uint64(req.ID))
+	reqID := newRequestID()
+
+	logrus.Debugf("Requested Lookup() operation for entry %v (req ID=%#x, fuse ID=%#x)",
+		req.Name, reqID, uint64(req.ID))
 
 	path := filepath.Join(d.path, req.Name)
 
@@ -102,13 +111,14 @@ func (d *Dir) Lookup(
 	if ok {
 		d.server.RUnlock()
 
-		// Overwrite uid & gid values with those of the root in the userns.
+		// Overwrite uid & gid values with those of the root in the userns (or
+		// the ones defined for the emulated node, if any).
 		if file, ok := (*node).(*File); ok {
-			file.attr.Uid = rootUid
-			file.attr.Gid = rootGid
+			file.attr.Uid = rootUid + file.cntrUid
+			file.attr.Gid = rootGid + file.cntrGid
 		} else if dir, ok := (*node).(*Dir); ok {
-			dir.attr.Uid = rootUid
-			dir.attr.Gid = rootGid
+			dir.attr.Uid = rootUid + dir.cntrUid
+			dir.attr.Gid = rootGid + dir.cntrGid
 		}
 
 		return *node, nil
@@ -128,20 +138,27 @@ func (d *Dir) Lookup(
 	ionode := d.server.service.ios.NewIOnode(req.Name, path, 0)
 
 	// Lookup the associated handler within handler-DB.
-	handler, ok := d.server.service.hds.LookupHandler(ionode)
+	handler, ok := d.server.service.hds.LookupContainerHandler(ionode, d.server.container)
 	if !ok {
 		logrus.Errorf("No supported handler for %v resource", d.path)
 		return nil, fmt.Errorf("No supported handler for %v resource", d.path)
 	}
 
 	request := &domain.HandlerRequest{
-		ID:        uint64(req.ID),
+		ID:        reqID,
+		Ctx:       ctx,
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
 		Container: d.server.container,
 	}
 
+	// Wait for a handler-execution slot (see MaxWorkers).
+	if !d.server.acquireWorker(ctx) {
+		return nil, fuse.EINTR
+	}
+	defer d.server.releaseWorker()
+
 	// Handler execution.
 	info, err := handler.Lookup(ionode, request)
 	if err != nil {
@@ -151,19 +168,28 @@ func (d *Dir) Lookup(
 	// Convert os.FileInfo attributes to fuseAttr format.
 	fuseAttrs := convertFileInfoToFuse(info)
 
+	attrTTL, entryTTL := handlerCacheTTLs(handler)
+	fuseAttrs.Valid = attrTTL
+
 	// Override the uid & gid attributes with the root uid & gid in the
-	// requester's user-ns.
-	fuseAttrs.Uid = rootUid
-	fuseAttrs.Gid = rootGid
+	// requester's user-ns. Emulated nodes can be owned by a different user
+	// within the sys container if so configured by the operator.
+	cntrUid, cntrGid := emuResourceOwner(handler, path)
+	fuseAttrs.Uid = rootUid + cntrUid
+	fuseAttrs.Gid = rootGid + cntrGid
 
 	var newNode fs.Node
 
 	// Create a new file/dir entry associated to the received os.FileInfo.
 	if info.IsDir() {
 		fuseAttrs.Mode |= os.ModeDir
-		newNode = NewDir(req.Name, path, &fuseAttrs, d.File.server)
+		dir := NewDir(req.Name, path, &fuseAttrs, d.File.server)
+		dir.cntrUid, dir.cntrGid = cntrUid, cntrGid
+		newNode = dir
 	} else {
-		newNode = NewFile(req.Name, path, &fuseAttrs, d.File.server)
+		file := NewFile(req.Name, path, &fuseAttrs, d.File.server)
+		file.cntrUid, file.cntrGid = cntrUid, cntrGid
+		newNode = file
 	}
 
 	// Insert new fs node into nodeDB.
@@ -172,7 +198,7 @@ func (d *Dir) Lookup(
 	d.server.Unlock()
 
 	// Adjust response to carry the proper dentry-cache-timeout value.
-	resp.EntryValid = time.Duration(DentryCacheTimeout)
+	resp.EntryValid = entryTTL
 
 	return newNode, nil
 }
@@ -209,7 +235,10 @@ func (d *Dir) Create(
 	req *fuse.CreateRequest,
 	resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
 
-	logrus.Debugf("Requested Create() operation for entry %v (req ID=%#x)", req.Name, uint64(req.ID))
+	reqID := newRequestID()
+
+	logrus.Debugf("Requested Create() operation for entry %v (req ID=%#x, fuse ID=%#x)",
+		req.Name, reqID, uint64(req.ID))
 
 	// Ensure operation is generated from within a registered sys container.
 	if d.server.container == nil {
@@ -227,25 +256,33 @@ func (d *Dir) Create(
 	ionode.SetOpenMode(req.Mode)
 
 	// Lookup the associated handler within handler-DB.
-	handler, ok := d.server.service.hds.LookupHandler(ionode)
+	handler, ok := d.server.service.hds.LookupContainerHandler(ionode, d.server.container)
 	if !ok {
 		logrus.Errorf("No supported handler for %v resource", path)
 		return nil, nil, fmt.Errorf("No supported handler for %v resource", path)
 	}
 
 	request := &domain.HandlerRequest{
-		ID:        uint64(req.ID),
+		ID:        reqID,
+		Ctx:       ctx,
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
+		Handle:    newHandleID(),
 		Container: d.server.container,
 	}
 
+	// Wait for a handler-execution slot (see MaxWorkers).
+	if !d.server.acquireWorker(ctx) {
+		return nil, nil, fuse.EINTR
+	}
+	defer d.server.releaseWorker()
+
 	// Handler execution. 'Open' handler will create new element if requesting
 	// process has the proper credentials / capabilities.
 	err := handler.Open(ionode, request)
 	if err != nil && err != io.EOF {
-		logrus.Debugf("Open() error: %v", err)
+		logrus.Debugf("Open() error for req-id %#x: %v", reqID, err)
 		return nil, nil, err
 	}
 	resp.Flags |= fuse.OpenDirectIO
@@ -260,18 +297,28 @@ func (d *Dir) Create(
 	// Extract received file attributes.
 	fuseAttrs := convertFileInfoToFuse(info)
 
+	attrTTL, entryTTL := handlerCacheTTLs(handler)
+	fuseAttrs.Valid = attrTTL
+
 	// Adjust response to carry the proper dentry-cache-timeout value.
-	resp.EntryValid = time.Duration(DentryCacheTimeout)
+	resp.EntryValid = entryTTL
 
-	var newNode fs.Node
-	newNode = NewFile(req.Name, path, &fuseAttrs, d.File.server)
+	file := NewFile(req.Name, path, &fuseAttrs, d.File.server)
+
+	var newNode fs.Node = file
 
 	// Insert new fs node into nodeDB.
 	d.server.Lock()
 	d.server.nodeDB[path] = &newNode
 	d.server.Unlock()
 
-	return newNode, newNode, nil
+	fh := &fileHandle{
+		File:  file,
+		id:    request.Handle,
+		flags: int(req.Flags),
+	}
+
+	return newNode, fh, nil
 }
 
 //
@@ -281,7 +328,10 @@ func (d *Dir) ReadDirAll(ctx context.Context, req *fuse.ReadRequest) ([]fuse.Dir
 
 	var children []fuse.Dirent
 
-	logrus.Debugf("Requested ReadDirAll() on directory %v (req ID=%#v)", d.path, uint64(req.ID))
+	reqID := newRequestID()
+
+	logrus.Debugf("Requested ReadDirAll() on directory %v (req ID=%#x, fuse ID=%#x)",
+		d.path, reqID, uint64(req.ID))
 
 	// Ensure operation is generated from within a registered sys container.
 	if d.server.container == nil {
@@ -296,24 +346,31 @@ func (d *Dir) ReadDirAll(ctx context.Context, req *fuse.ReadRequest) ([]fuse.Dir
 	ionode.SetOpenFlags(int(req.Flags))
 
 	// Lookup the associated handler within handler-DB.
-	handler, ok := d.server.service.hds.LookupHandler(ionode)
+	handler, ok := d.server.service.hds.LookupContainerHandler(ionode, d.server.container)
 	if !ok {
 		logrus.Errorf("No supported handler for %v resource", d.path)
 		return nil, fmt.Errorf("No supported handler for %v resource", d.path)
 	}
 
 	request := &domain.HandlerRequest{
-		ID:        uint64(req.ID),
+		ID:        reqID,
+		Ctx:       ctx,
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
 		Container: d.server.container,
 	}
 
+	// Wait for a handler-execution slot (see MaxWorkers).
+	if !d.server.acquireWorker(ctx) {
+		return nil, fuse.EINTR
+	}
+	defer d.server.releaseWorker()
+
 	// Handler execution.
 	files, err := handler.ReadDirAll(ionode, request)
 	if err != nil {
-		logrus.Errorf("ReadDirAll() error: %v", err)
+		logrus.Errorf("ReadDirAll() error for req-id %#x: %v", reqID, err)
 		return nil, fuse.ENOENT
 	}
 
@@ -359,11 +416,111 @@ func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error
 	}
 
 	path := filepath.Join(d.path, req.Name)
+
+	// Handlers supporting directory creation (e.g. cgroup hierarchies) get to
+	// create it; the new node is then looked up as any other one.
+	if dh, handler, ok := d.dirHandler(req.Name, path, uint64(req.ID)); ok {
+		request := &domain.HandlerRequest{
+			ID:        newRequestID(),
+			Ctx:       ctx,
+			Pid:       req.Pid,
+			Uid:       req.Uid,
+			Gid:       req.Gid,
+			Container: d.server.container,
+		}
+
+		ionode := d.server.service.ios.NewIOnode(req.Name, path, req.Mode)
+
+		if err := dh.Mkdir(ionode, request); err != nil {
+			logrus.Debugf("Mkdir() error for req-id %#x (handler %s): %v",
+				request.ID, handler.GetName(), err)
+			return nil, err
+		}
+
+		return d.Lookup(ctx, &fuse.LookupRequest{Header: req.Header, Name: req.Name},
+			&fuse.LookupResponse{})
+	}
+
 	newDir := NewDir(req.Name, path, &fuse.Attr{}, d.File.server)
 
 	return newDir, nil
 }
 
+//
+// Remove FS operation. Only directories served by handlers supporting their
+// removal can be removed.
+//
+func (d *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+
+	logrus.Debugf("Requested Remove() on entry %v (Req ID=%#v)", req.Name, uint64(req.ID))
+
+	// Ensure operation is generated from within a registered sys container.
+	if d.server.container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return fmt.Errorf("Could not find container originating this request (pid %v)",
+			req.Pid)
+	}
+
+	path := filepath.Join(d.path, req.Name)
+
+	dh, handler, ok := d.dirHandler(req.Name, path, uint64(req.ID))
+	if !ok || !req.Dir {
+		return fuse.EPERM
+	}
+
+	request := &domain.HandlerRequest{
+		ID:        newRequestID(),
+		Ctx:       ctx,
+		Pid:       req.Pid,
+		Uid:       req.Uid,
+		Gid:       req.Gid,
+		Container: d.server.container,
+	}
+
+	ionode := d.server.service.ios.NewIOnode(req.Name, path, 0)
+
+	if err := dh.Rmdir(ionode, request); err != nil {
+		logrus.Debugf("Remove() error for req-id %#x (handler %s): %v",
+			request.ID, handler.GetName(), err)
+		return err
+	}
+
+	// Drop the nodes cached for the removed directory and its content.
+	d.server.Lock()
+	for p := range d.server.nodeDB {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(d.server.nodeDB, p)
+		}
+	}
+	d.server.Unlock()
+
+	return nil
+}
+
+// Returns the handler serving the given directory entry, as long as it
+// supports the creation and removal of directories.
+func (d *Dir) dirHandler(
+	name string,
+	path string,
+	fuseID uint64) (domain.DirHandlerIface, domain.HandlerIface, bool) {
+
+	ionode := d.server.service.ios.NewIOnode(name, path, 0)
+
+	handler, ok := d.server.service.hds.LookupContainerHandler(ionode, d.server.container)
+	if !ok {
+		logrus.Debugf("No supported handler for %v resource (fuse ID=%#x)", path, fuseID)
+		return nil, nil, false
+	}
+
+	dh, ok := handler.(domain.DirHandlerIface)
+	if !ok {
+		return nil, nil, false
+	}
+
+	return dh, handler, true
+}
+
 //
 // Forget FS operation.
 //
@@ -21,6 +21,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -32,6 +35,11 @@ import (
 )
 
 type File struct {
+	// Size of the latest content snapshot (see fileSnapshot), which prevails
+	// over the one in attr. Accessed atomically, as Open() and Attr() requests
+	// are served concurrently.
+	snapshotSize uint64
+
 	// File name.
 	name string
 
@@ -41,6 +49,11 @@ type File struct {
 	// File attributes.
 	attr *fuse.Attr
 
+	// Ownership of emulated nodes, as seen within the sys container's user-ns
+	// (zero for all the non-emulated ones).
+	cntrUid uint32
+	cntrGid uint32
+
 	// Pointer to parent fuseService hosting this file/dir.
 	server *fuseServer
 }
@@ -60,6 +73,41 @@ func NewFile(name string, path string, attr *fuse.Attr, srv *fuseServer) *File {
 	return newFile
 }
 
+// Sysbox-fs-wide file-handle counter (see HandlerRequest.Handle).
+var handleCounter uint64
+
+func newHandleID() uint64 {
+	return atomic.AddUint64(&handleCounter, 1)
+}
+
+//
+// Per-open state of the files served by sysbox-fs (i.e. FUSE file-handle).
+// Every open() of a file obtains its own handle, so that the subsequent
+// read / write / release requests are served on behalf of that open.
+//
+type fileHandle struct {
+	*File
+
+	// Handle ID, unique across all the fuse-servers.
+	id uint64
+
+	// Flags of the open() request.
+	flags int
+
+	// Chunks of the write in progress and its offset (see Write()).
+	mu   sync.Mutex
+	wbuf []byte
+	woff int64
+}
+
+// Size of the write chunks issued by the kernel (i.e. the max_write value
+// negotiated by the fuse library), and the maximum size of the writes being
+// accumulated out of them.
+const (
+	maxWriteChunk      = 128 << 10
+	maxWriteBufferSize = 4 << 20
+)
+
 //
 // Attr FS operation.
 //
@@ -70,6 +118,9 @@ func (f *File) Attr(ctx context.Context, a *fuse.Attr) error {
 	// Simply return the attributes that were previously collected during the
 	// lookup() execution.
 	*a = *f.attr
+	if size := atomic.LoadUint64(&f.snapshotSize); size != 0 {
+		a.Size = size
+	}
 
 	// Override the uid & gid attributes with the user-ns' root uid & gid of the
 	// sys container under which the request is received. In the future we should
@@ -95,8 +146,10 @@ func (f *File) Open(
 	req *fuse.OpenRequest,
 	resp *fuse.OpenResponse) (fs.Handle, error) {
 
-	logrus.Debugf("Requested Open() operation for entry %v (Req ID=%#v)",
-		f.path, uint64(req.ID))
+	reqID := newRequestID()
+
+	logrus.Debugf("Requested Open() operation for entry %v (req ID=%#x, fuse ID=%#x)",
+		f.path, reqID, uint64(req.ID))
 
 	// Ensure operation is generated from within a registered sys container.
 	if f.server.container == nil {
@@ -110,27 +163,56 @@ func (f *File) Open(
 	ionode.SetOpenFlags(int(req.Flags))
 
 	// Lookup the associated handler within handler-DB.
-	handler, ok := f.server.service.hds.LookupHandler(ionode)
+	handler, ok := f.server.service.hds.LookupContainerHandler(ionode, f.server.container)
 	if !ok {
 		logrus.Errorf("No supported handler for %v resource", f.path)
 		return nil, fmt.Errorf("No supported handler for %v resource", f.path)
 	}
 
+	fh := &fileHandle{
+		File:  f,
+		id:    newHandleID(),
+		flags: int(req.Flags),
+	}
+
 	request := &domain.HandlerRequest{
-		ID:        uint64(req.ID),
+		ID:        reqID,
+		Ctx:       ctx,
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
+		Handle:    fh.id,
 		Container: f.server.container,
 	}
 
+	// Wait for a handler-execution slot (see MaxWorkers).
+	if !f.server.acquireWorker(ctx) {
+		return nil, fuse.EINTR
+	}
+	defer f.server.releaseWorker()
+
 	// Handler execution.
 	err := handler.Open(ionode, request)
 	if err != nil && err != io.EOF {
-		logrus.Debugf("Open() error: %v", err)
+		logrus.Debugf("Open() error for req-id %#x: %v", reqID, err)
 		return nil, err
 	}
 
+	// Read-only opens of the nodes with mmap support enabled (see
+	// domain.FuseCacheTTLs) are served through the kernel's page-cache, which
+	// is a requirement for the mmap() of these nodes to succeed. As the
+	// kernel relies on the file-size to do so, content is obtained at this
+	// point and served from this snapshot for the duration of the open. The
+	// size of any previous snapshot is dropped otherwise, so that it's not
+	// reported for content that may have changed since then.
+	if handlerMmap(handler) && req.Flags.IsReadOnly() {
+		if data, ok := f.snapshot(ionode, request, handler); ok {
+			atomic.StoreUint64(&f.snapshotSize, uint64(len(data)))
+			return &fileSnapshot{fileHandle: fh, data: data}, nil
+		}
+	}
+	atomic.StoreUint64(&f.snapshotSize, 0)
+
 	//
 	// Due to the nature of procfs and sysfs, files lack explicit sizes (other
 	// than zero) as regular files have. In consequence, read operations (also
@@ -147,7 +229,75 @@ func (f *File) Open(
 	//
 	resp.Flags |= fuse.OpenDirectIO
 
-	return f, nil
+	return fh, nil
+}
+
+// Maximum size of the contents served through the page-cache (see
+// fileSnapshot); larger contents fall back to direct-io.
+const maxSnapshotSize = 4 << 20
+
+// Handle of the files opened through the page-cache. Read requests are served
+// from the content collected during open().
+type fileSnapshot struct {
+	*fileHandle
+	data []byte
+}
+
+func (s *fileSnapshot) Read(
+	ctx context.Context,
+	req *fuse.ReadRequest,
+	resp *fuse.ReadResponse) error {
+
+	logrus.Debugf("Requested Read() operation for snapshot of entry %v (fuse ID=%#x)",
+		s.path, uint64(req.ID))
+
+	if req.Offset >= int64(len(s.data)) {
+		resp.Data = resp.Data[:0]
+		return nil
+	}
+
+	n := copy(resp.Data[:req.Size], s.data[req.Offset:])
+	resp.Data = resp.Data[:n]
+
+	return nil
+}
+
+// Release FS operation for snapshot handles. The size of the snapshot stops
+// being reported unless a newer snapshot has been taken in the meantime.
+func (s *fileSnapshot) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+
+	atomic.CompareAndSwapUint64(&s.snapshotSize, uint64(len(s.data)), 0)
+
+	return s.fileHandle.Release(ctx, req)
+}
+
+// Obtains the full content of the given node through a single read at offset
+// zero, which is what emulated-node handlers expect. Buffer size is doubled
+// till the content fits (up to maxSnapshotSize).
+func (f *File) snapshot(
+	ionode domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	handler domain.HandlerIface) ([]byte, bool) {
+
+	for size := 64 << 10; size <= maxSnapshotSize; size *= 2 {
+		req.Offset = 0
+		req.Data = make([]byte, size)
+
+		n, err := handler.Read(ionode, req)
+		if err != nil && err != io.EOF {
+			logrus.Debugf("Read() error for snapshot of req-id %#x: %v", req.ID, err)
+			return nil, false
+		}
+
+		if n < size {
+			return req.Data[:n], true
+		}
+	}
+
+	logrus.Debugf("Content of %v exceeds %d bytes; falling back to direct-io",
+		f.path, maxSnapshotSize)
+
+	return nil, false
 }
 
 //
@@ -183,16 +333,105 @@ func (f *File) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
 	return nil
 }
 
+//
+// Release FS operation for file handles. Handlers keeping per-open state of
+// their nodes (see domain.CloseHandlerIface) get to release it at this point.
+//
+func (fh *fileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+
+	logrus.Debugf("Requested Release() operation for entry %v, handle %#x (fuse ID=%#x)",
+		fh.path, fh.id, uint64(req.ID))
+
+	if fh.server.container == nil {
+		return nil
+	}
+
+	ionode := fh.server.service.ios.NewIOnode(fh.name, fh.path, fh.attr.Mode)
+	ionode.SetOpenFlags(fh.flags)
+
+	handler, ok := fh.server.service.hds.LookupContainerHandler(ionode, fh.server.container)
+	if !ok {
+		return nil
+	}
+
+	ch, ok := handler.(domain.CloseHandlerIface)
+	if !ok {
+		return nil
+	}
+
+	request := &domain.HandlerRequest{
+		ID:        newRequestID(),
+		Ctx:       ctx,
+		Pid:       req.Pid,
+		Uid:       req.Uid,
+		Gid:       req.Gid,
+		Handle:    fh.id,
+		Container: fh.server.container,
+	}
+
+	if err := ch.Close(ionode, request); err != nil {
+		logrus.Debugf("Close() error for req-id %#x: %v", request.ID, err)
+	}
+
+	return nil
+}
+
+// Returns the handler of this file handle's node, along with the node and the
+// request to hand over to it.
+func (fh *fileHandle) handlerRequest(
+	ctx context.Context,
+	hdr fuse.Header) (domain.HandlerIface, domain.IOnodeIface, *domain.HandlerRequest, error) {
+
+	// Ensure operation is generated from within a registered sys container.
+	if fh.server.container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			hdr.Pid)
+		return nil, nil, nil, fmt.Errorf("Could not find container originating this request (pid %v)",
+			hdr.Pid)
+	}
+
+	ionode := fh.server.service.ios.NewIOnode(fh.name, fh.path, fh.attr.Mode)
+	ionode.SetOpenFlags(fh.flags)
+
+	handler, ok := fh.server.service.hds.LookupContainerHandler(ionode, fh.server.container)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("No supported handler for %v resource", fh.path)
+	}
+
+	request := &domain.HandlerRequest{
+		ID:        newRequestID(),
+		Ctx:       ctx,
+		Pid:       hdr.Pid,
+		Uid:       hdr.Uid,
+		Gid:       hdr.Gid,
+		Handle:    fh.id,
+		Container: fh.server.container,
+	}
+
+	return handler, ionode, request, nil
+}
+
 //
 // Read FS operation.
 //
-func (f *File) Read(
+func (fh *fileHandle) Read(
 	ctx context.Context,
 	req *fuse.ReadRequest,
 	resp *fuse.ReadResponse) error {
 
-	logrus.Debugf("Requested Read() operation for entry %v (Req ID=%#v)",
-		f.path, uint64(req.ID))
+	return fh.File.read(ctx, req, resp, fh)
+}
+
+func (f *File) read(
+	ctx context.Context,
+	req *fuse.ReadRequest,
+	resp *fuse.ReadResponse,
+	fh *fileHandle) error {
+
+	reqID := newRequestID()
+
+	logrus.Debugf("Requested Read() operation for entry %v (req ID=%#x, fuse ID=%#x)",
+		f.path, reqID, uint64(req.ID))
 
 	// Ensure operation is generated from within a registered sys container.
 	if f.server.container == nil {
@@ -203,86 +442,214 @@ func (f *File) Read(
 	}
 
 	ionode := f.server.service.ios.NewIOnode(f.name, f.path, f.attr.Mode)
+	ionode.SetOpenFlags(fh.flags)
 
 	// Adjust receiving buffer to the request's size.
 	resp.Data = resp.Data[:req.Size]
 
 	// Identify the associated handler and execute it accordingly.
-	handler, ok := f.server.service.hds.LookupHandler(ionode)
+	handler, ok := f.server.service.hds.LookupContainerHandler(ionode, f.server.container)
 	if !ok {
 		logrus.Errorf("Read() error: No supported handler for %v resource", f.path)
 		return fmt.Errorf("No supported handler for %v resource", f.path)
 	}
 
 	request := &domain.HandlerRequest{
-		ID:        uint64(req.ID),
+		ID:        reqID,
+		Ctx:       ctx,
 		Pid:       req.Pid,
 		Uid:       req.Uid,
 		Gid:       req.Gid,
 		Offset:    req.Offset,
 		Data:      resp.Data,
+		Handle:    fh.id,
 		Container: f.server.container,
 	}
 
+	// Serve the request from the handler's read cache if possible.
+	cache := handlerReadCache(handler)
+	if cache != nil {
+		if data, ok := cache.Get(f.server.container.ID(), f.path); ok {
+			if req.Offset >= int64(len(data)) {
+				resp.Data = resp.Data[:0]
+				return nil
+			}
+			n := copy(resp.Data, data[req.Offset:])
+			resp.Data = resp.Data[:n]
+			return nil
+		}
+	}
+
+	// Wait for a handler-execution slot (see MaxWorkers).
+	if !f.server.acquireWorker(ctx) {
+		return fuse.EINTR
+	}
+	defer f.server.releaseWorker()
+
 	// Handler execution.
 	n, err := handler.Read(ionode, request)
 	if err != nil && err != io.EOF {
-		logrus.Debugf("Read() error: %v", err)
+		logrus.Debugf("Read() error for req-id %#x: %v", reqID, err)
 		return err
 	}
 
 	resp.Data = resp.Data[:n]
 
+	// Only full contents can be cached (i.e., obtained through a single read).
+	if cache != nil && req.Offset == 0 && n < req.Size {
+		cache.Set(f.server.container.ID(), f.path, resp.Data)
+	}
+
 	return nil
 }
 
 //
 // Write FS operation.
 //
-func (f *File) Write(
+// Writes exceeding the kernel's maximum write size reach sysbox-fs split
+// into multiple requests (chunks). As emulated nodes expect their values to be
+// written at once, chunks are accumulated within the file handle and delivered
+// to the handler, at the offset of the first one, as soon as a chunk smaller
+// than maxWriteChunk (i.e. the last one of the write) arrives. Only writes
+// whose size is a multiple of maxWriteChunk are left for flush (i.e. close())
+// to deliver. Chunk offsets must be contiguous, except for O_APPEND opens where
+// every chunk is appended to the pending write.
+//
+func (fh *fileHandle) Write(
 	ctx context.Context,
 	req *fuse.WriteRequest,
 	resp *fuse.WriteResponse) error {
 
-	logrus.Debugf("Requested Write() operation for entry %v (Req ID=%#v)",
-		f.path, uint64(req.ID))
+	logrus.Debugf("Requested Write() operation for entry %v, handle %#x (fuse ID=%#x)",
+		fh.path, fh.id, uint64(req.ID))
+
+	fh.mu.Lock()
+	if fh.wbuf == nil && len(req.Data) < maxWriteChunk {
+		fh.mu.Unlock()
+
+		n, err := fh.File.write(ctx, req.Header, req.Data, req.Offset, fh)
+		if err != nil {
+			return err
+		}
+		resp.Size = n
+
+		return nil
+	}
+	defer fh.mu.Unlock()
+
+	if fh.wbuf == nil {
+		fh.woff = req.Offset
+	} else if fh.flags&syscall.O_APPEND == 0 &&
+		req.Offset != fh.woff+int64(len(fh.wbuf)) {
+		fh.wbuf = nil
+		return IOerror{Code: syscall.EINVAL}
+	}
+
+	if len(fh.wbuf)+len(req.Data) > maxWriteBufferSize {
+		fh.wbuf = nil
+		return IOerror{Code: syscall.EFBIG}
+	}
+
+	fh.wbuf = append(fh.wbuf, req.Data...)
+
+	if len(req.Data) < maxWriteChunk {
+		data := fh.wbuf
+		fh.wbuf = nil
+
+		if _, err := fh.File.write(ctx, req.Header, data, fh.woff, fh); err != nil {
+			return err
+		}
+	}
+
+	resp.Size = len(req.Data)
+
+	return nil
+}
+
+//
+// Flush FS operation. Delivers the write accumulated within the file handle
+// (if any).
+//
+func (fh *fileHandle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+
+	fh.mu.Lock()
+	data, offset := fh.wbuf, fh.woff
+	fh.wbuf = nil
+	fh.mu.Unlock()
+
+	if data == nil {
+		return nil
+	}
+
+	logrus.Debugf("Requested Flush() operation for entry %v, handle %#x (fuse ID=%#x)",
+		fh.path, fh.id, uint64(req.ID))
+
+	_, err := fh.File.write(ctx, req.Header, data, offset, fh)
+
+	return err
+}
+
+// Delivers the given content to the node's handler.
+func (f *File) write(
+	ctx context.Context,
+	hdr fuse.Header,
+	data []byte,
+	offset int64,
+	fh *fileHandle) (int, error) {
+
+	reqID := newRequestID()
+
+	logrus.Debugf("Delivering Write() operation for entry %v (req ID=%#x, fuse ID=%#x)",
+		f.path, reqID, uint64(hdr.ID))
 
 	// Ensure operation is generated from within a registered sys container.
 	if f.server.container == nil {
 		logrus.Errorf("Could not find the container originating this request (pid %v)",
-			req.Pid)
-		return fmt.Errorf("Could not find container originating this request (pid %v)",
-			req.Pid)
+			hdr.Pid)
+		return 0, fmt.Errorf("Could not find container originating this request (pid %v)",
+			hdr.Pid)
 	}
 
 	ionode := f.server.service.ios.NewIOnode(f.name, f.path, f.attr.Mode)
+	ionode.SetOpenFlags(fh.flags)
 
 	// Lookup the associated handler within handler-DB.
-	handler, ok := f.server.service.hds.LookupHandler(ionode)
+	handler, ok := f.server.service.hds.LookupContainerHandler(ionode, f.server.container)
 	if !ok {
 		logrus.Errorf("Write() error: No supported handler for %v resource", f.path)
-		return fmt.Errorf("No supported handler for %v resource", f.path)
+		return 0, fmt.Errorf("No supported handler for %v resource", f.path)
 	}
 
 	request := &domain.HandlerRequest{
-		ID:        uint64(req.ID),
-		Pid:       req.Pid,
-		Uid:       req.Uid,
-		Gid:       req.Gid,
-		Data:      req.Data,
+		ID:        reqID,
+		Ctx:       ctx,
+		Pid:       hdr.Pid,
+		Uid:       hdr.Uid,
+		Gid:       hdr.Gid,
+		Offset:    offset,
+		Data:      data,
+		Handle:    fh.id,
 		Container: f.server.container,
 	}
 
+	// Wait for a handler-execution slot (see MaxWorkers).
+	if !f.server.acquireWorker(ctx) {
+		return 0, fuse.EINTR
+	}
+	defer f.server.releaseWorker()
+
 	// Handler execution.
 	n, err := handler.Write(ionode, request)
 	if err != nil && err != io.EOF {
-		logrus.Debugf("Write() error: %v", err)
-		return err
+		logrus.Debugf("Write() error for req-id %#x: %v", reqID, err)
+		return 0, err
 	}
 
-	resp.Size = n
+	if cache := handlerReadCache(handler); cache != nil {
+		cache.Invalidate(f.server.container.ID(), f.path)
+	}
 
-	return nil
+	return n, nil
 }
 
 //
@@ -335,6 +702,9 @@ func (f *File) Forget() {
 // Size method returns the 'size' of a File element.
 //
 func (f *File) Size() uint64 {
+	if size := atomic.LoadUint64(&f.snapshotSize); size != 0 {
+		return size
+	}
 	return f.attr.Size
 }
 
@@ -352,6 +722,28 @@ func (f *File) ModTime() time.Time {
 	return f.attr.Mtime
 }
 
+//
+// emuResourceOwner function returns the uid & gid, relative to the sys
+// container's user-ns, of the resource placed at 'path' if this one is being
+// emulated by the given handler.
+//
+func emuResourceOwner(h domain.HandlerIface, path string) (uint32, uint32) {
+
+	if filepath.Dir(path) != h.GetPath() {
+		return 0, 0
+	}
+
+	resource, ok := h.GetResourceMap()[filepath.Base(path)]
+	if !ok {
+		return 0, 0
+	}
+
+	resource.Mutex.Lock()
+	defer resource.Mutex.Unlock()
+
+	return resource.Uid, resource.Gid
+}
+
 //
 // convertFileInfoToFuse function translates FS node-attributes from a kernel
 // friendly DS type, to those expected by Bazil-FUSE-lib to interact with
@@ -397,3 +789,51 @@ func convertFileInfoToFuse(info os.FileInfo) fuse.Attr {
 
 	return a
 }
+
+// Returns the read cache of the given handler, or nil if it has none.
+func handlerReadCache(h domain.HandlerIface) *domain.ReadCache {
+	if rch, ok := h.(domain.ReadCacheHolderIface); ok {
+		return rch.GetReadCache()
+	}
+
+	return nil
+}
+
+// Returns the kernel-side attribute and entry cache TTLs of the nodes served by
+// the given handler, which fall back to the global ones (see AttrCacheTimeout
+// and DentryCacheTimeout) unless set for the handler.
+func handlerCacheTTLs(h domain.HandlerIface) (attr, entry time.Duration) {
+
+	attr = time.Duration(AttrCacheTimeout)
+	entry = time.Duration(DentryCacheTimeout)
+
+	fch, ok := h.(domain.FuseCacheHolderIface)
+	if !ok {
+		return attr, entry
+	}
+
+	if ttls := fch.GetFuseCacheTTLs(); ttls != nil {
+		if ttls.Attr != nil {
+			attr = *ttls.Attr
+		}
+		if ttls.Entry != nil {
+			entry = *ttls.Entry
+		}
+	}
+
+	return attr, entry
+}
+
+// Returns whether the nodes served by the given handler are to be opened
+// through the page-cache when opened for reading (i.e. mmap support).
+func handlerMmap(h domain.HandlerIface) bool {
+
+	fch, ok := h.(domain.FuseCacheHolderIface)
+	if !ok {
+		return false
+	}
+
+	ttls := fch.GetFuseCacheTTLs()
+
+	return ttls != nil && ttls.Mmap
+}
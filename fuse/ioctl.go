@@ -0,0 +1,71 @@
+//
+// Copyright 2019-2021 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package fuse
+
+import (
+	"context"
+	"syscall"
+
+	"bazil.org/fuse"
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// Ioctl FS operation.
+//
+// Requests are forwarded to the handlers implementing domain.IoctlHandlerIface.
+// Requests over the nodes of the remaining handlers fail with ENOTTY, which is
+// what the kernel reports for the ioctls issued over regular files.
+//
+// TODO: Have this method invoked by the fuse library (nestybox fork of bazil,
+// see "bazil" submodule), which doesn't dispatch the IOCTL opcode as of today.
+//
+func (fh *fileHandle) ioctl(
+	ctx context.Context,
+	hdr fuse.Header,
+	cmd uint32,
+	arg []byte) ([]byte, error) {
+
+	logrus.Debugf("Requested Ioctl() operation for entry %v, cmd %#x (fuse ID=%#x)",
+		fh.path, cmd, uint64(hdr.ID))
+
+	handler, ionode, request, err := fh.handlerRequest(ctx, hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	ih, ok := handler.(domain.IoctlHandlerIface)
+	if !ok {
+		return nil, fuse.Errno(syscall.ENOTTY)
+	}
+
+	// Wait for a handler-execution slot (see MaxWorkers).
+	if !fh.server.acquireWorker(ctx) {
+		return nil, fuse.EINTR
+	}
+	defer fh.server.releaseWorker()
+
+	data, err := ih.Ioctl(ionode, cmd, arg, request)
+	if err != nil {
+		logrus.Debugf("Ioctl() error for req-id %#x: %v", request.ID, err)
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,67 @@
+//
+// Copyright 2019-2021 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package fuse
+
+import (
+	"context"
+
+	"bazil.org/fuse"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+// Events reported for the nodes lacking a poll handler (i.e. the kernel's
+// DEFAULT_POLLMASK): these are always ready for reading and writing.
+const defaultPollEvents = uint32(unix.POLLIN | unix.POLLOUT | unix.POLLRDNORM | unix.POLLWRNORM)
+
+//
+// Poll FS operation.
+//
+// Requests are forwarded to the handlers implementing domain.PollHandlerIface,
+// which report the events their nodes are ready for. Nodes of the remaining
+// handlers are reported as always ready.
+//
+// TODO: Have this method invoked by the fuse library (nestybox fork of bazil,
+// see "bazil" submodule), which doesn't dispatch the POLL opcode as of today.
+// Waiters would also need to be woken up (NOTIFY_POLL) upon the handlers'
+// events.
+//
+func (fh *fileHandle) poll(ctx context.Context, hdr fuse.Header) (uint32, error) {
+
+	logrus.Debugf("Requested Poll() operation for entry %v (fuse ID=%#x)",
+		fh.path, uint64(hdr.ID))
+
+	handler, ionode, request, err := fh.handlerRequest(ctx, hdr)
+	if err != nil {
+		return 0, err
+	}
+
+	ph, ok := handler.(domain.PollHandlerIface)
+	if !ok {
+		return defaultPollEvents, nil
+	}
+
+	events, err := ph.Poll(ionode, request)
+	if err != nil {
+		logrus.Debugf("Poll() error for req-id %#x: %v", request.ID, err)
+		return 0, err
+	}
+
+	return events, nil
+}
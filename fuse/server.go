@@ -17,9 +17,16 @@
 package fuse
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
@@ -30,6 +37,34 @@ import (
 	"github.com/nestybox/sysbox-fs/domain"
 )
 
+// Sysbox-fs-wide request counter. FUSE request IDs are only unique within the
+// FUSE connection of each sys container, so a daemon-wide ID is generated for
+// every request received at the FUSE boundary. This ID is then carried over
+// to the handlers and nsenter helpers serving the request.
+var requestCounter uint64
+
+func newRequestID() uint64 {
+	return atomic.AddUint64(&requestCounter, 1)
+}
+
+// Maximum number of requests being concurrently served by the handlers of
+// each FUSE server. Bazil-FUSE serves every request in its own goroutine;
+// requests exceeding the limit wait for a worker to become available (or for
+// their interruption). As most handlers block on nsenter processes rather
+// than on the CPU, the default allows several requests per CPU. Zero stands
+// for no limit.
+var MaxWorkers int = 8 * runtime.NumCPU()
+
+// Maximum number of background (e.g. readahead, async-io) requests the kernel
+// is allowed to queue up on each FUSE connection, as well as the threshold at
+// which the connection is flagged as congested. Zero (default) leaves the
+// kernel defaults in place.
+var MaxBackground int = 0
+var CongestionThreshold int = 0
+
+// Location of the knobs exposed by the kernel for every FUSE connection.
+const fuseConnectionsDir = "/sys/fs/fuse/connections"
+
 // FuseServer class in charge of running/hosting sysbox-fs' FUSE server features.
 type fuseServer struct {
 	sync.RWMutex                       // nodeDB protection
@@ -40,6 +75,10 @@ type fuseServer struct {
 	nodeDB       map[string]*fs.Node   // map to store all fs nodes, e.g. "/proc/uptime" -> File
 	root         *Dir                  // root node of fuse fs -- "/" by default
 	initDone     chan bool             // sync-up channel to alert about fuse-server's init-completion
+	workers      chan struct{}         // handler-execution slots -- nil if unlimited
+	stopped      chan struct{}         // closed upon fuse-server's main-loop exit
+	done         chan struct{}         // closed upon fuse-server's destruction
+	doneOnce     sync.Once             // done channel protection
 	service      *FuseServerService    // backpointer to parent service
 }
 
@@ -107,11 +146,20 @@ func (s *fuseServer) Create() error {
 	// Initialize pending members.
 	s.nodeDB = make(map[string]*fs.Node)
 	s.initDone = make(chan bool)
+	s.stopped = make(chan struct{})
+	s.done = make(chan struct{})
+
+	if MaxWorkers > 0 {
+		s.workers = make(chan struct{}, MaxWorkers)
+	}
 
 	return nil
 }
 
 func (s *fuseServer) Run() error {
+
+	defer close(s.stopped)
+
 	//
 	// Creating a FUSE mount at the requested mountpoint.
 	//
@@ -152,11 +200,20 @@ func (s *fuseServer) Run() error {
 		return errors.New("FUSE file-system could not be created")
 	}
 
+	// Tune the kernel's request queueing for this connection; failing to do
+	// so is not fatal, as the kernel defaults remain in place.
+	if err := s.tuneConnection(); err != nil {
+		logrus.Warnf("Could not tune FUSE connection of mountpoint %s: %v",
+			s.mountPoint, err)
+	}
+
 	// At this point we are done with fuse-server initialization, so let's
 	// caller know about it.
 	s.initDone <- true
 
-	// Launch fuse-server's main-loop to handle incoming requests.
+	// Launch fuse-server's main-loop to handle incoming requests. Each request
+	// is served within its own goroutine, so a slow handler (e.g. nsenter-based
+	// one) doesn't hold up the remaining requests of the sys container.
 	if err := s.server.Serve(s); err != nil {
 		logrus.Panic(err)
 		return err
@@ -174,6 +231,9 @@ func (s *fuseServer) Run() error {
 
 func (s *fuseServer) Destroy() error {
 
+	// Let the watchdog (if any) know that the server is going away.
+	s.doneOnce.Do(func() { close(s.done) })
+
 	// Unmount sysboxfs from mountpoint.
 	err := fuse.Unmount(s.mountPoint)
 	if err != nil {
@@ -190,6 +250,116 @@ func (s *fuseServer) Destroy() error {
 	return nil
 }
 
+// Waits for a handler-execution slot to be available (see MaxWorkers). Returns
+// false if the request is interrupted in the meantime.
+func (s *fuseServer) acquireWorker(ctx context.Context) bool {
+
+	if s.workers == nil {
+		return true
+	}
+
+	select {
+	case s.workers <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Releases the handler-execution slot obtained through acquireWorker().
+func (s *fuseServer) releaseWorker() {
+
+	if s.workers == nil {
+		return
+	}
+
+	<-s.workers
+}
+
+// Applies the configured background-requests limits (if any) to the FUSE
+// connection of this server.
+func (s *fuseServer) tuneConnection() error {
+
+	if MaxBackground <= 0 && CongestionThreshold <= 0 {
+		return nil
+	}
+
+	connDir, err := s.connectionDir()
+	if err != nil {
+		return err
+	}
+
+	knobs := []struct {
+		name  string
+		value int
+	}{
+		{"max_background", MaxBackground},
+		{"congestion_threshold", CongestionThreshold},
+	}
+
+	for _, k := range knobs {
+		if k.value <= 0 {
+			continue
+		}
+
+		path := filepath.Join(connDir, k.name)
+		ionode := s.service.ios.NewIOnode(k.name, path, 0)
+
+		if err := ionode.WriteFile([]byte(strconv.Itoa(k.value))); err != nil {
+			return fmt.Errorf("unable to write %s: %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+// Checks whether this server answers the requests received for its root node.
+// The outcome is delivered through the returned channel; notice that the stat()
+// of a wedged server only returns once the server resumes (or its connection
+// is aborted), so callers must not issue further checks meanwhile.
+func (s *fuseServer) probe() <-chan error {
+
+	errChan := make(chan error, 1)
+
+	go func() {
+		ionode := s.service.ios.NewIOnode(s.mountPoint, s.mountPoint, 0)
+		_, err := ionode.Stat()
+		errChan <- err
+	}()
+
+	return errChan
+}
+
+// Returns the directory holding the kernel knobs of this server's FUSE
+// connection, which is identified by the device-minor of the mount. Notice
+// that the mount's device is obtained from the host's mountinfo, as stat()ing
+// the mountpoint would require this same server to be serving requests.
+func (s *fuseServer) connectionDir() (string, error) {
+
+	ionode := s.service.ios.NewIOnode("mountinfo", "/proc/self/mountinfo", 0)
+
+	content, err := ionode.ReadFile()
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[4] != s.mountPoint {
+			continue
+		}
+
+		devFields := strings.Split(fields[2], ":")
+		if len(devFields) != 2 {
+			break
+		}
+
+		return filepath.Join(fuseConnectionsDir, devFields[1]), nil
+	}
+
+	return "", fmt.Errorf("mountpoint %s not found", s.mountPoint)
+}
+
 //
 // Root method. This is a Bazil-FUSE-lib requirement. Function returns
 // sysbox-fs' root-node.
@@ -18,16 +18,52 @@ package fuse
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	_ "bazil.org/fuse/fs/fstestutil"
 
 	"github.com/nestybox/sysbox-fs/domain"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
+// Interval between the health checks of each fuse-server (see watchdog()).
+// Zero (default) disables them.
+var WatchdogInterval time.Duration = 0
+
+// Time within which fuse-servers must answer the health checks.
+var WatchdogTimeout time.Duration = 5 * time.Second
+
+// Layout of the per-container mountpoints, relative to the base mountpoint,
+// where "{id}" stands for the container id (e.g. "{id}/fs"). It must match the
+// one expected by sysbox-runc for the bind-mounts of the emulated nodes.
+var MountpointLayout string = "{id}"
+
+// Validates and sets the layout of the per-container mountpoints (see
+// MountpointLayout).
+func SetMountpointLayout(layout string) error {
+
+	if !strings.Contains(layout, "{id}") {
+		return fmt.Errorf("layout %q lacks the {id} placeholder", layout)
+	}
+
+	layout = filepath.Clean(layout)
+	if filepath.IsAbs(layout) || layout == ".." || strings.HasPrefix(layout, "../") {
+		return fmt.Errorf("layout %q is not relative to the base mountpoint", layout)
+	}
+
+	MountpointLayout = layout
+
+	return nil
+}
+
 type FuseServerService struct {
 	sync.RWMutex                                   // servers map protection
 	path         string                            // fs path to emulate -- "/" by default
@@ -60,6 +96,173 @@ func (fss *FuseServerService) Setup(
 	fss.mountPoint = mp
 }
 
+// Removes the per-container mountpoints left behind by a previous sysbox-fs
+// instance (e.g. upon crash), which are lazily unmounted first. Returns the
+// dirs removed from the base mountpoint. Meant to be invoked at startup time,
+// prior to any container registration.
+//
+// Notice that the mountpoints of dead fuse-servers fail stat() (ENOTCONN), so
+// these are obtained from the host's mountinfo, and are only walked through
+// once unmounted. Mountpoints placed out of the base one (i.e. given at
+// pre-registration time) are not taken into account.
+func (fss *FuseServerService) CleanupStaleMountpoints() ([]string, error) {
+
+	mounts, err := fss.fuseMountsUnder(fss.mountPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mountpoints of dead fuse-servers can only be unmounted lazily.
+	for _, path := range mounts {
+		if err := unix.Unmount(path, unix.MNT_DETACH); err != nil {
+			logrus.Warnf("Stale mountpoint %s could not be unmounted: %v", path, err)
+		}
+	}
+
+	dir, err := os.Open(fss.mountPoint)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+
+	for _, name := range names {
+		path := filepath.Join(fss.mountPoint, name)
+
+		if err := removeDirTree(path); err != nil {
+			logrus.Warnf("Stale mountpoint %s could not be removed: %v", path, err)
+			continue
+		}
+
+		removed = append(removed, path)
+	}
+
+	return removed, nil
+}
+
+// Returns the FUSE mountpoints placed under the given dir (at any depth, as
+// per MountpointLayout), as per the host's mountinfo.
+func (fss *FuseServerService) fuseMountsUnder(base string) ([]string, error) {
+
+	ionode := fss.ios.NewIOnode("mountinfo", "/proc/self/mountinfo", 0)
+
+	content, err := ionode.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var mounts []string
+
+	for _, line := range strings.Split(string(content), "\n") {
+		// Optional fields are followed by a "-" separator, the fs-type and the
+		// mount source.
+		fields := strings.Fields(line)
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || sep+1 >= len(fields) {
+			continue
+		}
+
+		fsType := fields[sep+1]
+		if fsType != "fuse" && !strings.HasPrefix(fsType, "fuse.") {
+			continue
+		}
+
+		mp := unescapeMountinfo(fields[4])
+		if strings.HasPrefix(mp, filepath.Clean(base)+"/") {
+			mounts = append(mounts, mp)
+		}
+	}
+
+	return mounts, nil
+}
+
+// Removes the given dir along with its subdirs, as long as these are empty
+// (e.g. the intermediate dirs of the nested mountpoint layouts).
+func removeDirTree(path string) error {
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			return fmt.Errorf("%s is not empty", path)
+		}
+		if err := removeDirTree(filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(path)
+}
+
+// Returns the mountpoint of the given container's fuse-server, as laid out
+// within the base mountpoint (see MountpointLayout).
+func (fss *FuseServerService) cntrMountpoint(cntr domain.ContainerIface) string {
+
+	return filepath.Join(
+		fss.mountPoint,
+		strings.Replace(MountpointLayout, "{id}", cntr.ID(), -1),
+	)
+}
+
+// Removes the given mountpoint dir, along with the parent dirs created for it
+// within the base mountpoint (if left empty).
+func (fss *FuseServerService) removeMountpoint(mp string) error {
+
+	if err := os.Remove(mp); err != nil {
+		return err
+	}
+
+	base := filepath.Clean(fss.mountPoint)
+
+	for dir := filepath.Dir(mp); strings.HasPrefix(dir, base+"/"); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Undoes the octal escaping of the mountinfo fields (e.g. "\040" for spaces).
+func unescapeMountinfo(s string) string {
+
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
 // FuseServerService destructor.
 func (fss *FuseServerService) DestroyFuseService() {
 
@@ -92,7 +295,7 @@ func (fss *FuseServerService) CreateFuseServer(serveCntr, stateCntr domain.Conta
 	fss.RUnlock()
 
 	// Create required mountpoint in host file-system.
-	cntrMountpoint := filepath.Join(fss.mountPoint, cntrId)
+	cntrMountpoint := fss.cntrMountpoint(serveCntr)
 	mountpointIOnode := fss.ios.NewIOnode("", cntrMountpoint, 0600)
 	if err := mountpointIOnode.MkdirAll(); err != nil {
 		return errors.New("FuseServer with invalid mountpoint")
@@ -122,6 +325,10 @@ func (fss *FuseServerService) CreateFuseServer(serveCntr, stateCntr domain.Conta
 
 	logrus.Debugf("Created fuse server for container %s", cntrId)
 
+	if WatchdogInterval > 0 {
+		go fss.watchdog(serveCntr, srv.(*fuseServer))
+	}
+
 	if serveCntr != stateCntr {
 		logrus.Debugf("Fuse server for container %s shares state with container %s", cntrId, stateCntr.ID())
 	}
@@ -151,8 +358,7 @@ func (fss *FuseServerService) DestroyFuseServer(cntrId string) error {
 	}
 
 	// Remove mountpoint dir from host file-system.
-	cntrMountpoint := filepath.Join(fss.mountPoint, cntrId)
-	if err := os.Remove(cntrMountpoint); err != nil {
+	if err := fss.removeMountpoint(srv.MountPoint()); err != nil {
 		logrus.Errorf("FuseServer mountpoint could not be eliminated for container id %s",
 			cntrId)
 		return nil
@@ -167,3 +373,83 @@ func (fss *FuseServerService) DestroyFuseServer(cntrId string) error {
 
 	return nil
 }
+
+// Fuse-server watchdog. Periodically verifies that the given fuse-server keeps
+// serving requests, and reports (i.e. logs and records within the container's
+// kernel log) the checks it fails to answer within WatchdogTimeout, as well as
+// its recovery. Returns upon the fuse-server's destruction or main-loop exit.
+//
+// Unresponsive servers are neither aborted nor remounted: a server may just be
+// slow (e.g. requests touching a frozen container), and the nodes already
+// bind-mounted within the sys container would remain attached to the aborted
+// FUSE connection (ENOTCONN), which can't be re-established from sysbox-fs.
+func (fss *FuseServerService) watchdog(
+	cntr domain.ContainerIface,
+	srv *fuseServer) {
+
+	ticker := time.NewTicker(WatchdogInterval)
+	defer ticker.Stop()
+
+	var (
+		probe   <-chan error     // outstanding health check, if any
+		timeout <-chan time.Time // deadline of the outstanding health check
+		stalled bool
+	)
+
+	for {
+		select {
+		case <-srv.done:
+			return
+
+		case <-srv.stopped:
+			// Servers being destroyed are expected to stop serving requests.
+			select {
+			case <-srv.done:
+				return
+			default:
+			}
+			fss.watchdogEvent(cntr, domain.KernelLogErr, "exited unexpectedly")
+			return
+
+		case <-ticker.C:
+			if probe == nil {
+				probe = srv.probe()
+				timeout = time.After(WatchdogTimeout)
+			}
+
+		case <-timeout:
+			timeout = nil
+			if !stalled {
+				stalled = true
+				fss.watchdogEvent(cntr, domain.KernelLogErr, "not responding")
+			}
+
+		case err := <-probe:
+			probe, timeout = nil, nil
+			if err != nil && !stalled {
+				stalled = true
+				fss.watchdogEvent(cntr, domain.KernelLogErr,
+					fmt.Sprintf("failing health checks (%v)", err))
+			} else if err == nil && stalled {
+				stalled = false
+				fss.watchdogEvent(cntr, domain.KernelLogNotice, "responding again")
+			}
+		}
+	}
+}
+
+func (fss *FuseServerService) watchdogEvent(
+	cntr domain.ContainerIface,
+	level int,
+	event string) {
+
+	msg := fmt.Sprintf("sysbox-fs: fuse server for container %s %s", cntr.ID(), event)
+
+	if level == domain.KernelLogErr {
+		logrus.Warn(msg)
+	} else {
+		logrus.Info(msg)
+	}
+
+	cntr.KernelLog().Append(level, msg)
+}
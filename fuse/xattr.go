@@ -0,0 +1,195 @@
+//
+// Copyright 2019-2021 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+
+	"bazil.org/fuse"
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// Extended-attribute FS operations.
+//
+// Requests are forwarded to the handlers implementing domain.XattrHandlerIface.
+// Nodes of the remaining handlers are reported as lacking extended attributes
+// (ENODATA), same as procfs / sysfs nodes do, instead of having tools (e.g.
+// "ls -l", systemd) stumble upon security.* / system.posix_acl_* lookups
+// failing with unexpected errors.
+//
+
+//
+// Getxattr FS operation.
+//
+func (f *File) Getxattr(
+	ctx context.Context,
+	req *fuse.GetxattrRequest,
+	resp *fuse.GetxattrResponse) error {
+
+	logrus.Debugf("Requested Getxattr() operation for entry %v, xattr %v (fuse ID=%#x)",
+		f.path, req.Name, uint64(req.ID))
+
+	xh, ionode, request, err := f.xattrHandler(ctx, req.Header)
+	if err != nil {
+		return err
+	}
+	if xh == nil {
+		return fuse.ErrNoXattr
+	}
+
+	value, err := xh.Getxattr(ionode, req.Name, request)
+	if err != nil {
+		logrus.Debugf("Getxattr() error for req-id %#x: %v", request.ID, err)
+		return err
+	}
+
+	// A zero size stands for a query of the attribute's size.
+	if req.Size != 0 && uint32(len(value)) > req.Size {
+		return IOerror{Code: syscall.ERANGE}
+	}
+
+	resp.Xattr = value
+
+	return nil
+}
+
+//
+// Listxattr FS operation.
+//
+func (f *File) Listxattr(
+	ctx context.Context,
+	req *fuse.ListxattrRequest,
+	resp *fuse.ListxattrResponse) error {
+
+	logrus.Debugf("Requested Listxattr() operation for entry %v (fuse ID=%#x)",
+		f.path, uint64(req.ID))
+
+	xh, ionode, request, err := f.xattrHandler(ctx, req.Header)
+	if err != nil {
+		return err
+	}
+	if xh == nil {
+		return nil
+	}
+
+	names, err := xh.Listxattr(ionode, request)
+	if err != nil {
+		logrus.Debugf("Listxattr() error for req-id %#x: %v", request.ID, err)
+		return err
+	}
+
+	resp.Append(names...)
+
+	// A zero size stands for a query of the list's size.
+	if req.Size != 0 && uint32(len(resp.Xattr)) > req.Size {
+		return IOerror{Code: syscall.ERANGE}
+	}
+
+	return nil
+}
+
+//
+// Setxattr FS operation.
+//
+func (f *File) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
+
+	logrus.Debugf("Requested Setxattr() operation for entry %v, xattr %v (fuse ID=%#x)",
+		f.path, req.Name, uint64(req.ID))
+
+	xh, ionode, request, err := f.xattrHandler(ctx, req.Header)
+	if err != nil {
+		return err
+	}
+	if xh == nil {
+		return IOerror{Code: syscall.ENOTSUP}
+	}
+
+	if err := xh.Setxattr(ionode, req.Name, req.Xattr, req.Flags, request); err != nil {
+		logrus.Debugf("Setxattr() error for req-id %#x: %v", request.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+//
+// Removexattr FS operation.
+//
+func (f *File) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
+
+	logrus.Debugf("Requested Removexattr() operation for entry %v, xattr %v (fuse ID=%#x)",
+		f.path, req.Name, uint64(req.ID))
+
+	xh, ionode, request, err := f.xattrHandler(ctx, req.Header)
+	if err != nil {
+		return err
+	}
+	if xh == nil {
+		return fuse.ErrNoXattr
+	}
+
+	if err := xh.Removexattr(ionode, req.Name, request); err != nil {
+		logrus.Debugf("Removexattr() error for req-id %#x: %v", request.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// Returns the handler serving the extended attributes of this node (nil if
+// the node's handler lacks xattr support), along with the ionode and request
+// to hand over to it.
+func (f *File) xattrHandler(
+	ctx context.Context,
+	hdr fuse.Header) (domain.XattrHandlerIface, domain.IOnodeIface, *domain.HandlerRequest, error) {
+
+	// Ensure operation is generated from within a registered sys container.
+	if f.server.container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			hdr.Pid)
+		return nil, nil, nil, fmt.Errorf("Could not find container originating this request (pid %v)",
+			hdr.Pid)
+	}
+
+	ionode := f.server.service.ios.NewIOnode(f.name, f.path, f.attr.Mode)
+
+	handler, ok := f.server.service.hds.LookupContainerHandler(ionode, f.server.container)
+	if !ok {
+		return nil, nil, nil, nil
+	}
+
+	xh, ok := handler.(domain.XattrHandlerIface)
+	if !ok {
+		return nil, nil, nil, nil
+	}
+
+	request := &domain.HandlerRequest{
+		ID:        newRequestID(),
+		Ctx:       ctx,
+		Pid:       hdr.Pid,
+		Uid:       hdr.Uid,
+		Gid:       hdr.Gid,
+		Container: f.server.container,
+	}
+
+	return xh, ionode, request, nil
+}
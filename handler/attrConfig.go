@@ -0,0 +1,466 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// Load the deployment-wide attribute overrides of the emulated resources from
+// the json file passed by the operator. Example:
+//
+// {
+//   "rules": [
+//     { "path": "/proc/sys/net", "mode": "0444" },
+//     { "path": "/proc/sys/kernel/hostname", "uid": 0, "gid": 5 },
+//     { "path": "/proc/sys/net/core/netdev_budget", "policy": "pooled-max" }
+//   ],
+//   "exceptions": [ "/proc/sys/net/core/somaxconn" ],
+//   "sysctls": [
+//     { "path": "/proc/sys/net/sctp", "policy": "netns-exec" },
+//     { "path": "/proc/sys/user/*", "policy": "write-virtual" },
+//     { "path": "/proc/sys/vm/compact_memory", "policy": "write-deny" }
+//   ],
+//   "readCache": [
+//     { "path": "/sys/devices/virtual/dmi/id", "ttl": "10s" }
+//   ],
+//   "disabledHandlers": [
+//     { "path": "/proc/cpuinfo", "containers": [ "<container-id>" ] }
+//   ],
+//   "containers": [
+//     { "id": "*", "overrides": [ { "path": "/proc/kallsyms", "passthrough": true } ] },
+//     { "id": "<container-id>", "kernelRelease": "5.4.0-generic",
+//       "dmiId": { "product_uuid": "3d7b0f2e-2b1c-4a3e-9d52-6c0e8f1a7b44" } }
+//   ]
+// }
+//
+func LoadEmuResourceAttrConfig(path string) (*domain.EmuResourceAttrConfig, error) {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg domain.EmuResourceAttrConfig
+
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid emulated-resources attributes file %s: %v",
+			path, err)
+	}
+
+	for i, rule := range cfg.Rules {
+		if !filepath.IsAbs(rule.Path) {
+			return nil, fmt.Errorf("invalid rule path %q: must be absolute",
+				rule.Path)
+		}
+		cfg.Rules[i].Path = filepath.Clean(rule.Path)
+
+		if rule.Mode != "" {
+			if _, err := parseEmuResourceMode(rule.Mode); err != nil {
+				return nil, err
+			}
+		}
+
+		switch rule.Policy {
+		case "":
+		case domain.StateOnlyPolicy:
+		case domain.WriteThroughPolicy:
+		case domain.PooledMaxPolicy:
+		case domain.PooledMinPolicy:
+		case domain.HostClampedPolicy:
+		case domain.ReadOnlyPolicy:
+		default:
+			return nil, fmt.Errorf("invalid policy %q for rule path %s",
+				rule.Policy, rule.Path)
+		}
+	}
+
+	for i, rule := range cfg.Sysctls {
+		if !pathUnder(filepath.Clean(rule.Path), "/proc/sys") {
+			return nil, fmt.Errorf("invalid sysctl path %q: must be placed under /proc/sys",
+				rule.Path)
+		}
+		if _, err := filepath.Match(rule.Path, rule.Path); err != nil {
+			return nil, fmt.Errorf("invalid sysctl path %q: %v", rule.Path, err)
+		}
+		cfg.Sysctls[i].Path = filepath.Clean(rule.Path)
+
+		switch rule.Policy {
+		case domain.SysctlReadPassthrough:
+		case domain.SysctlWriteVirtual:
+		case domain.SysctlWriteDeny:
+		case domain.SysctlNetnsExec:
+		default:
+			return nil, fmt.Errorf("invalid policy %q for sysctl path %s",
+				rule.Policy, rule.Path)
+		}
+	}
+
+	for i, rule := range cfg.ReadCache {
+		if !filepath.IsAbs(rule.Path) {
+			return nil, fmt.Errorf("invalid read-cache path %q: must be absolute",
+				rule.Path)
+		}
+		cfg.ReadCache[i].Path = filepath.Clean(rule.Path)
+
+		if ttl, err := time.ParseDuration(rule.TTL); err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid ttl %q for read-cache path %s",
+				rule.TTL, rule.Path)
+		}
+	}
+
+	for i, rule := range cfg.FuseCache {
+		if !filepath.IsAbs(rule.Path) {
+			return nil, fmt.Errorf("invalid fuse-cache path %q: must be absolute",
+				rule.Path)
+		}
+		cfg.FuseCache[i].Path = filepath.Clean(rule.Path)
+
+		if rule.AttrTTL == "" && rule.EntryTTL == "" && !rule.Mmap {
+			return nil, fmt.Errorf("no ttl or mmap defined for fuse-cache path %s",
+				rule.Path)
+		}
+
+		for _, val := range []string{rule.AttrTTL, rule.EntryTTL} {
+			if val == "" {
+				continue
+			}
+			if ttl, err := time.ParseDuration(val); err != nil || ttl < 0 {
+				return nil, fmt.Errorf("invalid ttl %q for fuse-cache path %s",
+					val, rule.Path)
+			}
+		}
+
+		// Snapshot sizes are only valid for the duration of the open (see
+		// fuse.fileSnapshot), so they must not be cached beyond it. Mmap rules
+		// get a zero attr ttl when none is given.
+		if rule.Mmap && rule.AttrTTL != "" {
+			if ttl, _ := time.ParseDuration(rule.AttrTTL); ttl != 0 {
+				return nil, fmt.Errorf("mmap can't be combined with a non-zero attr ttl for fuse-cache path %s",
+					rule.Path)
+			}
+		}
+	}
+
+	for i, rule := range cfg.ModuleParams {
+		if !pathUnder(filepath.Clean(rule.Path), "/sys/module") {
+			return nil, fmt.Errorf("invalid module parameter path %q: must be placed under /sys/module",
+				rule.Path)
+		}
+		if _, err := filepath.Match(rule.Path, rule.Path); err != nil {
+			return nil, fmt.Errorf("invalid module parameter path %q: %v", rule.Path, err)
+		}
+		cfg.ModuleParams[i].Path = filepath.Clean(rule.Path)
+
+		switch rule.Policy {
+		case domain.ModuleParamReadOnly:
+		case domain.ModuleParamWriteVirtual:
+		default:
+			return nil, fmt.Errorf("invalid policy %q for module parameter path %s",
+				rule.Policy, rule.Path)
+		}
+	}
+
+	for _, fs := range cfg.Filesystems {
+		if fs == "" || strings.ContainsAny(fs, " \t\n") {
+			return nil, fmt.Errorf("invalid filesystem type %q", fs)
+		}
+	}
+
+	for i, rule := range cfg.DisabledHandlers {
+		if !filepath.IsAbs(rule.Path) {
+			return nil, fmt.Errorf("invalid disabled handler path %q: must be absolute",
+				rule.Path)
+		}
+		cfg.DisabledHandlers[i].Path = filepath.Clean(rule.Path)
+
+		for _, id := range rule.Containers {
+			if id == "" {
+				return nil, fmt.Errorf("invalid container id for disabled handler %s",
+					rule.Path)
+			}
+		}
+	}
+
+	for i, rule := range cfg.Containers {
+		if _, err := filepath.Match(rule.Id, rule.Id); rule.Id == "" || err != nil {
+			return nil, fmt.Errorf("invalid container id %q", rule.Id)
+		}
+
+		for j, ov := range rule.Overrides {
+			if !filepath.IsAbs(ov.Path) {
+				return nil, fmt.Errorf("invalid handler override path %q for container %s: must be absolute",
+					ov.Path, rule.Id)
+			}
+			cfg.Containers[i].Overrides[j].Path = filepath.Clean(ov.Path)
+		}
+
+		if strings.ContainsAny(rule.KernelRelease, " \t\n") {
+			return nil, fmt.Errorf("invalid kernel release %q for container %s",
+				rule.KernelRelease, rule.Id)
+		}
+
+		for field, id := range rule.DmiId {
+			if _, ok := domain.DmiIdFields[field]; !ok {
+				return nil, fmt.Errorf("invalid dmi-id field %q for container %s",
+					field, rule.Id)
+			}
+			if id == "" || strings.ContainsAny(id, "\n") ||
+				(field == "product_uuid" && !validUuid(id)) {
+				return nil, fmt.Errorf("invalid dmi-id %s %q for container %s",
+					field, id, rule.Id)
+			}
+		}
+	}
+
+	for i, exc := range cfg.Exceptions {
+		if !filepath.IsAbs(exc) {
+			return nil, fmt.Errorf("invalid exception path %q: must be absolute",
+				exc)
+		}
+		cfg.Exceptions[i] = filepath.Clean(exc)
+	}
+
+	return &cfg, nil
+}
+
+// Returns 'true' if the given string is a uuid in its canonical (36 chars)
+// format, as displayed by /sys/devices/virtual/dmi/id/product_uuid.
+func validUuid(s string) bool {
+
+	if len(s) != 36 {
+		return false
+	}
+
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func parseEmuResourceMode(mode string) (os.FileMode, error) {
+
+	val, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || val > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid mode %q: expected octal permission bits",
+			mode)
+	}
+
+	return os.FileMode(val), nil
+}
+
+// Returns 'true' if 'path' matches 'prefix' or is placed under it.
+func pathUnder(path, prefix string) bool {
+	if path == prefix || prefix == "/" {
+		return true
+	}
+
+	return strings.HasPrefix(path, prefix+"/")
+}
+
+// Finds the rule that better matches the given resource path, or nil if the
+// resource must keep its default attributes.
+func matchEmuResourceAttrRule(
+	cfg *domain.EmuResourceAttrConfig,
+	path string) *domain.EmuResourceAttrRule {
+
+	if cfg == nil {
+		return nil
+	}
+
+	for _, exc := range cfg.Exceptions {
+		if pathUnder(path, exc) {
+			return nil
+		}
+	}
+
+	var match *domain.EmuResourceAttrRule
+
+	for i, rule := range cfg.Rules {
+		if !pathUnder(path, rule.Path) {
+			continue
+		}
+		if match == nil || len(rule.Path) > len(match.Path) {
+			match = &cfg.Rules[i]
+		}
+	}
+
+	return match
+}
+
+// Applies the operator-defined attribute overrides to every resource emulated
+// by the given handler.
+func (hs *handlerService) applyEmuResourceAttrs(h domain.HandlerIface) {
+
+	if hs.attrCfg == nil {
+		return
+	}
+
+	for name, resource := range h.GetResourceMap() {
+		path := filepath.Join(h.GetPath(), name)
+
+		rule := matchEmuResourceAttrRule(hs.attrCfg, path)
+		if rule == nil {
+			continue
+		}
+
+		resource.Mutex.Lock()
+
+		if rule.Mode != "" {
+			// Mode was validated when loading the config file.
+			mode, _ := parseEmuResourceMode(rule.Mode)
+			resource.Mode = (resource.Mode &^ os.ModePerm) | mode
+		}
+		if rule.Uid != nil {
+			resource.Uid = *rule.Uid
+		}
+		if rule.Gid != nil {
+			resource.Gid = *rule.Gid
+		}
+		if rule.Policy != "" {
+			if emuResourcePolicySupported(resource, rule.Policy) {
+				resource.Policy = rule.Policy
+			} else {
+				logrus.Warnf("Policy %s not supported by emulated resource %s",
+					rule.Policy, path)
+			}
+		}
+
+		resource.Mutex.Unlock()
+
+		logrus.Debugf("Applied attributes of rule %s to emulated resource %s",
+			rule.Path, path)
+	}
+}
+
+// Pairs the given handler with a read cache if requested by the operator. When
+// multiple rules match the handler, the one with the longest path prevails.
+func (hs *handlerService) applyReadCacheRules(h domain.HandlerIface) {
+
+	if hs.attrCfg == nil {
+		return
+	}
+
+	rch, ok := h.(domain.ReadCacheHolderIface)
+	if !ok {
+		return
+	}
+
+	var match *domain.ReadCacheRule
+
+	for i, rule := range hs.attrCfg.ReadCache {
+		if !pathUnder(h.GetPath(), rule.Path) {
+			continue
+		}
+		if match == nil || len(rule.Path) > len(match.Path) {
+			match = &hs.attrCfg.ReadCache[i]
+		}
+	}
+
+	if match == nil {
+		return
+	}
+
+	// TTL was validated when loading the config file.
+	ttl, _ := time.ParseDuration(match.TTL)
+	rch.SetReadCache(domain.NewReadCache(ttl))
+
+	logrus.Debugf("Enabled read cache (ttl %v) for handler %s", ttl, h.GetName())
+}
+
+// Sets the kernel-side cache TTLs of the given handler's nodes if requested by
+// the operator. When multiple rules match the handler, the one with the
+// longest path prevails.
+func (hs *handlerService) applyFuseCacheRules(h domain.HandlerIface) {
+
+	if hs.attrCfg == nil {
+		return
+	}
+
+	fch, ok := h.(domain.FuseCacheHolderIface)
+	if !ok {
+		return
+	}
+
+	var match *domain.FuseCacheRule
+
+	for i, rule := range hs.attrCfg.FuseCache {
+		if !pathUnder(h.GetPath(), rule.Path) {
+			continue
+		}
+		if match == nil || len(rule.Path) > len(match.Path) {
+			match = &hs.attrCfg.FuseCache[i]
+		}
+	}
+
+	if match == nil {
+		return
+	}
+
+	// TTLs were validated when loading the config file.
+	var ttls domain.FuseCacheTTLs
+
+	if match.AttrTTL != "" || match.Mmap {
+		ttl, _ := time.ParseDuration(match.AttrTTL)
+		ttls.Attr = &ttl
+	}
+	if match.EntryTTL != "" {
+		ttl, _ := time.ParseDuration(match.EntryTTL)
+		ttls.Entry = &ttl
+	}
+	ttls.Mmap = match.Mmap
+
+	fch.SetFuseCacheTTLs(&ttls)
+
+	logrus.Debugf("Set fuse-cache ttls (attr %q, entry %q, mmap %v) for handler %s",
+		match.AttrTTL, match.EntryTTL, match.Mmap, h.GetName())
+}
+
+func emuResourcePolicySupported(
+	resource *domain.EmuResource,
+	policy domain.EmuResourcePolicy) bool {
+
+	for _, p := range resource.Policies {
+		if p == policy {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,276 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func Test_matchEmuResourceAttrRule(t *testing.T) {
+
+	var cfg = &domain.EmuResourceAttrConfig{
+		Rules: []domain.EmuResourceAttrRule{
+			{Path: "/proc/sys/net", Mode: "0444"},
+			{Path: "/proc/sys/net/unix", Mode: "0440"},
+			{Path: "/proc/sys/kernel/hostname", Mode: "0644"},
+		},
+		Exceptions: []string{
+			"/proc/sys/net/core/somaxconn",
+		},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		// Resource under the generic net rule.
+		{"1", "/proc/sys/net/netfilter/nf_conntrack_max", "/proc/sys/net"},
+
+		// Longest rule must prevail.
+		{"2", "/proc/sys/net/unix/max_dgram_qlen", "/proc/sys/net/unix"},
+
+		// Exceptions keep the default attributes.
+		{"3", "/proc/sys/net/core/somaxconn", ""},
+
+		// Exact-path rule.
+		{"4", "/proc/sys/kernel/hostname", "/proc/sys/kernel/hostname"},
+
+		// Partial path-component matches must be discarded.
+		{"5", "/proc/sys/kernel/hostname_bogus", ""},
+
+		// No matching rule.
+		{"6", "/proc/sys/vm/max_map_count", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if rule := matchEmuResourceAttrRule(cfg, tt.path); rule != nil {
+				got = rule.Path
+			}
+			if got != tt.want {
+				t.Errorf("matchEmuResourceAttrRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEmuResourceAttrConfig_Sysctls(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-attrs")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"1", `{"sysctls": [{"path": "/proc/sys/user/*", "policy": "write-virtual"}]}`, false},
+
+		// Paths must be placed under /proc/sys.
+		{"2", `{"sysctls": [{"path": "/sys/kernel/mm", "policy": "write-deny"}]}`, true},
+
+		// Unknown policies are rejected.
+		{"3", `{"sysctls": [{"path": "/proc/sys/vm", "policy": "pooled-max"}]}`, true},
+
+		// Malformed glob patterns are rejected.
+		{"4", `{"sysctls": [{"path": "/proc/sys/net/[", "policy": "netns-exec"}]}`, true},
+
+		// File-system types must be non-empty words.
+		{"5", `{"filesystems": ["proc", "tmpfs"]}`, false},
+		{"6", `{"filesystems": ["proc", ""]}`, true},
+
+		// Module parameters must be placed under /sys/module.
+		{"7", `{"moduleParams": [{"path": "/sys/module/nf_conntrack/parameters/*", "policy": "read-only"}]}`, false},
+		{"8", `{"moduleParams": [{"path": "/proc/sys/vm", "policy": "read-only"}]}`, true},
+		{"9", `{"moduleParams": [{"path": "/sys/module/kvm/parameters/nx_huge_pages", "policy": "write-deny"}]}`, true},
+
+		// Fuse-cache rules require at least one valid ttl.
+		{"10", `{"fuseCache": [{"path": "/proc/meminfo", "attrTtl": "1s", "entryTtl": "1m"}]}`, false},
+		{"11", `{"fuseCache": [{"path": "/proc/meminfo"}]}`, true},
+		{"12", `{"fuseCache": [{"path": "/proc/meminfo", "attrTtl": "-1s"}]}`, true},
+		{"13", `{"fuseCache": [{"path": "/proc/cpuinfo", "mmap": true}]}`, false},
+		{"14", `{"fuseCache": [{"path": "/proc/cpuinfo", "mmap": true, "attrTtl": "0s"}]}`, false},
+		{"15", `{"fuseCache": [{"path": "/proc/cpuinfo", "mmap": true, "attrTtl": "1s"}]}`, true},
+
+		// Disabled handlers require an absolute path and non-empty container ids.
+		{"16", `{"disabledHandlers": [{"path": "/proc/cpuinfo", "containers": ["c1"]}]}`, false},
+		{"17", `{"disabledHandlers": [{"path": "proc/cpuinfo"}]}`, true},
+		{"18", `{"disabledHandlers": [{"path": "/proc/cpuinfo", "containers": [""]}]}`, true},
+
+		// Container rules require a valid id pattern and well-formed settings.
+		{"19", `{"containers": [{"id": "*", "kernelRelease": "5.4.0-generic"}]}`, false},
+		{"20", `{"containers": [{"id": "[", "kernelRelease": "5.4.0-generic"}]}`, true},
+		{"21", `{"containers": [{"id": "*", "overrides": [{"path": "proc/cpuinfo"}]}]}`, true},
+		{"22", `{"containers": [{"id": "*", "kernelRelease": "5.4.0 generic"}]}`, true},
+		{"23", `{"containers": [{"id": "c1", "dmiId": {"product_uuid": "3d7b0f2e-2b1c-4a3e-9d52-6c0e8f1a7b44"}}]}`, false},
+		{"24", `{"containers": [{"id": "c1", "dmiId": {"product_uuid": "not-a-uuid"}}]}`, true},
+		{"25", `{"containers": [{"id": "c1", "dmiId": {"bios_vendor": "Nestybox"}}]}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Unable to write config file: %v", err)
+			}
+
+			_, err := LoadEmuResourceAttrConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadEmuResourceAttrConfig() error = %v, wantErr %v",
+					err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_applyReadCacheRules(t *testing.T) {
+
+	var hs = &handlerService{
+		attrCfg: &domain.EmuResourceAttrConfig{
+			ReadCache: []domain.ReadCacheRule{
+				{Path: "/proc", TTL: "1s"},
+				{Path: "/proc/sys/kernel", TTL: "5s"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want time.Duration
+	}{
+		// Longest rule must prevail.
+		{"1", "/proc/sys/kernel", 5 * time.Second},
+
+		// Handler under the generic rule.
+		{"2", "/proc/sys/vm", time.Second},
+
+		// No matching rule.
+		{"3", "/sys/devices/virtual/dmi/id", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.ProcSys{domain.HandlerBase{Path: tt.path}}
+			hs.applyReadCacheRules(h)
+
+			var got time.Duration
+			if cache := h.GetReadCache(); cache != nil {
+				got = cache.TTL()
+			}
+			if got != tt.want {
+				t.Errorf("applyReadCacheRules() ttl = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Cached entries are kept per container, and can be invalidated.
+	cache := domain.NewReadCache(time.Minute)
+	cache.Set("c1", "/proc/sys/kernel/pid_max", []byte("32768\n"))
+
+	if _, ok := cache.Get("c2", "/proc/sys/kernel/pid_max"); ok {
+		t.Errorf("Unexpected cache hit for container c2")
+	}
+	if data, ok := cache.Get("c1", "/proc/sys/kernel/pid_max"); !ok ||
+		string(data) != "32768\n" {
+		t.Errorf("Unexpected cache content for container c1: %q", data)
+	}
+
+	cache.InvalidateContainer("c1")
+	if _, ok := cache.Get("c1", "/proc/sys/kernel/pid_max"); ok {
+		t.Errorf("Unexpected cache hit after invalidation")
+	}
+}
+
+func Test_applyFuseCacheRules(t *testing.T) {
+
+	var hs = &handlerService{
+		attrCfg: &domain.EmuResourceAttrConfig{
+			FuseCache: []domain.FuseCacheRule{
+				{Path: "/proc", AttrTTL: "1s"},
+				{Path: "/proc/sys/kernel", EntryTTL: "5s"},
+				{Path: "/proc/cpuinfo", Mmap: true},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		wantAttr  *time.Duration
+		wantEntry *time.Duration
+	}{
+		// Longest rule must prevail; omitted ttls are left unset.
+		{"1", "/proc/sys/kernel", nil, durationPtr(5 * time.Second)},
+
+		// Handler under the generic rule.
+		{"2", "/proc/sys/vm", durationPtr(time.Second), nil},
+
+		// Mmap rules pin the attr ttl to zero.
+		{"3", "/proc/cpuinfo", durationPtr(0), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &implementations.ProcSys{domain.HandlerBase{Path: tt.path}}
+			hs.applyFuseCacheRules(h)
+
+			ttls := h.GetFuseCacheTTLs()
+			if ttls == nil {
+				t.Fatalf("applyFuseCacheRules() ttls not set")
+			}
+			if !equalDurationPtr(ttls.Attr, tt.wantAttr) {
+				t.Errorf("applyFuseCacheRules() attr ttl = %v, want %v",
+					ttls.Attr, tt.wantAttr)
+			}
+			if !equalDurationPtr(ttls.Entry, tt.wantEntry) {
+				t.Errorf("applyFuseCacheRules() entry ttl = %v, want %v",
+					ttls.Entry, tt.wantEntry)
+			}
+		})
+	}
+
+	// No matching rule.
+	h := &implementations.ProcSys{domain.HandlerBase{Path: "/sys/kernel/mm"}}
+	hs.applyFuseCacheRules(h)
+	if h.GetFuseCacheTTLs() != nil {
+		t.Errorf("applyFuseCacheRules() unexpected ttls for %s", h.GetPath())
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+func equalDurationPtr(a, b *time.Duration) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
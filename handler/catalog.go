@@ -0,0 +1,81 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// Catalog function generates the list of resources emulated by the given
+// handlers, along with the details of the emulation being carried out for
+// each one of them. Entries are sorted by path.
+//
+func Catalog(hdlrs []domain.HandlerIface) []domain.HandlerCatalogEntry {
+
+	var catalog []domain.HandlerCatalogEntry
+
+	for _, h := range hdlrs {
+		for name, resource := range h.GetResourceMap() {
+
+			resource.Mutex.Lock()
+
+			entry := domain.HandlerCatalogEntry{
+				Path:     filepath.Join(h.GetPath(), name),
+				Handler:  h.GetName(),
+				Kind:     "file",
+				Mode:     fmt.Sprintf("%#o", resource.Mode.Perm()),
+				Enabled:  h.GetEnabled() && resource.Enabled,
+				Policy:   resource.Policy,
+				Policies: resource.Policies,
+				Format:   resource.Format,
+				Bounds:   resource.Bounds,
+			}
+			if resource.Kind == domain.DirEmuResource {
+				entry.Kind = "dir"
+			}
+
+			resource.Mutex.Unlock()
+
+			catalog = append(catalog, entry)
+		}
+	}
+
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].Path < catalog[j].Path
+	})
+
+	return catalog
+}
+
+func (hs *handlerService) HandlersCatalog() []domain.HandlerCatalogEntry {
+
+	var hdlrs []domain.HandlerIface
+
+	hs.RLock()
+	hs.handlerTree.Root().Walk(func(key []byte, val interface{}) bool {
+		hdlrs = append(hdlrs, val.(domain.HandlerIface))
+		return false
+	})
+	hs.RUnlock()
+
+	return Catalog(hdlrs)
+}
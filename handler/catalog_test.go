@@ -0,0 +1,64 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestCatalog(t *testing.T) {
+
+	catalog := Catalog(DefaultHandlers)
+
+	if !sort.SliceIsSorted(catalog, func(i, j int) bool {
+		return catalog[i].Path < catalog[j].Path
+	}) {
+		t.Errorf("Catalog() entries are not sorted by path")
+	}
+
+	want := domain.HandlerCatalogEntry{
+		Path:    "/proc/sys/kernel/yama/ptrace_scope",
+		Handler: implementations.ProcSysKernelYama_Handler.GetName(),
+		Kind:    "file",
+		Mode:    "0644",
+		Enabled: true,
+		Policy:  domain.StateOnlyPolicy,
+		Format:  domain.IntFormat,
+		Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 3},
+	}
+
+	var found bool
+
+	for _, entry := range catalog {
+		if entry.Path != want.Path {
+			continue
+		}
+		found = true
+		if !reflect.DeepEqual(entry, want) {
+			t.Errorf("Catalog() entry = %+v, want %+v", entry, want)
+		}
+	}
+
+	if !found {
+		t.Errorf("Catalog() entry %s not found", want.Path)
+	}
+}
@@ -0,0 +1,101 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+//
+// Load the simple emulated nodes declared by the operator in the json file
+// passed at startup time, and build the handlers serving them. Example:
+//
+// {
+//   "nodes": [
+//     { "path": "/proc/sys/kernel/watchdog", "type": "fixed", "value": "0" },
+//     { "path": "/proc/sys/vm/swappiness", "type": "stored", "value": "60" },
+//     { "path": "/proc/sys/kernel/msgmni", "type": "clamped",
+//       "bounds": { "min": 0, "max": 32768 }, "mode": "0644" }
+//   ]
+// }
+//
+func LoadDeclarativeHandlers(path string) ([]domain.HandlerIface, error) {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg domain.DeclarativeConfig
+
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid handlers config file %s: %v", path, err)
+	}
+
+	var (
+		hdlrs []domain.HandlerIface
+		paths = make(map[string]bool)
+	)
+
+	for i := range cfg.Nodes {
+		node := &cfg.Nodes[i]
+
+		if !filepath.IsAbs(node.Path) || filepath.Clean(node.Path) == "/" {
+			return nil, fmt.Errorf("invalid node path %q: must be absolute",
+				node.Path)
+		}
+		node.Path = filepath.Clean(node.Path)
+
+		if paths[node.Path] {
+			return nil, fmt.Errorf("duplicated node path %s", node.Path)
+		}
+		paths[node.Path] = true
+
+		mode := os.FileMode(0644)
+
+		switch node.Type {
+		case domain.FixedNode:
+			mode = os.FileMode(0444)
+		case domain.StoredNode:
+		case domain.ClampedNode:
+			if node.Bounds == nil || node.Bounds.Min > node.Bounds.Max {
+				return nil, fmt.Errorf("invalid bounds for clamped node %s",
+					node.Path)
+			}
+		default:
+			return nil, fmt.Errorf("invalid type %q for node path %s",
+				node.Type, node.Path)
+		}
+
+		if node.Mode != "" {
+			if mode, err = parseEmuResourceMode(node.Mode); err != nil {
+				return nil, err
+			}
+		}
+
+		hdlrs = append(hdlrs, implementations.NewDeclarativeHandler(node, mode))
+	}
+
+	return hdlrs, nil
+}
@@ -0,0 +1,82 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDeclarativeHandlers(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-decl")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{"1", `{"nodes": [
+			{"path": "/proc/sys/kernel/watchdog", "type": "fixed", "value": "0"},
+			{"path": "/proc/sys/vm/swappiness", "type": "stored"},
+			{"path": "/proc/sys/kernel/msgmni", "type": "clamped",
+			 "bounds": {"min": 0, "max": 32768}}]}`, 3, false},
+
+		// Unknown node types are rejected.
+		{"2", `{"nodes": [{"path": "/proc/sys/vm/swappiness", "type": "pooled"}]}`, 0, true},
+
+		// Clamped nodes require bounds.
+		{"3", `{"nodes": [{"path": "/proc/sys/kernel/msgmni", "type": "clamped"}]}`, 0, true},
+
+		// Relative paths are rejected.
+		{"4", `{"nodes": [{"path": "proc/sys/vm/swappiness", "type": "stored"}]}`, 0, true},
+
+		// Duplicated paths are rejected.
+		{"5", `{"nodes": [
+			{"path": "/proc/sys/vm/swappiness", "type": "stored"},
+			{"path": "/proc/sys/vm/swappiness/", "type": "fixed"}]}`, 0, true},
+
+		// Invalid modes are rejected.
+		{"6", `{"nodes": [{"path": "/proc/sys/vm/swappiness", "type": "stored", "mode": "0999"}]}`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Unable to write config file: %v", err)
+			}
+
+			hdlrs, err := LoadDeclarativeHandlers(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadDeclarativeHandlers() error = %v, wantErr %v",
+					err, tt.wantErr)
+			}
+			if len(hdlrs) != tt.want {
+				t.Errorf("LoadDeclarativeHandlers() got %d handlers, want %d",
+					len(hdlrs), tt.want)
+			}
+		})
+	}
+}
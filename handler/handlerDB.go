@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/sirupsen/logrus"
@@ -40,18 +41,38 @@ var DefaultHandlers = []domain.HandlerIface{
 	implementations.PassThrough_Handler,                    // *
 	implementations.Root_Handler,                           // /
 	implementations.Proc_Handler,                           // /proc
+	implementations.ProcNet_Handler,                        // /proc/net
 	implementations.ProcSys_Handler,                        // /proc/sys/
+	implementations.ProcSysAbi_Handler,                     // /proc/sys/abi
+	implementations.ProcSysDebug_Handler,                   // /proc/sys/debug
 	implementations.ProcSysFs_Handler,                      // /proc/sys/fs
 	implementations.ProcSysKernel_Handler,                  // /proc/sys/kernel
+	implementations.ProcSysKernelKeys_Handler,              // /proc/sys/kernel/keys
 	implementations.ProcSysKernelYama_Handler,              // /proc/sys/kernel/yama
+	implementations.ProcSysNetBridge_Handler,               // /proc/sys/net/bridge
 	implementations.ProcSysNetCore_Handler,                 // /proc/sys/net/core
+	implementations.ProcSysNetIpv4_Handler,                 // /proc/sys/net/ipv4
+	implementations.ProcSysNetIpv4Conf_Handler,             // /proc/sys/net/ipv4/conf
 	implementations.ProcSysNetIpv4Vs_Handler,               // /proc/sys/net/ipv4/vs
 	implementations.ProcSysNetIpv4Neigh_Handler,            // /proc/sys/net/ipv4/neigh
+	implementations.ProcSysNetIpv6_Handler,                 // /proc/sys/net/ipv6
 	implementations.ProcSysNetNetfilter_Handler,            // /proc/sys/net/netfilter
 	implementations.ProcSysNetUnix_Handler,                 // /proc/sys/net/unix
 	implementations.ProcSysVm_Handler,                      // /proc/sys/vm
+	implementations.SysBlock_Handler,                       // /sys/block
+	implementations.SysClassNet_Handler,                    // /sys/class/net
+	implementations.SysDevicesSystemCpu_Handler,            // /sys/devices/system/cpu
+	implementations.SysDevicesSystemNode_Handler,           // /sys/devices/system/node
 	implementations.SysDevicesVirtualDmiId_Handler,         // /sys/devices/virtual/dmi/id
+	implementations.SysFirmware_Handler,                    // /sys/firmware
+	implementations.SysFsCgroup_Handler,                    // /sys/fs/cgroup
+	implementations.SysFsSelinux_Handler,                   // /sys/fs/selinux
+	implementations.SysKernelMmHugepages_Handler,           // /sys/kernel/mm/hugepages
+	implementations.SysKernelMmTransparentHugepage_Handler, // /sys/kernel/mm/transparent_hugepage
+	implementations.SysKernelSecurity_Handler,              // /sys/kernel/security
+	implementations.SysModule_Handler,                      // /sys/module
 	implementations.SysModuleNfconntrackParameters_Handler, // /sys/module/nf_conntrack/parameters
+	implementations.SysPower_Handler,                       // /sys/power
 }
 
 type handlerService struct {
@@ -83,6 +104,13 @@ type handlerService struct {
 	// Handler i/o errors should be obviated if this flag is enabled (testing
 	// purposes).
 	ignoreErrors bool
+
+	// Deployment-wide attribute overrides of the emulated resources (optional).
+	attrCfg *domain.EmuResourceAttrConfig
+
+	// Handlers disabled at runtime, indexed by handler path and container ID
+	// (empty for all sys containers).
+	cntrDisabled map[string]map[string]struct{}
 }
 
 // HandlerService constructor.
@@ -97,13 +125,16 @@ func (hs *handlerService) Setup(
 	css domain.ContainerStateServiceIface,
 	nss domain.NSenterServiceIface,
 	prs domain.ProcessServiceIface,
-	ios domain.IOServiceIface) {
+	ios domain.IOServiceIface,
+	attrCfg *domain.EmuResourceAttrConfig) {
 
 	hs.css = css
 	hs.nss = nss
 	hs.prs = prs
 	hs.ios = ios
 	hs.ignoreErrors = ignoreErrors
+	hs.attrCfg = attrCfg
+	hs.cntrDisabled = make(map[string]map[string]struct{})
 
 	hs.handlerTree = iradix.New()
 	if hs.handlerTree == nil {
@@ -118,6 +149,22 @@ func (hs *handlerService) Setup(
 	// Set pointer to passthrough handler.
 	hs.passThroughHandler = implementations.PassThrough_Handler
 
+	// Install the policy table of the non-emulated sysctls (if any), as well
+	// as the file-system types to expose through /proc/filesystems and the
+	// module parameters to expose under /sys/module.
+	if attrCfg != nil {
+		implementations.SetSysctlPolicies(attrCfg.Sysctls)
+		if len(attrCfg.Filesystems) > 0 {
+			implementations.SetProcFilesystems(attrCfg.Filesystems)
+		}
+		if len(attrCfg.ModuleParams) > 0 {
+			implementations.SetModuleParamPolicies(attrCfg.ModuleParams)
+		}
+		if err := hs.SetDisabledHandlers(attrCfg.DisabledHandlers); err != nil {
+			logrus.Fatalf("Unable to disable handlers: %v", err)
+		}
+	}
+
 	// Obtain user-ns inode corresponding to sysbox-fs.
 	hostUserNsInode, err := hs.FindUserNsInode(uint32(os.Getpid()))
 	if err != nil {
@@ -140,6 +187,12 @@ func (hs *handlerService) RegisterHandler(h domain.HandlerIface) error {
 
 	h.SetService(hs)
 
+	// Enforce the operator-defined attributes (if any) before the handler's
+	// resources are exposed.
+	hs.applyEmuResourceAttrs(h)
+	hs.applyReadCacheRules(h)
+	hs.applyFuseCacheRules(h)
+
 	tree, _, ok := hs.handlerTree.Insert([]byte(path), h)
 	if ok {
 		hs.Unlock()
@@ -178,24 +231,43 @@ func (hs *handlerService) LookupHandler(
 
 	var h domain.HandlerIface
 
-	// Iterate the handler's radix-tree looking for the handler that better
-	// match the fs node being searched.
-	//
-	// Notice that this approach could potentially lead to overlapping scenarios
-	// if we were to have handlers such as "/proc/update" and "/proc/update_1",
-	// but there's no such a case today. If we ever need to address this point,
-	// we would simply extend this handler-lookup logic by placing it in a "for"
-	// loop and by comparing the "base" components of the overlapping elements.
-	_, node, ok := hs.handlerTree.Root().LongestPrefix([]byte(i.Path()))
-	if !ok {
+	path := i.Path()
+
+	// Walk the handler's radix-tree along the fs node's path, and pick the
+	// deepest handler matching it at a path-component boundary. This prevents
+	// handlers such as "/proc/sys/kernel" from capturing unrelated nodes such
+	// as "/proc/sys/kernel_foo", and lets nested handlers (e.g.
+	// "/proc/sys/net/ipv4/neigh") take precedence over their parents with no
+	// extra dispatching logic.
+	hs.handlerTree.Root().WalkPath([]byte(path), func(k []byte, v interface{}) bool {
+		if pathPrefixMatch(path, string(k)) {
+			h = v.(domain.HandlerIface)
+		}
+		return false
+	})
+
+	if h == nil {
 		return nil, false
 	}
 
-	h = node.(domain.HandlerIface)
-
 	return h, true
 }
 
+// Returns 'true' if the handler 'prefix' applies to 'path', that is, if both
+// match or 'prefix' covers a parent directory of 'path'.
+func pathPrefixMatch(path, prefix string) bool {
+
+	if len(prefix) > len(path) || path[:len(prefix)] != prefix {
+		return false
+	}
+
+	if len(path) == len(prefix) || prefix[len(prefix)-1] == '/' {
+		return true
+	}
+
+	return path[len(prefix)] == '/'
+}
+
 func (hs *handlerService) FindHandler(s string) (domain.HandlerIface, bool) {
 	hs.RLock()
 	defer hs.RUnlock()
@@ -208,34 +280,213 @@ func (hs *handlerService) FindHandler(s string) (domain.HandlerIface, bool) {
 	return h.(domain.HandlerIface), true
 }
 
-func (hs *handlerService) EnableHandler(path string) error {
+// LookupContainerHandler method returns the handler to serve the given node on
+// behalf of the given sys container. Requests targeting handlers disabled at
+// runtime (either globally or for this specific container), or overridden by
+// the container as "passthrough", are served by the passthrough handler. Nodes
+// for which the container defines a fixed value are served by an ad-hoc
+// declarative handler.
+func (hs *handlerService) LookupContainerHandler(
+	i domain.IOnodeIface,
+	c domain.ContainerIface) (domain.HandlerIface, bool) {
+
+	h, ok := hs.LookupHandler(i)
+	if !ok {
+		return nil, false
+	}
+
+	if !h.GetEnabled() {
+		return hs.passThroughHandler, true
+	}
+
+	hs.RLock()
+	cntrs := hs.cntrDisabled[h.GetPath()]
+	_, disabled := cntrs[""]
+	if !disabled && c != nil {
+		_, disabled = cntrs[c.ID()]
+	}
+	hs.RUnlock()
+
+	if disabled {
+		return hs.passThroughHandler, true
+	}
+
+	if c == nil {
+		return h, true
+	}
+
+	// Enforce the container's handler overrides (if any).
+	if ov, ok := c.HandlerOverride(h.GetPath()); ok {
+		if ov.Passthrough {
+			return hs.passThroughHandler, true
+		}
+
+		relPath, err := filepath.Rel(h.GetPath(), i.Path())
+		if err != nil {
+			return h, true
+		}
+		if val, ok := ov.Values[relPath]; ok {
+			fh := implementations.NewDeclarativeHandler(
+				&domain.DeclarativeNode{
+					Path:  i.Path(),
+					Type:  domain.FixedNode,
+					Value: val,
+				},
+				os.FileMode(0444),
+			)
+			fh.SetService(hs)
+
+			return fh, true
+		}
+	}
+
+	return h, true
+}
+
+// EnableHandler method re-enables the handler associated to the given path,
+// either for all sys containers (empty cntrId) or for the given one. Notice
+// that a handler disabled globally stays disabled regardless of any
+// per-container setting.
+func (hs *handlerService) EnableHandler(path string, cntrId string) error {
 	hs.Lock()
 	defer hs.Unlock()
 
-	h, ok := hs.FindHandler(path)
-	if !ok {
+	if _, ok := hs.handlerTree.Get([]byte(path)); !ok {
 		return fmt.Errorf("handler %s not found in handlerDB", path)
 	}
 
-	h.SetEnabled(true)
+	if cntrs, ok := hs.cntrDisabled[path]; ok {
+		delete(cntrs, cntrId)
+		if len(cntrs) == 0 {
+			delete(hs.cntrDisabled, path)
+		}
+	}
+
+	if cntrId == "" {
+		logrus.Infof("Handler %s enabled", path)
+	} else {
+		logrus.Infof("Handler %s enabled for container %s", path, cntrId)
+	}
 
 	return nil
 }
 
-func (hs *handlerService) DisableHandler(path string) error {
+// DisableHandler method disables the handler associated to the given path,
+// either for all sys containers (empty cntrId) or for the given one. Accesses
+// to the nodes of a disabled handler are served by the passthrough handler.
+func (hs *handlerService) DisableHandler(path string, cntrId string) error {
 	hs.Lock()
 	defer hs.Unlock()
 
-	h, ok := hs.FindHandler(path)
-	if !ok {
+	if _, ok := hs.handlerTree.Get([]byte(path)); !ok {
 		return fmt.Errorf("handler %s not found in handlerDB", path)
 	}
 
-	h.SetEnabled(false)
+	cntrs, ok := hs.cntrDisabled[path]
+	if !ok {
+		cntrs = make(map[string]struct{})
+		hs.cntrDisabled[path] = cntrs
+	}
+	cntrs[cntrId] = struct{}{}
+
+	if cntrId == "" {
+		logrus.Infof("Handler %s disabled", path)
+	} else {
+		logrus.Infof("Handler %s disabled for container %s", path, cntrId)
+	}
 
 	return nil
 }
 
+// SetDisabledHandlers method brings the handlers disabled at runtime in line
+// with the given rules (see domain.DisabledHandlerRule): handlers not covered
+// by them are re-enabled. Rules are checked upfront, so none is applied if any
+// of them refers to an unknown handler.
+func (hs *handlerService) SetDisabledHandlers(rules []domain.DisabledHandlerRule) error {
+
+	want := make(map[string]map[string]struct{})
+
+	for _, rule := range rules {
+		if _, ok := hs.FindHandler(rule.Path); !ok {
+			return fmt.Errorf("handler %s not found in handlerDB", rule.Path)
+		}
+
+		if _, ok := want[rule.Path]; !ok {
+			want[rule.Path] = make(map[string]struct{})
+		}
+		if len(rule.Containers) == 0 {
+			want[rule.Path][""] = struct{}{}
+		}
+		for _, id := range rule.Containers {
+			want[rule.Path][id] = struct{}{}
+		}
+	}
+
+	hs.RLock()
+	have := make(map[string][]string)
+	for path, cntrs := range hs.cntrDisabled {
+		for id := range cntrs {
+			have[path] = append(have[path], id)
+		}
+	}
+	hs.RUnlock()
+
+	for path, ids := range have {
+		for _, id := range ids {
+			if _, ok := want[path][id]; ok {
+				delete(want[path], id)
+				continue
+			}
+			if err := hs.EnableHandler(path, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	for path, cntrs := range want {
+		for id := range cntrs {
+			if err := hs.DisableHandler(path, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reconciles the host value of the emulated resources shared across sys
+// containers, once the given one is about to go away.
+func (hs *handlerService) ReconcileEmuResources(c domain.ContainerIface) {
+	implementations.ReconcilePooledResources(c)
+
+	// Drop the runtime settings of the departing container too.
+	hs.Lock()
+	for path, cntrs := range hs.cntrDisabled {
+		delete(cntrs, c.ID())
+		if len(cntrs) == 0 {
+			delete(hs.cntrDisabled, path)
+		}
+	}
+	hs.Unlock()
+}
+
+// Drops the content cached on behalf of the given container by all handlers,
+// as it may no longer be accurate (e.g. after cgroup changes).
+func (hs *handlerService) InvalidateReadCaches(c domain.ContainerIface) {
+
+	hs.RLock()
+	defer hs.RUnlock()
+
+	hs.handlerTree.Root().Walk(func(key []byte, val interface{}) bool {
+		if rch, ok := val.(domain.ReadCacheHolderIface); ok {
+			if cache := rch.GetReadCache(); cache != nil {
+				cache.InvalidateContainer(c.ID())
+			}
+		}
+		return false
+	})
+}
+
 func (hs *handlerService) HandlersResourcesList() []string {
 
 	var resourcesList []string
@@ -250,9 +501,10 @@ func (hs *handlerService) HandlersResourcesList() []string {
 	// emulated.
 	hs.handlerTree.Root().Walk(func(key []byte, val interface{}) bool {
 
+		// Notice that returning 'true' would terminate the walk.
 		h := val.(domain.HandlerIface)
 		if !h.GetEnabled() {
-			return true
+			return false
 		}
 
 		list := h.GetResourcesList()
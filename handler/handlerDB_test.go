@@ -0,0 +1,274 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/mocks"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_pathPrefixMatch(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		path   string
+		prefix string
+		want   bool
+	}{
+		// Exact match.
+		{"1", "/proc/sys/kernel", "/proc/sys/kernel", true},
+
+		// Node placed under the handler's path.
+		{"2", "/proc/sys/net/ipv4/neigh/lo", "/proc/sys/net/ipv4", true},
+
+		// Partial path-component matches must be discarded.
+		{"3", "/proc/sys/kernel_foo", "/proc/sys/kernel", false},
+
+		// Handler paths ending in '/' cover their whole subtree.
+		{"4", "/proc/sys/vm", "/proc/sys/", true},
+		{"5", "/proc/sys", "/proc/sys/", false},
+
+		// Root handler.
+		{"6", "/proc/uptime", "/", true},
+
+		// Unrelated paths.
+		{"7", "/sys/kernel", "/proc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pathPrefixMatch(tt.path, tt.prefix); got != tt.want {
+				t.Errorf("pathPrefixMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestHandler(path string, enabled bool, resources ...string) domain.HandlerIface {
+
+	rm := make(map[string]*domain.EmuResource)
+	for _, r := range resources {
+		rm[r] = &domain.EmuResource{Kind: domain.FileEmuResource, Enabled: true}
+	}
+
+	return &implementations.ProcSys{
+		domain.HandlerBase{
+			Name:           path,
+			Path:           path,
+			Enabled:        enabled,
+			EmuResourceMap: rm,
+		},
+	}
+}
+
+func newTestHandlerService(hdlrs ...domain.HandlerIface) *handlerService {
+
+	hs := &handlerService{
+		handlerTree:        iradix.New(),
+		passThroughHandler: implementations.PassThrough_Handler,
+		cntrDisabled:       make(map[string]map[string]struct{}),
+	}
+
+	for _, h := range hdlrs {
+		hs.RegisterHandler(h)
+	}
+
+	return hs
+}
+
+func TestHandlersResourcesList(t *testing.T) {
+
+	hs := newTestHandlerService(
+		newTestHandler("/proc/a", true, "x"),
+		newTestHandler("/proc/b", false, "y"),
+		newTestHandler("/proc/c", true, "z"),
+	)
+
+	// Disabled handlers are skipped, without cutting the walk short.
+	got := hs.HandlersResourcesList()
+	sort.Strings(got)
+
+	want := []string{"/proc/a/x", "/proc/c/z"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HandlersResourcesList() = %v, want %v", got, want)
+	}
+}
+
+func TestLookupContainerHandler(t *testing.T) {
+
+	h := newTestHandler("/proc/sys/kernel", true, "pid_max")
+
+	n := &mocks.IOnodeIface{}
+	n.On("Path").Return("/proc/sys/kernel/pid_max")
+
+	c1 := &mocks.ContainerIface{}
+	c1.On("ID").Return("c1")
+	c1.On("HandlerOverride", mock.Anything).Return(nil, false)
+	c2 := &mocks.ContainerIface{}
+	c2.On("ID").Return("c2")
+	c2.On("HandlerOverride", mock.Anything).Return(nil, false)
+
+	tests := []struct {
+		name    string
+		prepare func(hs *handlerService) error
+		want1   domain.HandlerIface
+		want2   domain.HandlerIface
+	}{
+		// Enabled handler serves all containers.
+		{"1", func(hs *handlerService) error {
+			return nil
+		}, h, h},
+
+		// Handler disabled for one container only.
+		{"2", func(hs *handlerService) error {
+			return hs.DisableHandler("/proc/sys/kernel", "c1")
+		}, implementations.PassThrough_Handler, h},
+
+		// Handler disabled and re-enabled for one container.
+		{"3", func(hs *handlerService) error {
+			if err := hs.DisableHandler("/proc/sys/kernel", "c1"); err != nil {
+				return err
+			}
+			return hs.EnableHandler("/proc/sys/kernel", "c1")
+		}, h, h},
+
+		// Handler disabled globally.
+		{"4", func(hs *handlerService) error {
+			return hs.DisableHandler("/proc/sys/kernel", "")
+		}, implementations.PassThrough_Handler, implementations.PassThrough_Handler},
+
+		// Handlers disabled globally stay so despite per-container settings.
+		{"5", func(hs *handlerService) error {
+			if err := hs.DisableHandler("/proc/sys/kernel", ""); err != nil {
+				return err
+			}
+			return hs.EnableHandler("/proc/sys/kernel", "c1")
+		}, implementations.PassThrough_Handler, implementations.PassThrough_Handler},
+
+		// Settings of departed containers are dropped.
+		{"6", func(hs *handlerService) error {
+			if err := hs.DisableHandler("/proc/sys/kernel", "c1"); err != nil {
+				return err
+			}
+			hs.ReconcileEmuResources(c1)
+			return nil
+		}, h, h},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.SetEnabled(true)
+			hs := newTestHandlerService(h)
+
+			if err := tt.prepare(hs); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got, _ := hs.LookupContainerHandler(n, c1); got != tt.want1 {
+				t.Errorf("LookupContainerHandler(c1) = %v, want %v",
+					got.GetName(), tt.want1.GetName())
+			}
+			if got, _ := hs.LookupContainerHandler(n, c2); got != tt.want2 {
+				t.Errorf("LookupContainerHandler(c2) = %v, want %v",
+					got.GetName(), tt.want2.GetName())
+			}
+		})
+	}
+
+	// Unknown handlers can't be disabled.
+	hs := newTestHandlerService(h)
+	if err := hs.DisableHandler("/proc/foo", ""); err == nil {
+		t.Errorf("DisableHandler() of unknown handler succeeded")
+	}
+}
+
+func TestSetDisabledHandlers(t *testing.T) {
+
+	h1 := newTestHandler("/proc/sys/kernel", true, "pid_max")
+	h2 := newTestHandler("/proc/sys/vm", true, "max_map_count")
+	hs := newTestHandlerService(h1, h2)
+
+	n1 := &mocks.IOnodeIface{}
+	n1.On("Path").Return("/proc/sys/kernel/pid_max")
+	n2 := &mocks.IOnodeIface{}
+	n2.On("Path").Return("/proc/sys/vm/max_map_count")
+
+	c1 := &mocks.ContainerIface{}
+	c1.On("ID").Return("c1")
+	c1.On("HandlerOverride", mock.Anything).Return(nil, false)
+	c2 := &mocks.ContainerIface{}
+	c2.On("ID").Return("c2")
+	c2.On("HandlerOverride", mock.Anything).Return(nil, false)
+
+	pt := implementations.PassThrough_Handler
+
+	tests := []struct {
+		name  string
+		rules []domain.DisabledHandlerRule
+		want  [][]domain.HandlerIface // per node, per container
+	}{
+		// Handler disabled globally, another one for one container only.
+		{"1", []domain.DisabledHandlerRule{
+			{Path: "/proc/sys/kernel"},
+			{Path: "/proc/sys/vm", Containers: []string{"c2"}},
+		}, [][]domain.HandlerIface{{pt, pt}, {h2, pt}}},
+
+		// Handlers left out of the rules are re-enabled.
+		{"2", []domain.DisabledHandlerRule{
+			{Path: "/proc/sys/vm", Containers: []string{"c1"}},
+		}, [][]domain.HandlerIface{{h1, h1}, {pt, h2}}},
+
+		{"3", nil, [][]domain.HandlerIface{{h1, h1}, {h2, h2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := hs.SetDisabledHandlers(tt.rules); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for i, n := range []*mocks.IOnodeIface{n1, n2} {
+				for j, c := range []*mocks.ContainerIface{c1, c2} {
+					if got, _ := hs.LookupContainerHandler(n, c); got != tt.want[i][j] {
+						t.Errorf("LookupContainerHandler(%s, %s) = %v, want %v",
+							n.Path(), c.ID(), got.GetName(), tt.want[i][j].GetName())
+					}
+				}
+			}
+		})
+	}
+
+	// Rules with unknown handlers are dismissed as a whole.
+	err := hs.SetDisabledHandlers([]domain.DisabledHandlerRule{
+		{Path: "/proc/sys/kernel"},
+		{Path: "/proc/foo"},
+	})
+	if err == nil {
+		t.Errorf("SetDisabledHandlers() with unknown handler succeeded")
+	}
+	if got, _ := hs.LookupContainerHandler(n1, c1); got != h1 {
+		t.Errorf("SetDisabledHandlers() partially applied")
+	}
+}
@@ -0,0 +1,580 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+// Clock ticks per second in which the kernel reports cpu times to user-space
+// (USER_HZ).
+const userHz = 100
+
+// Returns the paths of the cgroup nodes of the given process, indexed by
+// controller name. Under cgroup v2 all the controllers share the same entry,
+// indexed as "".
+func cgroupPaths(ios domain.IOServiceIface, pid uint32) (map[string]string, error) {
+
+	cgNode := ios.NewIOnode(
+		"cgroup",
+		filepath.Join("/proc", strconv.FormatUint(uint64(pid), 10), "cgroup"),
+		0)
+	content, err := cgNode.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		// Entries are in "hierarchy-id:controllers:cgroup-path" format.
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		if fields[0] == "0" {
+			paths[""] = filepath.Join(cgroupV2Root, fields[2])
+			continue
+		}
+
+		// Named hierarchies (e.g. "name=systemd") are mounted after their name.
+		for _, ctrl := range strings.Split(fields[1], ",") {
+			paths[ctrl] = filepath.Join(
+				cgroupV2Root, strings.TrimPrefix(ctrl, "name="), fields[2])
+		}
+	}
+
+	return paths, nil
+}
+
+// cgroupFile function returns the path of a cgroup file out of the paths
+// returned by cgroupPaths(): the v1 file if the given controller is attached
+// to a v1 hierarchy, or the v2 one otherwise. The hierarchy mode is thereby
+// detected per process, which also covers hybrid hosts (i.e. v1 controllers
+// plus an unified hierarchy).
+func cgroupFile(
+	paths map[string]string,
+	ctrl string,
+	v1File string,
+	v2File string) (path string, v1 bool, ok bool) {
+
+	if path, ok := paths[ctrl]; ok {
+		return filepath.Join(path, v1File), true, true
+	}
+
+	if path, ok := paths[""]; ok {
+		return filepath.Join(path, v2File), false, true
+	}
+
+	return "", false, false
+}
+
+// readCgroupUint function reads a cgroup file holding a single integer.
+func readCgroupUint(ios domain.IOServiceIface, path string) (uint64, error) {
+
+	content, err := ios.NewIOnode(filepath.Base(path), path, 0).ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// readCgroupLimit function reads a cgroup file holding a limit. Zero stands
+// for "no limit", which is reported as "max" in cgroup v2, and as a
+// page-aligned LONG_MAX in cgroup v1.
+func readCgroupLimit(ios domain.IOServiceIface, path string) (uint64, error) {
+
+	content, err := ios.NewIOnode(filepath.Base(path), path, 0).ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	val := strings.TrimSpace(string(content))
+	if val == "max" {
+		return 0, nil
+	}
+
+	limit, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if limit >= uint64(MaxInt)&^0xfff {
+		return 0, nil
+	}
+
+	return limit, nil
+}
+
+// readCgroupStats function reads a flat-keyed cgroup file (e.g. memory.stat,
+// cpu.stat), made of "key value" lines.
+func readCgroupStats(
+	ios domain.IOServiceIface,
+	path string) (map[string]uint64, error) {
+
+	content, err := ios.NewIOnode(filepath.Base(path), path, 0).ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]uint64)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if val, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			stats[fields[0]] = val
+		}
+	}
+
+	return stats, nil
+}
+
+// containerCpus function returns the (sorted) ids of the cpus available to the
+// given process as per its cpuset cgroup, be it in a cgroup v1 or v2 hierarchy.
+func containerCpus(ios domain.IOServiceIface, pid uint32) ([]int, error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	cpusPath, _, ok := cgroupFile(
+		paths, "cpuset", "cpuset.effective_cpus", "cpuset.cpus.effective")
+	if !ok {
+		return nil, fmt.Errorf("cpuset cgroup of process %d not found", pid)
+	}
+
+	cpusNode := ios.NewIOnode(filepath.Base(cpusPath), cpusPath, 0)
+	cpus, err := cpusNode.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCpuList(strings.TrimSpace(string(cpus)))
+}
+
+// containerMems function returns the (sorted) ids of the memory nodes available
+// to the given process as per its cpuset cgroup.
+func containerMems(ios domain.IOServiceIface, pid uint32) ([]int, error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	memsPath, _, ok := cgroupFile(
+		paths, "cpuset", "cpuset.effective_mems", "cpuset.mems.effective")
+	if !ok {
+		return nil, fmt.Errorf("cpuset cgroup of process %d not found", pid)
+	}
+
+	mems, err := ios.NewIOnode(filepath.Base(memsPath), memsPath, 0).ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCpuList(strings.TrimSpace(string(mems)))
+}
+
+// parseCpuList function parses a cpu list in the kernel's "0-3,6,8-9" format
+// (also utilized for memory-node lists).
+func parseCpuList(list string) ([]int, error) {
+
+	var cpus []int
+
+	if list == "" {
+		return cpus, nil
+	}
+
+	for _, chunk := range strings.Split(list, ",") {
+		bounds := strings.SplitN(chunk, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q", list)
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("invalid cpu list %q", list)
+			}
+		}
+
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	sort.Ints(cpus)
+
+	return cpus, nil
+}
+
+// containerMemory function returns the memory limit and usage (in bytes) of
+// the given process as per its memory cgroup. A zero limit stands for "no
+// limit".
+func containerMemory(
+	ios domain.IOServiceIface,
+	pid uint32) (limit uint64, usage uint64, err error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	limitPath, _, ok := cgroupFile(
+		paths, "memory", "memory.limit_in_bytes", "memory.max")
+	if !ok {
+		return 0, 0, fmt.Errorf("memory cgroup of process %d not found", pid)
+	}
+
+	usagePath, _, _ := cgroupFile(
+		paths, "memory", "memory.usage_in_bytes", "memory.current")
+
+	if limit, err = readCgroupLimit(ios, limitPath); err != nil {
+		return 0, 0, err
+	}
+
+	if usage, err = readCgroupUint(ios, usagePath); err != nil {
+		return 0, 0, err
+	}
+
+	return limit, usage, nil
+}
+
+// containerHugetlb function returns the limit and usage (in bytes) of the
+// hugepages of the given size (in kB) as per the hugetlb cgroup of the given
+// process. A zero limit stands for "no limit", which is also the case of the
+// hierarchies where the hugetlb controller is not enabled.
+func containerHugetlb(
+	ios domain.IOServiceIface,
+	pid uint32,
+	sizeKB uint64) (limit uint64, usage uint64, err error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	prefix := "hugetlb." + hugetlbSizeName(sizeKB)
+
+	limitPath, _, ok := cgroupFile(
+		paths, "hugetlb", prefix+".limit_in_bytes", prefix+".max")
+	if !ok {
+		return 0, 0, nil
+	}
+
+	usagePath, _, _ := cgroupFile(
+		paths, "hugetlb", prefix+".usage_in_bytes", prefix+".current")
+
+	if limit, err = readCgroupLimit(ios, limitPath); err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+
+	if usage, err = readCgroupUint(ios, usagePath); err != nil {
+		return 0, 0, err
+	}
+
+	return limit, usage, nil
+}
+
+// hugetlbSizeName function returns the name given by the hugetlb controller
+// to the hugepages of the given size (in kB), e.g. "2MB" or "1GB".
+func hugetlbSizeName(sizeKB uint64) string {
+
+	switch {
+	case sizeKB%(1<<20) == 0:
+		return strconv.FormatUint(sizeKB>>20, 10) + "GB"
+	case sizeKB%(1<<10) == 0:
+		return strconv.FormatUint(sizeKB>>10, 10) + "MB"
+	}
+
+	return strconv.FormatUint(sizeKB, 10) + "KB"
+}
+
+// containerSwap function returns the swap limit and usage (in bytes) of the
+// given process as per its memory cgroup. A zero limit stands for "no limit",
+// which is also the case of the hosts lacking swap accounting. Cgroup v1 only
+// accounts memory+swap, so swap figures are obtained out of the difference
+// with the memory ones.
+func containerSwap(
+	ios domain.IOServiceIface,
+	pid uint32) (limit uint64, usage uint64, err error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	limitPath, v1, ok := cgroupFile(
+		paths, "memory", "memory.memsw.limit_in_bytes", "memory.swap.max")
+	if !ok {
+		return 0, 0, fmt.Errorf("memory cgroup of process %d not found", pid)
+	}
+
+	usagePath, _, _ := cgroupFile(
+		paths, "memory", "memory.memsw.usage_in_bytes", "memory.swap.current")
+
+	if limit, err = readCgroupLimit(ios, limitPath); err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+
+	if usage, err = readCgroupUint(ios, usagePath); err != nil {
+		return 0, 0, err
+	}
+
+	if v1 {
+		memLimit, memUsage, err := containerMemory(ios, pid)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if limit > memLimit && memLimit != 0 {
+			limit -= memLimit
+		} else {
+			limit = 0
+		}
+
+		if usage > memUsage {
+			usage -= memUsage
+		} else {
+			usage = 0
+		}
+	}
+
+	return limit, usage, nil
+}
+
+// containerPageCache function returns the amount of page cache (in bytes)
+// charged to the memory cgroup of the given process.
+func containerPageCache(ios domain.IOServiceIface, pid uint32) (uint64, error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return 0, err
+	}
+
+	statPath, v1, ok := cgroupFile(paths, "memory", "memory.stat", "memory.stat")
+	if !ok {
+		return 0, fmt.Errorf("memory cgroup of process %d not found", pid)
+	}
+
+	stats, err := readCgroupStats(ios, statPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// The v1 "total_" counters include the descendant cgroups, as the v2 ones.
+	if v1 {
+		return stats["total_cache"], nil
+	}
+
+	return stats["file"], nil
+}
+
+// containerCpuUsage function returns the cpu time consumed in user and system
+// mode by the cpu cgroup of the given process.
+func containerCpuUsage(
+	ios domain.IOServiceIface,
+	pid uint32) (user time.Duration, system time.Duration, err error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	statPath, v1, ok := cgroupFile(paths, "cpuacct", "cpuacct.stat", "cpu.stat")
+	if !ok {
+		return 0, 0, fmt.Errorf("cpuacct cgroup of process %d not found", pid)
+	}
+
+	stats, err := readCgroupStats(ios, statPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Cgroup v1 reports usage in USER_HZ ticks, and v2 in microseconds.
+	if v1 {
+		return time.Duration(stats["user"]) * time.Second / userHz,
+			time.Duration(stats["system"]) * time.Second / userHz,
+			nil
+	}
+
+	return time.Duration(stats["user_usec"]) * time.Microsecond,
+		time.Duration(stats["system_usec"]) * time.Microsecond,
+		nil
+}
+
+// ioStats holds the I/O counters of a block device, as accounted by the io
+// (v2) or blkio (v1) controllers.
+type ioStats struct {
+	rbytes uint64
+	wbytes uint64
+	rios   uint64
+	wios   uint64
+}
+
+// containerIoStats function returns the I/O counters of the io cgroup of the
+// given process, indexed by "major:minor" device id. A nil map is returned for
+// the hierarchies where the io controller is not enabled.
+func containerIoStats(
+	ios domain.IOServiceIface,
+	pid uint32) (map[string]*ioStats, error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]*ioStats)
+
+	devStats := func(dev string) *ioStats {
+		if _, ok := stats[dev]; !ok {
+			stats[dev] = &ioStats{}
+		}
+		return stats[dev]
+	}
+
+	bytesPath, v1, ok := cgroupFile(
+		paths, "blkio", "blkio.throttle.io_service_bytes", "io.stat")
+	if !ok {
+		return nil, nil
+	}
+
+	content, err := ios.NewIOnode(filepath.Base(bytesPath), bytesPath, 0).ReadFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !v1 {
+		// Entries are in "major:minor key=value..." format.
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+
+			s := devStats(fields[0])
+
+			for _, field := range fields[1:] {
+				kv := strings.SplitN(field, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				val, err := strconv.ParseUint(kv[1], 10, 64)
+				if err != nil {
+					continue
+				}
+
+				switch kv[0] {
+				case "rbytes":
+					s.rbytes = val
+				case "wbytes":
+					s.wbytes = val
+				case "rios":
+					s.rios = val
+				case "wios":
+					s.wios = val
+				}
+			}
+		}
+
+		return stats, nil
+	}
+
+	iosPath, _, _ := cgroupFile(paths, "blkio", "blkio.throttle.io_serviced", "")
+
+	iosContent, err := ios.NewIOnode(filepath.Base(iosPath), iosPath, 0).ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries are in "major:minor operation value" format, followed by a
+	// "Total value" line.
+	for i, data := range [][]byte{content, iosContent} {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 3 {
+				continue
+			}
+			val, err := strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			s := devStats(fields[0])
+
+			switch {
+			case fields[1] == "Read" && i == 0:
+				s.rbytes = val
+			case fields[1] == "Write" && i == 0:
+				s.wbytes = val
+			case fields[1] == "Read":
+				s.rios = val
+			case fields[1] == "Write":
+				s.wios = val
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// containerPids function returns the number of tasks in the pids cgroup of the
+// given process.
+func containerPids(ios domain.IOServiceIface, pid uint32) (uint64, error) {
+
+	paths, err := cgroupPaths(ios, pid)
+	if err != nil {
+		return 0, err
+	}
+
+	currentPath, _, ok := cgroupFile(paths, "pids", "pids.current", "pids.current")
+	if !ok {
+		return 0, fmt.Errorf("pids cgroup of process %d not found", pid)
+	}
+
+	return readCgroupUint(ios, currentPath)
+}
@@ -0,0 +1,280 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/sysio"
+)
+
+// Returns an in-memory I/O service laid out as per 'files'.
+func newCgroupTestFs(t *testing.T, files map[string]string) domain.IOServiceIface {
+
+	ios := sysio.NewIOService(domain.IOMemFileService)
+
+	for path, content := range files {
+		n := ios.NewIOnode(filepath.Base(path), path, 0644)
+		if err := n.WriteFile([]byte(content)); err != nil {
+			t.Fatalf("Unable to write file %s: %v", path, err)
+		}
+	}
+
+	return ios
+}
+
+func TestParseCpuList(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{"1", "", nil, false},
+		{"2", "3", []int{3}, false},
+		{"3", "0-3", []int{0, 1, 2, 3}, false},
+		{"4", "8-9,0,2-3", []int{0, 2, 3, 8, 9}, false},
+
+		// Malformed lists.
+		{"5", "0-", nil, true},
+		{"6", "3-1", nil, true},
+		{"7", "a,b", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCpuList(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCpuList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCpuList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCgroupFile(t *testing.T) {
+
+	var (
+		v1 = map[string]string{
+			"memory": "/sys/fs/cgroup/memory/docker/c1",
+		}
+		v2 = map[string]string{
+			"": "/sys/fs/cgroup/docker/c1",
+		}
+		hybrid = map[string]string{
+			"memory": "/sys/fs/cgroup/memory/docker/c1",
+			"":       "/sys/fs/cgroup/unified/docker/c1",
+		}
+	)
+
+	tests := []struct {
+		name     string
+		paths    map[string]string
+		ctrl     string
+		wantPath string
+		wantV1   bool
+		wantOk   bool
+	}{
+		{"1", v1, "memory", "/sys/fs/cgroup/memory/docker/c1/memory.limit_in_bytes", true, true},
+		{"2", v2, "memory", "/sys/fs/cgroup/docker/c1/memory.max", false, true},
+
+		// Controllers lacking a v1 hierarchy fall back to the unified one.
+		{"3", hybrid, "memory", "/sys/fs/cgroup/memory/docker/c1/memory.limit_in_bytes", true, true},
+		{"4", hybrid, "pids", "/sys/fs/cgroup/unified/docker/c1/memory.max", false, true},
+
+		// Controller not available.
+		{"5", v1, "pids", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, v1, ok := cgroupFile(tt.paths, tt.ctrl, "memory.limit_in_bytes", "memory.max")
+			if path != tt.wantPath || v1 != tt.wantV1 || ok != tt.wantOk {
+				t.Errorf("cgroupFile() = (%q, %v, %v), want (%q, %v, %v)",
+					path, v1, ok, tt.wantPath, tt.wantV1, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestReadCgroupLimit(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		content string
+		want    uint64
+		wantErr bool
+	}{
+		{"1", "1073741824\n", 1073741824, false},
+
+		// No limit, as per cgroup v2 and v1.
+		{"2", "max\n", 0, false},
+		{"3", "9223372036854771712\n", 0, false},
+
+		{"4", "abc\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/sys/fs/cgroup/docker/c1/memory.max"
+			ios := newCgroupTestFs(t, map[string]string{path: tt.content})
+
+			got, err := readCgroupLimit(ios, path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readCgroupLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readCgroupLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Cgroup figures of a sys container (init pid 1001), as laid out by cgroup v1
+// and v2 hosts.
+func TestContainerCgroupStats(t *testing.T) {
+
+	type memStats struct {
+		limit, usage, swapLimit, swapUsage, cache uint64
+	}
+
+	tests := []struct {
+		name       string
+		files      map[string]string
+		wantMem    memStats
+		wantUser   time.Duration
+		wantSystem time.Duration
+		wantIo     map[string]*ioStats
+		wantPids   uint64
+	}{
+		{"v1", map[string]string{
+			"/proc/1001/cgroup": "6:pids:/docker/c1\n" +
+				"5:blkio:/docker/c1\n" +
+				"4:memory:/docker/c1\n" +
+				"3:cpu,cpuacct:/docker/c1\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.limit_in_bytes":       "1073741824\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.usage_in_bytes":       "536870912\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.memsw.limit_in_bytes": "1610612736\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.memsw.usage_in_bytes": "671088640\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.stat":                 "cache 4096\ntotal_cache 134217728\n",
+			"/sys/fs/cgroup/cpuacct/docker/c1/cpuacct.stat":               "user 200\nsystem 100\n",
+			"/sys/fs/cgroup/blkio/docker/c1/blkio.throttle.io_service_bytes": "8:1 Read 1048576\n" +
+				"8:1 Write 2097152\n" +
+				"8:1 Sync 0\n" +
+				"Total 3145728\n",
+			"/sys/fs/cgroup/blkio/docker/c1/blkio.throttle.io_serviced": "8:1 Read 10\n" +
+				"8:1 Write 20\n" +
+				"Total 30\n",
+			"/sys/fs/cgroup/pids/docker/c1/pids.current": "5\n",
+		},
+			memStats{1073741824, 536870912, 536870912, 134217728, 134217728},
+			2 * time.Second,
+			time.Second,
+			map[string]*ioStats{"8:1": {1048576, 2097152, 10, 20}},
+			5},
+
+		{"v2", map[string]string{
+			"/proc/1001/cgroup":                            "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/memory.max":          "1073741824\n",
+			"/sys/fs/cgroup/docker/c1/memory.current":      "536870912\n",
+			"/sys/fs/cgroup/docker/c1/memory.swap.max":     "536870912\n",
+			"/sys/fs/cgroup/docker/c1/memory.swap.current": "134217728\n",
+			"/sys/fs/cgroup/docker/c1/memory.stat":         "anon 402653184\nfile 134217728\n",
+			"/sys/fs/cgroup/docker/c1/cpu.stat":            "usage_usec 3000000\nuser_usec 2000000\nsystem_usec 1000000\n",
+			"/sys/fs/cgroup/docker/c1/io.stat":             "8:1 rbytes=1048576 wbytes=2097152 rios=10 wios=20 dbytes=0 dios=0\n",
+			"/sys/fs/cgroup/docker/c1/pids.current":        "5\n",
+		},
+			memStats{1073741824, 536870912, 536870912, 134217728, 134217728},
+			2 * time.Second,
+			time.Second,
+			map[string]*ioStats{"8:1": {1048576, 2097152, 10, 20}},
+			5},
+
+		// Hosts lacking swap accounting and the io controller.
+		{"v1-noswap", map[string]string{
+			"/proc/1001/cgroup": "6:pids:/docker/c1\n" +
+				"4:memory:/docker/c1\n" +
+				"3:cpu,cpuacct:/docker/c1\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.limit_in_bytes": "9223372036854771712\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.usage_in_bytes": "536870912\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.stat":           "total_cache 0\n",
+			"/sys/fs/cgroup/cpuacct/docker/c1/cpuacct.stat":         "user 0\nsystem 0\n",
+			"/sys/fs/cgroup/pids/docker/c1/pids.current":            "1\n",
+		},
+			memStats{0, 536870912, 0, 0, 0},
+			0,
+			0,
+			nil,
+			1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ios := newCgroupTestFs(t, tt.files)
+
+			var (
+				got memStats
+				err error
+			)
+
+			if got.limit, got.usage, err = containerMemory(ios, 1001); err != nil {
+				t.Fatalf("containerMemory() error = %v", err)
+			}
+			if got.swapLimit, got.swapUsage, err = containerSwap(ios, 1001); err != nil {
+				t.Fatalf("containerSwap() error = %v", err)
+			}
+			if got.cache, err = containerPageCache(ios, 1001); err != nil {
+				t.Fatalf("containerPageCache() error = %v", err)
+			}
+			if got != tt.wantMem {
+				t.Errorf("container memory stats = %+v, want %+v", got, tt.wantMem)
+			}
+
+			user, system, err := containerCpuUsage(ios, 1001)
+			if err != nil {
+				t.Fatalf("containerCpuUsage() error = %v", err)
+			}
+			if user != tt.wantUser || system != tt.wantSystem {
+				t.Errorf("containerCpuUsage() = (%v, %v), want (%v, %v)",
+					user, system, tt.wantUser, tt.wantSystem)
+			}
+
+			stats, err := containerIoStats(ios, 1001)
+			if err != nil {
+				t.Fatalf("containerIoStats() error = %v", err)
+			}
+			if !reflect.DeepEqual(stats, tt.wantIo) {
+				t.Errorf("containerIoStats() = %v, want %v", stats, tt.wantIo)
+			}
+
+			pids, err := containerPids(ios, 1001)
+			if err != nil {
+				t.Fatalf("containerPids() error = %v", err)
+			}
+			if pids != tt.wantPids {
+				t.Errorf("containerPids() = %v, want %v", pids, tt.wantPids)
+			}
+		})
+	}
+}
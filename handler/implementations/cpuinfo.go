@@ -0,0 +1,203 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+//
+// /proc/cpuinfo layouts
+//
+// The content of /proc/cpuinfo is architecture specific. All the supported
+// layouts are made of sections separated by empty lines, each one holding "key
+// : value" lines; per-cpu sections are identified by the key holding the cpu
+// id, while the rest of the sections hold system-wide attributes:
+//
+// * x86 (amd64, 386): one section per cpu ("processor", "vendor_id", "flags",
+// ...), with the "siblings" and "cpu cores" counters repeated in each one.
+//
+// * arm64 / arm: one section per cpu ("processor", "BogoMIPS", "Features", "CPU
+// implementer", ...), optionally followed by a system-wide one ("Hardware",
+// "Revision", "Serial") on older kernels.
+//
+// * ppc64le / ppc64: one section per cpu ("processor", "cpu", "clock",
+// "revision"), followed by a system-wide one ("timebase", "platform", "model",
+// "machine", "MMU").
+//
+// * s390x: a system-wide section ("vendor_id", "# processors", "features",
+// ...) that also holds one "processor N: ..." line per cpu, followed by one
+// section per cpu ("cpu number", "cpu MHz dynamic", ...).
+//
+// * riscv64: one section per cpu ("processor", "hart", "isa", "mmu", "uarch").
+//
+
+type cpuinfoLayout struct {
+	// Key holding the cpu id within per-cpu sections.
+	cpuKey string
+
+	// Prefix of the per-cpu lines within system-wide sections, if any (in
+	// "<prefix>N: ..." format).
+	cpuLinePrefix string
+
+	// Keys holding the number of cpus in the system, be them in per-cpu or
+	// system-wide sections.
+	countKeys []string
+}
+
+var cpuinfoLayouts = map[string]*cpuinfoLayout{
+	"amd64": {
+		cpuKey:    "processor",
+		countKeys: []string{"siblings", "cpu cores"},
+	},
+	"386": {
+		cpuKey:    "processor",
+		countKeys: []string{"siblings", "cpu cores"},
+	},
+	"arm64": {
+		cpuKey: "processor",
+	},
+	"arm": {
+		cpuKey: "processor",
+	},
+	"ppc64le": {
+		cpuKey: "processor",
+	},
+	"ppc64": {
+		cpuKey: "processor",
+	},
+	"s390x": {
+		cpuKey:        "cpu number",
+		cpuLinePrefix: "processor ",
+		countKeys:     []string{"# processors"},
+	},
+	"riscv64": {
+		cpuKey: "processor",
+	},
+}
+
+// Layout of the architectures not listed above.
+var defaultCpuinfoLayout = &cpuinfoLayout{cpuKey: "processor"}
+
+// FilterCpuinfo method rewrites the given /proc/cpuinfo content, laid out as
+// per the given architecture (GOARCH naming), to only display the cpus present
+// in 'cpus', which are renumbered starting from cpu 0. Cpu counters are
+// adjusted accordingly.
+func (h *Proc) FilterCpuinfo(content []byte, cpus []int, arch string) []byte {
+
+	layout, ok := cpuinfoLayouts[arch]
+	if !ok {
+		layout = defaultCpuinfoLayout
+	}
+
+	renumbering := cpuRenumbering(cpus)
+	count := strconv.Itoa(len(cpus))
+
+	var result bytes.Buffer
+
+	for _, section := range strings.Split(string(content), "\n\n") {
+		lines := strings.Split(strings.Trim(section, "\n"), "\n")
+		if len(lines) == 1 && lines[0] == "" {
+			continue
+		}
+
+		var (
+			sectionLines []string
+			dropped      bool
+		)
+
+		for _, line := range lines {
+			key, val := splitCpuinfoLine(line)
+
+			switch {
+			case key == layout.cpuKey:
+				cpu, err := strconv.Atoi(val)
+				if err != nil {
+					break
+				}
+				id, ok := renumbering[cpu]
+				if !ok {
+					dropped = true
+					break
+				}
+				line = setCpuinfoValue(line, strconv.Itoa(id))
+
+			case layout.cpuLinePrefix != "" &&
+				strings.HasPrefix(key, layout.cpuLinePrefix):
+				cpu, err := strconv.Atoi(strings.TrimPrefix(key, layout.cpuLinePrefix))
+				if err != nil {
+					break
+				}
+				id, ok := renumbering[cpu]
+				if !ok {
+					continue
+				}
+				line = layout.cpuLinePrefix + strconv.Itoa(id) +
+					line[strings.Index(line, ":"):]
+
+			case containsString(layout.countKeys, key):
+				line = setCpuinfoValue(line, count)
+			}
+
+			if dropped {
+				break
+			}
+
+			sectionLines = append(sectionLines, line)
+		}
+
+		if dropped {
+			continue
+		}
+
+		result.WriteString(strings.Join(sectionLines, "\n") + "\n\n")
+	}
+
+	return result.Bytes()
+}
+
+// splitCpuinfoLine function splits a "key : value" cpuinfo line, trimming the
+// (tab or space) padding around both parts.
+func splitCpuinfoLine(line string) (string, string) {
+
+	sep := strings.Index(line, ":")
+	if sep < 0 {
+		return strings.TrimSpace(line), ""
+	}
+
+	return strings.TrimSpace(line[:sep]), strings.TrimSpace(line[sep+1:])
+}
+
+// setCpuinfoValue function replaces the value of a "key : value" cpuinfo line,
+// keeping the key's padding.
+func setCpuinfoValue(line, val string) string {
+
+	return line[:strings.Index(line, ":")+1] + " " + val
+}
+
+func containsString(list []string, s string) bool {
+
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+
+	return false
+}
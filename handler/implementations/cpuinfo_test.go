@@ -0,0 +1,191 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"testing"
+
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+// Host cpuinfo samples (two cpus), per architecture.
+var cpuinfoSamples = map[string]string{
+	"amd64": `processor	: 0
+vendor_id	: GenuineIntel
+model name	: Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz
+siblings	: 2
+core id		: 0
+cpu cores	: 2
+flags		: fpu vme de pse tsc msr
+
+processor	: 1
+vendor_id	: GenuineIntel
+model name	: Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz
+siblings	: 2
+core id		: 1
+cpu cores	: 2
+flags		: fpu vme de pse tsc msr
+
+`,
+	"arm64": `processor	: 0
+BogoMIPS	: 50.00
+Features	: fp asimd evtstrm aes pmull sha1 sha2 crc32 atomics
+CPU implementer	: 0x41
+CPU architecture: 8
+CPU variant	: 0x3
+CPU part	: 0xd0c
+CPU revision	: 1
+
+processor	: 1
+BogoMIPS	: 50.00
+Features	: fp asimd evtstrm aes pmull sha1 sha2 crc32 atomics
+CPU implementer	: 0x41
+CPU architecture: 8
+CPU variant	: 0x3
+CPU part	: 0xd0c
+CPU revision	: 1
+
+`,
+	"ppc64le": `processor	: 0
+cpu		: POWER9 (architected), altivec supported
+clock		: 2200.000000MHz
+revision	: 2.2 (pvr 004e 1202)
+
+processor	: 1
+cpu		: POWER9 (architected), altivec supported
+clock		: 2200.000000MHz
+revision	: 2.2 (pvr 004e 1202)
+
+timebase	: 512000000
+platform	: pSeries
+model		: IBM,9009-22A
+machine		: CHRP IBM,9009-22A
+MMU		: Radix
+
+`,
+	"s390x": `vendor_id       : IBM/S390
+# processors    : 2
+bogomips per cpu: 3241.00
+features	: esan3 zarch stfle msa ldisp eimm dfp edat etf3eh highgprs te vx sie
+processor 0: version = FF,  identification = 0133E8,  machine = 2964
+processor 1: version = FF,  identification = 0133E8,  machine = 2964
+
+cpu number      : 0
+cpu MHz dynamic : 5000
+cpu MHz static  : 5000
+
+cpu number      : 1
+cpu MHz dynamic : 5000
+cpu MHz static  : 5000
+
+`,
+	"riscv64": `processor	: 0
+hart		: 0
+isa		: rv64imafdc
+mmu		: sv39
+uarch		: sifive,u74-mc
+
+processor	: 1
+hart		: 1
+isa		: rv64imafdc
+mmu		: sv39
+uarch		: sifive,u74-mc
+
+`,
+}
+
+func TestProc_FilterCpuinfo(t *testing.T) {
+
+	var h = implementations.Proc_Handler
+
+	tests := []struct {
+		name string
+		arch string
+		cpus []int
+		want string
+	}{
+		// All host cpus present: content is kept as is.
+		{"1", "amd64", []int{0, 1}, cpuinfoSamples["amd64"]},
+
+		// X86 cpu counters are adjusted to the container's cpus.
+		{"2", "amd64", []int{1}, `processor	: 0
+vendor_id	: GenuineIntel
+model name	: Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz
+siblings	: 1
+core id		: 1
+cpu cores	: 1
+flags		: fpu vme de pse tsc msr
+
+`},
+
+		{"3", "arm64", []int{1}, `processor	: 0
+BogoMIPS	: 50.00
+Features	: fp asimd evtstrm aes pmull sha1 sha2 crc32 atomics
+CPU implementer	: 0x41
+CPU architecture: 8
+CPU variant	: 0x3
+CPU part	: 0xd0c
+CPU revision	: 1
+
+`},
+
+		// System-wide sections are kept.
+		{"4", "ppc64le", []int{0}, `processor	: 0
+cpu		: POWER9 (architected), altivec supported
+clock		: 2200.000000MHz
+revision	: 2.2 (pvr 004e 1202)
+
+timebase	: 512000000
+platform	: pSeries
+model		: IBM,9009-22A
+machine		: CHRP IBM,9009-22A
+MMU		: Radix
+
+`},
+
+		// Per-cpu lines within the system-wide section are filtered too.
+		{"5", "s390x", []int{1}, `vendor_id       : IBM/S390
+# processors    : 1
+bogomips per cpu: 3241.00
+features	: esan3 zarch stfle msa ldisp eimm dfp edat etf3eh highgprs te vx sie
+processor 0: version = FF,  identification = 0133E8,  machine = 2964
+
+cpu number      : 0
+cpu MHz dynamic : 5000
+cpu MHz static  : 5000
+
+`},
+
+		// Hart ids are kept as per the host.
+		{"6", "riscv64", []int{1}, `processor	: 0
+hart		: 1
+isa		: rv64imafdc
+mmu		: sv39
+uarch		: sifive,u74-mc
+
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(h.FilterCpuinfo([]byte(cpuinfoSamples[tt.arch]), tt.cpus, tt.arch))
+			if got != tt.want {
+				t.Errorf("FilterCpuinfo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// Declarative handler
+//
+// Handler serving a single node declared through the handlers config file (see
+// domain.DeclarativeNode), so that trivial emulations don't require a dedicated
+// handler implementation. The node is emulated as follows:
+//
+// * "fixed": reads return the declared value; writes are rejected.
+//
+// * "stored": reads return the value previously written by the sys container,
+// or the declared (host) value if no write has taken place yet.
+//
+// * "clamped": same as "stored", but for integers, which are clamped to the
+// node's bounds.
+//
+// As opposed to the rest of the handlers, declarative ones are instantiated at
+// runtime, and their path refers to the emulated node itself.
+//
+
+type Declarative struct {
+	domain.HandlerBase
+
+	// Type of emulation and initial value of the node being served.
+	nodeType domain.DeclarativeNodeType
+	value    string
+}
+
+// Declarative handler constructor.
+func NewDeclarativeHandler(
+	node *domain.DeclarativeNode,
+	mode os.FileMode) *Declarative {
+
+	resource := &domain.EmuResource{
+		Kind:    domain.FileEmuResource,
+		Mode:    mode,
+		Policy:  domain.StateOnlyPolicy,
+		Format:  domain.StringFormat,
+		Bounds:  node.Bounds,
+		Enabled: true,
+	}
+
+	switch node.Type {
+	case domain.FixedNode:
+		resource.Policy = domain.ReadOnlyPolicy
+
+	case domain.ClampedNode:
+		resource.Format = domain.IntFormat
+	}
+
+	return &Declarative{
+		HandlerBase: domain.HandlerBase{
+			Name:    "Declarative",
+			Path:    node.Path,
+			Enabled: true,
+			EmuResourceMap: map[string]*domain.EmuResource{
+				filepath.Base(node.Path): resource,
+			},
+		},
+		nodeType: node.Type,
+		value:    node.Value,
+	}
+}
+
+func (h *Declarative) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if n.Path() == h.Path {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    h.EmuResourceMap[resource].Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	return h.Service.GetPassThroughHandler().Lookup(n, req)
+}
+
+func (h *Declarative) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.Path() != h.Path {
+		return h.Service.GetPassThroughHandler().Open(n, req)
+	}
+
+	if h.nodeType == domain.FixedNode && n.OpenFlags() != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *Declarative) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.Path() != h.Path {
+		return h.Service.GetPassThroughHandler().Read(n, req)
+	}
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if h.nodeType == domain.FixedNode {
+		return copyResultBuffer(req.Data, []byte(h.value+"\n"))
+	}
+
+	// Nodes lacking a declared value are initialized with the host one.
+	if h.value == "" {
+		return readFileString(h, n, req)
+	}
+
+	cntr := req.Container
+
+	cntr.Lock()
+	data, ok := cntr.Data(n.Path(), n.Name())
+	if !ok {
+		data = h.value
+	}
+	cntr.Unlock()
+
+	return copyResultBuffer(req.Data, []byte(data+"\n"))
+}
+
+func (h *Declarative) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.Path() != h.Path {
+		return h.Service.GetPassThroughHandler().Write(n, req)
+	}
+
+	switch h.nodeType {
+	case domain.FixedNode:
+		return 0, fuse.IOerror{Code: syscall.EACCES}
+
+	case domain.ClampedNode:
+		return h.writeClamped(n, req)
+	}
+
+	return writeFileString(h, n, req, false)
+}
+
+func (h *Declarative) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	return h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+}
+
+func (h *Declarative) GetName() string {
+	return h.Name
+}
+
+func (h *Declarative) GetPath() string {
+	return h.Path
+}
+
+func (h *Declarative) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *Declarative) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *Declarative) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *Declarative) GetResourcesList() []string {
+
+	var resources []string
+
+	for _, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, h.GetPath())
+	}
+
+	return resources
+}
+
+func (h *Declarative) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *Declarative) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	if n.Path() != h.Path {
+		return nil
+	}
+
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *Declarative) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+func (h *Declarative) writeClamped(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	val, err := strconv.Atoi(strings.TrimSpace(string(req.Data)))
+	if err != nil {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	if b := h.EmuResourceMap[n.Name()].Bounds; b != nil {
+		if val < b.Min {
+			val = b.Min
+		} else if val > b.Max {
+			val = b.Max
+		}
+	}
+
+	cntr := req.Container
+
+	cntr.Lock()
+	cntr.SetData(n.Path(), n.Name(), strconv.Itoa(val))
+	cntr.Unlock()
+
+	return len(req.Data), nil
+}
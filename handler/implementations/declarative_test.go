@@ -0,0 +1,124 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestDeclarative_ReadWrite(t *testing.T) {
+
+	var (
+		fixed = &domain.DeclarativeNode{
+			Path:  "/proc/sys/kernel/watchdog",
+			Type:  domain.FixedNode,
+			Value: "0",
+		}
+		stored = &domain.DeclarativeNode{
+			Path:  "/proc/sys/vm/swappiness",
+			Type:  domain.StoredNode,
+			Value: "60",
+		}
+		clamped = &domain.DeclarativeNode{
+			Path:   "/proc/sys/kernel/msgmni",
+			Type:   domain.ClampedNode,
+			Value:  "32000",
+			Bounds: &domain.EmuResourceBounds{Min: 0, Max: 32768},
+		}
+	)
+
+	tests := []struct {
+		name        string
+		node        *domain.DeclarativeNode
+		openFlags   int
+		wantOpenErr error
+		write       string // no write if empty
+		wantWrErr   error
+		wantRead    string
+	}{
+		// Fixed nodes display the declared value, and can't be written.
+		{"1", fixed, syscall.O_RDONLY, nil, "", nil, "0\n"},
+		{"2", fixed, syscall.O_WRONLY, fuse.IOerror{Code: syscall.EACCES}, "", nil, "0\n"},
+		{"3", fixed, syscall.O_RDONLY, nil, "1", fuse.IOerror{Code: syscall.EACCES}, "0\n"},
+
+		// Stored nodes display the declared value till written.
+		{"4", stored, syscall.O_RDONLY, nil, "", nil, "60\n"},
+		{"5", stored, syscall.O_RDWR, nil, "10", nil, "10\n"},
+
+		// Clamped nodes keep the written values within bounds.
+		{"6", clamped, syscall.O_RDWR, nil, "1024", nil, "1024\n"},
+		{"7", clamped, syscall.O_RDWR, nil, "65536", nil, "32768\n"},
+		{"8", clamped, syscall.O_RDWR, nil, "-1", nil, "0\n"},
+		{"9", clamped, syscall.O_RDWR, nil, "abc", fuse.IOerror{Code: syscall.EINVAL}, "32000\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := implementations.NewDeclarativeHandler(tt.node, 0644)
+			h.SetService(hds)
+
+			cntr := css.ContainerCreate(
+				"c1",
+				uint32(1001),
+				time.Time{},
+				231072,
+				65535,
+				231072,
+				65535,
+				nil,
+				nil,
+				nil,
+			)
+
+			n := ios.NewIOnode(filepath.Base(tt.node.Path), tt.node.Path, 0644)
+			n.SetOpenFlags(tt.openFlags)
+
+			if err := h.Open(n, &domain.HandlerRequest{}); err != tt.wantOpenErr {
+				t.Errorf("Declarative.Open() error = %v, wantErr %v", err, tt.wantOpenErr)
+			}
+
+			if tt.write != "" {
+				req := &domain.HandlerRequest{
+					Data:      []byte(tt.write + "\n"),
+					Container: cntr,
+				}
+				if _, err := h.Write(n, req); err != tt.wantWrErr {
+					t.Errorf("Declarative.Write() error = %v, wantErr %v", err, tt.wantWrErr)
+				}
+			}
+
+			req := &domain.HandlerRequest{
+				Data:      make([]byte, 64),
+				Container: cntr,
+			}
+			got, err := h.Read(n, req)
+			if err != nil {
+				t.Fatalf("Declarative.Read() error = %v", err)
+			}
+			if string(req.Data[:got]) != tt.wantRead {
+				t.Errorf("Declarative.Read() = %q, want %q", req.Data[:got], tt.wantRead)
+			}
+		})
+	}
+}
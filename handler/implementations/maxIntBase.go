@@ -0,0 +1,206 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// MaxIntBase handler
+//
+// Reusable handler for system-wide integer resources that can't be namespaced
+// by the kernel, and for which sysbox-fs must keep the largest value requested
+// across all sys containers (e.g. nf_conntrack_max).
+//
+// Every resource within the handler's EmuResourceMap is emulated as follows:
+//
+// * Reads return the value previously written by the sys container, or the
+// host value if no write has taken place yet.
+//
+// * Writes are stored within the container state. The value is only pushed
+// to the host kernel if it's larger than the current host value, so a sys
+// container can never reduce the setting observed by its peers.
+//
+// Handlers that need this behavior for some of their resources can embed this
+// type and hand over those resources through its Open/Read/Write methods.
+//
+
+type MaxIntBase struct {
+	domain.HandlerBase
+}
+
+func (h *MaxIntBase) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// emulated nodes.
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	// If looked-up element hasn't been found by now, let's look into the actual
+	// sys container rootfs.
+	return h.Service.GetPassThroughHandler().Lookup(n, req)
+}
+
+func (h *MaxIntBase) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		return nil
+	}
+
+	return h.Service.GetPassThroughHandler().Open(n, req)
+}
+
+func (h *MaxIntBase) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		// Single integer being read, so we can return right away if offset is
+		// any higher than zero.
+		if req.Offset > 0 {
+			return 0, io.EOF
+		}
+
+		return readFileInt(h, n, req)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Read(n, req)
+}
+
+func (h *MaxIntBase) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		// Resources not declaring an emulation policy are pooled-max ones.
+		if v.Policy == "" {
+			return writeFileMaxInt(h, n, req, true)
+		}
+
+		return writeFileByPolicy(h, n, req, v)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
+
+func (h *MaxIntBase) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Return all entries as seen within container's namespaces.
+	return h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+}
+
+func (h *MaxIntBase) GetName() string {
+	return h.Name
+}
+
+func (h *MaxIntBase) GetPath() string {
+	return h.Path
+}
+
+func (h *MaxIntBase) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *MaxIntBase) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *MaxIntBase) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *MaxIntBase) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *MaxIntBase) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *MaxIntBase) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *MaxIntBase) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
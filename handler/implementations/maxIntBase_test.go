@@ -0,0 +1,267 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestMaxIntBase_Write(t *testing.T) {
+
+	var h = &implementations.MaxIntBase{
+		domain.HandlerBase{
+			Name:    "ProcSysVm",
+			Path:    "/proc/sys/vm",
+			Enabled: true,
+			EmuResourceMap: map[string]*domain.EmuResource{
+				"max_map_count": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Enabled: true,
+				},
+			},
+			Service: hds,
+		},
+	}
+
+	var c1 = css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		nil,
+	)
+
+	var c2 = css.ContainerCreate(
+		"c2",
+		uint32(2001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		nil,
+	)
+
+	// Host's default value.
+	n := ios.NewIOnode("max_map_count", "/proc/sys/vm/max_map_count", 0644)
+	if err := n.WriteFile([]byte("65530")); err != nil {
+		t.Fatalf("Unable to initialize host value: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		cntr     domain.ContainerIface
+		val      string
+		wantCntr string
+		wantHost string
+	}{
+		// Value larger than the host one must be pushed to the kernel.
+		{"1", c1, "262144", "262144", "262144"},
+
+		// Smaller value must be kept within the container only.
+		{"2", c2, "100000", "100000", "262144"},
+
+		// Container lowering its own value can't lower the host one.
+		{"3", c1, "70000", "70000", "262144"},
+
+		// New max across all containers.
+		{"4", c2, "524288", "524288", "524288"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &domain.HandlerRequest{
+				Data:      []byte(tt.val + "\n"),
+				Container: tt.cntr,
+			}
+
+			if _, err := h.Write(n, req); err != nil {
+				t.Fatalf("MaxIntBase.Write() error = %v", err)
+			}
+
+			got, _ := tt.cntr.Data(n.Path(), n.Name())
+			if got != tt.wantCntr {
+				t.Errorf("MaxIntBase.Write() container value = %v, want %v",
+					got, tt.wantCntr)
+			}
+
+			host, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Unable to read host value: %v", err)
+			}
+			if host != tt.wantHost {
+				t.Errorf("MaxIntBase.Write() host value = %v, want %v",
+					host, tt.wantHost)
+			}
+		})
+	}
+
+	// Host value must be lowered to the max across the remaining containers
+	// once the one driving it goes away.
+	implementations.ReconcilePooledResources(c2)
+
+	host, err := n.ReadLine()
+	if err != nil {
+		t.Fatalf("Unable to read host value: %v", err)
+	}
+	if host != "70000" {
+		t.Errorf("ReconcilePooledResources() host value = %v, want %v",
+			host, "70000")
+	}
+
+	// And back to the original host value once no container is left.
+	implementations.ReconcilePooledResources(c1)
+
+	host, err = n.ReadLine()
+	if err != nil {
+		t.Fatalf("Unable to read host value: %v", err)
+	}
+	if host != "65530" {
+		t.Errorf("ReconcilePooledResources() host value = %v, want %v",
+			host, "65530")
+	}
+}
+
+func TestMaxIntBase_WritePolicy(t *testing.T) {
+
+	var h = &implementations.MaxIntBase{
+		domain.HandlerBase{
+			Name:    "ProcSysKernelKeys",
+			Path:    "/proc/sys/kernel/keys",
+			Enabled: true,
+			EmuResourceMap: map[string]*domain.EmuResource{
+				"maxkeys": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.PooledMinPolicy,
+					Format:  domain.IntFormat,
+					Bounds:  &domain.EmuResourceBounds{Min: 1, Max: 1000000},
+					Enabled: true,
+				},
+				"maxbytes": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.StateOnlyPolicy,
+					Format:  domain.IntFormat,
+					Enabled: true,
+				},
+				"root_maxkeys": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.HostClampedPolicy,
+					Format:  domain.IntFormat,
+					Enabled: true,
+				},
+				"gc_delay": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.ReadOnlyPolicy,
+					Format:  domain.IntFormat,
+					Enabled: true,
+				},
+			},
+			Service: hds,
+		},
+	}
+
+	var c1 = css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		nil,
+	)
+
+	tests := []struct {
+		name     string
+		resource string
+		host     string
+		val      string
+		wantCntr string
+		wantHost string
+		wantErr  bool
+	}{
+		// Pooled-min value lower than the host one is pushed to the kernel.
+		{"1", "maxkeys", "200", "100", "100", "100", false},
+
+		// Out of bounds values are rejected.
+		{"2", "maxkeys", "200", "0", "100", "200", true},
+
+		// State-only value never reaches the kernel.
+		{"3", "maxbytes", "20000", "40000", "40000", "20000", false},
+
+		// Host-clamped value can't exceed the host one.
+		{"4", "root_maxkeys", "1000000", "2000000", "1000000", "1000000", false},
+
+		// Read-only resource rejects writes.
+		{"5", "gc_delay", "300", "100", "", "300", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/proc/sys/kernel/keys/" + tt.resource
+
+			n := ios.NewIOnode(tt.resource, path, 0644)
+			if err := n.WriteFile([]byte(tt.host)); err != nil {
+				t.Fatalf("Unable to initialize host value: %v", err)
+			}
+
+			req := &domain.HandlerRequest{
+				Data:      []byte(tt.val + "\n"),
+				Container: c1,
+			}
+
+			_, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MaxIntBase.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, _ := c1.Data(path, tt.resource)
+			if got != tt.wantCntr {
+				t.Errorf("MaxIntBase.Write() container value = %v, want %v",
+					got, tt.wantCntr)
+			}
+
+			host, err := n.ReadLine()
+			if err != nil {
+				t.Fatalf("Unable to read host value: %v", err)
+			}
+			if host != tt.wantHost {
+				t.Errorf("MaxIntBase.Write() host value = %v, want %v",
+					host, tt.wantHost)
+			}
+		})
+	}
+}
@@ -23,8 +23,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 
 	"github.com/sirupsen/logrus"
 )
@@ -41,6 +43,10 @@ import (
 // implementation (see that the Path attribute is set to "*"), so this one could
 // be utilized for pass-through operations in other subtrees too.
 //
+// Accesses to /proc/sys nodes are subject to the operator-defined policy table
+// (see sysctlPolicy.go), which allows the writes to be ignored, denied or kept
+// at sys-container level.
+//
 
 type PassThrough struct {
 	domain.HandlerBase
@@ -67,7 +73,9 @@ func (h *PassThrough) Lookup(
 		req.Pid,
 		&domain.AllNSsButMount,
 		&domain.NSenterMessage{
-			Type: domain.LookupRequest,
+			Type:  domain.LookupRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
 			Payload: &domain.LookupPayload{
 				Entry: n.Path(),
 			},
@@ -100,13 +108,27 @@ func (h *PassThrough) Open(
 	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
 		req.ID, h.Name, n.Name())
 
+	if n.OpenFlags() != syscall.O_RDONLY {
+		switch sysctlPolicy(h, n) {
+		case domain.SysctlWriteDeny:
+			return fuse.IOerror{Code: syscall.EACCES}
+
+		case domain.SysctlReadPassthrough, domain.SysctlWriteVirtual:
+			// Writes never reach the node, so there's no need for it to be
+			// writable within the container.
+			return nil
+		}
+	}
+
 	// Create nsenterEvent to initiate interaction with container namespaces.
 	nss := h.Service.NSenterService()
 	event := nss.NewEvent(
 		req.Pid,
 		&domain.AllNSsButMount,
 		&domain.NSenterMessage{
-			Type: domain.OpenFileRequest,
+			Type:  domain.OpenFileRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
 			Payload: &domain.OpenFilePayload{
 				File:  n.Path(),
 				Flags: strconv.Itoa(n.OpenFlags()),
@@ -156,6 +178,17 @@ func (h *PassThrough) Read(
 	process := prs.ProcessCreate(req.Pid, req.Uid, req.Gid)
 	cntr := req.Container
 
+	// Virtual values are served to all the processes within the sys container.
+	if sysctlPolicy(h, n) == domain.SysctlWriteVirtual {
+		cntr.Lock()
+		data, ok = cntr.Data(path, resource)
+		cntr.Unlock()
+
+		if ok {
+			return copyResultBuffer(req.Data, []byte(data+"\n"))
+		}
+	}
+
 	//
 	// Caching here improves performance by avoiding dispatching the nsenter agent.  But
 	// note that caching is only helping processes at the sys container level, not in inner
@@ -171,7 +204,7 @@ func (h *PassThrough) Read(
 		cntr.Lock()
 		data, ok = cntr.Data(path, resource)
 		if !ok {
-			data, err = h.fetchFile(n, process)
+			data, err = h.fetchFile(n, process, req)
 			if err != nil {
 				cntr.Unlock()
 				return 0, err
@@ -181,7 +214,7 @@ func (h *PassThrough) Read(
 		}
 		cntr.Unlock()
 	} else {
-		data, err = h.fetchFile(n, process)
+		data, err = h.fetchFile(n, process, req)
 		if err != nil {
 			return 0, err
 		}
@@ -205,6 +238,22 @@ func (h *PassThrough) Write(
 	cntr := req.Container
 
 	newContent := strings.TrimSpace(string(req.Data))
+
+	switch sysctlPolicy(h, n) {
+	case domain.SysctlReadPassthrough:
+		return 0, nil
+
+	case domain.SysctlWriteDeny:
+		return 0, fuse.IOerror{Code: syscall.EACCES}
+
+	case domain.SysctlWriteVirtual:
+		cntr.Lock()
+		cntr.SetData(path, resource, newContent)
+		cntr.Unlock()
+
+		return len(req.Data), nil
+	}
+
 	prs := h.Service.ProcessService()
 	process := prs.ProcessCreate(req.Pid, req.Uid, req.Gid)
 
@@ -213,7 +262,7 @@ func (h *PassThrough) Write(
 	// a write-through to the host FS. Otherwise just do the write-through.
 	if domain.ProcessNsMatch(process, cntr.InitProc()) {
 		cntr.Lock()
-		if err := h.pushFile(n, process, newContent); err != nil {
+		if err := h.pushFile(n, process, newContent, req); err != nil {
 			cntr.Unlock()
 			return 0, err
 		}
@@ -221,7 +270,7 @@ func (h *PassThrough) Write(
 		cntr.Unlock()
 
 	} else {
-		if err := h.pushFile(n, process, newContent); err != nil {
+		if err := h.pushFile(n, process, newContent, req); err != nil {
 			return 0, err
 		}
 	}
@@ -242,7 +291,9 @@ func (h *PassThrough) ReadDirAll(
 		req.Pid,
 		&domain.AllNSsButMount,
 		&domain.NSenterMessage{
-			Type: domain.ReadDirRequest,
+			Type:  domain.ReadDirRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
 			Payload: &domain.ReadDirPayload{
 				Dir: n.Path(),
 			},
@@ -289,7 +340,9 @@ func (h *PassThrough) Setattr(
 		req.Pid,
 		&domain.AllNSsButMount,
 		&domain.NSenterMessage{
-			Type: domain.OpenFileRequest,
+			Type:  domain.OpenFileRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
 			Payload: &domain.OpenFilePayload{
 				File:  n.Path(),
 				Flags: strconv.Itoa(n.OpenFlags()),
@@ -318,7 +371,8 @@ func (h *PassThrough) Setattr(
 // Auxiliary method to fetch the content of any given file within a container.
 func (h *PassThrough) fetchFile(
 	n domain.IOnodeIface,
-	process domain.ProcessIface) (string, error) {
+	process domain.ProcessIface,
+	req *domain.HandlerRequest) (string, error) {
 
 	// Create nsenterEvent to initiate interaction with container namespaces.
 	nss := h.Service.NSenterService()
@@ -326,7 +380,9 @@ func (h *PassThrough) fetchFile(
 		process.Pid(),
 		&domain.AllNSsButMount,
 		&domain.NSenterMessage{
-			Type: domain.ReadFileRequest,
+			Type:  domain.ReadFileRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
 			Payload: &domain.ReadFilePayload{
 				File: n.Path(),
 			},
@@ -353,11 +409,55 @@ func (h *PassThrough) fetchFile(
 	return info, nil
 }
 
+// Auxiliary method to fetch the content of several files within a container
+// through a single nsenter round trip.
+func (h *PassThrough) fetchFiles(
+	files []string,
+	process domain.ProcessIface,
+	req *domain.HandlerRequest) ([]domain.ReadFilesResult, error) {
+
+	// Create nsenterEvent to initiate interaction with container namespaces.
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		process.Pid(),
+		&domain.AllNSsButMount,
+		&domain.NSenterMessage{
+			Type:  domain.ReadFilesRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
+			Payload: &domain.ReadFilesPayload{
+				Files: files,
+			},
+		},
+		nil,
+		false,
+	)
+
+	// Launch nsenter-event to obtain files state within container
+	// namespaces.
+	err := nss.SendRequestEvent(event)
+	if err != nil {
+		return nil, err
+	}
+
+	// Obtain nsenter-event response.
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return nil, responseMsg.Payload.(error)
+	}
+
+	return responseMsg.Payload.([]domain.ReadFilesResult), nil
+}
+
 // Auxiliary method to inject content into any given file within a container.
 func (h *PassThrough) pushFile(
 	n domain.IOnodeIface,
 	process domain.ProcessIface,
-	s string) error {
+	s string,
+	req *domain.HandlerRequest) error {
+
+	// Content prefetched prior to the write can't be served past it.
+	defer nsFilePrefetch.drop(process, n.Path())
 
 	// Create nsenterEvent to initiate interaction with container namespaces.
 	nss := h.Service.NSenterService()
@@ -365,7 +465,9 @@ func (h *PassThrough) pushFile(
 		process.Pid(),
 		&domain.AllNSsButMount,
 		&domain.NSenterMessage{
-			Type: domain.WriteFileRequest,
+			Type:  domain.WriteFileRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
 			Payload: &domain.WriteFilePayload{
 				File:    n.Path(),
 				Content: s,
@@ -429,6 +531,10 @@ func (h *PassThrough) GetResourcesList() []string {
 	return resources
 }
 
+func (h *PassThrough) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *PassThrough) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
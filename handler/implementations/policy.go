@@ -0,0 +1,132 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// Policy-driven emulation of sysctl-like resources.
+//
+// Handlers declare the emulation policy of each resource within their
+// EmuResourceMap (see domain.EmuResourcePolicy), and hand over the read / write
+// operations of these resources to the functions below, which carry out the
+// emulation as follows:
+//
+// * "state-only": value is kept within the sys container state (virtual-only).
+//
+// * "write-through": value is read / written within the requester's namespaces
+// (e.g. netns-scoped sysctls).
+//
+// * "pooled-max" / "pooled-min": value is kept within the sys container state,
+// and the max / min across all sys containers is pushed to the host kernel.
+//
+// * "host-clamped": value is kept within the sys container state, and can't
+// exceed the host one.
+//
+// * "read-only": writes are rejected.
+//
+// Integer resources are validated against their bounds (if any) before any
+// policy is applied.
+//
+
+// readFileByPolicy function serves the resource as per its emulation policy.
+func readFileByPolicy(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	resource *domain.EmuResource) (int, error) {
+
+	switch resource.Policy {
+	case domain.WriteThroughPolicy, domain.ReadOnlyPolicy:
+		return h.GetService().GetPassThroughHandler().Read(n, req)
+	}
+
+	if resource.Format == domain.IntFormat {
+		return readFileInt(h, n, req)
+	}
+
+	return readFileString(h, n, req)
+}
+
+// writeFileByPolicy function routes the write as per the resource's emulation
+// policy.
+func writeFileByPolicy(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	resource *domain.EmuResource) (int, error) {
+
+	if resource.Policy == domain.ReadOnlyPolicy {
+		return 0, fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	if resource.Format != domain.IntFormat {
+		switch resource.Policy {
+		case domain.StateOnlyPolicy:
+			return writeFileString(h, n, req, false)
+
+		case domain.WriteThroughPolicy:
+			return h.GetService().GetPassThroughHandler().Write(n, req)
+		}
+
+		logrus.Errorf("Unexpected policy %s for emulated resource %s",
+			resource.Policy, n.Path())
+
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	min, max := MinInt, MaxInt
+	if b := resource.Bounds; b != nil {
+		min, max = b.Min, b.Max
+	}
+
+	val, err := strconv.Atoi(strings.TrimSpace(string(req.Data)))
+	if err != nil || val < min || val > max {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	switch resource.Policy {
+	case domain.StateOnlyPolicy:
+		return writeFileInt(h, n, req, min, max, false)
+
+	case domain.WriteThroughPolicy:
+		return writeNetnsFileInt(h, n, req, min, max)
+
+	case domain.PooledMaxPolicy:
+		return writeFileMaxInt(h, n, req, true)
+
+	case domain.PooledMinPolicy:
+		return writeFileMinInt(h, n, req, true)
+
+	case domain.HostClampedPolicy:
+		return writeFileClampedInt(h, n, req, min, false)
+	}
+
+	logrus.Errorf("Unexpected policy %s for emulated resource %s",
+		resource.Policy, n.Path())
+
+	return 0, fuse.IOerror{Code: syscall.EINVAL}
+}
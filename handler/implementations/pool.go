@@ -0,0 +1,177 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// Bookkeeping of the pooled ("pooled-max" / "pooled-min") resources.
+//
+// The host value of a pooled resource is the max (min) across the values
+// requested by all sys containers. To be able to revert it once the sys
+// containers driving it go away, sysbox-fs keeps track of the host value found
+// prior to any sys container acting on the resource, as well as of the latest
+// value requested by every sys container. Upon container unregistration, the
+// host value is recomputed over the remaining containers (see
+// ReconcilePooledResources()).
+//
+
+type pooledResource struct {
+	h       domain.HandlerIface
+	n       domain.IOnodeIface
+	max     bool
+	hostVal int
+	vals    map[string]int
+}
+
+var pooledResources = struct {
+	sync.Mutex
+	m map[string]*pooledResource
+}{m: make(map[string]*pooledResource)}
+
+// Returns the value to be exposed by the host as per the pool's policy.
+func (p *pooledResource) target() int {
+	val := p.hostVal
+
+	for _, v := range p.vals {
+		if (p.max && v > val) || (!p.max && v < val) {
+			val = v
+		}
+	}
+
+	return val
+}
+
+// trackPooledValue function records the value requested by a sys container for
+// a pooled resource, along with the original host value if this is the first
+// time the resource is acted on.
+func trackPooledValue(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	c domain.ContainerIface,
+	val int,
+	max bool) error {
+
+	pooledResources.Lock()
+	defer pooledResources.Unlock()
+
+	p, ok := pooledResources.m[n.Path()]
+	if !ok {
+		resourceMutex := h.GetResourceMutex(n)
+		if resourceMutex == nil {
+			logrus.Errorf("Unexpected error: no mutex found for emulated resource %s",
+				n.Path())
+			return errors.New("no mutex found for emulated resource")
+		}
+
+		resourceMutex.Lock()
+		hostVal, err := n.ReadLine()
+		resourceMutex.Unlock()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		hostValInt, err := strconv.Atoi(hostVal)
+		if err != nil {
+			logrus.Errorf("Unexpected error: %v", err)
+			return err
+		}
+
+		p = &pooledResource{
+			h:       h,
+			n:       n,
+			max:     max,
+			hostVal: hostValInt,
+			vals:    make(map[string]int),
+		}
+		pooledResources.m[n.Path()] = p
+	}
+
+	p.vals[c.ID()] = val
+
+	return nil
+}
+
+// ReconcilePooledResources function drops the values requested by the given
+// sys container, and adjusts the host value of the pooled resources it was
+// driving to the max (min) across the remaining containers (or to the original
+// host value if there's none left). Host values modified by other agents in
+// the meantime are left untouched.
+func ReconcilePooledResources(c domain.ContainerIface) {
+
+	pooledResources.Lock()
+	defer pooledResources.Unlock()
+
+	for path, p := range pooledResources.m {
+		if _, ok := p.vals[c.ID()]; !ok {
+			continue
+		}
+
+		prevVal := p.target()
+		delete(p.vals, c.ID())
+		newVal := p.target()
+
+		if len(p.vals) == 0 {
+			delete(pooledResources.m, path)
+		}
+
+		if newVal == prevVal {
+			continue
+		}
+
+		if err := reconcilePooledResource(p, prevVal, newVal); err != nil {
+			logrus.Warnf("Could not reconcile host value of %s: %v", path, err)
+		}
+	}
+}
+
+func reconcilePooledResource(p *pooledResource, prevVal, newVal int) error {
+
+	resourceMutex := p.h.GetResourceMutex(p.n)
+	if resourceMutex == nil {
+		return errors.New("no mutex found for emulated resource")
+	}
+	resourceMutex.Lock()
+	defer resourceMutex.Unlock()
+
+	curHostVal, err := p.n.ReadLine()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	curHostValInt, err := strconv.Atoi(curHostVal)
+	if err != nil {
+		return err
+	}
+
+	// Someone else changed the host value; leave it alone.
+	if curHostValInt != prevVal {
+		return nil
+	}
+
+	logrus.Debugf("Reconciling host value of %s: %d -> %d",
+		p.n.Path(), prevVal, newVal)
+
+	return p.n.WriteFile([]byte(strconv.Itoa(newVal)))
+}
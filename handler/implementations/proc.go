@@ -17,16 +17,22 @@
 package implementations
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 
 	"github.com/nestybox/sysbox-fs/domain"
 	"github.com/nestybox/sysbox-fs/fuse"
@@ -36,8 +42,60 @@ import (
 // /proc handler
 //
 
-// /proc/swaps static header
-var swapsHeader = "Filename                                Type            Size    Used    Priority"
+// File-system types displayed through /proc/filesystems, as long as they're
+// also supported by the host kernel. Defaults to the ones sys containers can
+// mount; block-device based file-systems are left out as these ones would
+// require access to host devices.
+var procFilesystems = struct {
+	sync.RWMutex
+	types map[string]struct{}
+}{
+	types: map[string]struct{}{
+		"sysfs":   {},
+		"tmpfs":   {},
+		"proc":    {},
+		"cgroup":  {},
+		"cgroup2": {},
+		"devpts":  {},
+		"mqueue":  {},
+		"overlay": {},
+		"shiftfs": {},
+		"fuse":    {},
+	},
+}
+
+// SetProcFilesystems function overrides the file-system types to display
+// through /proc/filesystems.
+func SetProcFilesystems(types []string) {
+
+	m := make(map[string]struct{})
+	for _, t := range types {
+		m[t] = struct{}{}
+	}
+
+	procFilesystems.Lock()
+	procFilesystems.types = m
+	procFilesystems.Unlock()
+}
+
+// /proc/slabinfo static header
+var slabinfoHeader = "slabinfo - version: 2.1\n" +
+	"# name            <active_objs> <num_objs> <objsize> <objperslab> " +
+	"<pagesperslab> : tunables <limit> <batchcount> <sharedfactor> : " +
+	"slabdata <active_slabs> <num_slabs> <sharedavail>"
+
+// /proc/mdstat static content (no md arrays)
+var mdstatContent = "Personalities : \nunused devices: <none>"
+
+// Uid displayed for the users not mapped into the reader's user-ns.
+var overflowUid = "65534"
+
+// Signals delivered to the sys container processes for the supported sysrq
+// keys: 'e' (terminate all tasks) and 'i' (kill all tasks).
+var sysrqSignals = map[byte]syscall.Signal{
+	'e': syscall.SIGTERM,
+	'i': syscall.SIGKILL,
+}
 
 type Proc struct {
 	domain.HandlerBase
@@ -54,14 +112,193 @@ var Proc_Handler = &Proc{
 				Mode:    os.ModeDir | os.FileMode(uint32(0555)),
 				Enabled: true,
 			},
+			"cpuinfo": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"crypto": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"devices": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"filesystems": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"interrupts": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"kallsyms": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"keys": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"kmsg": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0400)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"key-users": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"loadavg": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"mdstat": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"meminfo": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"modules": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"diskstats": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"partitions": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"schedstat": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"slabinfo": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0400)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"softirqs": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"stat": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
 			"swaps": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"buddyinfo": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"cgroups": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"sysrq-trigger": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0200)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"timer_list": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
 				Enabled: true,
 			},
 			"uptime": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"version": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"zoneinfo": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
 				Enabled: true,
 			},
 		},
@@ -111,7 +348,16 @@ func (h *Proc) Open(
 	case "sys":
 		return nil
 
-	case "swaps", "uptime":
+	case "sysrq-trigger":
+		if flags&(syscall.O_WRONLY|syscall.O_RDWR) == 0 {
+			return fuse.IOerror{Code: syscall.EACCES}
+		}
+
+	case "buddyinfo", "cgroups", "cpuinfo", "crypto", "devices", "diskstats", "filesystems",
+		"interrupts", "kallsyms", "keys", "key-users", "kmsg", "loadavg", "mdstat",
+		"meminfo", "modules", "partitions",
+		"schedstat", "slabinfo", "softirqs", "stat", "swaps", "timer_list",
+		"uptime", "version", "zoneinfo":
 		if flags != syscall.O_RDONLY {
 			return fuse.IOerror{Code: syscall.EACCES}
 		}
@@ -129,6 +375,21 @@ func (h *Proc) Read(
 	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
 		req.ID, h.Name, resource)
 
+	// /proc/sysrq-trigger is write-only.
+	if resource == "sysrq-trigger" {
+		return 0, io.EOF
+	}
+
+	// /proc/kmsg records are consumed as read, regardless of the offset.
+	if resource == "kmsg" {
+		return h.readKmsg(n, req)
+	}
+
+	// /proc/kallsyms is served across multiple reads.
+	if resource == "kallsyms" {
+		return h.readKallsyms(n, req)
+	}
+
 	// We are dealing with a single boolean element being read, so we can save
 	// some cycles by returning right away if offset is any higher than zero.
 	if req.Offset > 0 {
@@ -136,11 +397,63 @@ func (h *Proc) Read(
 	}
 
 	switch resource {
+	case "buddyinfo", "zoneinfo":
+		return h.readMemZone(n, req)
+
+	case "cgroups":
+		return h.readCgroups(n, req)
+
+	case "cpuinfo":
+		return h.readCpuinfo(n, req)
+
+	case "crypto":
+		return h.readCrypto(n, req)
+
+	case "devices":
+		return h.readDevices(n, req)
+
+	case "filesystems":
+		return h.readFilesystems(n, req)
+
+	case "interrupts", "softirqs", "schedstat", "timer_list":
+		return h.readPerCpuCounters(n, req)
+
+	case "keys", "key-users":
+		return h.readKeys(n, req)
+
+	case "loadavg":
+		return h.readLoadavg(n, req)
+
+	case "mdstat":
+		// Host's md arrays are not exposed.
+		return copyResultBuffer(req.Data, []byte(mdstatContent+"\n"))
+
+	case "meminfo":
+		return h.readMeminfo(n, req)
+
+	case "modules":
+		// Host's kernel modules are not exposed.
+		return 0, io.EOF
+
+	case "diskstats", "partitions":
+		return h.readPartitions(n, req)
+
+	case "slabinfo":
+		// Host's slab caches are not exposed; just the header is served so
+		// that parsers find a well-formed file.
+		return copyResultBuffer(req.Data, []byte(slabinfoHeader+"\n"))
+
+	case "stat":
+		return h.readStat(n, req)
+
 	case "swaps":
 		return h.readSwaps(n, req)
 
 	case "uptime":
 		return h.readUptime(n, req)
+
+	case "version":
+		return h.readVersion(n, req)
 	}
 
 	return 0, nil
@@ -150,6 +463,16 @@ func (h *Proc) Write(
 	n domain.IOnodeIface,
 	req *domain.HandlerRequest) (int, error) {
 
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	switch resource {
+	case "sysrq-trigger":
+		return h.writeSysrqTrigger(n, req)
+	}
+
 	return 0, nil
 }
 
@@ -208,6 +531,10 @@ func (h *Proc) GetResourcesList() []string {
 	return resources
 }
 
+func (h *Proc) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *Proc) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
@@ -231,29 +558,9 @@ func (h *Proc) readSwaps(
 		return 0, io.EOF
 	}
 
-	name := n.Name()
-	path := n.Path()
-	cntr := req.Container
-
-	// If no modification has been ever made to this container's swapping mode,
-	// then let's assume that swapping in OFF by default.
-	data, ok := cntr.Data(path, name)
-	if !ok || data == "swapoff" {
-		result := []byte(swapsHeader + "\n")
-		return copyResultBuffer(req.Data, result)
-	}
-
-	var result []byte
-
-	// If swapping is enabled ("swapon" value was explicitly set), extract the
-	// information directly from the host fs. Note that this action displays
-	// stats of the overall system, and not of the container itself, but it's
-	// a valid approximation for now given that kernel doesn't expose anything
-	// close to this.
-	_, err := n.Read(result)
-	if err != nil && err != io.EOF {
-		return 0, err
-	}
+	// The swap areas are emulated per container (see domain.SwapTable), so
+	// that the host's ones aren't exposed.
+	result := []byte(req.Container.SwapTable().Format())
 
 	return copyResultBuffer(req.Data, result)
 }
@@ -303,3 +610,1331 @@ func (h *Proc) readUptime(
 
 	return copyResultBuffer(req.Data, result)
 }
+
+// readCgroups method rewrites the host's /proc/cgroups to only display the
+// controllers delegated to the sys container, i.e. those attached to the
+// cgroup v1 hierarchies the container's init process is part of, and those
+// enabled in the parent of its cgroup v2 node (see cgroup.controllers).
+// Hierarchy and cgroup counters are kept as per the host.
+func (h *Proc) readCgroups(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	delegated, err := h.delegatedControllers(cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cgroup controllers of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	result := filterCgroups(content, delegated)
+
+	return copyResultBuffer(req.Data, result)
+}
+
+// delegatedControllers method returns the cgroup controllers available to the
+// given process, indexed by the id of the hierarchy they're attached to ("0"
+// for cgroup v2).
+func (h *Proc) delegatedControllers(pid uint32) (map[string]string, error) {
+
+	ios := h.Service.IOService()
+
+	cgNode := ios.NewIOnode(
+		"cgroup",
+		filepath.Join("/proc", strconv.FormatUint(uint64(pid), 10), "cgroup"),
+		0)
+	content, err := cgNode.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	delegated := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		// Entries are in "hierarchy-id:controllers:cgroup-path" format.
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		if fields[0] != "0" {
+			for _, ctrl := range strings.Split(fields[1], ",") {
+				if ctrl != "" && !strings.HasPrefix(ctrl, "name=") {
+					delegated[ctrl] = fields[0]
+				}
+			}
+			continue
+		}
+
+		ctrlNode := ios.NewIOnode(
+			"cgroup.controllers",
+			filepath.Join(cgroupV2Root, fields[2], "cgroup.controllers"),
+			0)
+		ctrls, err := ctrlNode.ReadFile()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ctrl := range strings.Fields(string(ctrls)) {
+			if _, ok := delegated[ctrl]; !ok {
+				delegated[ctrl] = "0"
+			}
+		}
+	}
+
+	return delegated, nil
+}
+
+// filterCgroups drops the /proc/cgroups entries of the controllers that are
+// not delegated through the hierarchy reported by the host.
+func filterCgroups(content []byte, delegated map[string]string) []byte {
+
+	var result bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Entries are in "subsys_name hierarchy num_cgroups enabled" format,
+		// preceded by a commented header.
+		fields := strings.Fields(line)
+		if strings.HasPrefix(line, "#") || len(fields) < 2 {
+			result.WriteString(line + "\n")
+			continue
+		}
+
+		if hier, ok := delegated[fields[0]]; !ok || hier != fields[1] {
+			continue
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	return result.Bytes()
+}
+
+// readPartitions method rewrites the host's /proc/partitions (and
+// /proc/diskstats) to only display the block devices backing the sys
+// container's mounts (i.e. its rootfs device and any volume mounted into it),
+// so that host disks aren't exposed. Where the io cgroup controller is
+// available, diskstats counters reflect the I/O issued by the sys container
+// (see diskstatsEntry).
+func (h *Proc) readPartitions(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	ios := h.Service.IOService()
+
+	devs, err := containerBlockDevs(ios, req)
+	if err != nil {
+		return 0, err
+	}
+
+	var stats map[string]*ioStats
+
+	if n.Name() == "diskstats" {
+		stats, err = containerIoStats(ios, req.Container.InitPid())
+		if err != nil {
+			logrus.Errorf("Could not obtain the io cgroup of container %s: %v",
+				req.Container.ID(), err)
+			return 0, fuse.IOerror{Code: syscall.EIO}
+		}
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	var result bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Entries are in "major minor #blocks name" format, preceded by a
+		// header and an empty line (diskstats entries are in "major minor
+		// name stats..." format, with no header).
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] == "major" {
+			result.WriteString(line + "\n")
+			continue
+		}
+
+		dev := fields[0] + ":" + fields[1]
+
+		if _, ok := devs[dev]; !ok {
+			continue
+		}
+
+		if stats != nil {
+			line = diskstatsEntry(fields, stats[dev])
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	return copyResultBuffer(req.Data, result.Bytes())
+}
+
+// diskstatsEntry function rebuilds a /proc/diskstats entry out of the given
+// cgroup I/O counters: reads & writes completed, and sectors read & written.
+// The counters not accounted by cgroups (merges, times, in-flight I/Os) are
+// zeroed, as are all of them for the devices the cgroup hasn't issued I/O to.
+func diskstatsEntry(fields []string, stats *ioStats) string {
+
+	if stats == nil {
+		stats = &ioStats{}
+	}
+
+	counters := make([]string, len(fields)-3)
+	for i := range counters {
+		counters[i] = "0"
+	}
+
+	// Fields are in "major minor name reads merged sectors ms writes merged
+	// sectors ms ..." format, with 512-byte sectors.
+	if len(counters) >= 7 {
+		counters[0] = strconv.FormatUint(stats.rios, 10)
+		counters[2] = strconv.FormatUint(stats.rbytes/512, 10)
+		counters[4] = strconv.FormatUint(stats.wios, 10)
+		counters[6] = strconv.FormatUint(stats.wbytes/512, 10)
+	}
+
+	return fmt.Sprintf("%4s %7s %s %s",
+		fields[0], fields[1], fields[2], strings.Join(counters, " "))
+}
+
+// readDevices method rewrites the host's /proc/devices to only display the
+// block-device drivers backing the sys container's mounts. Character devices
+// are displayed as per the host.
+func (h *Proc) readDevices(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	devs, err := containerBlockDevs(h.Service.IOService(), req)
+	if err != nil {
+		return 0, err
+	}
+
+	majors := make(map[string]struct{})
+	for dev := range devs {
+		majors[strings.SplitN(dev, ":", 2)[0]] = struct{}{}
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		result bytes.Buffer
+		block  bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Entries are in "major name" format, grouped in "Character devices:"
+		// and "Block devices:" sections.
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !block {
+			block = block || line == "Block devices:"
+			result.WriteString(line + "\n")
+			continue
+		}
+
+		if _, ok := majors[fields[0]]; !ok {
+			continue
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	return copyResultBuffer(req.Data, result.Bytes())
+}
+
+// containerBlockDevs function returns the "major:minor" identifiers of the
+// devices backing the mounts of the sys container's init process. For overlay
+// mounts (e.g. the container's rootfs) the device holding the upper layer is
+// picked, as overlayfs is backed by an anonymous device.
+func containerBlockDevs(
+	ios domain.IOServiceIface,
+	req *domain.HandlerRequest) (map[string]struct{}, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return nil, errors.New("Container not found")
+	}
+
+	miNode := ios.NewIOnode(
+		"mountinfo",
+		filepath.Join("/proc", strconv.FormatUint(uint64(cntr.InitPid()), 10), "mountinfo"),
+		0)
+	content, err := miNode.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	devs := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		// See domain.MountInfo for details on the mountinfo format.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		sep := 6
+		for sep < len(fields) && fields[sep] != "-" {
+			sep++
+		}
+		if sep+3 >= len(fields) {
+			continue
+		}
+
+		if fields[sep+1] != "overlay" {
+			devs[fields[2]] = struct{}{}
+			continue
+		}
+
+		for _, opt := range strings.Split(fields[sep+3], ",") {
+			if !strings.HasPrefix(opt, "upperdir=") {
+				continue
+			}
+
+			upperNode := ios.NewIOnode("", strings.TrimPrefix(opt, "upperdir="), 0)
+			info, err := upperNode.Stat()
+			if err != nil {
+				break
+			}
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				dev := uint64(st.Dev)
+				devs[fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev))] = struct{}{}
+			}
+		}
+	}
+
+	return devs, nil
+}
+
+// readFilesystems method rewrites the host's /proc/filesystems to only display
+// the file-system types sys containers are allowed to mount (see
+// SetProcFilesystems).
+func (h *Proc) readFilesystems(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	procFilesystems.RLock()
+	defer procFilesystems.RUnlock()
+
+	var result bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Entries are in "[nodev]<tab>type" format.
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if _, ok := procFilesystems.types[fields[len(fields)-1]]; !ok {
+			continue
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	return copyResultBuffer(req.Data, result.Bytes())
+}
+
+// Masked /proc/kallsyms content. It's shared by all sys containers, and only
+// kept for a short while, as it depends on the host's loaded modules.
+var kallsymsCache = domain.NewReadCache(10 * time.Second)
+
+// readKallsyms method displays the host's kernel symbols with their addresses
+// zeroed, as the host kernel does for unprivileged readers (kptr_restrict).
+// Containers in need of the actual addresses (e.g. tracing tools) can opt into
+// the pass-through behavior through a handler override (see
+// domain.ContainerAttrRule).
+func (h *Proc) readKallsyms(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	// As opposed to the other nodes, /proc/kallsyms is too large to be served
+	// at once, so its (container-agnostic) content is cached across reads.
+	path := n.Path()
+
+	data, ok := kallsymsCache.Get("", path)
+	if !ok {
+		content, err := n.ReadFile()
+		if err != nil {
+			return 0, err
+		}
+		data = maskKallsyms(content)
+		kallsymsCache.Set("", path, data)
+	}
+
+	if req.Offset >= int64(len(data)) {
+		return 0, io.EOF
+	}
+
+	return copyResultBuffer(req.Data, data[req.Offset:])
+}
+
+// maskKallsyms zeroes the addresses of the given /proc/kallsyms content, made
+// of "address type name [module]" entries.
+func maskKallsyms(content []byte) []byte {
+
+	var result bytes.Buffer
+
+	result.Grow(len(content))
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			result.WriteString(line + "\n")
+			continue
+		}
+
+		result.WriteString(strings.Repeat("0", i) + line[i:] + "\n")
+	}
+
+	return result.Bytes()
+}
+
+// readPerCpuCounters method rewrites the host's per-cpu stats (/proc/interrupts,
+// /proc/softirqs, /proc/schedstat and /proc/timer_list) to only display the
+// cpus present in the sys container's cpuset, which are renumbered starting
+// from cpu 0.
+func (h *Proc) readPerCpuCounters(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	cpus, err := containerCpus(h.Service.IOService(), cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cpuset of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	var result []byte
+
+	switch n.Name() {
+	case "schedstat":
+		result = filterSchedstat(content, cpus)
+	case "timer_list":
+		result = filterTimerList(content, cpus)
+	default:
+		result = filterPerCpuCounters(content, cpus)
+	}
+
+	return copyResultBuffer(req.Data, result)
+}
+
+// readCpuinfo method rewrites the host's /proc/cpuinfo to only display the
+// cpus present in the sys container's cpuset (see FilterCpuinfo), as laid out
+// by the host's architecture.
+func (h *Proc) readCpuinfo(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	cpus, err := containerCpus(h.Service.IOService(), cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cpuset of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	// Sysbox-fs runs natively on the host, so its architecture is the host's.
+	return copyResultBuffer(req.Data, h.FilterCpuinfo(content, cpus, runtime.GOARCH))
+}
+
+// filterPerCpuCounters function drops the columns of the cpus not present in
+// 'cpus' from the given per-cpu counters table. The table is made of a header
+// holding the "CPUn" column names, followed by "label: count... [description]"
+// rows. Rows lacking per-cpu counters (e.g. "ERR:") are kept as is.
+func filterPerCpuCounters(content []byte, cpus []int) []byte {
+
+	var (
+		result bytes.Buffer
+		keep   []bool // per-column indication of whether to keep it
+	)
+
+	allowed := make(map[int]struct{})
+	for _, cpu := range cpus {
+		allowed[cpu] = struct{}{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	// Header.
+	if scanner.Scan() {
+		line := scanner.Text()
+		header := strings.Fields(line)
+
+		var kept int
+		for _, col := range header {
+			cpu, err := strconv.Atoi(strings.TrimPrefix(col, "CPU"))
+			_, ok := allowed[cpu]
+			keep = append(keep, err == nil && ok)
+			if err == nil && ok {
+				kept++
+			}
+		}
+
+		prefix := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		result.WriteString(prefix)
+		for i := 0; i < kept; i++ {
+			result.WriteString(fmt.Sprintf("CPU%-8d", i))
+		}
+		result.WriteString("\n")
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		sep := strings.IndexByte(line, ':')
+		if sep < 0 {
+			result.WriteString(line + "\n")
+			continue
+		}
+
+		label := line[:sep+1]
+		rest := line[sep+1:]
+
+		// Extract one counter per column.
+		var counters []string
+		for range keep {
+			trimmed := strings.TrimLeft(rest, " ")
+			end := strings.IndexByte(trimmed, ' ')
+			if end < 0 {
+				end = len(trimmed)
+			}
+			if _, err := strconv.ParseUint(trimmed[:end], 10, 64); err != nil {
+				break
+			}
+			counters = append(counters, trimmed[:end])
+			rest = trimmed[end:]
+		}
+
+		if len(counters) != len(keep) {
+			result.WriteString(line + "\n")
+			continue
+		}
+
+		result.WriteString(label + " ")
+		for i, counter := range counters {
+			if keep[i] {
+				result.WriteString(fmt.Sprintf("%10s ", counter))
+			}
+		}
+		if desc := strings.TrimLeft(rest, " "); desc != "" {
+			result.WriteString(" " + desc)
+		}
+		result.WriteString("\n")
+	}
+
+	return result.Bytes()
+}
+
+// readMemZone method synthesizes /proc/zoneinfo and /proc/buddyinfo out of the
+// sys container's memory limit and usage, as if the container's memory was
+// made of a single "Normal" zone within a single NUMA node. Host memory is
+// utilized in the absence of a memory limit.
+func (h *Proc) readMemZone(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	ios := h.Service.IOService()
+
+	limit, usage, err := containerMemory(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the memory cgroup of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	if limit == 0 {
+		limit, err = hostMemTotal(ios)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	managed := limit / pageSize
+
+	var free uint64
+	if usage < limit {
+		free = (limit - usage) / pageSize
+	}
+
+	var result string
+
+	if n.Name() == "buddyinfo" {
+		result = "Node 0, zone   Normal"
+
+		// Free pages are arranged in the largest possible blocks (up to
+		// order 10).
+		for order := uint(0); order <= 10; order++ {
+			var blocks uint64
+			if order == 10 {
+				blocks = free >> order
+			} else {
+				blocks = (free >> order) & 1
+			}
+			result += fmt.Sprintf(" %6d", blocks)
+		}
+		result += "\n"
+
+	} else {
+		// Watermarks are set as the kernel does (low & high are 125% and 150%
+		// of min), with min approximated to a fraction of the zone.
+		wmin := managed / 256
+
+		result = fmt.Sprintf("Node 0, zone   Normal\n"+
+			"  pages free     %d\n"+
+			"        min      %d\n"+
+			"        low      %d\n"+
+			"        high     %d\n"+
+			"        spanned  %d\n"+
+			"        present  %d\n"+
+			"        managed  %d\n",
+			free, wmin, wmin+wmin/4, wmin+wmin/2, managed, managed, managed)
+	}
+
+	return copyResultBuffer(req.Data, []byte(result))
+}
+
+// hostMemTotal function returns the host's memory size (in bytes).
+func hostMemTotal(ios domain.IOServiceIface) (uint64, error) {
+
+	content, err := ios.NewIOnode("meminfo", "/proc/meminfo", 0).ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		// Entry is in "MemTotal:  <size> kB" format.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb * 1024, nil
+		}
+	}
+
+	return 0, errors.New("MemTotal not found in /proc/meminfo")
+}
+
+// readMeminfo method rewrites the host's /proc/meminfo out of the sys
+// container's memory cgroup: memory (and swap) totals reflect the cgroup
+// limits, and the free / available / cached figures its usage. The remaining
+// entries are displayed as per the host, which is also the case of the whole
+// file in the absence of a memory limit.
+func (h *Proc) readMeminfo(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	ios := h.Service.IOService()
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	limit, usage, err := containerMemory(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the memory cgroup of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	if limit == 0 {
+		return copyResultBuffer(req.Data, content)
+	}
+
+	cache, err := containerPageCache(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the memory stats of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	swapLimit, swapUsage, err := containerSwap(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the swap usage of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	var free uint64
+	if usage < limit {
+		free = limit - usage
+	}
+
+	if cache > usage {
+		cache = usage
+	}
+
+	avail := free + cache
+	if avail > limit {
+		avail = limit
+	}
+
+	values := map[string]uint64{
+		"MemTotal:":     limit,
+		"MemFree:":      free,
+		"MemAvailable:": avail,
+		"Cached:":       cache,
+	}
+
+	if swapLimit != 0 {
+		values["SwapTotal:"] = swapLimit
+		values["SwapFree:"] = 0
+		if swapUsage < swapLimit {
+			values["SwapFree:"] = swapLimit - swapUsage
+		}
+	}
+
+	var result bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Entries are in "Label:  <size> kB" format, with the label padded to
+		// 16 chars and the size to 8.
+		fields := strings.Fields(line)
+		if len(fields) == 3 {
+			if val, ok := values[fields[0]]; ok {
+				line = fmt.Sprintf("%-16s%8d kB", fields[0], val/1024)
+			}
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	return copyResultBuffer(req.Data, result.Bytes())
+}
+
+// readStat method rewrites the host's /proc/stat cpu entries out of the sys
+// container's cpu cgroup: the aggregated "cpu" entry holds the user and system
+// time consumed by the container, and its idle time is what remains of the
+// container's cpus since the container was started. Only the cpus present in
+// the container's cpuset are displayed (renumbered starting from cpu 0), with
+// the container's usage split evenly across them as cgroups don't account
+// per-cpu usage in v2. The boot time reflects the container's creation (see
+// /proc/uptime); the remaining entries are displayed as per the host.
+func (h *Proc) readStat(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	ios := h.Service.IOService()
+
+	cpus, err := containerCpus(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cpuset of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	user, system, err := containerCpuUsage(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cpu usage of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	ncpus := uint64(len(cpus))
+	if ncpus == 0 {
+		ncpus = 1
+	}
+
+	ticks := func(d time.Duration) uint64 {
+		return uint64(d / (time.Second / userHz))
+	}
+
+	userTicks := ticks(user)
+	systemTicks := ticks(system)
+
+	var idleTicks uint64
+	if total := ticks(time.Since(cntr.Ctime())) * ncpus; total > userTicks+systemTicks {
+		idleTicks = total - userTicks - systemTicks
+	}
+
+	// Fields: user nice system idle iowait irq softirq steal guest guest_nice.
+	cpuEntry := func(label string, div uint64) string {
+		return fmt.Sprintf("%s %d 0 %d %d 0 0 0 0 0 0",
+			label, userTicks/div, systemTicks/div, idleTicks/div)
+	}
+
+	renumbering := cpuRenumbering(cpus)
+
+	var result bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case fields[0] == "cpu":
+			result.WriteString(cpuEntry("cpu ", 1) + "\n")
+
+		case strings.HasPrefix(fields[0], "cpu"):
+			cpu, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+			if err != nil {
+				continue
+			}
+			if id, ok := renumbering[cpu]; ok {
+				result.WriteString(cpuEntry(fmt.Sprintf("cpu%d", id), ncpus) + "\n")
+			}
+
+		case fields[0] == "btime":
+			result.WriteString(fmt.Sprintf("btime %d\n", cntr.Ctime().Unix()))
+
+		default:
+			result.WriteString(line + "\n")
+		}
+	}
+
+	return copyResultBuffer(req.Data, result.Bytes())
+}
+
+// readLoadavg method rewrites the host's /proc/loadavg to display the number
+// of tasks in the sys container's pids cgroup (the "running/total" field).
+// The load averages themselves are displayed as per the host, as the kernel
+// doesn't track them per cgroup.
+func (h *Proc) readLoadavg(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	// Content is in "avg1 avg5 avg15 running/total last-pid" format.
+	fields := strings.Fields(string(content))
+	if len(fields) != 5 {
+		return copyResultBuffer(req.Data, content)
+	}
+
+	tasks, err := containerPids(h.Service.IOService(), cntr.InitPid())
+	if err != nil {
+		logrus.Debugf("Could not obtain the pids cgroup of container %s: %v",
+			cntr.ID(), err)
+		return copyResultBuffer(req.Data, content)
+	}
+
+	counters := strings.SplitN(fields[3], "/", 2)
+
+	running, err := strconv.ParseUint(counters[0], 10, 64)
+	if err != nil || running > tasks {
+		running = tasks
+	}
+
+	fields[3] = fmt.Sprintf("%d/%d", running, tasks)
+
+	return copyResultBuffer(req.Data, []byte(strings.Join(fields, " ")+"\n"))
+}
+
+// cpuRenumbering function maps the ids of the given cpus to their position.
+func cpuRenumbering(cpus []int) map[int]int {
+
+	m := make(map[int]int)
+	for i, cpu := range cpus {
+		m[cpu] = i
+	}
+
+	return m
+}
+
+// filterSchedstat function drops the "cpuN" entries of the cpus not present in
+// 'cpus' from the given /proc/schedstat content. The "domainN" entries are
+// dropped altogether as they describe the host's scheduling domains.
+func filterSchedstat(content []byte, cpus []int) []byte {
+
+	var result bytes.Buffer
+
+	renum := cpuRenumbering(cpus)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "domain") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "cpu") {
+			fields := strings.SplitN(line, " ", 2)
+			cpu, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+			if err != nil {
+				continue
+			}
+			idx, ok := renum[cpu]
+			if !ok {
+				continue
+			}
+			fields[0] = fmt.Sprintf("cpu%d", idx)
+			line = strings.Join(fields, " ")
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	return result.Bytes()
+}
+
+// filterTimerList function sanitizes the given /proc/timer_list content: the
+// per-cpu sections of the cpus not present in 'cpus' are dropped, the active
+// timers (which refer to host tasks) are left out, as well as the tick-device
+// sections, and kernel addresses are zeroed.
+func filterTimerList(content []byte, cpus []int) []byte {
+
+	var (
+		result bytes.Buffer
+		keep   = true
+	)
+
+	renum := cpuRenumbering(cpus)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "Tick Device:") {
+			break
+		}
+
+		if strings.HasPrefix(line, "cpu: ") {
+			cpu, err := strconv.Atoi(strings.TrimPrefix(line, "cpu: "))
+			idx, ok := renum[cpu]
+			keep = err == nil && ok
+			if keep {
+				line = fmt.Sprintf("cpu: %d", idx)
+			}
+		}
+
+		if !keep || strings.HasPrefix(line, " #") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for _, field := range fields {
+			addr := strings.Trim(field, "<>,")
+			if len(addr) == 16 {
+				if _, err := strconv.ParseUint(addr, 16, 64); err == nil {
+					line = strings.Replace(line, addr, strings.Repeat("0", 16), 1)
+				}
+			}
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	return result.Bytes()
+}
+
+// readCrypto method rewrites the host's /proc/crypto to only display the
+// algorithms built into the kernel, leaving out those provided by modules
+// (e.g. hardware-accelerated implementations) which reveal the host's
+// configuration.
+func (h *Proc) readCrypto(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	var result bytes.Buffer
+
+	// Entries are made of "field : value" lines, and separated by empty ones.
+	for _, entry := range strings.Split(string(content), "\n\n") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		builtin := false
+		for _, line := range strings.Split(entry, "\n") {
+			fields := strings.SplitN(line, ":", 2)
+			if len(fields) == 2 &&
+				strings.TrimSpace(fields[0]) == "module" &&
+				strings.TrimSpace(fields[1]) == "kernel" {
+				builtin = true
+				break
+			}
+		}
+
+		if builtin {
+			result.WriteString(strings.Trim(entry, "\n") + "\n\n")
+		}
+	}
+
+	return copyResultBuffer(req.Data, result.Bytes())
+}
+
+// readKeys method displays /proc/keys and /proc/key-users as seen from within
+// the user-ns of the process originating the request, which restricts the
+// display to the keys this one can view. Entries of users not mapped into the
+// user-ns (i.e. displayed with the overflow uid) are dropped, as these ones
+// belong to the host or to other containers.
+func (h *Proc) readKeys(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	content, err := fetchNsFile(h, n, req)
+	if err != nil {
+		return 0, err
+	}
+
+	// Position of the uid field: "<serial> <flags> <usage> <expiry> <perm>
+	// <uid> <gid> <type> <description>" entries in /proc/keys, and "<uid>:
+	// <usage> ..." ones in /proc/key-users.
+	uidField := 5
+	if n.Name() == "key-users" {
+		uidField = 0
+	}
+
+	var result bytes.Buffer
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		fields := strings.Fields(line)
+		if len(fields) <= uidField ||
+			strings.TrimSuffix(fields[uidField], ":") == overflowUid {
+			continue
+		}
+
+		result.WriteString(line + "\n")
+	}
+
+	return copyResultBuffer(req.Data, result.Bytes())
+}
+
+// writeSysrqTrigger method emulates the sysrq keys written to
+// /proc/sysrq-trigger within the scope of the sys container: the keys
+// signaling tasks ('e' and 'i') are applied to the processes within the
+// container's pid-ns (through nsenter), whereas any other key (e.g. reboot,
+// crash, sync) is ignored so that the host is never affected. As in the
+// kernel, only the first key is processed, unless the write is prefixed with
+// '_', in which case all the keys are.
+func (h *Proc) writeSysrqTrigger(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	keys := req.Data
+	if len(keys) > 0 && keys[0] == '_' {
+		keys = keys[1:]
+	} else if len(keys) > 1 {
+		keys = keys[:1]
+	}
+
+	for _, key := range keys {
+		signal, ok := sysrqSignals[key]
+		if !ok {
+			logrus.Debugf("Ignoring sysrq key %q written by container %s",
+				key, cntr.ID())
+			continue
+		}
+
+		nss := h.Service.NSenterService()
+		event := nss.NewEvent(
+			cntr.InitPid(),
+			&domain.AllNSsButMount,
+			&domain.NSenterMessage{
+				Type:    domain.SignalProcsRequest,
+				ReqID:   req.ID,
+				Ctx:     req.Ctx,
+				Payload: &domain.SignalProcsReqPayload{Signal: int(signal)},
+			},
+			nil,
+			false,
+		)
+
+		if err := nss.SendRequestEvent(event); err != nil {
+			return 0, err
+		}
+
+		responseMsg := nss.ReceiveResponseEvent(event)
+		if responseMsg.Type == domain.ErrorResponse {
+			return 0, responseMsg.Payload.(error)
+		}
+	}
+
+	return len(req.Data), nil
+}
+
+// readKmsg method serves /proc/kmsg out of the sys container's own kernel log
+// (see domain.KernelLog), so that the host's kernel messages aren't exposed.
+// Records are consumed as read; as opposed to the kernel, reads don't block
+// when there are no pending records.
+func (h *Proc) readKmsg(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	data := cntr.KernelLog().Consume(len(req.Data))
+	if len(data) == 0 {
+		return 0, io.EOF
+	}
+
+	return copyResultBuffer(req.Data, data)
+}
+
+// readVersion method displays the host's /proc/version, with the kernel release
+// replaced by the one set for the sys container through its "osrelease"
+// override of /proc/sys/kernel (see domain.ContainerAttrRule), if any.
+func (h *Proc) readVersion(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing %v Read() method", h.Name)
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	if req.Container == nil {
+		return copyResultBuffer(req.Data, content)
+	}
+
+	ov, ok := req.Container.HandlerOverride("/proc/sys/kernel")
+	if !ok {
+		return copyResultBuffer(req.Data, content)
+	}
+	release, ok := ov.Values["osrelease"]
+	if !ok {
+		return copyResultBuffer(req.Data, content)
+	}
+
+	// Content is in "Linux version <release> (<builder>) ..." format.
+	fields := strings.SplitN(string(content), " ", 4)
+	if len(fields) != 4 || fields[1] != "version" {
+		return copyResultBuffer(req.Data, content)
+	}
+	fields[2] = release
+
+	return copyResultBuffer(req.Data, []byte(strings.Join(fields, " ")))
+}
@@ -0,0 +1,220 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/net handler
+//
+// Emulated resources:
+//
+// * /proc/net/dev
+// * /proc/net/snmp
+// * /proc/net/netstat
+//
+// Network-namespace-scoped stats (per-interface counters and protocol MIBs).
+// As /proc/net is a symlink to /proc/self/net, these files are displayed as
+// per the netns of the process reading them, which in the case of sysbox-fs is
+// the host one. Reads are hence always carried out (through nsenter) within the
+// network namespace of the process originating the request, and never served
+// from the passthrough handler's cache given that counters change by themselves.
+//
+// Only these files are registered as emulated nodes; the /proc/net dir itself
+// (and the rest of its entries) is left to the kernel.
+//
+type ProcNet struct {
+	domain.HandlerBase
+}
+
+var ProcNet_Handler = &ProcNet{
+	domain.HandlerBase{
+		Name:    "ProcNet",
+		Path:    "/proc/net",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"dev": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"netstat": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"snmp": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *ProcNet) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Nodes are looked up within the requester's netns.
+	return h.Service.GetPassThroughHandler().Lookup(n, req)
+}
+
+func (h *ProcNet) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		if n.OpenFlags() != syscall.O_RDONLY {
+			return fuse.IOerror{Code: syscall.EACCES}
+		}
+		return nil
+	}
+
+	return h.Service.GetPassThroughHandler().Open(n, req)
+}
+
+func (h *ProcNet) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		// Content is fetched at once, so we can save some cycles by returning
+		// right away if offset is any higher than zero.
+		if req.Offset > 0 {
+			return 0, io.EOF
+		}
+
+		return readNetnsFileUncached(h, n, req)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Read(n, req)
+}
+
+func (h *ProcNet) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		return 0, fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
+
+func (h *ProcNet) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Entries are enumerated within the requester's netns.
+	return h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+}
+
+func (h *ProcNet) GetName() string {
+	return h.Name
+}
+
+func (h *ProcNet) GetPath() string {
+	return h.Path
+}
+
+func (h *ProcNet) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ProcNet) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ProcNet) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *ProcNet) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *ProcNet) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *ProcNet) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *ProcNet) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
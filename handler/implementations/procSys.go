@@ -134,6 +134,10 @@ func (h *ProcSys) GetResourcesList() []string {
 	return resources
 }
 
+func (h *ProcSys) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSys) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
@@ -0,0 +1,83 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"os"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// /proc/sys/abi handler
+//
+// Emulated resources:
+//
+// * /proc/sys/abi/vsyscall32
+//
+// Documentation: Controls (x86_64) whether the vDSO is mapped into 32-bit
+// processes (1: enabled, 0: disabled).
+//
+// * /proc/sys/abi/*
+//
+// Any other node within this subtree.
+//
+// Same as with /proc/sys/debug, these are rarely utilized system-wide
+// attributes, so reads are served from the host, while writes are denied by
+// default ("read-only" policy) unless operators allow them through the
+// emulated-resources attributes file (see ProcSysDebug). Example:
+//
+//   { "path": "/proc/sys/abi/vsyscall32", "policy": "state-only" }
+//
+// The subtree itself is exposed even if not present in the host.
+//
+type ProcSysAbi struct {
+	WildcardBase
+}
+
+// Policies selectable through the emulated-resources attributes file.
+var procSysAbiPolicies = []domain.EmuResourcePolicy{
+	domain.ReadOnlyPolicy,
+	domain.StateOnlyPolicy,
+	domain.WriteThroughPolicy,
+}
+
+var ProcSysAbi_Handler = &ProcSysAbi{
+	WildcardBase{domain.HandlerBase{
+		Name:    "ProcSysAbi",
+		Path:    "/proc/sys/abi",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"vsyscall32": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.ReadOnlyPolicy,
+				Policies: procSysAbiPolicies,
+				Format:   domain.IntFormat,
+				Enabled:  true,
+			},
+			"*": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.ReadOnlyPolicy,
+				Policies: procSysAbiPolicies,
+				Format:   domain.StringFormat,
+				Enabled:  true,
+			},
+		},
+	}},
+}
@@ -0,0 +1,97 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"os"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// /proc/sys/debug handler
+//
+// Emulated resources:
+//
+// * /proc/sys/debug/exception-trace
+//
+// Documentation: Enables (1) / disables (0) the logging of unhandled signals
+// (e.g. segfaults) of user processes.
+//
+// * /proc/sys/debug/kprobes-optimization
+//
+// Documentation: Enables (1) / disables (0) the jump optimization of kprobes.
+//
+// * /proc/sys/debug/*
+//
+// Any other node within this subtree.
+//
+// These are rarely utilized system-wide attributes, so reads are served from
+// the host, while writes are denied by default ("read-only" policy). Operators
+// can allow them through the emulated-resources attributes file, either at sys
+// container level ("state-only" policy) or by passing them through to the
+// kernel ("write-through" policy), where the usual permission checks apply.
+// Example:
+//
+//   { "path": "/proc/sys/debug/exception-trace", "policy": "state-only" }
+//
+// The subtree itself is exposed even if not present in the host.
+//
+type ProcSysDebug struct {
+	WildcardBase
+}
+
+// Policies selectable through the emulated-resources attributes file.
+var procSysDebugPolicies = []domain.EmuResourcePolicy{
+	domain.ReadOnlyPolicy,
+	domain.StateOnlyPolicy,
+	domain.WriteThroughPolicy,
+}
+
+var ProcSysDebug_Handler = &ProcSysDebug{
+	WildcardBase{domain.HandlerBase{
+		Name:    "ProcSysDebug",
+		Path:    "/proc/sys/debug",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"exception-trace": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.ReadOnlyPolicy,
+				Policies: procSysDebugPolicies,
+				Format:   domain.IntFormat,
+				Enabled:  true,
+			},
+			"kprobes-optimization": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.ReadOnlyPolicy,
+				Policies: procSysDebugPolicies,
+				Format:   domain.IntFormat,
+				Enabled:  true,
+			},
+			"*": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.ReadOnlyPolicy,
+				Policies: procSysDebugPolicies,
+				Format:   domain.StringFormat,
+				Enabled:  true,
+			},
+		},
+	}},
+}
@@ -21,10 +21,13 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 )
 
 //
@@ -40,12 +43,75 @@ import (
 //
 // * /proc/sys/fs/protected_symlinks
 //
+// * /proc/sys/fs/protected_fifos
+//
+// * /proc/sys/fs/protected_regular
+//
+// Documentation: Hardening knobs restricting the creation of hard links, the
+// following of symlinks, and the opening of FIFOs / regular files (with O_CREAT)
+// in world-writable sticky directories. The last two accept 0 (disabled), 1
+// (applies to world-writable dirs) and 2 (applies to group-writable ones too).
+//
+// Note: As these are system-wide attributes, changes will be only made
+// superficially (at sys-container level), so hardening scripts can run within
+// sys containers while the host values are kept intact. Kernels lacking
+// protected_fifos / protected_regular (< 4.19) present them as disabled.
+//
+// * /proc/sys/fs/suid_dumpable
+//
+// Documentation: Value defining whether core dumps are produced for setuid or
+// otherwise protected/tainted binaries (0: default, 1: debug, 2: suidsafe).
+//
+// Note: Coredump generation is driven by the host kernel settings, which can't
+// be scoped to a sys container. Hence, values are only stored per container
+// (so debugging tooling can toggle them), and the host value is left untouched.
+//
+// * /proc/sys/fs/aio-max-nr
+//
+// Documentation: Maximum number of allowable concurrent async-io requests.
+//
+// Note: System-wide limit, so the largest value across all sys containers is
+// pushed to the host kernel, while each sys container keeps seeing the value
+// it wrote.
+//
+// * /proc/sys/fs/aio-nr
+//
+// Documentation: Running total of the number of events specified on the
+// io_setup system call for all currently active aio contexts. Read-only node
+// served (uncached) from the host.
+//
+// * /proc/sys/fs/pipe-max-size
+//
+// * /proc/sys/fs/pipe-user-pages-hard
+//
+// * /proc/sys/fs/pipe-user-pages-soft
+//
+// Documentation: Maximum size (bytes) of a pipe buffer settable by unprivileged
+// users, and hard / soft limits on the total number of pages that can be
+// allocated to pipes by a single unprivileged user (0: no limit).
+//
+// Note: Values are stored per container, clamped to the host ones, so that sys
+// containers can tighten (but never relax) the host pipe sizing limits.
+//
 
 const (
 	minProtectedSymlinksVal = 0
 	maxProtectedSymlinksVal = 1
 )
 
+const (
+	minProtectedOpenVal = 0
+	maxProtectedOpenVal = 2
+)
+
+// Smallest pipe buffer size (one page).
+const minPipeMaxSize = 4096
+
+const (
+	minSuidDumpableVal = 0
+	maxSuidDumpableVal = 2
+)
+
 const (
 	minProtectedHardlinksVal = 0
 	maxProtectedHardlinksVal = 1
@@ -64,21 +130,93 @@ var ProcSysFs_Handler = &ProcSysFs{
 			"file-max": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
 				Enabled: true,
 			},
 			"nr_open": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
 				Enabled: true,
 			},
 			"protected_hardlinks": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0600)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minProtectedHardlinksVal, Max: maxProtectedHardlinksVal},
 				Enabled: true,
 			},
 			"protected_symlinks": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0600)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minProtectedSymlinksVal, Max: maxProtectedSymlinksVal},
+				Enabled: true,
+			},
+			"protected_fifos": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0600)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minProtectedOpenVal, Max: maxProtectedOpenVal},
+				Enabled: true,
+			},
+			"aio-max-nr": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"aio-nr": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"pipe-max-size": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.HostClampedPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minPipeMaxSize, Max: MaxInt32},
+				Enabled: true,
+			},
+			"pipe-user-pages-hard": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.HostClampedPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt},
+				Enabled: true,
+			},
+			"pipe-user-pages-soft": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.HostClampedPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt},
+				Enabled: true,
+			},
+			"suid_dumpable": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minSuidDumpableVal, Max: maxSuidDumpableVal},
+				Enabled: true,
+			},
+			"protected_regular": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0600)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minProtectedOpenVal, Max: maxProtectedOpenVal},
 				Enabled: true,
 			},
 		},
@@ -94,6 +232,16 @@ func (h *ProcSysFs) Lookup(
 	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
 		req.ID, h.Name, resource)
 
+	// Nodes missing in older kernels are artificially exposed.
+	switch resource {
+	case "protected_fifos", "protected_regular":
+		return &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    h.EmuResourceMap[resource].Mode,
+			FmodTime: time.Now(),
+		}, nil
+	}
+
 	// If looked-up element hasn't been found by now, let's look into the actual
 	// sys container rootfs.
 	return h.Service.GetPassThroughHandler().Lookup(n, req)
@@ -120,6 +268,27 @@ func (h *ProcSysFs) Open(
 
 	case "protected_symlinks":
 		return nil
+
+	case "protected_fifos":
+		return nil
+
+	case "protected_regular":
+		return nil
+
+	case "suid_dumpable":
+		return nil
+
+	case "aio-max-nr":
+		return nil
+
+	case "pipe-max-size", "pipe-user-pages-hard", "pipe-user-pages-soft":
+		return nil
+
+	case "aio-nr":
+		if n.OpenFlags() != syscall.O_RDONLY {
+			return fuse.IOerror{Code: syscall.EACCES}
+		}
+		return nil
 	}
 
 	return h.Service.GetPassThroughHandler().Open(n, req)
@@ -152,6 +321,29 @@ func (h *ProcSysFs) Read(
 
 	case "protected_symlinks":
 		return readFileInt(h, n, req)
+
+	case "protected_fifos":
+		return readFileIntDefault(h, n, req, minProtectedOpenVal)
+
+	case "protected_regular":
+		return readFileIntDefault(h, n, req, minProtectedOpenVal)
+
+	case "suid_dumpable":
+		return readFileInt(h, n, req)
+
+	case "aio-max-nr":
+		return readFileInt(h, n, req)
+
+	case "pipe-max-size", "pipe-user-pages-hard", "pipe-user-pages-soft":
+		return readFileInt(h, n, req)
+
+	case "aio-nr":
+		data, err := fetchFileData(h, n, req.Container)
+		if err != nil {
+			return 0, err
+		}
+
+		return copyResultBuffer(req.Data, []byte(data+"\n"))
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -179,6 +371,24 @@ func (h *ProcSysFs) Write(
 
 	case "protected_symlinks":
 		return writeFileInt(h, n, req, minProtectedSymlinksVal, maxProtectedSymlinksVal, false)
+
+	case "protected_fifos":
+		return writeFileInt(h, n, req, minProtectedOpenVal, maxProtectedOpenVal, false)
+
+	case "protected_regular":
+		return writeFileInt(h, n, req, minProtectedOpenVal, maxProtectedOpenVal, false)
+
+	case "suid_dumpable":
+		return writeFileInt(h, n, req, minSuidDumpableVal, maxSuidDumpableVal, false)
+
+	case "aio-max-nr", "pipe-max-size":
+		return writeFileByPolicy(h, n, req, h.EmuResourceMap[resource])
+
+	case "pipe-user-pages-hard", "pipe-user-pages-soft":
+		return writeFileClampedInt(h, n, req, 0, true)
+
+	case "aio-nr":
+		return 0, nil
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -234,6 +444,10 @@ func (h *ProcSysFs) GetResourcesList() []string {
 	return resources
 }
 
+func (h *ProcSysFs) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSysFs) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
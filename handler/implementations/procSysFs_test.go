@@ -0,0 +1,97 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+func TestProcSysFs_WritePipeLimits(t *testing.T) {
+
+	var h = implementations.ProcSysFs_Handler
+
+	var c1 = css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		nil,
+	)
+
+	tests := []struct {
+		name     string
+		resource string
+		host     string
+		val      string
+		wantCntr string
+		wantErr  bool
+	}{
+		// Values below the host one are kept as they are.
+		{"1", "pipe-max-size", "1048576", "65536", "65536", false},
+
+		// Values above the host one are clamped to it.
+		{"2", "pipe-max-size", "1048576", "4194304", "1048576", false},
+
+		// Values below one page are rejected.
+		{"3", "pipe-max-size", "1048576", "1024", "", true},
+
+		// "No limit" requests can't relax a host limit.
+		{"4", "pipe-user-pages-hard", "16384", "0", "16384", false},
+
+		// Any value is honored if the host has no limit.
+		{"5", "pipe-user-pages-soft", "0", "32768", "32768", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/proc/sys/fs/" + tt.resource
+
+			n := ios.NewIOnode(tt.resource, path, 0644)
+			if err := n.WriteFile([]byte(tt.host)); err != nil {
+				t.Fatalf("Unable to initialize host value: %v", err)
+			}
+
+			req := &domain.HandlerRequest{
+				Data:      []byte(tt.val + "\n"),
+				Container: c1,
+			}
+
+			_, err := h.Write(n, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProcSysFs.Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, _ := c1.Data(path, tt.resource)
+			if got != tt.wantCntr {
+				t.Errorf("ProcSysFs.Write() container value = %v, want %v",
+					got, tt.wantCntr)
+			}
+		})
+	}
+}
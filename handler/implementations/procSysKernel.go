@@ -20,10 +20,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	cap "github.com/nestybox/sysbox-libs/capability"
 	"github.com/sirupsen/logrus"
 
 	"github.com/nestybox/sysbox-fs/domain"
@@ -177,6 +180,117 @@ import (
 // the kernel. This is something that we may need to improve in the future.
 // Example: "4   4 	1	7".
 //
+// * /proc/sys/kernel/sched_latency_ns
+//
+// * /proc/sys/kernel/sched_min_granularity_ns
+//
+// * /proc/sys/kernel/sched_wakeup_granularity_ns
+//
+// Documentation: CFS scheduler tunables: targeted preemption latency for
+// cpu-bound tasks, minimal preemption granularity, and wake-up preemption
+// granularity (nanoseconds).
+//
+// * /proc/sys/kernel/sched_rt_period_us
+//
+// * /proc/sys/kernel/sched_rt_runtime_us
+//
+// Documentation: Period over which real-time tasks bandwidth enforcement is
+// measured, and the portion of it that can be consumed by real-time tasks
+// (-1 disables the throttling).
+//
+// These are system-wide attributes, so changes will be only made superficially
+// (at sys-container level), leaving the host FS value untouched. Recent kernels
+// (5.13+) moved the CFS tunables to debugfs; in that case the kernel's default
+// values are presented to the sys container.
+//
+// * /proc/sys/kernel/numa_balancing
+//
+// Documentation: Enables / disables automatic NUMA memory balancing (0: off,
+// 1: normal mode, 2: memory tiering mode, 3: both).
+//
+// Note: As this is a system-wide attribute, changes will be only made
+// superficially (at sys-container level). Non-NUMA hosts don't expose this
+// node, in which case "0" is presented to the sys container.
+//
+// * /proc/sys/kernel/core_uses_pid
+//
+// Documentation: When set to 1, the coredump filename gets the pid of the
+// crashing process appended (".PID"), unless core_pattern already includes it.
+//
+// Note: Coredumps are generated by the host kernel as per its own settings, so
+// the value is only stored at sys-container level, leaving the host FS value
+// untouched.
+//
+// * /proc/sys/kernel/unprivileged_bpf_disabled
+//
+// Documentation: Controls whether unprivileged users can call bpf() (0: allowed,
+// 1: disabled with no way back, 2: disabled but can be re-enabled by admins).
+//
+// * /proc/sys/kernel/unprivileged_userns_clone
+//
+// Documentation: Debian / Ubuntu specific knob allowing (1) or preventing (0)
+// unprivileged users from creating user namespaces. Presented as "1" on kernels
+// lacking it.
+//
+// Note: These security toggles are virtual-only: changes are kept at the
+// sys-container level, so nested runtimes and security scanners can operate
+// normally without ever weakening the host. The sticky semantics of
+// unprivileged_bpf_disabled ("1") are honored within each sys container.
+//
+// * /proc/sys/kernel/perf_event_paranoid
+//
+// Documentation: Controls use of the performance events system by unprivileged
+// users (-1: no restrictions, 0: disallow raw tracepoint access, 1: disallow
+// cpu events access, 2: disallow kernel profiling; some distros add 3 / 4 to
+// fully disallow unprivileged perf_event_open()).
+//
+// Note: The value is stored per container, so profilers can relax it within a
+// sys container without affecting the host. The host kernel keeps enforcing
+// its own value on perf_event_open(), as this syscall is not trapped by
+// sysbox-fs.
+//
+// * /proc/sys/kernel/kexec_load_disabled
+//
+// Documentation: Toggle indicating if the kexec_load() syscall has been
+// disabled. Once set to "1", it can't be reverted.
+//
+// Note: Virtual-only attribute, with its sticky semantics honored within each
+// sys container.
+//
+// * /proc/sys/kernel/modules_disabled
+//
+// Documentation: Toggle indicating if modules are allowed to be loaded in an
+// otherwise modular kernel. Once set to "1", it can't be reverted.
+//
+// Note: Module loading is never allowed within a sys container, so this toggle
+// is virtual-only (with its sticky semantics honored per sys container).
+//
+// * /proc/sys/kernel/tainted
+//
+// Documentation: Bitmask reflecting the reasons for which the kernel has been
+// tainted (e.g. 1: proprietary module loaded, 4096: out-of-tree module loaded).
+//
+// Note: Read-only node served from the host, with the module-related taint
+// flags cleared, so that host's module state isn't leaked to sys containers.
+//
+// * /proc/sys/kernel/ns_last_pid
+//
+// Documentation: The last pid allocated within the pid namespace of the
+// accessing process. Checkpoint / restore tools (e.g. CRIU) write it to have
+// processes restored with their original pids.
+//
+// Note: Writes require cap_sys_admin or cap_checkpoint_restore, as in the
+// kernel. Accesses are carried out within the requester's namespaces (i.e. its
+// pid-ns and the user-ns owning it) and never cached, as the value is specific
+// to each pid namespace and changes on every process creation. Notice that the
+// nsenter process carrying out the access takes a pid within the requester's
+// pid namespace; this is harmless for writes (the pid is taken before the
+// value is written), but reads reflect this very pid.
+//
+
+// Capability granting checkpoint / restore operations (e.g. ns_last_pid
+// writes) without cap_sys_admin (kernel 5.9+).
+const capCheckpointRestore cap.Cap = 40
 
 const (
 	minSysrqVal = 0
@@ -193,6 +307,59 @@ const (
 	maxPanicOopsVal = 1
 )
 
+const (
+	minCoreUsesPidVal = 0
+	maxCoreUsesPidVal = 1
+)
+
+const (
+	minBpfDisabledVal    = 0
+	maxBpfDisabledVal    = 2
+	bpfDisabledStickyVal = 1
+)
+
+const (
+	minPerfParanoidVal = -1
+	maxPerfParanoidVal = 4
+)
+
+const (
+	minKexecDisabledVal = 0
+	maxKexecDisabledVal = 1
+)
+
+const (
+	minModulesDisabledVal = 0
+	maxModulesDisabledVal = 1
+)
+
+// Module-related taint flags (P, F, R, O, E and K) hidden from sys containers.
+const taintModuleFlagsMask = 1<<0 | 1<<1 | 1<<3 | 1<<12 | 1<<13 | 1<<15
+
+const (
+	minUsernsCloneVal = 0
+	maxUsernsCloneVal = 1
+)
+
+const (
+	minNumaBalancingVal = 0
+	maxNumaBalancingVal = 3
+)
+
+const (
+	minSchedGranularityNs = 100000
+	maxSchedGranularityNs = 1000000000
+)
+
+// Values presented whenever the sched_* tunables are not exposed by the host.
+var schedDefaults = map[string]int{
+	"sched_latency_ns":            6000000,
+	"sched_min_granularity_ns":    750000,
+	"sched_wakeup_granularity_ns": 1000000,
+	"sched_rt_period_us":          1000000,
+	"sched_rt_runtime_us":         950000,
+}
+
 type ProcSysKernel struct {
 	domain.HandlerBase
 }
@@ -206,51 +373,184 @@ var ProcSysKernel_Handler = &ProcSysKernel{
 			"domainname": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
 				Enabled: true,
 			},
 			"hostname": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
 				Enabled: true,
 			},
 			"kptr_restrict": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minRestrictVal, Max: maxRestrictVal},
 				Enabled: true,
 			},
 			"ngroups_max": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.IntFormat,
 				Enabled: true,
 			},
 			"cap_last_cap": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.IntFormat,
 				Enabled: true,
 			},
 			"panic": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
 				Enabled: true,
 			},
 			"panic_on_oops": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minPanicOopsVal, Max: maxPanicOopsVal},
 				Enabled: true,
 			},
 			"printk": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
 				Enabled: true,
 			},
 			"sysrq": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minSysrqVal, Max: maxSysrqVal},
 				Enabled: true,
 			},
 			"pid_max": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"core_uses_pid": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minCoreUsesPidVal, Max: maxCoreUsesPidVal},
+				Enabled: true,
+			},
+			"unprivileged_bpf_disabled": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minBpfDisabledVal, Max: maxBpfDisabledVal},
+				Enabled: true,
+			},
+			"unprivileged_userns_clone": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minUsernsCloneVal, Max: maxUsernsCloneVal},
+				Enabled: true,
+			},
+			"perf_event_paranoid": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minPerfParanoidVal, Max: maxPerfParanoidVal},
+				Enabled: true,
+			},
+			"kexec_load_disabled": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minKexecDisabledVal, Max: maxKexecDisabledVal},
+				Enabled: true,
+			},
+			"modules_disabled": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minModulesDisabledVal, Max: maxModulesDisabledVal},
+				Enabled: true,
+			},
+			"tainted": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"ns_last_pid": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0666)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"numa_balancing": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minNumaBalancingVal, Max: maxNumaBalancingVal},
+				Enabled: true,
+			},
+			"sched_latency_ns": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minSchedGranularityNs, Max: maxSchedGranularityNs},
+				Enabled: true,
+			},
+			"sched_min_granularity_ns": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minSchedGranularityNs, Max: maxSchedGranularityNs},
+				Enabled: true,
+			},
+			"sched_wakeup_granularity_ns": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: maxSchedGranularityNs},
+				Enabled: true,
+			},
+			"sched_rt_period_us": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 1, Max: MaxInt32},
+				Enabled: true,
+			},
+			"sched_rt_runtime_us": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: -1, Max: MaxInt32},
 				Enabled: true,
 			},
 		},
@@ -330,6 +630,37 @@ func (h *ProcSysKernel) Open(
 
 	case "printk":
 		return nil
+
+	case "sched_latency_ns",
+		"sched_min_granularity_ns",
+		"sched_wakeup_granularity_ns",
+		"sched_rt_period_us",
+		"sched_rt_runtime_us":
+		return nil
+
+	case "numa_balancing":
+		return nil
+
+	case "core_uses_pid":
+		return nil
+
+	case "unprivileged_bpf_disabled", "unprivileged_userns_clone":
+		return nil
+
+	case "perf_event_paranoid", "kexec_load_disabled":
+		return nil
+
+	case "modules_disabled":
+		return nil
+
+	case "tainted":
+		if flags != syscall.O_RDONLY {
+			return fuse.IOerror{Code: syscall.EACCES}
+		}
+		return nil
+
+	case "ns_last_pid":
+		return nil
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -381,6 +712,37 @@ func (h *ProcSysKernel) Read(
 
 	case "printk":
 		return readFileString(h, n, req)
+
+	case "numa_balancing":
+		return readFileIntDefault(h, n, req, minNumaBalancingVal)
+
+	case "core_uses_pid":
+		return readFileInt(h, n, req)
+
+	case "unprivileged_bpf_disabled":
+		return readFileInt(h, n, req)
+
+	case "perf_event_paranoid", "kexec_load_disabled":
+		return readFileInt(h, n, req)
+
+	case "modules_disabled":
+		return readFileIntDefault(h, n, req, minModulesDisabledVal)
+
+	case "tainted":
+		return h.readTainted(n, req)
+
+	case "ns_last_pid":
+		return h.readNsLastPid(n, req)
+
+	case "unprivileged_userns_clone":
+		return readFileIntDefault(h, n, req, maxUsernsCloneVal)
+
+	case "sched_latency_ns",
+		"sched_min_granularity_ns",
+		"sched_wakeup_granularity_ns",
+		"sched_rt_period_us",
+		"sched_rt_runtime_us":
+		return readFileIntDefault(h, n, req, schedDefaults[resource])
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -426,6 +788,44 @@ func (h *ProcSysKernel) Write(
 
 	case "hostname":
 		return writeFileString(h, n, req, false)
+
+	case "numa_balancing":
+		return writeFileInt(h, n, req, minNumaBalancingVal, maxNumaBalancingVal, false)
+
+	case "core_uses_pid":
+		return writeFileInt(h, n, req, minCoreUsesPidVal, maxCoreUsesPidVal, false)
+
+	case "unprivileged_bpf_disabled":
+		return h.writeFileStickyInt(
+			n, req, minBpfDisabledVal, maxBpfDisabledVal, bpfDisabledStickyVal)
+
+	case "perf_event_paranoid":
+		return writeFileInt(h, n, req, minPerfParanoidVal, maxPerfParanoidVal, false)
+
+	case "kexec_load_disabled":
+		return h.writeFileStickyInt(
+			n, req, minKexecDisabledVal, maxKexecDisabledVal, maxKexecDisabledVal)
+
+	case "unprivileged_userns_clone":
+		return writeFileInt(h, n, req, minUsernsCloneVal, maxUsernsCloneVal, false)
+
+	case "modules_disabled":
+		return h.writeFileStickyInt(
+			n, req, minModulesDisabledVal, maxModulesDisabledVal, maxModulesDisabledVal)
+
+	case "tainted":
+		return 0, nil
+
+	case "ns_last_pid":
+		return h.writeNsLastPid(n, req)
+
+	case "sched_latency_ns",
+		"sched_min_granularity_ns",
+		"sched_wakeup_granularity_ns",
+		"sched_rt_period_us",
+		"sched_rt_runtime_us":
+		bounds := h.EmuResourceMap[resource].Bounds
+		return writeFileInt(h, n, req, bounds.Min, bounds.Max, false)
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -481,6 +881,10 @@ func (h *ProcSysKernel) GetResourcesList() []string {
 	return resources
 }
 
+func (h *ProcSysKernel) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSysKernel) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
@@ -493,3 +897,126 @@ func (h *ProcSysKernel) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 func (h *ProcSysKernel) SetService(hs domain.HandlerServiceIface) {
 	h.Service = hs
 }
+
+// Stores the container's value of a toggle that, once set to 'sticky', can't be
+// changed anymore (as per kernel semantics).
+func (h *ProcSysKernel) writeFileStickyInt(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	min, max, sticky int) (int, error) {
+
+	cntr := req.Container
+
+	cntr.Lock()
+	curVal, ok := cntr.Data(n.Path(), n.Name())
+	cntr.Unlock()
+
+	if ok && curVal == strconv.Itoa(sticky) &&
+		strings.TrimSpace(string(req.Data)) != curVal {
+		return 0, fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	return writeFileInt(h, n, req, min, max, false)
+}
+
+// Serves the host's taint bitmask with the module-related flags cleared. Value
+// is fetched on every read as the host can be tainted at any time.
+func (h *ProcSysKernel) readTainted(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	data, err := fetchFileData(h, n, req.Container)
+	if err != nil {
+		return 0, err
+	}
+
+	val, err := strconv.ParseUint(strings.TrimSpace(data), 10, 64)
+	if err != nil {
+		logrus.Errorf("Unexpected taint value %q: %v", data, err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	val &^= taintModuleFlagsMask
+
+	return copyResultBuffer(req.Data, []byte(strconv.FormatUint(val, 10)+"\n"))
+}
+
+func (h *ProcSysKernel) readNsLastPid(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		req.Pid,
+		&domain.AllNSsButMount,
+		&domain.NSenterMessage{
+			Type:  domain.ReadFileRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
+			Payload: &domain.ReadFilePayload{
+				File: n.Path(),
+			},
+		},
+		nil,
+		false,
+	)
+
+	if err := nss.SendRequestEvent(event); err != nil {
+		return 0, err
+	}
+
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return 0, responseMsg.Payload.(error)
+	}
+
+	data := strings.TrimSpace(responseMsg.Payload.(string)) + "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *ProcSysKernel) writeNsLastPid(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	prs := h.Service.ProcessService()
+	process := prs.ProcessCreate(req.Pid, req.Uid, req.Gid)
+
+	if !process.IsSysAdminCapabilitySet() &&
+		!process.IsCapabilitySet(cap.EFFECTIVE, capCheckpointRestore) {
+		return 0, fuse.IOerror{Code: syscall.EPERM}
+	}
+
+	newVal := strings.TrimSpace(string(req.Data))
+	if _, err := strconv.Atoi(newVal); err != nil {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		req.Pid,
+		&domain.AllNSsButMount,
+		&domain.NSenterMessage{
+			Type:  domain.WriteFileRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
+			Payload: &domain.WriteFilePayload{
+				File:    n.Path(),
+				Content: newVal,
+			},
+		},
+		nil,
+		false,
+	)
+
+	if err := nss.SendRequestEvent(event); err != nil {
+		return 0, err
+	}
+
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return 0, responseMsg.Payload.(error)
+	}
+
+	return len(req.Data), nil
+}
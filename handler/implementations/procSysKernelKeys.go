@@ -0,0 +1,271 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// /proc/sys/kernel/keys handler
+//
+// Emulated resources:
+//
+// * /proc/sys/kernel/keys/maxkeys
+//
+// * /proc/sys/kernel/keys/maxbytes
+//
+// * /proc/sys/kernel/keys/root_maxkeys
+//
+// * /proc/sys/kernel/keys/root_maxbytes
+//
+// Documentation: Maximum number of keys, and of bytes of data, that a non-root
+// (root) user can hold in their keyrings.
+//
+// * /proc/sys/kernel/keys/gc_delay
+//
+// * /proc/sys/kernel/keys/persistent_keyring_expiry
+//
+// Documentation: Delay (seconds) between a key being revoked / expired and it
+// being garbage collected, and expiry timeout of persistent keyrings.
+//
+// Note: Key quotas are tracked by the kernel per (host) user, and can't be
+// scoped to a sys container's keyrings. Hence, values are stored per container
+// by default, leaving the host FS value untouched. Operators that need the
+// quotas requested within sys containers (e.g. by kubelet) to be effective can
+// opt for a "pooled-max" policy through the emulated-resources attributes
+// config.
+//
+
+// Policies supported by the keys quota attributes.
+var keysQuotaPolicies = []domain.EmuResourcePolicy{
+	domain.StateOnlyPolicy,
+	domain.PooledMaxPolicy,
+}
+
+type ProcSysKernelKeys struct {
+	domain.HandlerBase
+}
+
+var ProcSysKernelKeys_Handler = &ProcSysKernelKeys{
+	domain.HandlerBase{
+		Name:    "ProcSysKernelKeys",
+		Path:    "/proc/sys/kernel/keys",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"maxkeys": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.StateOnlyPolicy,
+				Policies: keysQuotaPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"maxbytes": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.StateOnlyPolicy,
+				Policies: keysQuotaPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"root_maxkeys": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.StateOnlyPolicy,
+				Policies: keysQuotaPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"root_maxbytes": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.StateOnlyPolicy,
+				Policies: keysQuotaPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"gc_delay": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"persistent_keyring_expiry": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *ProcSysKernelKeys) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// emulated nodes.
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	// If looked-up element hasn't been found by now, let's look into the actual
+	// container rootfs.
+	return h.Service.GetPassThroughHandler().Lookup(n, req)
+}
+
+func (h *ProcSysKernelKeys) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	return nil
+}
+
+func (h *ProcSysKernelKeys) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// We are dealing with a single integer element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		return readFileInt(h, n, req)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Read(n, req)
+}
+
+func (h *ProcSysKernelKeys) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		return writeFileByPolicy(h, n, req, v)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
+
+func (h *ProcSysKernelKeys) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Return all entries as seen within container's namespaces.
+	return h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+}
+
+func (h *ProcSysKernelKeys) GetName() string {
+	return h.Name
+}
+
+func (h *ProcSysKernelKeys) GetPath() string {
+	return h.Path
+}
+
+func (h *ProcSysKernelKeys) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ProcSysKernelKeys) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ProcSysKernelKeys) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *ProcSysKernelKeys) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *ProcSysKernelKeys) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *ProcSysKernelKeys) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *ProcSysKernelKeys) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
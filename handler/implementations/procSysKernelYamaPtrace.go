@@ -108,6 +108,9 @@ var ProcSysKernelYama_Handler = &ProcSysKernelYama{
 			"ptrace_scope": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minScopeVal, Max: maxScopeVal},
 				Enabled: true,
 			},
 		},
@@ -238,6 +241,10 @@ func (h *ProcSysKernelYama) GetResourcesList() []string {
 	return resources
 }
 
+func (h *ProcSysKernelYama) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSysKernelYama) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
@@ -0,0 +1,140 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	cap "github.com/nestybox/sysbox-libs/capability"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/mocks"
+	"github.com/nestybox/sysbox-fs/nsenter"
+)
+
+func TestProcSysKernel_WriteNsLastPid(t *testing.T) {
+
+	var c1 = css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		nil,
+	)
+
+	// Dedicated process service, to control the requester's capabilities.
+	var prsMock = &mocks.ProcessServiceIface{}
+	var hdsMock = &mocks.HandlerServiceIface{}
+	hdsMock.On("NSenterService").Return(nss)
+	hdsMock.On("ProcessService").Return(prsMock)
+
+	h := &implementations.ProcSysKernel{
+		domain.HandlerBase{
+			Name:    "ProcSysKernel",
+			Path:    "/proc/sys/kernel",
+			Service: hdsMock,
+		},
+	}
+
+	n := ios.NewIOnode("ns_last_pid", "/proc/sys/kernel/ns_last_pid", 0666)
+
+	tests := []struct {
+		name        string
+		sysAdmin    bool
+		ckptRstr    bool
+		val         string
+		wantNsenter bool
+		wantErrVal  error
+	}{
+		// Requesters with cap_sys_admin can set the value.
+		{"1", true, false, "1000", true, nil},
+
+		// Requesters with cap_checkpoint_restore can set the value.
+		{"2", false, true, "1000", true, nil},
+
+		// Requesters lacking both capabilities are denied.
+		{"3", false, false, "1000", false, fuse.IOerror{Code: syscall.EPERM}},
+
+		// Non-numeric values are rejected.
+		{"4", true, false, "abc", false, fuse.IOerror{Code: syscall.EINVAL}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &domain.HandlerRequest{
+				Pid:       1001,
+				Data:      []byte(tt.val + "\n"),
+				Container: c1,
+			}
+
+			process := &mocks.ProcessIface{}
+			process.On("IsSysAdminCapabilitySet").Return(tt.sysAdmin)
+			process.On("IsCapabilitySet", cap.EFFECTIVE, cap.Cap(40)).Return(tt.ckptRstr)
+			prsMock.On("ProcessCreate", req.Pid, req.Uid, req.Gid).Return(process)
+
+			if tt.wantNsenter {
+				nsenterEventReq := &nsenter.NSenterEvent{
+					Pid:       req.Pid,
+					Namespace: &domain.AllNSsButMount,
+					ReqMsg: &domain.NSenterMessage{
+						Type: domain.WriteFileRequest,
+						Payload: &domain.WriteFilePayload{
+							File:    n.Path(),
+							Content: tt.val,
+						},
+					},
+				}
+
+				nss.On(
+					"NewEvent",
+					req.Pid,
+					&domain.AllNSsButMount,
+					nsenterEventReq.ReqMsg,
+					(*domain.NSenterMessage)(nil),
+					false).Return(nsenterEventReq)
+
+				nss.On("SendRequestEvent", nsenterEventReq).Return(nil)
+				nss.On("ReceiveResponseEvent", nsenterEventReq).Return(
+					&domain.NSenterMessage{Type: domain.WriteFileResponse})
+			}
+
+			got, err := h.Write(n, req)
+			if err != tt.wantErrVal {
+				t.Fatalf("ProcSysKernel.Write() error = %v, wantErrVal %v",
+					err, tt.wantErrVal)
+			}
+			if err == nil && got != len(req.Data) {
+				t.Errorf("ProcSysKernel.Write() = %v, want %v", got, len(req.Data))
+			}
+
+			// Ensure that mocks were properly invoked and reset expectedCalls
+			// object.
+			nss.AssertExpectations(t)
+			nss.ExpectedCalls = nil
+			prsMock.ExpectedCalls = nil
+		})
+	}
+}
@@ -0,0 +1,314 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// /proc/sys/net/bridge handler
+//
+// Emulated resources:
+//
+// * /proc/sys/net/bridge/bridge-nf-call-iptables
+//
+// * /proc/sys/net/bridge/bridge-nf-call-ip6tables
+//
+// * /proc/sys/net/bridge/bridge-nf-call-arptables
+//
+// Documentation: Pass bridged IPv4 / IPv6 / ARP traffic to iptables' chains
+// (0: disabled, 1: enabled). Defaults to 1.
+//
+// These nodes are only present when the br_netfilter module is loaded, and are
+// netns-scoped in recent kernels (5.3+). Reads and writes are thereby carried
+// out within the requester's netns whenever the nodes are exposed there. When
+// that's not the case (module not loaded, or older kernels), sysbox-fs exposes
+// the bridge directory itself, and keeps a per-container virtual state for
+// these nodes, so Kubernetes preflight checks running within sys containers
+// don't fail.
+//
+type ProcSysNetBridge struct {
+	domain.HandlerBase
+}
+
+// Value presented when the br_netfilter module is not loaded in the host.
+const bridgeNfCallDefault = "1"
+
+var ProcSysNetBridge_Handler = &ProcSysNetBridge{
+	domain.HandlerBase{
+		Name:    "ProcSysNetBridge",
+		Path:    "/proc/sys/net/bridge",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"bridge-nf-call-iptables": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"bridge-nf-call-ip6tables": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"bridge-nf-call-arptables": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *ProcSysNetBridge) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Expose the bridge directory even if br_netfilter is not loaded.
+	if n.Path() == h.Path {
+		info, err := h.Service.GetPassThroughHandler().Lookup(n, req)
+		if err == nil {
+			return info, nil
+		}
+
+		return &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0555)),
+			FmodTime: time.Now(),
+			FisDir:   true,
+		}, nil
+	}
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// emulated nodes.
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	// If looked-up element hasn't been found by now, let's look into the actual
+	// sys container rootfs.
+	return h.Service.GetPassThroughHandler().Lookup(n, req)
+}
+
+func (h *ProcSysNetBridge) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if n.Path() == h.Path {
+		return nil
+	}
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		return nil
+	}
+
+	return h.Service.GetPassThroughHandler().Open(n, req)
+}
+
+func (h *ProcSysNetBridge) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		// Single boolean element being read, so we can save some cycles by
+		// returning right away if offset is any higher than zero.
+		if req.Offset > 0 {
+			return 0, io.EOF
+		}
+
+		return h.readBridgeNfCall(n, req)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Read(n, req)
+}
+
+func (h *ProcSysNetBridge) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		if _, err := parseIntTuple(
+			string(req.Data), 1, v.Bounds.Min, v.Bounds.Max); err != nil {
+			return 0, err
+		}
+
+		return writeNetnsFileOrState(h, n, req)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
+
+func (h *ProcSysNetBridge) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Obtain the usual entries seen within container's namespaces, if any.
+	fileEntries, _ := h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+
+	if n.Path() != h.Path {
+		return fileEntries, nil
+	}
+
+	present := make(map[string]bool, len(fileEntries))
+	for _, e := range fileEntries {
+		present[e.Name()] = true
+	}
+
+	for k, v := range h.EmuResourceMap {
+		if present[k] {
+			continue
+		}
+
+		info := &domain.FileInfo{
+			Fname:    k,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		fileEntries = append(fileEntries, info)
+	}
+
+	return fileEntries, nil
+}
+
+func (h *ProcSysNetBridge) GetName() string {
+	return h.Name
+}
+
+func (h *ProcSysNetBridge) GetPath() string {
+	return h.Path
+}
+
+func (h *ProcSysNetBridge) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ProcSysNetBridge) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ProcSysNetBridge) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *ProcSysNetBridge) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *ProcSysNetBridge) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *ProcSysNetBridge) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *ProcSysNetBridge) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+func (h *ProcSysNetBridge) readBridgeNfCall(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	num, err := readNetnsFile(h, n, req)
+	if err == nil || !isNotExistError(err) {
+		return num, err
+	}
+
+	// Node not exposed within the requester's netns, so serve the container's
+	// virtual state (see writeNetnsFileOrState()).
+	cntr := req.Container
+
+	cntr.Lock()
+	data, ok := cntr.Data(n.Path(), n.Name())
+	if !ok {
+		data = bridgeNfCallDefault
+	}
+	cntr.Unlock()
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
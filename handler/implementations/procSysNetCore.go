@@ -68,6 +68,45 @@ import (
 // Somaxconn refers to the maximum number of clients that the server can accept
 // to process data, that is, to complete the connection limit. Defaults to 128.
 //
+// This is a network-namespace-scoped attribute, so reads and writes are carried
+// out within the requester's network namespace (through nsenter). This way each
+// sys container (and any netns created within it) holds its own value, and the
+// host's netns is never altered.
+//
+// * /proc/sys/net/core/rmem_max
+//
+// * /proc/sys/net/core/wmem_max
+//
+// * /proc/sys/net/core/rmem_default
+//
+// * /proc/sys/net/core/wmem_default
+//
+// Documentation: The maximum (and default) receive / send socket buffer sizes
+// in bytes. High-throughput applications (e.g. QUIC or gRPC servers) typically
+// need to raise the maximum values to be able to enlarge their socket buffers.
+//
+// These attributes are not network-namespace-scoped (they are only exposed in
+// the initial netns), so every sys container is presented with its own value
+// while sysbox-fs pushes the largest one across all sys containers to the
+// host kernel.
+//
+// * /proc/sys/net/core/netdev_max_backlog
+//
+// Documentation: Maximum number of packets, queued on the INPUT side, when the
+// interface receives packets faster than kernel can process them.
+//
+// * /proc/sys/net/core/netdev_budget
+//
+// Documentation: Maximum number of packets taken from all interfaces in one
+// polling cycle (NAPI poll).
+//
+// These are system-wide attributes, so every sys container is presented with
+// its own value. By default ("state-only" policy) the host FS value is left
+// untouched; operators running packet-processing workloads (e.g. DPDK-adjacent
+// apps or load-balancers) can opt for the "pooled-max" policy through the
+// emulated-resources attributes config, in which case the largest value across
+// all sys containers is pushed to the host kernel.
+//
 type ProcSysNetCore struct {
 	domain.HandlerBase
 }
@@ -81,11 +120,68 @@ var ProcSysNetCore_Handler = &ProcSysNetCore{
 			"default_qdisc": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
 				Enabled: true,
 			},
 			"somaxconn": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"rmem_max": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"wmem_max": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"rmem_default": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"wmem_default": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"netdev_max_backlog": {
+				Kind:   domain.FileEmuResource,
+				Mode:   os.FileMode(uint32(0644)),
+				Policy: domain.StateOnlyPolicy,
+				Policies: []domain.EmuResourcePolicy{
+					domain.StateOnlyPolicy,
+					domain.PooledMaxPolicy,
+				},
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 1, Max: MaxInt32},
+				Enabled: true,
+			},
+			"netdev_budget": {
+				Kind:   domain.FileEmuResource,
+				Mode:   os.FileMode(uint32(0644)),
+				Policy: domain.StateOnlyPolicy,
+				Policies: []domain.EmuResourcePolicy{
+					domain.StateOnlyPolicy,
+					domain.PooledMaxPolicy,
+				},
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 1, Max: MaxInt32},
 				Enabled: true,
 			},
 		},
@@ -145,6 +241,12 @@ func (h *ProcSysNetCore) Read(
 		return readFileString(h, n, req)
 
 	case "somaxconn":
+		return readNetnsFile(h, n, req)
+
+	case "rmem_max", "wmem_max", "rmem_default", "wmem_default":
+		return readFileInt(h, n, req)
+
+	case "netdev_max_backlog", "netdev_budget":
 		return readFileInt(h, n, req)
 	}
 
@@ -166,7 +268,13 @@ func (h *ProcSysNetCore) Write(
 		return h.writeDefaultQdisc(n, req)
 
 	case "somaxconn":
+		return writeNetnsFileInt(h, n, req, 0, MaxInt32)
+
+	case "rmem_max", "wmem_max", "rmem_default", "wmem_default":
 		return writeFileMaxInt(h, n, req, true)
+
+	case "netdev_max_backlog", "netdev_budget":
+		return writeFileByPolicy(h, n, req, h.EmuResourceMap[resource])
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -241,6 +349,11 @@ func (h *ProcSysNetCore) GetResourcesList() []string {
 
 	return resources
 }
+
+func (h *ProcSysNetCore) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSysNetCore) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
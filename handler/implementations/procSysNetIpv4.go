@@ -0,0 +1,520 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/sys/net/ipv4 handler
+//
+// Emulated resources:
+//
+// * /proc/sys/net/ipv4/ip_forward
+//
+// Documentation: Forward packets between interfaces. This variable is special,
+// its change resets all configuration parameters to their default state (RFC1122
+// for hosts, RFC1812 for routers). Defaults to 0.
+//
+// This is a network-namespace-scoped attribute, so reads and writes are carried
+// out within the requester's network namespace (through nsenter). This allows
+// nested Docker / Kubernetes networking to enable forwarding within the sys
+// container without ever touching the host's netns.
+//
+// * /proc/sys/net/ipv4/ip_local_port_range
+//
+// Documentation: Defines the local port range that is used by TCP and UDP to
+// choose the local port. The first number is the first, the second the last
+// local port number. Must be in the 1..65535 range, and the first number can't
+// be greater than the second one. Defaults to "32768 60999".
+//
+// Also scoped to the network namespace, so the "low high" tuple is validated by
+// sysbox-fs and then written within the requester's netns, and reads return the
+// container's own range.
+//
+// * /proc/sys/net/ipv4/tcp_rmem
+//
+// * /proc/sys/net/ipv4/tcp_wmem
+//
+// Documentation: Vectors of 3 integers (min, default, max) defining the size of
+// the receive / send buffers used by TCP sockets.
+//
+// * /proc/sys/net/ipv4/tcp_max_syn_backlog
+//
+// Documentation: Maximal number of remembered connection requests (SYN_RECV),
+// which have not received an acknowledgment from connecting client.
+//
+// * /proc/sys/net/ipv4/tcp_tw_reuse
+//
+// Documentation: Enable reuse of TIME-WAIT sockets for new connections when it
+// is safe from protocol viewpoint (0: disable, 1: global enable, 2: enable for
+// loopback traffic only).
+//
+// * /proc/sys/net/ipv4/tcp_fin_timeout
+//
+// Documentation: The length of time (seconds) an orphaned connection will remain
+// in the FIN_WAIT_2 state before it is aborted at the local end.
+//
+// * /proc/sys/net/ipv4/tcp_keepalive_time
+//
+// * /proc/sys/net/ipv4/tcp_keepalive_intvl
+//
+// * /proc/sys/net/ipv4/tcp_keepalive_probes
+//
+// Documentation: How often TCP sends out keepalive messages when keepalive is
+// enabled, the interval between unacknowledged probes, and the number of
+// probes sent before deciding that the connection is broken.
+//
+// All these tcp attributes are network-namespace-scoped in recent kernels, so
+// they are read / written within the requester's netns. Kernels that don't
+// expose them in non-initial netns (e.g. tcp_rmem/tcp_wmem prior to 4.15) get
+// them emulated through a per-container virtual state, leaving the host value
+// untouched.
+//
+// * /proc/sys/net/ipv4/tcp_available_congestion_control
+//
+// Documentation: Shows the available congestion control choices that are
+// registered. Always served from the host, as it reflects the set of kernel
+// modules currently loaded.
+//
+// * /proc/sys/net/ipv4/tcp_congestion_control
+//
+// Documentation: Set the congestion control algorithm to be used for new
+// connections. Netns-scoped in recent kernels (with a per-container virtual
+// state otherwise, as per the tcp attributes above). Written algorithms must
+// be available in the host, as sys containers are not allowed to load the
+// associated kernel modules.
+//
+type ProcSysNetIpv4 struct {
+	domain.HandlerBase
+}
+
+const (
+	minLocalPort = 1
+	maxLocalPort = 65535
+)
+
+var ProcSysNetIpv4_Handler = &ProcSysNetIpv4{
+	domain.HandlerBase{
+		Name:    "ProcSysNetIpv4",
+		Path:    "/proc/sys/net/ipv4",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"ip_forward": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"ip_local_port_range": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.TextFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: minLocalPort, Max: maxLocalPort},
+				Enabled: true,
+			},
+			"tcp_rmem": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.TextFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 1, Max: MaxInt32},
+				Enabled: true,
+			},
+			"tcp_wmem": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.TextFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 1, Max: MaxInt32},
+				Enabled: true,
+			},
+			"tcp_max_syn_backlog": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"tcp_tw_reuse": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 2},
+				Enabled: true,
+			},
+			"tcp_fin_timeout": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"tcp_keepalive_time": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"tcp_keepalive_intvl": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"tcp_available_congestion_control": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+			"tcp_congestion_control": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"tcp_keepalive_probes": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 255},
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *ProcSysNetIpv4) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// emulated nodes.
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	// If looked-up element hasn't been found by now, let's look into the actual
+	// sys container rootfs.
+	return h.Service.GetPassThroughHandler().Lookup(n, req)
+}
+
+func (h *ProcSysNetIpv4) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	switch resource {
+	case "tcp_available_congestion_control":
+		if n.OpenFlags() != syscall.O_RDONLY {
+			return fuse.IOerror{Code: syscall.EACCES}
+		}
+		return nil
+	}
+
+	if _, ok := h.EmuResourceMap[resource]; ok {
+		return nil
+	}
+
+	return h.Service.GetPassThroughHandler().Open(n, req)
+}
+
+func (h *ProcSysNetIpv4) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	switch resource {
+	case "ip_forward", "ip_local_port_range":
+		// Single-line elements being read, so we can save some cycles by
+		// returning right away if offset is any higher than zero.
+		if req.Offset > 0 {
+			return 0, io.EOF
+		}
+
+		return readNetnsFile(h, n, req)
+
+	case "tcp_rmem",
+		"tcp_wmem",
+		"tcp_max_syn_backlog",
+		"tcp_tw_reuse",
+		"tcp_fin_timeout",
+		"tcp_keepalive_time",
+		"tcp_keepalive_intvl",
+		"tcp_keepalive_probes",
+		"tcp_congestion_control":
+		if req.Offset > 0 {
+			return 0, io.EOF
+		}
+
+		return readNetnsFileOrState(h, n, req)
+
+	case "tcp_available_congestion_control":
+		if req.Offset > 0 {
+			return 0, io.EOF
+		}
+
+		data, err := fetchFileData(h, n, req.Container)
+		if err != nil {
+			return 0, err
+		}
+
+		return copyResultBuffer(req.Data, []byte(data+"\n"))
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Read(n, req)
+}
+
+func (h *ProcSysNetIpv4) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	switch resource {
+	case "ip_forward":
+		return writeNetnsFileInt(h, n, req, 0, 1)
+
+	case "ip_local_port_range":
+		return h.writeIpLocalPortRange(n, req)
+
+	case "tcp_rmem", "tcp_wmem":
+		return h.writeTcpParam(n, req, 3)
+
+	case "tcp_max_syn_backlog",
+		"tcp_tw_reuse",
+		"tcp_fin_timeout",
+		"tcp_keepalive_time",
+		"tcp_keepalive_intvl",
+		"tcp_keepalive_probes":
+		return h.writeTcpParam(n, req, 1)
+
+	case "tcp_available_congestion_control":
+		return 0, nil
+
+	case "tcp_congestion_control":
+		return h.writeTcpCongestionControl(n, req)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
+
+func (h *ProcSysNetIpv4) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Obtain the usual entries seen within container's namespaces.
+	fileEntries, err := h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Emulated nodes are typically present in the container's netns too; add
+	// the ones that aren't (i.e. kernels lacking netns-scoping for them).
+	present := make(map[string]bool, len(fileEntries))
+	for _, e := range fileEntries {
+		present[e.Name()] = true
+	}
+
+	for k, v := range h.EmuResourceMap {
+		if present[k] {
+			continue
+		}
+
+		info := &domain.FileInfo{
+			Fname:    k,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		fileEntries = append(fileEntries, info)
+	}
+
+	return fileEntries, nil
+}
+
+func (h *ProcSysNetIpv4) GetName() string {
+	return h.Name
+}
+
+func (h *ProcSysNetIpv4) GetPath() string {
+	return h.Path
+}
+
+func (h *ProcSysNetIpv4) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ProcSysNetIpv4) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ProcSysNetIpv4) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *ProcSysNetIpv4) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *ProcSysNetIpv4) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *ProcSysNetIpv4) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *ProcSysNetIpv4) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+func (h *ProcSysNetIpv4) writeIpLocalPortRange(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	// Expected format: "<low> <high>" (any whitespace separator).
+	vals, err := parseIntTuple(string(req.Data), 2, minLocalPort, maxLocalPort)
+	if err != nil {
+		return 0, err
+	}
+
+	if vals[0] > vals[1] {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
+
+// Validates the 'count' integers being written to a tcp attribute against the
+// resource bounds, and pushes them to the requester's netns (or container
+// state).
+func (h *ProcSysNetIpv4) writeTcpParam(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	count int) (int, error) {
+
+	bounds := h.EmuResourceMap[n.Name()].Bounds
+
+	_, err := parseIntTuple(string(req.Data), count, bounds.Min, bounds.Max)
+	if err != nil {
+		return 0, err
+	}
+
+	return writeNetnsFileOrState(h, n, req)
+}
+
+func (h *ProcSysNetIpv4) writeTcpCongestionControl(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	newVal := strings.TrimSpace(string(req.Data))
+	if newVal == "" {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	// Only algorithms already registered in the host kernel can be picked.
+	ios := h.Service.IOService()
+	availNode := ios.NewIOnode(
+		"tcp_available_congestion_control",
+		filepath.Join(h.Path, "tcp_available_congestion_control"),
+		0)
+	avail, err := availNode.ReadLine()
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	for _, algo := range strings.Fields(avail) {
+		if algo == newVal {
+			return writeNetnsFileOrState(h, n, req)
+		}
+	}
+
+	return 0, fuse.IOerror{Code: syscall.ENOENT}
+}
@@ -0,0 +1,382 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /proc/sys/net/ipv4/conf handler
+//
+// Emulated resources:
+//
+// * /proc/sys/net/ipv4/conf/{all,default,<iface>}/<attr>
+//
+// Per-interface ipv4 attributes (arp_ignore, rp_filter, accept_redirects,
+// route_localnet, forwarding, etc). These are network-namespace-scoped, so the
+// interfaces exposed are those present in the requester's netns (as enumerated
+// by the passthrough handler), and the attributes are read / written within
+// that same netns once validated against their bounds. Reads are never served
+// from the passthrough handler's cache, as these attributes are frequently
+// modified by other agents within the sys container (e.g. CNI plugins of nested
+// Kubernetes deployments).
+//
+// * /proc/sys/net/ipv4/conf/<iface>/mc_forwarding
+//
+// Documentation: Multicast routing status of the interface. Only the kernel
+// (i.e. a multicast routing daemon through its socket API) can modify it, so it
+// is exposed as read-only.
+//
+// Resources are registered through "*/<attr>" patterns, given that interface
+// names are only known at runtime.
+//
+// Tools such as 'sysctl -a' enumerate each interface directory and then read
+// every attribute within it, so the emulated attributes of an interface are
+// prefetched through a single nsenter round trip upon its enumeration.
+//
+type ProcSysNetIpv4Conf struct {
+	domain.HandlerBase
+}
+
+var ProcSysNetIpv4Conf_Handler = &ProcSysNetIpv4Conf{
+	domain.HandlerBase{
+		Name:    "ProcSysNetIpv4Conf",
+		Path:    "/proc/sys/net/ipv4/conf",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"*/accept_local": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/accept_redirects": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/accept_source_route": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/arp_accept": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 2},
+				Enabled: true,
+			},
+			"*/arp_announce": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 2},
+				Enabled: true,
+			},
+			"*/arp_filter": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/arp_ignore": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 8},
+				Enabled: true,
+			},
+			"*/arp_notify": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/forwarding": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/log_martians": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/mc_forwarding": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+			"*/proxy_arp": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/route_localnet": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/rp_filter": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 2},
+				Enabled: true,
+			},
+			"*/secure_redirects": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/send_redirects": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"*/src_valid_mark": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *ProcSysNetIpv4Conf) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Interfaces (and their attributes) are only exposed if present within the
+	// requester's netns, so refer to the passthrough handler.
+	return h.Service.GetPassThroughHandler().Lookup(n, req)
+}
+
+func (h *ProcSysNetIpv4Conf) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if resource := h.emuResource(n); resource != nil &&
+		resource.Policy == domain.ReadOnlyPolicy &&
+		n.OpenFlags() != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return h.Service.GetPassThroughHandler().Open(n, req)
+}
+
+func (h *ProcSysNetIpv4Conf) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if h.emuResource(n) != nil {
+		// Single-line elements being read, so we can save some cycles by
+		// returning right away if offset is any higher than zero.
+		if req.Offset > 0 {
+			return 0, io.EOF
+		}
+
+		return readNetnsFileUncached(h, n, req)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Read(n, req)
+}
+
+func (h *ProcSysNetIpv4Conf) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if resource := h.emuResource(n); resource != nil {
+		return writeFileByPolicy(h, n, req, resource)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
+
+func (h *ProcSysNetIpv4Conf) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Interfaces are enumerated within the requester's netns.
+	entries, err := h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefetch the emulated attributes of the interface being enumerated.
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil || relPath == "." || strings.Contains(relPath, "/") {
+		return entries, nil
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if _, ok := h.EmuResourceMap["*/"+entry.Name()]; ok {
+			files = append(files, filepath.Join(n.Path(), entry.Name()))
+		}
+	}
+
+	if err := prefetchNsFiles(h, files, req); err != nil {
+		logrus.Debugf("Could not prefetch attributes of %s: %v", n.Path(), err)
+	}
+
+	return entries, nil
+}
+
+func (h *ProcSysNetIpv4Conf) GetName() string {
+	return h.Name
+}
+
+func (h *ProcSysNetIpv4Conf) GetPath() string {
+	return h.Path
+}
+
+func (h *ProcSysNetIpv4Conf) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ProcSysNetIpv4Conf) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ProcSysNetIpv4Conf) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *ProcSysNetIpv4Conf) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *ProcSysNetIpv4Conf) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *ProcSysNetIpv4Conf) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	if resource := h.emuResource(n); resource != nil {
+		return &resource.Mutex
+	}
+
+	return nil
+}
+
+func (h *ProcSysNetIpv4Conf) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the emulated resource matching the given per-interface node (e.g.
+// "eth0/rp_filter"), if any.
+func (h *ProcSysNetIpv4Conf) emuResource(n domain.IOnodeIface) *domain.EmuResource {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return nil
+	}
+
+	comps := strings.Split(relPath, "/")
+	if len(comps) != 2 {
+		return nil
+	}
+
+	resource, ok := h.EmuResourceMap["*/"+comps[1]]
+	if !ok {
+		return nil
+	}
+
+	return resource
+}
@@ -40,10 +40,32 @@ import (
 //
 // * /proc/sys/net/ipv4/default/gc_thresh3
 //
+// Documentation: Thresholds driving the garbage collection of the neighbor
+// table entries; gc_thresh3 being the hard limit on the table size.
+//
+// These are only exposed in the initial netns, so sys containers are presented
+// with their own values, and the max across all containers is pushed to the
+// host kernel ("pooled-max" policy). The host value is lowered back once the
+// containers requiring it go away.
+//
+// * /proc/sys/net/ipv4/neigh/<iface>/{retrans_time, gc_stale_time, ...}
+//
+// Per-interface neighbor-table attributes. Interfaces are those present in the
+// requester's network namespace (as enumerated by the passthrough handler), and
+// the attributes are read / written within that same netns. These resources are
+// registered through "*/<attr>" patterns, given that interface names are only
+// known at runtime.
+//
 type ProcSysNetIpv4Neigh struct {
 	domain.HandlerBase
 }
 
+// Policies selectable for the gc_thresh* resources.
+var gcThreshPolicies = []domain.EmuResourcePolicy{
+	domain.PooledMaxPolicy,
+	domain.StateOnlyPolicy,
+}
+
 var ProcSysNetIpv4Neigh_Handler = &ProcSysNetIpv4Neigh{
 	domain.HandlerBase{
 		Name:    "ProcSysNetIpv4Neigh",
@@ -56,18 +78,142 @@ var ProcSysNetIpv4Neigh_Handler = &ProcSysNetIpv4Neigh{
 				Enabled: true,
 			},
 			"default/gc_thresh1": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: gcThreshPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"default/gc_thresh2": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: gcThreshPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"default/gc_thresh3": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: gcThreshPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"*/app_solicit": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
 				Enabled: true,
 			},
-			"default/gc_thresh2": {
+			"*/base_reachable_time": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
 				Enabled: true,
 			},
-			"default/gc_thresh3": {
+			"*/base_reachable_time_ms": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/delay_first_probe_time": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/gc_stale_time": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/locktime": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/mcast_solicit": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/proxy_delay": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/proxy_qlen": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/retrans_time": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/retrans_time_ms": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/ucast_solicit": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/unres_qlen": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"*/unres_qlen_bytes": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
 				Enabled: true,
 			},
 		},
@@ -147,6 +293,9 @@ func (h *ProcSysNetIpv4Neigh) Read(
 
 	// Skip if node is not part of the emulated components.
 	if _, ok := h.EmuResourceMap[relPath]; !ok {
+		if h.isIfaceResource(relPath) {
+			return readNetnsFile(h, n, req)
+		}
 		return 0, nil
 	}
 
@@ -179,7 +328,11 @@ func (h *ProcSysNetIpv4Neigh) Write(
 	}
 
 	// Skip if node is not part of the emulated components.
-	if _, ok := h.EmuResourceMap[relPath]; !ok {
+	resource, ok := h.EmuResourceMap[relPath]
+	if !ok {
+		if h.isIfaceResource(relPath) {
+			return writeNetnsFileInt(h, n, req, 0, MaxInt32)
+		}
 		return 0, nil
 	}
 
@@ -193,9 +346,11 @@ func (h *ProcSysNetIpv4Neigh) Write(
 		n.SetPath("/proc/sys/net/ipv4/neigh/lo/retrans_time")
 		h.EmuResourceMap["lo/retrans_time"] =
 			&domain.EmuResource{Kind: domain.FileEmuResource, Mode: os.FileMode(uint32(0644))}
+
+		return writeFileInt(h, n, req, 0, MaxInt, false)
 	}
 
-	return writeFileInt(h, n, req, 0, MaxInt, false)
+	return writeFileByPolicy(h, n, req, resource)
 }
 
 func (h *ProcSysNetIpv4Neigh) ReadDirAll(
@@ -287,6 +442,10 @@ func (h *ProcSysNetIpv4Neigh) GetResourcesList() []string {
 	return resources
 }
 
+func (h *ProcSysNetIpv4Neigh) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSysNetIpv4Neigh) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 
 	// Obtain the relative path to the element being acted on.
@@ -309,3 +468,19 @@ func (h *ProcSysNetIpv4Neigh) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex
 func (h *ProcSysNetIpv4Neigh) SetService(hs domain.HandlerServiceIface) {
 	h.Service = hs
 }
+
+// Returns 'true' if the given relative path refers to a per-interface attribute
+// (e.g. "eth0/retrans_time").
+func (h *ProcSysNetIpv4Neigh) isIfaceResource(relPath string) bool {
+
+	for k := range h.EmuResourceMap {
+		if !strings.HasPrefix(k, "*/") {
+			continue
+		}
+		if match, _ := filepath.Match(k, relPath); match {
+			return true
+		}
+	}
+
+	return false
+}
@@ -20,12 +20,16 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 )
 
 //
@@ -37,27 +41,58 @@ import (
 //
 // Note: the resources handled by this handler are already namespaced by the
 // Linux kernel's net-ns. However, these resources are hidden inside non-init
-// user-namespace. Thus, this handler's only purpose is to expose these
-// resources inside a sys container.
+// user-namespace (kernels < 6.2). Thus, this handler's main purpose is to
+// expose these resources inside a sys container, so that kube-proxy (IPVS
+// mode) can run within nested Kubernetes clusters.
 //
 // Emulated resources:
 //
-// * /proc/sys/net/ipv4/vs/conn_reuse_mode handler
+// * /proc/sys/net/ipv4/vs/conntrack
 //
-// * /proc/sys/net/ipv4/vs/expire_nodest_conn handler
+// Documentation: Maintain (1) connection tracking entries for connections
+// handled by IPVS. The max across all sys containers is pushed to the host
+// kernel ("pooled-max" policy).
 //
-// * /proc/sys/net/ipv4/vs/expire_quiescent_template handler
+// * /proc/sys/net/ipv4/vs/conn_reuse_mode
+//
+// Documentation: Controls how IPVS deals with connections detected on port
+// reuse (bit 0: reschedule, bit 1: reschedule for OPS too).
+//
+// * /proc/sys/net/ipv4/vs/expire_nodest_conn
+//
+// Documentation: Expire (1) the connections whose destination server is no
+// longer available.
+//
+// * /proc/sys/net/ipv4/vs/expire_quiescent_template
+//
+// Documentation: Expire (1) the persistence templates whose destination server
+// is quiescent.
+//
+// These resources are read / written within the requester's netns by default
+// ("write-through" policy). On kernels hiding them within non-init
+// user-namespaces, accesses fall back to the sys container state. Operators can
+// have them kept within the sys container state regardless through the
+// "state-only" policy (see emulated-resources attributes file).
+//
+// The subtree itself is exposed as long as the "ip_vs" module is loaded in the
+// host, even if hidden within the sys container's namespaces.
 //
 
 const (
 	minConnReuseMode = 0
-	maxConnReuseMode = 1
+	maxConnReuseMode = 3
 )
 
 type ProcSysNetIpv4Vs struct {
 	domain.HandlerBase
 }
 
+// Policies selectable through the emulated-resources attributes file.
+var procSysNetIpv4VsPolicies = []domain.EmuResourcePolicy{
+	domain.StateOnlyPolicy,
+	domain.WriteThroughPolicy,
+}
+
 var ProcSysNetIpv4Vs_Handler = &ProcSysNetIpv4Vs{
 	domain.HandlerBase{
 		Name:    "ProcSysNetIpv4Vs",
@@ -65,24 +100,40 @@ var ProcSysNetIpv4Vs_Handler = &ProcSysNetIpv4Vs{
 		Enabled: true,
 		EmuResourceMap: map[string]*domain.EmuResource{
 			"conntrack": {
-				Kind:    domain.FileEmuResource,
-				Mode:    os.FileMode(uint32(0644)),
-				Enabled: true,
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: []domain.EmuResourcePolicy{domain.PooledMaxPolicy, domain.StateOnlyPolicy},
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled:  true,
 			},
 			"conn_reuse_mode": {
-				Kind:    domain.FileEmuResource,
-				Mode:    os.FileMode(uint32(0644)),
-				Enabled: true,
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.WriteThroughPolicy,
+				Policies: procSysNetIpv4VsPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: minConnReuseMode, Max: maxConnReuseMode},
+				Enabled:  true,
 			},
 			"expire_nodest_conn": {
-				Kind:    domain.FileEmuResource,
-				Mode:    os.FileMode(uint32(0644)),
-				Enabled: true,
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.WriteThroughPolicy,
+				Policies: procSysNetIpv4VsPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled:  true,
 			},
 			"expire_quiescent_template": {
-				Kind:    domain.FileEmuResource,
-				Mode:    os.FileMode(uint32(0644)),
-				Enabled: true,
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.WriteThroughPolicy,
+				Policies: procSysNetIpv4VsPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled:  true,
 			},
 		},
 	},
@@ -97,6 +148,26 @@ func (h *ProcSysNetIpv4Vs) Lookup(
 	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
 		req.ID, h.Name, resource)
 
+	// Expose the vs directory if the host exposes it, regardless of its
+	// visibility within the sys container.
+	if n.Path() == h.Path {
+		info, err := h.Service.GetPassThroughHandler().Lookup(n, req)
+		if err == nil {
+			return info, nil
+		}
+
+		if _, hostErr := n.Stat(); hostErr != nil {
+			return nil, err
+		}
+
+		return &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0555)),
+			FmodTime: time.Now(),
+			FisDir:   true,
+		}, nil
+	}
+
 	// Return an artificial fileInfo if looked-up element matches any of the
 	// emulated components.
 	if v, ok := h.EmuResourceMap[resource]; ok {
@@ -136,18 +207,11 @@ func (h *ProcSysNetIpv4Vs) Read(
 		return 0, io.EOF
 	}
 
-	switch resource {
-	case "conntrack":
-		return readFileInt(h, n, req)
-
-	case "conn_reuse_mode":
-		return readFileInt(h, n, req)
-
-	case "expire_nodest_conn":
-		return readFileInt(h, n, req)
-
-	case "expire_quiescent_template":
-		return readFileInt(h, n, req)
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		if v.Policy == domain.WriteThroughPolicy {
+			return readNetnsFileOrState(h, n, req)
+		}
+		return readFileByPolicy(h, n, req, v)
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -163,22 +227,34 @@ func (h *ProcSysNetIpv4Vs) Write(
 	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
 		req.ID, h.Name, resource)
 
-	switch resource {
-	case "conntrack":
-		return writeFileMaxInt(h, n, req, true)
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		if v.Policy == domain.WriteThroughPolicy {
+			return h.writeNetnsFileOrState(n, req, v)
+		}
+		return writeFileByPolicy(h, n, req, v)
+	}
 
-	case "conn_reuse_mode":
-		return writeFileInt(h, n, req, minConnReuseMode, maxConnReuseMode, false)
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
 
-	case "expire_nodest_conn":
-		return writeFileInt(h, n, req, MinInt, MaxInt, false)
+// Validates the integer being written, and pushes it to the requester's netns
+// (or to the sys container state if hidden within it).
+func (h *ProcSysNetIpv4Vs) writeNetnsFileOrState(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	v *domain.EmuResource) (int, error) {
 
-	case "expire_quiescent_template":
-		return writeFileInt(h, n, req, MinInt, MaxInt, false)
+	val, err := strconv.Atoi(strings.TrimSpace(string(req.Data)))
+	if err != nil {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
 	}
 
-	// Refer to generic handler if no node match is found above.
-	return h.Service.GetPassThroughHandler().Write(n, req)
+	if b := v.Bounds; b != nil && (val < b.Min || val > b.Max) {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	return writeNetnsFileOrState(h, n, req)
 }
 
 func (h *ProcSysNetIpv4Vs) ReadDirAll(
@@ -193,9 +269,10 @@ func (h *ProcSysNetIpv4Vs) ReadDirAll(
 	var fileEntries []os.FileInfo
 
 	// Iterate through map of virtual components.
-	for k, _ := range h.EmuResourceMap {
+	for k, v := range h.EmuResourceMap {
 		info := &domain.FileInfo{
 			Fname:    k,
+			Fmode:    v.Mode,
 			FmodTime: time.Now(),
 		}
 
@@ -203,10 +280,15 @@ func (h *ProcSysNetIpv4Vs) ReadDirAll(
 	}
 
 	// Obtain the usual entries seen within container's namespaces and add them
-	// to the emulated ones.
+	// to the emulated ones (unless already present).
 	usualEntries, err := h.Service.GetPassThroughHandler().ReadDirAll(n, req)
 	if err == nil {
-		fileEntries = append(fileEntries, usualEntries...)
+		for _, e := range usualEntries {
+			if _, ok := h.EmuResourceMap[e.Name()]; ok {
+				continue
+			}
+			fileEntries = append(fileEntries, e)
+		}
 	}
 
 	return fileEntries, nil
@@ -249,6 +331,11 @@ func (h *ProcSysNetIpv4Vs) GetResourcesList() []string {
 
 	return resources
 }
+
+func (h *ProcSysNetIpv4Vs) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSysNetIpv4Vs) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
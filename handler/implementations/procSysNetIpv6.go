@@ -0,0 +1,383 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// /proc/sys/net/ipv6 handler
+//
+// Emulated resources:
+//
+// * /proc/sys/net/ipv6/conf/<iface>/forwarding
+//
+// * /proc/sys/net/ipv6/conf/<iface>/disable_ipv6
+//
+// Documentation: Enable / disable IPv6 forwarding and IPv6 operation on the
+// given interface ("all" and "default" included).
+//
+// * /proc/sys/net/ipv6/ip6frag_high_thresh
+//
+// * /proc/sys/net/ipv6/ip6frag_low_thresh
+//
+// * /proc/sys/net/ipv6/ip6frag_time
+//
+// Documentation: Memory thresholds utilized to reassemble IPv6 fragments, and
+// time (seconds) to keep an IPv6 fragment in memory.
+//
+// The above attributes are network-namespace-scoped, so they are read / written
+// within the requester's netns. Per-interface nodes are registered through
+// "conf/*/<attr>" patterns, given that interface names are only known at
+// runtime.
+//
+// * /proc/sys/net/ipv6/neigh/default/gc_thresh1
+//
+// * /proc/sys/net/ipv6/neigh/default/gc_thresh2
+//
+// * /proc/sys/net/ipv6/neigh/default/gc_thresh3
+//
+// Same as their ipv4 counterparts, these are only exposed in the initial netns,
+// so the max across all sys containers is pushed to the host kernel.
+//
+type ProcSysNetIpv6 struct {
+	domain.HandlerBase
+}
+
+var ProcSysNetIpv6_Handler = &ProcSysNetIpv6{
+	domain.HandlerBase{
+		Name:    "ProcSysNetIpv6",
+		Path:    "/proc/sys/net/ipv6",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"conf/*/forwarding": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"conf/*/disable_ipv6": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"ip6frag_high_thresh": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"ip6frag_low_thresh": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"ip6frag_time": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled: true,
+			},
+			"neigh/default/gc_thresh1": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: gcThreshPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"neigh/default/gc_thresh2": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: gcThreshPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"neigh/default/gc_thresh3": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: gcThreshPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+		},
+	},
+}
+
+func (h *ProcSysNetIpv6) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Obtain relative path to the element being looked up.
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// (non-pattern) emulated components. Pattern-based ones are always present
+	// within the container's netns.
+	if v, ok := h.EmuResourceMap[relPath]; ok {
+		info := &domain.FileInfo{
+			Fname:    n.Name(),
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	// If looked-up element hasn't been found by now, look into the actual
+	// container rootfs.
+	return h.Service.GetPassThroughHandler().Lookup(n, req)
+}
+
+func (h *ProcSysNetIpv6) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return err
+	}
+
+	if _, resource := h.emuResource(relPath); resource != nil {
+		return nil
+	}
+
+	return h.Service.GetPassThroughHandler().Open(n, req)
+}
+
+func (h *ProcSysNetIpv6) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Obtain relative path to the element being read.
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return 0, err
+	}
+
+	key, resource := h.emuResource(relPath)
+	if resource == nil {
+		// Refer to generic handler if no node match is found above.
+		return h.Service.GetPassThroughHandler().Read(n, req)
+	}
+
+	// We are dealing with a single integer element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if strings.HasPrefix(key, "neigh/default/gc_thresh") {
+		return readFileInt(h, n, req)
+	}
+
+	return readNetnsFile(h, n, req)
+}
+
+func (h *ProcSysNetIpv6) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Obtain relative path to the element being written.
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return 0, err
+	}
+
+	key, resource := h.emuResource(relPath)
+	if resource == nil {
+		// Refer to generic handler if no node match is found above.
+		return h.Service.GetPassThroughHandler().Write(n, req)
+	}
+
+	if strings.HasPrefix(key, "neigh/default/gc_thresh") {
+		return writeFileByPolicy(h, n, req, resource)
+	}
+
+	bounds := resource.Bounds
+
+	return writeNetnsFileInt(h, n, req, bounds.Min, bounds.Max)
+}
+
+func (h *ProcSysNetIpv6) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Obtain relative path to the element being read.
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	// Obtain the usual entries seen within container's namespaces.
+	fileEntries, err := h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(fileEntries))
+	for _, e := range fileEntries {
+		present[e.Name()] = true
+	}
+
+	// Add the (non-pattern) emulated components missing in the container's
+	// netns (i.e. neigh/default/gc_thresh*).
+	for k, v := range h.EmuResourceMap {
+		if strings.Contains(k, "*") ||
+			filepath.Dir(k) != relPath ||
+			present[filepath.Base(k)] {
+			continue
+		}
+
+		info := &domain.FileInfo{
+			Fname:    filepath.Base(k),
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		fileEntries = append(fileEntries, info)
+	}
+
+	return fileEntries, nil
+}
+
+func (h *ProcSysNetIpv6) GetName() string {
+	return h.Name
+}
+
+func (h *ProcSysNetIpv6) GetPath() string {
+	return h.Path
+}
+
+func (h *ProcSysNetIpv6) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ProcSysNetIpv6) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ProcSysNetIpv6) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *ProcSysNetIpv6) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *ProcSysNetIpv6) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *ProcSysNetIpv6) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+
+	// Obtain the relative path to the element being acted on.
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return nil
+	}
+
+	if _, resource := h.emuResource(relPath); resource != nil {
+		return &resource.Mutex
+	}
+
+	return nil
+}
+
+func (h *ProcSysNetIpv6) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the emulated resource (and its map key) matching the given relative
+// path, either literally or through a "conf/*/<attr>" pattern.
+func (h *ProcSysNetIpv6) emuResource(
+	relPath string) (string, *domain.EmuResource) {
+
+	if v, ok := h.EmuResourceMap[relPath]; ok {
+		return relPath, v
+	}
+
+	for k, v := range h.EmuResourceMap {
+		if !strings.Contains(k, "*") {
+			continue
+		}
+		if match, _ := filepath.Match(k, relPath); match {
+			return k, v
+		}
+	}
+
+	return "", nil
+}
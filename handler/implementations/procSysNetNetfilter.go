@@ -45,8 +45,34 @@ import (
 //
 // * /proc/sys/net/netfilter/nf_conntrack_tcp_timeout_close_wait
 //
+// * /proc/sys/net/netfilter/nf_conntrack_tcp_timeout_time_wait
+//
+// * /proc/sys/net/netfilter/nf_conntrack_tcp_timeout_fin_wait
+//
+// * /proc/sys/net/netfilter/nf_conntrack_tcp_timeout_syn_sent
+//
+// * /proc/sys/net/netfilter/nf_conntrack_udp_timeout
+//
+// * /proc/sys/net/netfilter/nf_conntrack_udp_timeout_stream
+//
+// * /proc/sys/net/netfilter/nf_conntrack_icmp_timeout
+//
+// * /proc/sys/net/netfilter/nf_conntrack_buckets
+//
 // * /proc/sys/net/netfilter/nf_conntrack_tcp_be_liberal
 //
+// All the integer attributes above (be_liberal excluded) are emulated through
+// a pooled policy: each sys container is presented with its own value, and the
+// max value across all sys containers is pushed to the host kernel. Operators
+// can switch the timeouts to a "pooled-min" or "state-only" policy through the
+// emulated-resources attributes config.
+//
+// * /proc/sys/net/netfilter/nf_conntrack_count
+//
+// Read-only counter of the conntrack entries allocated within the requester's
+// network namespace. Served (uncached) from that same netns, so monitoring tools
+// running within sys containers never get to see the host totals.
+//
 // Documentation: https://www.kernel.org/doc/Documentation/networking/nf_conntrack-sysctl.txt
 //
 // nf_conntrack_tcp_be_liberal - BOOLEAN
@@ -65,6 +91,13 @@ const (
 	tcpLiberalOn  = 1
 )
 
+// Policies supported by the conntrack timeout attributes.
+var conntrackTimeoutPolicies = []domain.EmuResourcePolicy{
+	domain.PooledMaxPolicy,
+	domain.PooledMinPolicy,
+	domain.StateOnlyPolicy,
+}
+
 type ProcSysNetNetfilter struct {
 	domain.HandlerBase
 }
@@ -78,26 +111,112 @@ var ProcSysNetNetfilter_Handler = &ProcSysNetNetfilter{
 			"nf_conntrack_max": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
 				Enabled: true,
 			},
 			"nf_conntrack_generic_timeout": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"nf_conntrack_count": {
 				Kind:    domain.FileEmuResource,
-				Mode:    os.FileMode(uint32(0644)),
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.IntFormat,
 				Enabled: true,
 			},
 			"nf_conntrack_tcp_be_liberal": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: tcpLiberalOff, Max: tcpLiberalOn},
 				Enabled: true,
 			},
 			"nf_conntrack_tcp_timeout_established": {
-				Kind:    domain.FileEmuResource,
-				Mode:    os.FileMode(uint32(0644)),
-				Enabled: true,
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
 			},
 			"nf_conntrack_tcp_timeout_close_wait": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"nf_conntrack_tcp_timeout_time_wait": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"nf_conntrack_tcp_timeout_fin_wait": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"nf_conntrack_tcp_timeout_syn_sent": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"nf_conntrack_udp_timeout": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"nf_conntrack_udp_timeout_stream": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"nf_conntrack_icmp_timeout": {
+				Kind:     domain.FileEmuResource,
+				Mode:     os.FileMode(uint32(0644)),
+				Policy:   domain.PooledMaxPolicy,
+				Policies: conntrackTimeoutPolicies,
+				Format:   domain.IntFormat,
+				Bounds:   &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
+				Enabled:  true,
+			},
+			"nf_conntrack_buckets": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 1, Max: MaxInt32},
 				Enabled: true,
 			},
 		},
@@ -136,6 +255,13 @@ func (h *ProcSysNetNetfilter) Open(
 
 	logrus.Debugf("Executing %v Open() method\n", h.Name)
 
+	switch n.Name() {
+	case "nf_conntrack_count":
+		if n.OpenFlags() != syscall.O_RDONLY {
+			return fuse.IOerror{Code: syscall.EACCES}
+		}
+	}
+
 	return nil
 }
 
@@ -155,19 +281,14 @@ func (h *ProcSysNetNetfilter) Read(
 	}
 
 	switch resource {
-	case "nf_conntrack_max":
-		return readFileInt(h, n, req)
-
-	case "nf_conntrack_generic_timeout":
-		return readFileInt(h, n, req)
+	case "nf_conntrack_count":
+		return readNetnsFileUncached(h, n, req)
 
 	case "nf_conntrack_tcp_be_liberal":
 		return readFileInt(h, n, req)
+	}
 
-	case "nf_conntrack_tcp_timeout_established":
-		return readFileInt(h, n, req)
-
-	case "nf_conntrack_tcp_timeout_close_wait":
+	if _, ok := h.EmuResourceMap[resource]; ok {
 		return readFileInt(h, n, req)
 	}
 
@@ -185,20 +306,15 @@ func (h *ProcSysNetNetfilter) Write(
 		req.ID, h.Name, resource)
 
 	switch resource {
-	case "nf_conntrack_max":
-		return writeFileMaxInt(h, n, req, true)
-
-	case "nf_conntrack_generic_timeout":
-		return writeFileMaxInt(h, n, req, true)
+	case "nf_conntrack_count":
+		return 0, nil
 
 	case "nf_conntrack_tcp_be_liberal":
 		return h.writeTcpLiberal(n, req)
+	}
 
-	case "nf_conntrack_tcp_timeout_established":
-		return writeFileMaxInt(h, n, req, true)
-
-	case "nf_conntrack_tcp_timeout_close_wait":
-		return writeFileMaxInt(h, n, req, true)
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		return writeFileByPolicy(h, n, req, v)
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -283,6 +399,11 @@ func (h *ProcSysNetNetfilter) GetResourcesList() []string {
 
 	return resources
 }
+
+func (h *ProcSysNetNetfilter) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSysNetNetfilter) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
@@ -35,6 +35,13 @@ import (
 //
 // * /proc/sys/net/unix/max_dgram_qlen
 //
+// Documentation: The maximum number of datagrams that can be queued on a unix
+// datagram socket (systemd raises it during boot). Defaults to 512.
+//
+// This is a network-namespace-scoped attribute, so reads and writes are carried
+// out within the requester's network namespace (through nsenter), leaving the
+// host's netns untouched.
+//
 type ProcSysNetUnix struct {
 	domain.HandlerBase
 }
@@ -48,6 +55,9 @@ var ProcSysNetUnix_Handler = &ProcSysNetUnix{
 			"max_dgram_qlen": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.WriteThroughPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt32},
 				Enabled: true,
 			},
 		},
@@ -114,7 +124,7 @@ func (h *ProcSysNetUnix) Read(
 
 	switch resource {
 	case "max_dgram_qlen":
-		return readFileInt(h, n, req)
+		return readNetnsFile(h, n, req)
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -132,7 +142,7 @@ func (h *ProcSysNetUnix) Write(
 
 	switch resource {
 	case "max_dgram_qlen":
-		return writeFileMaxInt(h, n, req, true)
+		return writeNetnsFileInt(h, n, req, 0, MaxInt32)
 	}
 
 	// Refer to generic handler if no node match is found above.
@@ -143,41 +153,12 @@ func (h *ProcSysNetUnix) ReadDirAll(
 	n domain.IOnodeIface,
 	req *domain.HandlerRequest) ([]os.FileInfo, error) {
 
-	var resource = n.Name()
-
 	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
-		req.ID, h.Name, resource)
-
-	var fileEntries []os.FileInfo
-
-	// Obtain relative path to the element being read.
-	relpath, err := filepath.Rel(h.Path, n.Path())
-	if err != nil {
-		return nil, err
-	}
-
-	// Iterate through map of emulated components.
-	for k, _ := range h.EmuResourceMap {
+		req.ID, h.Name, n.Name())
 
-		if relpath == filepath.Dir(k) {
-			info := &domain.FileInfo{
-				Fname:    k,
-				Fmode:    os.FileMode(uint32(0644)),
-				FmodTime: time.Now(),
-			}
-
-			fileEntries = append(fileEntries, info)
-		}
-	}
-
-	// Obtain the usual entries seen within container's namespaces and add them
-	// to the emulated ones.
-	usualEntries, err := h.Service.GetPassThroughHandler().ReadDirAll(n, req)
-	if err == nil {
-		fileEntries = append(fileEntries, usualEntries...)
-	}
-
-	return fileEntries, nil
+	// Emulated node is also present in the container's netns, so there's no
+	// need to add it to the usual entries.
+	return h.Service.GetPassThroughHandler().ReadDirAll(n, req)
 }
 
 func (h *ProcSysNetUnix) GetName() string {
@@ -217,6 +198,11 @@ func (h *ProcSysNetUnix) GetResourcesList() []string {
 
 	return resources
 }
+
+func (h *ProcSysNetUnix) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *ProcSysNetUnix) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
@@ -17,15 +17,18 @@
 package implementations
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
-	"sync"
-	"time"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
 )
 
 //
@@ -51,62 +54,173 @@ import (
 //
 // * /proc/sys/vm/overcommit_memory
 //
+// * /proc/sys/vm/max_map_count
+//
+// Documentation: This file contains the maximum number of memory map areas a
+// process may have. Applications such as Elasticsearch require this value to
+// be raised well above the kernel default (65530).
+//
+// Note: This is a system-wide attribute, so the largest value configured across
+// all sys containers is pushed to the host kernel (see MaxIntBase handler),
+// while each sys container keeps seeing the value it wrote.
+//
+// * /proc/sys/vm/min_free_kbytes
+//
+// Documentation: This is used to force the Linux VM to keep a minimum number of
+// kilobytes free. The VM uses this number to compute a watermark value for each
+// lowmem zone in the system.
+//
+// Note: Changes are only made superficially (at sys-container level), as this
+// knob affects the memory reserves of the whole host.
+//
+// * /proc/sys/vm/drop_caches
+//
+// Documentation: Writing to this file causes the kernel to drop clean caches,
+// as well as reclaimable slab objects like dentries and inodes:
+//
+//   1: free pagecache
+//   2: free reclaimable slab objects (includes dentries and inodes)
+//   3: free slab objects and pagecache
+//   4: disable drop_caches informational messages (can be combined with above)
+//
+// Note: Writes are accepted but never forwarded to the host kernel, as that
+// would flush the caches of every workload in the system. Instead, on cgroup v2
+// hosts, the page cache charged to the sys container's cgroup is reclaimed
+// through its "memory.reclaim" interface (best effort).
+//
+// * /proc/sys/vm/zone_reclaim_mode
+//
+// Documentation: Bitmask defining the approach to reclaim memory when a zone
+// runs out of memory (1: zone reclaim on, 2: write dirty pages out, 4: swap
+// pages). Defaults to 0.
+//
+// Note: System-wide NUMA knob, so changes are only made superficially (at
+// sys-container level). Non-NUMA hosts don't expose this node, in which case
+// its default value is presented to the sys container.
+//
+// * /proc/sys/vm/swappiness
+//
+// Documentation: This control is used to define the rough relative IO cost of
+// swapping and filesystem paging, as a value between 0 and 200 (defaults to
+// 60).
+//
+// Note: On cgroup v1 hierarchies the sys container's memory cgroup holds its
+// own swappiness knob (memory.swappiness), which is the one read and written.
+// Cgroup v2 lacks such knob, so changes are only made superficially (at
+// sys-container level) there.
+//
+// * /proc/sys/vm/nr_hugepages
+//
+// Documentation: Size of the pool of persistent hugepages of the default size.
+//
+// Note: Equivalent to the nr_hugepages node of the default hugepage size under
+// /sys/kernel/mm/hugepages, whose emulation (and state) is shared with this
+// one (see SysKernelMmHugepages handler).
+//
 
 const (
 	minOvercommitMem = 0
 	maxOverCommitMem = 2
 )
 
+const (
+	dropCachesPageCache = 1
+	dropCachesMax       = 4
+)
+
+const (
+	minZoneReclaimMode = 0
+	maxZoneReclaimMode = 7
+)
+
+const (
+	minSwappiness = 0
+	maxSwappiness = 200
+)
+
+const cgroupV2Root = "/sys/fs/cgroup"
+
+// ProcSysVm relies on MaxIntBase for the resources that are not explicitly
+// handled below (i.e. max_map_count).
 type ProcSysVm struct {
-	domain.HandlerBase
+	MaxIntBase
 }
 
 var ProcSysVm_Handler = &ProcSysVm{
-	domain.HandlerBase{
-		Name:    "ProcSysVm",
-		Path:    "/proc/sys/vm",
-		Enabled: true,
-		EmuResourceMap: map[string]*domain.EmuResource{
-			"overcommit_memory": {
-				Kind:    domain.FileEmuResource,
-				Mode:    os.FileMode(uint32(0644)),
-				Enabled: true,
-			},
-			"mmap_min_addr": {
-				Kind:    domain.FileEmuResource,
-				Mode:    os.FileMode(uint32(0644)),
-				Enabled: true,
+	MaxIntBase{
+		domain.HandlerBase{
+			Name:    "ProcSysVm",
+			Path:    "/proc/sys/vm",
+			Enabled: true,
+			EmuResourceMap: map[string]*domain.EmuResource{
+				"overcommit_memory": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.StateOnlyPolicy,
+					Format:  domain.IntFormat,
+					Bounds:  &domain.EmuResourceBounds{Min: minOvercommitMem, Max: maxOverCommitMem},
+					Enabled: true,
+				},
+				"mmap_min_addr": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.StateOnlyPolicy,
+					Format:  domain.IntFormat,
+					Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt},
+					Enabled: true,
+				},
+				"max_map_count": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.PooledMaxPolicy,
+					Format:  domain.IntFormat,
+					Enabled: true,
+				},
+				"min_free_kbytes": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.StateOnlyPolicy,
+					Format:  domain.IntFormat,
+					Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt},
+					Enabled: true,
+				},
+				"drop_caches": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0200)),
+					Policy:  domain.StateOnlyPolicy,
+					Format:  domain.IntFormat,
+					Bounds:  &domain.EmuResourceBounds{Min: dropCachesPageCache, Max: dropCachesMax},
+					Enabled: true,
+				},
+				"zone_reclaim_mode": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.StateOnlyPolicy,
+					Format:  domain.IntFormat,
+					Bounds:  &domain.EmuResourceBounds{Min: minZoneReclaimMode, Max: maxZoneReclaimMode},
+					Enabled: true,
+				},
+				"swappiness": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.StateOnlyPolicy,
+					Format:  domain.IntFormat,
+					Bounds:  &domain.EmuResourceBounds{Min: minSwappiness, Max: maxSwappiness},
+					Enabled: true,
+				},
+				"nr_hugepages": {
+					Kind:    domain.FileEmuResource,
+					Mode:    os.FileMode(uint32(0644)),
+					Policy:  domain.StateOnlyPolicy,
+					Format:  domain.IntFormat,
+					Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt},
+					Enabled: true,
+				},
 			},
 		},
 	},
 }
 
-func (h *ProcSysVm) Lookup(
-	n domain.IOnodeIface,
-	req *domain.HandlerRequest) (os.FileInfo, error) {
-
-	var resource = n.Name()
-
-	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
-		req.ID, h.Name, resource)
-
-	// Return an artificial fileInfo if looked-up element matches any of the
-	// emulated nodes.
-	if v, ok := h.EmuResourceMap[resource]; ok {
-		info := &domain.FileInfo{
-			Fname:    resource,
-			Fmode:    v.Mode,
-			FmodTime: time.Now(),
-		}
-
-		return info, nil
-	}
-
-	// If looked-up element hasn't been found by now, let's look into the actual
-	// sys container rootfs.
-	return h.Service.GetPassThroughHandler().Lookup(n, req)
-}
-
 func (h *ProcSysVm) Open(
 	n domain.IOnodeIface,
 	req *domain.HandlerRequest) error {
@@ -122,9 +236,24 @@ func (h *ProcSysVm) Open(
 
 	case "mmap_min_addr":
 		return nil
+
+	case "min_free_kbytes":
+		return nil
+
+	case "drop_caches":
+		return nil
+
+	case "zone_reclaim_mode":
+		return nil
+
+	case "swappiness":
+		return nil
+
+	case "nr_hugepages":
+		return nil
 	}
 
-	return h.Service.GetPassThroughHandler().Open(n, req)
+	return h.MaxIntBase.Open(n, req)
 }
 
 func (h *ProcSysVm) Read(
@@ -148,10 +277,25 @@ func (h *ProcSysVm) Read(
 
 	case "mmap_min_addr":
 		return readFileInt(h, n, req)
+
+	case "min_free_kbytes":
+		return readFileInt(h, n, req)
+
+	case "drop_caches":
+		return readFileInt(h, n, req)
+
+	case "zone_reclaim_mode":
+		return readFileIntDefault(h, n, req, minZoneReclaimMode)
+
+	case "swappiness":
+		return h.readSwappiness(n, req)
+
+	case "nr_hugepages":
+		return h.readNrHugepages(n, req)
 	}
 
-	// Refer to generic handler if no node match is found above.
-	return h.Service.GetPassThroughHandler().Read(n, req)
+	// Refer to the max-int base handler for the remaining resources.
+	return h.MaxIntBase.Read(n, req)
 }
 
 func (h *ProcSysVm) Write(
@@ -178,70 +322,210 @@ func (h *ProcSysVm) Write(
 
 	case "mmap_min_addr":
 		return writeFileInt(h, n, req, 0, MaxInt, false)
+
+	case "min_free_kbytes":
+		return writeFileInt(h, n, req, 0, MaxInt, false)
+
+	case "drop_caches":
+		return h.writeDropCaches(n, req)
+
+	case "zone_reclaim_mode":
+		return writeFileInt(h, n, req, minZoneReclaimMode, maxZoneReclaimMode, false)
+
+	case "swappiness":
+		return h.writeSwappiness(n, req)
+
+	case "nr_hugepages":
+		return h.writeNrHugepages(n, req)
 	}
 
-	// Refer to generic handler if no node match is found above.
-	return h.Service.GetPassThroughHandler().Write(n, req)
+	// Refer to the max-int base handler for the remaining resources.
+	return h.MaxIntBase.Write(n, req)
 }
 
-func (h *ProcSysVm) ReadDirAll(
+func (h *ProcSysVm) writeDropCaches(
 	n domain.IOnodeIface,
-	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+	req *domain.HandlerRequest) (int, error) {
+
+	name := n.Name()
+	path := n.Path()
+	cntr := req.Container
+
+	newVal := strings.TrimSpace(string(req.Data))
+	newValInt, err := strconv.Atoi(newVal)
+	if err != nil {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	if newValInt < dropCachesPageCache || newValInt > dropCachesMax {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	// Page-cache reclaim is scoped to the sys container's cgroup. Any failure
+	// here is not reported back to the user, as the kernel itself doesn't
+	// guarantee that caches are actually dropped.
+	if newValInt&dropCachesPageCache != 0 {
+		if err := h.reclaimPageCache(cntr); err != nil {
+			logrus.Debugf("Could not reclaim page-cache of container %s: %v",
+				cntr.ID(), err)
+		}
+	}
 
-	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
-		req.ID, h.Name, n.Name())
+	cntr.Lock()
+	cntr.SetData(path, name, newVal)
+	cntr.Unlock()
 
-	// Return all entries as seen within container's namespaces.
-	return h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+	return len(req.Data), nil
 }
 
-func (h *ProcSysVm) GetName() string {
-	return h.Name
+func (h *ProcSysVm) readNrHugepages(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	ios := h.Service.IOService()
+
+	sizeKB, err := defaultHugepageSize(ios)
+	if err != nil {
+		logrus.Errorf("Could not obtain the default hugepage size: %v", err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	nr, err := hugepagesNr(ios, req, sizeKB)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyResultBuffer(req.Data, []byte(strconv.Itoa(nr)+"\n"))
 }
 
-func (h *ProcSysVm) GetPath() string {
-	return h.Path
+func (h *ProcSysVm) writeNrHugepages(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	ios := h.Service.IOService()
+
+	sizeKB, err := defaultHugepageSize(ios)
+	if err != nil {
+		logrus.Errorf("Could not obtain the default hugepage size: %v", err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return writeHugepagesNr(ios, req, sizeKB)
 }
 
-func (h *ProcSysVm) GetService() domain.HandlerServiceIface {
-	return h.Service
+func (h *ProcSysVm) readSwappiness(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	path, ok, err := swappinessCgroupFile(h.Service.IOService(), cntr)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return readFileInt(h, n, req)
+	}
+
+	val, err := readCgroupUint(h.Service.IOService(), path)
+	if err != nil {
+		logrus.Errorf("Could not read the swappiness of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return copyResultBuffer(req.Data, []byte(strconv.FormatUint(val, 10)+"\n"))
 }
 
-func (h *ProcSysVm) GetEnabled() bool {
-	return h.Enabled
+func (h *ProcSysVm) writeSwappiness(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	path, ok, err := swappinessCgroupFile(h.Service.IOService(), cntr)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return writeFileInt(h, n, req, minSwappiness, maxSwappiness, false)
+	}
+
+	newVal := strings.TrimSpace(string(req.Data))
+	newValInt, err := strconv.Atoi(newVal)
+	if err != nil || newValInt < minSwappiness || newValInt > maxSwappiness {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	node := h.Service.IOService().NewIOnode(filepath.Base(path), path, 0)
+	if err := node.WriteFile([]byte(newVal)); err != nil {
+		logrus.Errorf("Could not write the swappiness of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return len(req.Data), nil
 }
 
-func (h *ProcSysVm) SetEnabled(b bool) {
-	h.Enabled = b
+// swappinessCgroupFile function returns the path of the swappiness knob of the
+// sys container's memory cgroup, if any (cgroup v1 only).
+func swappinessCgroupFile(
+	ios domain.IOServiceIface,
+	cntr domain.ContainerIface) (string, bool, error) {
+
+	paths, err := cgroupPaths(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cgroups of container %s: %v",
+			cntr.ID(), err)
+		return "", false, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	path, v1, ok := cgroupFile(paths, "memory", "memory.swappiness", "")
+
+	return path, v1 && ok, nil
 }
 
-func (h *ProcSysVm) GetResourcesList() []string {
+// reclaimPageCache method requests the kernel to reclaim the file-backed
+// memory charged to the sys container's cgroup (cgroup v2 only, as v1 lacks a
+// proactive reclaim interface).
+func (h *ProcSysVm) reclaimPageCache(cntr domain.ContainerIface) error {
 
-	var resources []string
+	ios := h.Service.IOService()
 
-	for resourceKey, resource := range h.EmuResourceMap {
-		resource.Mutex.Lock()
-		if !resource.Enabled {
-			resource.Mutex.Unlock()
-			continue
-		}
-		resource.Mutex.Unlock()
+	paths, err := cgroupPaths(ios, cntr.InitPid())
+	if err != nil {
+		return err
+	}
 
-		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	reclaimPath, v1, ok := cgroupFile(paths, "memory", "", "memory.reclaim")
+	if !ok || v1 {
+		return errors.New("memory cgroup v2 hierarchy not found")
 	}
 
-	return resources
-}
+	fileBytes, err := containerPageCache(ios, cntr.InitPid())
+	if err != nil {
+		return err
+	}
 
-func (h *ProcSysVm) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
-	resource, ok := h.EmuResourceMap[n.Name()]
-	if !ok {
+	if fileBytes == 0 {
 		return nil
 	}
 
-	return &resource.Mutex
-}
+	reclaimNode := ios.NewIOnode("memory.reclaim", reclaimPath, 0)
 
-func (h *ProcSysVm) SetService(hs domain.HandlerServiceIface) {
-	h.Service = hs
+	return reclaimNode.WriteFile([]byte(strconv.FormatUint(fileBytes, 10)))
 }
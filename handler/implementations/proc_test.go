@@ -0,0 +1,813 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+	"github.com/nestybox/sysbox-fs/mocks"
+	"github.com/nestybox/sysbox-fs/sysio"
+)
+
+// Returns a Proc handler whose host files are served out of a dedicated
+// in-memory fs, laid out as per 'files'.
+func newProcTestHandler(t *testing.T, files map[string]string) *implementations.Proc {
+
+	hostFs := sysio.NewIOService(domain.IOMemFileService)
+
+	for path, content := range files {
+		n := hostFs.NewIOnode(filepath.Base(path), path, 0644)
+		if err := n.WriteFile([]byte(content)); err != nil {
+			t.Fatalf("Unable to write file %s: %v", path, err)
+		}
+	}
+
+	var hdsMock = &mocks.HandlerServiceIface{}
+	hdsMock.On("IOService").Return(hostFs)
+
+	return &implementations.Proc{
+		domain.HandlerBase{
+			Name:    "Proc",
+			Path:    "/proc",
+			Enabled: true,
+			Service: hdsMock,
+		},
+	}
+}
+
+// Reads the given /proc node through the given handler, on behalf of a sys
+// container whose init process is pid 1001.
+func readProcTestNode(
+	t *testing.T,
+	h *implementations.Proc,
+	name string,
+	ctime time.Time) string {
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		ctime,
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		nil,
+	)
+
+	n := h.Service.IOService().NewIOnode(name, filepath.Join("/proc", name), 0444)
+
+	req := &domain.HandlerRequest{
+		Pid:       1001,
+		Data:      make([]byte, 64<<10),
+		Container: cntr,
+	}
+
+	got, err := h.Read(n, req)
+	if err != nil {
+		t.Fatalf("Proc.Read() error = %v", err)
+	}
+
+	return string(req.Data[:got])
+}
+
+func TestProc_ReadCgroups(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		// Cgroup v1: only the controllers attached to the container's
+		// hierarchies are displayed.
+		{"1", map[string]string{
+			"/proc/cgroups": `#subsys_name	hierarchy	num_cgroups	enabled
+cpuset	3	10	1
+cpu	4	20	1
+cpuacct	4	20	1
+memory	5	30	1
+`,
+			"/proc/1001/cgroup": `5:memory:/docker/c1
+4:cpu,cpuacct:/docker/c1
+1:name=systemd:/docker/c1
+`,
+		}, `#subsys_name	hierarchy	num_cgroups	enabled
+cpu	4	20	1
+cpuacct	4	20	1
+memory	5	30	1
+`},
+
+		// Cgroup v2: only the controllers enabled in the container's cgroup
+		// are displayed.
+		{"2", map[string]string{
+			"/proc/cgroups": `#subsys_name	hierarchy	num_cgroups	enabled
+cpuset	0	10	1
+cpu	0	10	1
+memory	0	10	1
+pids	0	10	1
+`,
+			"/proc/1001/cgroup":                           "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/cgroup.controllers": "cpu memory\n",
+		}, `#subsys_name	hierarchy	num_cgroups	enabled
+cpu	0	10	1
+memory	0	10	1
+`},
+
+		// Hybrid hosts: controllers are matched against the hierarchy they're
+		// attached to.
+		{"3", map[string]string{
+			"/proc/cgroups": `#subsys_name	hierarchy	num_cgroups	enabled
+cpu	4	20	1
+memory	5	30	1
+pids	0	10	1
+`,
+			"/proc/1001/cgroup": `4:cpu:/docker/c1
+0::/docker/c1
+`,
+			"/sys/fs/cgroup/docker/c1/cgroup.controllers": "memory pids\n",
+		}, `#subsys_name	hierarchy	num_cgroups	enabled
+cpu	4	20	1
+pids	0	10	1
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newProcTestHandler(t, tt.files)
+
+			if got := readProcTestNode(t, h, "cgroups", time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Mounts of the sys container's init process: rootfs on sda1, and a volume on
+// sdb.
+var procTestMountinfo = `600 500 8:1 / / rw,relatime - ext4 /dev/sda1 rw
+601 600 0:50 / /proc rw,nosuid - proc proc rw
+602 600 8:16 / /data rw,relatime - xfs /dev/sdb rw
+`
+
+func TestProc_ReadPartitions(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		resource string
+		files    map[string]string
+		want     string
+	}{
+		// Only the devices backing the container's mounts are displayed.
+		{"1", "partitions", map[string]string{
+			"/proc/partitions": `major minor  #blocks  name
+
+   8        0  488386584 sda
+   8        1  488385536 sda1
+   8       16  976762584 sdb
+ 259        0  500107608 nvme0n1
+`,
+		}, `major minor  #blocks  name
+
+   8        1  488385536 sda1
+   8       16  976762584 sdb
+`},
+
+		// Block-device drivers not backing the container's mounts are left
+		// out; character devices are displayed as per the host.
+		{"2", "devices", map[string]string{
+			"/proc/devices": `Character devices:
+  1 mem
+  4 /dev/vc/0
+  5 /dev/tty
+
+Block devices:
+  8 sd
+  9 md
+259 blkext
+`,
+		}, `Character devices:
+  1 mem
+  4 /dev/vc/0
+  5 /dev/tty
+
+Block devices:
+  8 sd
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["/proc/1001/mountinfo"] = procTestMountinfo
+			h := newProcTestHandler(t, tt.files)
+
+			if got := readProcTestNode(t, h, tt.resource, time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadFilesystems(t *testing.T) {
+
+	var hostFilesystems = `nodev	sysfs
+nodev	tmpfs
+nodev	bdev
+nodev	proc
+	ext4
+nodev	overlay
+nodev	fuse
+	fuseblk
+`
+
+	tests := []struct {
+		name  string
+		types []string // default whitelist if nil
+		want  string
+	}{
+		// File-systems sys containers can mount (and supported by the host).
+		{"1", nil, `nodev	sysfs
+nodev	tmpfs
+nodev	proc
+nodev	overlay
+nodev	fuse
+`},
+
+		// Operator-defined whitelist.
+		{"2", []string{"ext4", "proc", "btrfs"}, `nodev	proc
+	ext4
+`},
+	}
+
+	defer implementations.SetProcFilesystems([]string{
+		"sysfs", "tmpfs", "proc", "cgroup", "cgroup2", "devpts", "mqueue",
+		"overlay", "shiftfs", "fuse"})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.types != nil {
+				implementations.SetProcFilesystems(tt.types)
+			}
+
+			h := newProcTestHandler(t, map[string]string{
+				"/proc/filesystems": hostFilesystems,
+			})
+
+			if got := readProcTestNode(t, h, "filesystems", time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadPerCpuCounters(t *testing.T) {
+
+	// Container's cpuset, as per cgroup v1 and v2 layouts.
+	var (
+		cpusetV1 = map[string]string{
+			"/proc/1001/cgroup": "3:cpuset:/docker/c1\n",
+			"/sys/fs/cgroup/cpuset/docker/c1/cpuset.effective_cpus": "1,3\n",
+		}
+		cpusetV2 = map[string]string{
+			"/proc/1001/cgroup": "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/cpuset.cpus.effective": "1,3\n",
+		}
+	)
+
+	tests := []struct {
+		name     string
+		resource string
+		cpuset   map[string]string
+		host     string
+		want     string
+	}{
+		// Columns of the cpus out of the container's cpuset are dropped (and
+		// the remaining ones renumbered). Rows lacking per-cpu counters are
+		// kept as is.
+		{"1", "interrupts", cpusetV1,
+			"           CPU0       CPU1       CPU2       CPU3       \n" +
+				"  0:         44          0          0          0   IO-APIC   2-edge      timer\n" +
+				"  8:          0          0          1          0   IO-APIC   8-edge      rtc0\n" +
+				"NMI:          1          2          3          4   Non-maskable interrupts\n" +
+				"ERR:          0\n",
+			"           CPU0       CPU1       \n" +
+				"  0:          0          0  IO-APIC   2-edge      timer\n" +
+				"  8:          0          0  IO-APIC   8-edge      rtc0\n" +
+				"NMI:          2          4  Non-maskable interrupts\n" +
+				"ERR:          0\n"},
+
+		{"2", "softirqs", cpusetV2,
+			"                    CPU0       CPU1       CPU2       CPU3       \n" +
+				"          HI:          1          2          3          4\n" +
+				"       TIMER:         10         20         30         40\n",
+			"                    CPU0       CPU1       \n" +
+				"          HI:          2          4 \n" +
+				"       TIMER:         20         40 \n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{filepath.Join("/proc", tt.resource): tt.host}
+			for path, content := range tt.cpuset {
+				files[path] = content
+			}
+			h := newProcTestHandler(t, files)
+
+			if got := readProcTestNode(t, h, tt.resource, time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadMemZone(t *testing.T) {
+
+	pageSize := uint64(os.Getpagesize())
+
+	pages := func(n uint64) string {
+		return strconv.FormatUint(n*pageSize, 10) + "\n"
+	}
+
+	var (
+		// Container limited to 1024 pages, 256 of them in use.
+		limited = map[string]string{
+			"/proc/1001/cgroup":                       "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/memory.max":     pages(1024),
+			"/sys/fs/cgroup/docker/c1/memory.current": pages(256),
+		}
+
+		// Unlimited container on a 2048-pages host.
+		unlimited = map[string]string{
+			"/proc/1001/cgroup":                       "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/memory.max":     "max\n",
+			"/sys/fs/cgroup/docker/c1/memory.current": "0\n",
+			"/proc/meminfo":                           fmt.Sprintf("MemTotal:       %8d kB\n", 2*pageSize),
+		}
+	)
+
+	tests := []struct {
+		name     string
+		resource string
+		files    map[string]string
+		want     string
+	}{
+		// Free pages (768) are arranged in the largest possible blocks.
+		{"1", "buddyinfo", limited,
+			"Node 0, zone   Normal      0      0      0      0      0      0      0      0      1      1      0\n"},
+
+		{"2", "zoneinfo", limited, `Node 0, zone   Normal
+  pages free     768
+        min      4
+        low      5
+        high     6
+        spanned  1024
+        present  1024
+        managed  1024
+`},
+
+		// Host memory is displayed in the absence of a limit.
+		{"3", "buddyinfo", unlimited,
+			"Node 0, zone   Normal      0      0      0      0      0      0      0      0      0      0      2\n"},
+
+		{"4", "zoneinfo", unlimited, `Node 0, zone   Normal
+  pages free     2048
+        min      8
+        low      10
+        high     12
+        spanned  2048
+        present  2048
+        managed  2048
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newProcTestHandler(t, tt.files)
+
+			if got := readProcTestNode(t, h, tt.resource, time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadSlabinfo(t *testing.T) {
+
+	// Host's slab caches must not be exposed.
+	h := newProcTestHandler(t, map[string]string{
+		"/proc/slabinfo": "slabinfo - version: 2.1\n" +
+			"kmalloc-64          6400   6400     64   64    1 : tunables    0    0    0 : slabdata    100    100      0\n",
+	})
+
+	want := "slabinfo - version: 2.1\n" +
+		"# name            <active_objs> <num_objs> <objsize> <objperslab> <pagesperslab> : " +
+		"tunables <limit> <batchcount> <sharedfactor> : " +
+		"slabdata <active_slabs> <num_slabs> <sharedavail>\n"
+
+	if got := readProcTestNode(t, h, "slabinfo", time.Time{}); got != want {
+		t.Errorf("Proc.Read() = %q, want %q", got, want)
+	}
+}
+
+func TestProc_ReadSchedstatTimerList(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		resource string
+		cpus     string
+		host     string
+		want     string
+	}{
+		// Entries of the cpus out of the container's cpuset are dropped (and
+		// the remaining ones renumbered), as are the scheduling domains.
+		{"1", "schedstat", "1-2", `version 15
+timestamp 4295000000
+cpu0 0 0 0 0 0 0 100 200 300
+domain0 3 0 0 0
+cpu1 0 0 0 0 0 0 110 210 310
+domain0 3 0 0 0
+cpu2 0 0 0 0 0 0 120 220 320
+domain0 3 0 0 0
+`, `version 15
+timestamp 4295000000
+cpu0 0 0 0 0 0 0 110 210 310
+cpu1 0 0 0 0 0 0 120 220 320
+`},
+
+		// Active timers and tick devices are left out, and kernel addresses
+		// are zeroed.
+		{"2", "timer_list", "1", `Timer List Version: v0.9
+HRTIMER_MAX_CLOCK_BASES: 8
+now at 1234567890 nsecs
+
+cpu: 0
+ clock 0:
+  .base:       ffff8880bfc1e0c0
+  .index:      0
+active timers:
+ #0: <ffffc90000abcdef>, tick_sched_timer, S:01
+ # expires at 1234568000-1234568000 nsecs [in 110 to 110 nsecs]
+  .expires_next   : 1234568000 nsecs
+cpu: 1
+ clock 0:
+  .base:       ffff8880bfd1e0c0
+  .index:      0
+active timers:
+ #0: <ffffc90000abcdf0>, tick_sched_timer, S:01
+ # expires at 1234569000-1234569000 nsecs [in 1110 to 1110 nsecs]
+  .expires_next   : 1234569000 nsecs
+
+Tick Device: mode:     1
+Broadcast device
+Clock Event Device: hpet
+`, `Timer List Version: v0.9
+HRTIMER_MAX_CLOCK_BASES: 8
+now at 1234567890 nsecs
+
+cpu: 0
+ clock 0:
+  .base:       0000000000000000
+  .index:      0
+active timers:
+  .expires_next   : 1234569000 nsecs
+
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newProcTestHandler(t, map[string]string{
+				filepath.Join("/proc", tt.resource):              tt.host,
+				"/proc/1001/cgroup":                              "0::/docker/c1\n",
+				"/sys/fs/cgroup/docker/c1/cpuset.cpus.effective": tt.cpus + "\n",
+			})
+
+			if got := readProcTestNode(t, h, tt.resource, time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadKmsg(t *testing.T) {
+
+	h := newProcTestHandler(t, nil)
+
+	cntr := css.ContainerCreate(
+		"c1",
+		uint32(1001),
+		time.Time{},
+		231072,
+		65535,
+		231072,
+		65535,
+		nil,
+		nil,
+		nil,
+	)
+
+	cntr.KernelLog().Append(domain.KernelLogInfo, "sysbox-fs: container c1 registered")
+
+	n := h.Service.IOService().NewIOnode("kmsg", "/proc/kmsg", 0400)
+
+	tests := []struct {
+		name    string
+		offset  int64
+		want    string
+		wantErr error
+	}{
+		// Container's own records are served regardless of the offset...
+		{"1", 128, "<6>sysbox-fs: container c1 registered\n", nil},
+
+		// ... and only once.
+		{"2", 0, "", io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &domain.HandlerRequest{
+				Pid:       1001,
+				Offset:    tt.offset,
+				Data:      make([]byte, 4096),
+				Container: cntr,
+			}
+
+			got, err := h.Read(n, req)
+			if err != tt.wantErr {
+				t.Fatalf("Proc.Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(req.Data[:got]) != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", req.Data[:got], tt.want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadMeminfo(t *testing.T) {
+
+	var hostMeminfo = `MemTotal:       16384000 kB
+MemFree:         8192000 kB
+MemAvailable:   12288000 kB
+Buffers:          102400 kB
+Cached:          4096000 kB
+SwapTotal:       2097152 kB
+SwapFree:        2097152 kB
+`
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		// Totals reflect the container's limits, and the free / available /
+		// cached figures its usage.
+		{"1", map[string]string{
+			"/proc/1001/cgroup":                            "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/memory.max":          "1073741824\n",
+			"/sys/fs/cgroup/docker/c1/memory.current":      "536870912\n",
+			"/sys/fs/cgroup/docker/c1/memory.swap.max":     "536870912\n",
+			"/sys/fs/cgroup/docker/c1/memory.swap.current": "134217728\n",
+			"/sys/fs/cgroup/docker/c1/memory.stat":         "anon 402653184\nfile 134217728\n",
+		}, `MemTotal:        1048576 kB
+MemFree:          524288 kB
+MemAvailable:     655360 kB
+Buffers:          102400 kB
+Cached:           131072 kB
+SwapTotal:        524288 kB
+SwapFree:         393216 kB
+`},
+
+		// Swap figures are displayed as per the host in the absence of a swap
+		// limit.
+		{"2", map[string]string{
+			"/proc/1001/cgroup": "4:memory:/docker/c1\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.limit_in_bytes": "1073741824\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.usage_in_bytes": "536870912\n",
+			"/sys/fs/cgroup/memory/docker/c1/memory.stat":           "total_cache 134217728\n",
+		}, `MemTotal:        1048576 kB
+MemFree:          524288 kB
+MemAvailable:     655360 kB
+Buffers:          102400 kB
+Cached:           131072 kB
+SwapTotal:       2097152 kB
+SwapFree:        2097152 kB
+`},
+
+		// Host figures are displayed in the absence of a memory limit.
+		{"3", map[string]string{
+			"/proc/1001/cgroup":                       "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/memory.max":     "max\n",
+			"/sys/fs/cgroup/docker/c1/memory.current": "536870912\n",
+		}, hostMeminfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["/proc/meminfo"] = hostMeminfo
+			h := newProcTestHandler(t, tt.files)
+
+			if got := readProcTestNode(t, h, "meminfo", time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadStat(t *testing.T) {
+
+	var hostStat = `cpu  1000 0 500 90000 0 0 0 0 0 0
+cpu0 250 0 125 22500 0 0 0 0 0 0
+cpu1 250 0 125 22500 0 0 0 0 0 0
+cpu2 250 0 125 22500 0 0 0 0 0 0
+cpu3 250 0 125 22500 0 0 0 0 0 0
+intr 12345 0 0
+ctxt 67890
+btime 1600000000
+processes 4242
+procs_running 2
+`
+
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		// Container consuming 2s of user time and 1s of system time over
+		// cpus 1 and 3, as laid out by cgroup v1 and v2 hosts.
+		{"v1", map[string]string{
+			"/proc/1001/cgroup": "4:cpuset:/docker/c1\n3:cpu,cpuacct:/docker/c1\n",
+			"/sys/fs/cgroup/cpuset/docker/c1/cpuset.effective_cpus": "1,3\n",
+			"/sys/fs/cgroup/cpuacct/docker/c1/cpuacct.stat":         "user 200\nsystem 100\n",
+		}},
+
+		{"v2", map[string]string{
+			"/proc/1001/cgroup": "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/cpuset.cpus.effective": "1,3\n",
+			"/sys/fs/cgroup/docker/c1/cpu.stat":              "user_usec 2000000\nsystem_usec 1000000\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["/proc/stat"] = hostStat
+			h := newProcTestHandler(t, tt.files)
+
+			// Container created 100 secs ago.
+			ctime := time.Now().Add(-100 * time.Second).Truncate(time.Second)
+
+			// Idle time is the remainder of the container's cpus time (2 cpus
+			// during 100 secs, in USER_HZ ticks), which depends on the time of
+			// the read.
+			minIdle := uint64(time.Since(ctime)/(10*time.Millisecond))*2 - 300
+			got := readProcTestNode(t, h, "stat", ctime)
+			maxIdle := uint64(time.Since(ctime)/(10*time.Millisecond))*2 - 300
+
+			lines := strings.Split(got, "\n")
+			for i, line := range lines {
+				fields := strings.Fields(line)
+				if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") {
+					continue
+				}
+
+				div := uint64(1)
+				if fields[0] != "cpu" {
+					div = 2
+				}
+
+				idle, err := strconv.ParseUint(fields[4], 10, 64)
+				if err != nil || idle < minIdle/div || idle > maxIdle/div {
+					t.Errorf("Proc.Read() %s idle = %s, want within [%d, %d]",
+						fields[0], fields[4], minIdle/div, maxIdle/div)
+				}
+				fields[4] = "-"
+				lines[i] = strings.Join(fields, " ")
+			}
+
+			want := fmt.Sprintf(`cpu 200 0 100 - 0 0 0 0 0 0
+cpu0 100 0 50 - 0 0 0 0 0 0
+cpu1 100 0 50 - 0 0 0 0 0 0
+intr 12345 0 0
+ctxt 67890
+btime %d
+processes 4242
+procs_running 2
+`, ctime.Unix())
+
+			if got := strings.Join(lines, "\n"); got != want {
+				t.Errorf("Proc.Read() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadLoadavg(t *testing.T) {
+
+	var hostLoadavg = "0.50 0.40 0.30 12/3456 7890\n"
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		// Total tasks reflect the container's pids cgroup...
+		{"1", map[string]string{
+			"/proc/1001/cgroup":                     "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/pids.current": "20\n",
+		}, "0.50 0.40 0.30 12/20 7890\n"},
+
+		// ... which also bounds the running ones.
+		{"2", map[string]string{
+			"/proc/1001/cgroup":                          "6:pids:/docker/c1\n",
+			"/sys/fs/cgroup/pids/docker/c1/pids.current": "5\n",
+		}, "0.50 0.40 0.30 5/5 7890\n"},
+
+		// Host figures are displayed in the absence of a pids cgroup.
+		{"3", map[string]string{
+			"/proc/1001/cgroup": "4:memory:/docker/c1\n",
+		}, hostLoadavg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["/proc/loadavg"] = hostLoadavg
+			h := newProcTestHandler(t, tt.files)
+
+			if got := readProcTestNode(t, h, "loadavg", time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProc_ReadDiskstats(t *testing.T) {
+
+	var hostDiskstats = `   8       0 sda 300 10 6000 90 600 18 12000 120 0 150 210
+   8       1 sda1 100 5 2000 30 200 6 4000 40 0 50 70
+   8      16 sdb 5 0 40 1 0 0 0 0 0 1 1
+`
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		// Counters reflect the I/O issued by the container (as per the io /
+		// blkio cgroups), and are zeroed for the devices it hasn't issued I/O
+		// to.
+		{"1", map[string]string{
+			"/proc/1001/cgroup":                "0::/docker/c1\n",
+			"/sys/fs/cgroup/docker/c1/io.stat": "8:1 rbytes=1048576 wbytes=2097152 rios=10 wios=20 dbytes=0 dios=0\n",
+		}, `   8       1 sda1 10 0 2048 0 20 0 4096 0 0 0 0
+   8      16 sdb 0 0 0 0 0 0 0 0 0 0 0
+`},
+
+		{"2", map[string]string{
+			"/proc/1001/cgroup": "5:blkio:/docker/c1\n",
+			"/sys/fs/cgroup/blkio/docker/c1/blkio.throttle.io_service_bytes": "8:1 Read 1048576\n8:1 Write 2097152\nTotal 3145728\n",
+			"/sys/fs/cgroup/blkio/docker/c1/blkio.throttle.io_serviced":      "8:1 Read 10\n8:1 Write 20\nTotal 30\n",
+		}, `   8       1 sda1 10 0 2048 0 20 0 4096 0 0 0 0
+   8      16 sdb 0 0 0 0 0 0 0 0 0 0 0
+`},
+
+		// Host counters are displayed in the absence of the io controller.
+		{"3", map[string]string{
+			"/proc/1001/cgroup": "4:memory:/docker/c1\n",
+		}, `   8       1 sda1 100 5 2000 30 200 6 4000 40 0 50 70
+   8      16 sdb 5 0 40 1 0 0 0 0 0 1 1
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["/proc/diskstats"] = hostDiskstats
+			tt.files["/proc/1001/mountinfo"] = procTestMountinfo
+			h := newProcTestHandler(t, tt.files)
+
+			if got := readProcTestNode(t, h, "diskstats", time.Time{}); got != tt.want {
+				t.Errorf("Proc.Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// Process-plugin handler
+//
+// Handler forwarding the operations over the nodes placed under its path to an
+// external process (see domain.HandlerPluginRequest). The plugin is reached
+// through json-rpc calls over a unix socket; the connection is established
+// upon the first request, and re-established after any transport error.
+// Plugins that can't be reached cause EIO errors to be returned to the sys
+// container. Requests are issued concurrently over the shared connection.
+//
+
+// Deadline of the connection establishment and version handshake with the
+// plugins.
+var PluginHandshakeTimeout time.Duration = 5 * time.Second
+
+type ProcessPlugin struct {
+	domain.HandlerBase
+
+	socket string
+
+	mu     sync.Mutex
+	client *rpc.Client
+}
+
+// Process-plugin handler constructor.
+func NewProcessPluginHandler(name, path, socket string) *ProcessPlugin {
+
+	return &ProcessPlugin{
+		HandlerBase: domain.HandlerBase{
+			Name:    name,
+			Path:    path,
+			Enabled: true,
+			EmuResourceMap: map[string]*domain.EmuResource{
+				filepath.Base(path): {
+					Kind:    domain.DirEmuResource,
+					Mode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0555)),
+					Enabled: true,
+				},
+			},
+		},
+		socket: socket,
+	}
+}
+
+func (h *ProcessPlugin) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	var resp domain.HandlerPluginResponse
+
+	if err := h.call("Lookup", n, req, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Info == nil {
+		return nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	return pluginFileInfo(resp.Info), nil
+}
+
+func (h *ProcessPlugin) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	var resp domain.HandlerPluginResponse
+
+	return h.call("Open", n, req, &resp)
+}
+
+func (h *ProcessPlugin) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	var resp domain.HandlerPluginResponse
+
+	if err := h.call("Read", n, req, &resp); err != nil {
+		return 0, err
+	}
+
+	if len(resp.Data) == 0 && req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	return copyResultBuffer(req.Data, resp.Data)
+}
+
+func (h *ProcessPlugin) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	var resp domain.HandlerPluginResponse
+
+	if err := h.call("Write", n, req, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Size, nil
+}
+
+func (h *ProcessPlugin) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	var resp domain.HandlerPluginResponse
+
+	if err := h.call("ReadDirAll", n, req, &resp); err != nil {
+		return nil, err
+	}
+
+	var fileEntries []os.FileInfo
+
+	for i := range resp.Entries {
+		fileEntries = append(fileEntries, pluginFileInfo(&resp.Entries[i]))
+	}
+
+	return fileEntries, nil
+}
+
+func (h *ProcessPlugin) GetName() string {
+	return h.Name
+}
+
+func (h *ProcessPlugin) GetPath() string {
+	return h.Path
+}
+
+func (h *ProcessPlugin) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *ProcessPlugin) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *ProcessPlugin) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *ProcessPlugin) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *ProcessPlugin) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *ProcessPlugin) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[filepath.Base(h.Path)]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *ProcessPlugin) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Version method retrieves the plugins API version implemented by the plugin.
+func (h *ProcessPlugin) Version() (int, error) {
+
+	var resp domain.HandlerPluginResponse
+
+	ctx, cancel := context.WithTimeout(context.Background(), PluginHandshakeTimeout)
+	defer cancel()
+
+	err := h.invoke(ctx, "Version", &domain.HandlerPluginRequest{}, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Version, nil
+}
+
+// Forwards the given operation to the plugin, and translates its outcome into
+// a fuse error (if any).
+func (h *ProcessPlugin) call(
+	op string,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	resp *domain.HandlerPluginResponse) error {
+
+	preq := &domain.HandlerPluginRequest{
+		Path:   n.Path(),
+		Name:   n.Name(),
+		Flags:  n.OpenFlags(),
+		Pid:    req.Pid,
+		Uid:    req.Uid,
+		Gid:    req.Gid,
+		Offset: req.Offset,
+	}
+
+	if req.Container != nil {
+		preq.Container = req.Container.ID()
+	}
+
+	if op == "Write" {
+		preq.Data = req.Data
+	} else {
+		preq.Size = len(req.Data)
+	}
+
+	if err := h.invoke(req.Context(), op, preq, resp); err != nil {
+		if err == context.Canceled {
+			return fuse.IOerror{Code: syscall.EINTR}
+		}
+		logrus.Errorf("Plugin %s could not process %s() request for %s: %v",
+			h.Name, op, n.Path(), err)
+		return fuse.IOerror{Code: syscall.EIO}
+	}
+
+	if resp.Errno != 0 {
+		return fuse.IOerror{Code: syscall.Errno(resp.Errno)}
+	}
+
+	return nil
+}
+
+func (h *ProcessPlugin) invoke(
+	ctx context.Context,
+	op string,
+	preq *domain.HandlerPluginRequest,
+	resp *domain.HandlerPluginResponse) error {
+
+	client, err := h.getClient()
+	if err != nil {
+		return err
+	}
+
+	call := client.Go(domain.HandlerPluginServiceName+"."+op, preq, resp, nil)
+
+	select {
+	case <-call.Done:
+		err = call.Error
+
+	// Requests interrupted by the sys container are abandoned; responses are
+	// matched to their requests, so the plugin's one (if any) is discarded
+	// once received.
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// Drop the connection upon transport errors; errors reported by the
+	// plugin itself leave it untouched.
+	if _, ok := err.(rpc.ServerError); err != nil && !ok {
+		h.dropClient(client)
+	}
+
+	return err
+}
+
+// Returns the client of the plugin's connection, which is established if not
+// already there.
+func (h *ProcessPlugin) getClient() (*rpc.Client, error) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.client == nil {
+		conn, err := net.DialTimeout("unix", h.socket, PluginHandshakeTimeout)
+		if err != nil {
+			return nil, err
+		}
+		h.client = jsonrpc.NewClient(conn)
+	}
+
+	return h.client, nil
+}
+
+// Drops the given client, unless it has already been replaced.
+func (h *ProcessPlugin) dropClient(client *rpc.Client) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.client == client {
+		h.client.Close()
+		h.client = nil
+	}
+}
+
+func pluginFileInfo(info *domain.HandlerPluginFileInfo) *domain.FileInfo {
+
+	mode := os.FileMode(info.Mode) & os.ModePerm
+	if info.IsDir {
+		mode |= os.ModeDir
+	}
+
+	return &domain.FileInfo{
+		Fname:    info.Name,
+		Fsize:    info.Size,
+		Fmode:    mode,
+		FmodTime: time.Now(),
+		FisDir:   info.IsDir,
+	}
+}
@@ -0,0 +1,177 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+// Trivial process plugin serving a single "value" node.
+type testPlugin struct {
+	value []byte
+}
+
+func (p *testPlugin) Version(
+	req *domain.HandlerPluginRequest,
+	resp *domain.HandlerPluginResponse) error {
+
+	resp.Version = domain.HandlerPluginAPIVersion
+	return nil
+}
+
+func (p *testPlugin) Read(
+	req *domain.HandlerPluginRequest,
+	resp *domain.HandlerPluginResponse) error {
+
+	if req.Name != "value" {
+		resp.Errno = int(syscall.ENOENT)
+		return nil
+	}
+	if req.Offset == 0 {
+		resp.Data = p.value
+	}
+	return nil
+}
+
+func (p *testPlugin) Write(
+	req *domain.HandlerPluginRequest,
+	resp *domain.HandlerPluginResponse) error {
+
+	p.value = req.Data
+	resp.Size = len(req.Data)
+	return nil
+}
+
+func TestProcessPlugin_ReadWrite(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-plugin")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "plugin.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Unable to listen on %s: %v", socket, err)
+	}
+	defer l.Close()
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(domain.HandlerPluginServiceName,
+		&testPlugin{value: []byte("1\n")}); err != nil {
+		t.Fatalf("Unable to register plugin: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+
+	h := implementations.NewProcessPluginHandler("test", "/sys/class/test", socket)
+
+	if version, err := h.Version(); err != nil ||
+		version != domain.HandlerPluginAPIVersion {
+		t.Fatalf("Version() = %v, %v", version, err)
+	}
+
+	n := ios.NewIOnode("value", "/sys/class/test/value", 0644)
+
+	buf := make([]byte, 16)
+	size, err := h.Read(n, &domain.HandlerRequest{Data: buf})
+	if err != nil || string(buf[:size]) != "1\n" {
+		t.Errorf("Read() = %q, %v; want \"1\\n\"", string(buf[:size]), err)
+	}
+
+	size, err = h.Write(n, &domain.HandlerRequest{Data: []byte("2\n")})
+	if err != nil || size != 2 {
+		t.Errorf("Write() = %v, %v; want 2", size, err)
+	}
+
+	size, err = h.Read(n, &domain.HandlerRequest{Data: buf})
+	if err != nil || string(buf[:size]) != "2\n" {
+		t.Errorf("Read() = %q, %v; want \"2\\n\"", string(buf[:size]), err)
+	}
+
+	// Errors reported by the plugin must reach the caller.
+	n = ios.NewIOnode("bogus", "/sys/class/test/bogus", 0644)
+	_, err = h.Read(n, &domain.HandlerRequest{Data: buf})
+	if err != (fuse.IOerror{Code: syscall.ENOENT}) {
+		t.Errorf("Read() error = %v, want ENOENT", err)
+	}
+}
+
+func TestProcessPlugin_Unresponsive(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "sysbox-fs-plugin")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "plugin.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Unable to listen on %s: %v", socket, err)
+	}
+	defer l.Close()
+
+	// Plugin accepting connections but never answering.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	defer func(timeout time.Duration) {
+		implementations.PluginHandshakeTimeout = timeout
+	}(implementations.PluginHandshakeTimeout)
+	implementations.PluginHandshakeTimeout = 100 * time.Millisecond
+
+	h := implementations.NewProcessPluginHandler("test", "/sys/class/test", socket)
+
+	start := time.Now()
+	if _, err := h.Version(); err == nil {
+		t.Errorf("Version() of unresponsive plugin succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Version() took %v, beyond the handshake deadline", elapsed)
+	}
+}
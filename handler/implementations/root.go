@@ -135,6 +135,10 @@ func (h *Root) GetResourcesList() []string {
 	return resources
 }
 
+func (h *Root) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *Root) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
@@ -0,0 +1,476 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/block handler
+//
+// Emulated resources:
+//
+// * /sys/block/<disk>
+//
+// Only the disks backing the sys container's mounts (or holding partitions
+// that do so) are exposed, in line with the /proc/partitions and
+// /proc/diskstats emulation (see Proc handler). Disk entries (symlinks into
+// /sys/devices within sysfs) are presented as directories, while the symlinks
+// placed within them (e.g. "device", "bdi") are omitted, as they lead outside
+// of this directory.
+//
+// * /sys/block/<disk>/queue/{scheduler,read_ahead_kb,nr_requests,...}
+//
+// Request-queue tunables are frequently adjusted by database and storage
+// workloads during startup. As disks are shared with the host, writes are
+// only made superficially (at sys-container level), as long as they're valid
+// for the device (e.g. the I/O scheduler must be one supported by the disk).
+//
+// The rest of the nodes are displayed (read-only) as per the host.
+//
+
+type SysBlock struct {
+	domain.HandlerBase
+}
+
+var SysBlock_Handler = &SysBlock{
+	domain.HandlerBase{
+		Name:    "SysBlock",
+		Path:    "/sys/block",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"scheduler": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"read_ahead_kb": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt},
+				Enabled: true,
+			},
+			"nr_requests": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 1, Max: MaxInt},
+				Enabled: true,
+			},
+			"max_sectors_kb": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 1, Max: MaxInt},
+				Enabled: true,
+			},
+			"rotational": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"add_random": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"iostats": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 1},
+				Enabled: true,
+			},
+			"nomerges": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 2},
+				Enabled: true,
+			},
+			"rq_affinity": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: 2},
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysBlock) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if err := h.checkDisk(n, req); err != nil {
+		return nil, err
+	}
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// emulated components.
+	if v := h.emuResource(n); v != nil {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	return n.Stat()
+}
+
+func (h *SysBlock) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if err := h.checkDisk(n, req); err != nil {
+		return err
+	}
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) != 0 && h.emuResource(n) == nil {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *SysBlock) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Sysfs attributes are single-line elements, so we can save some cycles
+	// by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if err := h.checkDisk(n, req); err != nil {
+		return 0, err
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	if h.emuResource(n) == nil {
+		return copyResultBuffer(req.Data, content)
+	}
+
+	data := strings.TrimSpace(string(content))
+	path := n.Path()
+	cntr := req.Container
+
+	cntr.Lock()
+	val, ok := cntr.Data(path, resource)
+	cntr.Unlock()
+
+	if ok {
+		if resource == "scheduler" {
+			data = selectThpMode(data, val)
+		} else {
+			data = val
+		}
+	}
+
+	return copyResultBuffer(req.Data, []byte(data+"\n"))
+}
+
+func (h *SysBlock) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if err := h.checkDisk(n, req); err != nil {
+		return 0, err
+	}
+
+	v := h.emuResource(n)
+	if v == nil {
+		return 0, fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	val := strings.TrimSpace(string(req.Data))
+
+	if resource == "scheduler" {
+		// As in the kernel, schedulers not supported by the device are
+		// rejected.
+		content, err := n.ReadFile()
+		if err != nil {
+			return 0, err
+		}
+		if selectThpMode(strings.TrimSpace(string(content)), val) == "" {
+			return 0, fuse.IOerror{Code: syscall.EINVAL}
+		}
+	} else {
+		valInt, err := strconv.Atoi(val)
+		if err != nil || valInt < v.Bounds.Min || valInt > v.Bounds.Max {
+			return 0, fuse.IOerror{Code: syscall.EINVAL}
+		}
+		val = strconv.Itoa(valInt)
+	}
+
+	path := n.Path()
+	cntr := req.Container
+
+	cntr.Lock()
+	cntr.SetData(path, resource, val)
+	cntr.Unlock()
+
+	return len(req.Data), nil
+}
+
+func (h *SysBlock) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if err := h.checkDisk(n, req); err != nil {
+		return nil, err
+	}
+
+	entries, err := n.ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var disks map[string]struct{}
+
+	if n.Path() == h.Path {
+		disks, err = h.disks(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var fileEntries []os.FileInfo
+
+	for _, entry := range entries {
+		if entry.Mode()&os.ModeSymlink == 0 {
+			fileEntries = append(fileEntries, entry)
+			continue
+		}
+
+		if disks == nil {
+			continue
+		}
+		if _, ok := disks[entry.Name()]; !ok {
+			continue
+		}
+
+		fileEntries = append(fileEntries, &domain.FileInfo{
+			Fname:    entry.Name(),
+			Fmode:    os.ModeDir | os.FileMode(0755),
+			FmodTime: entry.ModTime(),
+			FisDir:   true,
+		})
+	}
+
+	return fileEntries, nil
+}
+
+func (h *SysBlock) GetName() string {
+	return h.Name
+}
+
+func (h *SysBlock) GetPath() string {
+	return h.Path
+}
+
+func (h *SysBlock) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysBlock) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysBlock) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysBlock) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysBlock) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysBlock) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	if resource := h.emuResource(n); resource != nil {
+		return &resource.Mutex
+	}
+
+	return nil
+}
+
+func (h *SysBlock) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the emulated resource matching the given node, if any. Only the
+// tunables placed within the disks' request-queue directory are emulated
+// (i.e. "/sys/block/<disk>/queue/<tunable>").
+func (h *SysBlock) emuResource(n domain.IOnodeIface) *domain.EmuResource {
+
+	queueDir := filepath.Dir(n.Path())
+	if filepath.Base(queueDir) != "queue" ||
+		filepath.Dir(filepath.Dir(queueDir)) != h.Path {
+		return nil
+	}
+
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return resource
+}
+
+// Returns ENOENT for the nodes placed within the disks that are not exposed to
+// the sys container.
+func (h *SysBlock) checkDisk(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil || relPath == "." {
+		return nil
+	}
+
+	disks, err := h.disks(req)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := disks[strings.SplitN(relPath, "/", 2)[0]]; !ok {
+		return fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	return nil
+}
+
+// Returns the names of the disks exposed to the sys container originating the
+// request: those backing any of its mounts, either directly or through any of
+// their partitions.
+func (h *SysBlock) disks(req *domain.HandlerRequest) (map[string]struct{}, error) {
+
+	ios := h.Service.IOService()
+
+	devs, err := containerBlockDevs(ios, req)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ios.NewIOnode("block", h.Path, 0).ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Returns the "major:minor" identifier of the given sysfs block device.
+	blockDev := func(path string) string {
+		content, err := ios.NewIOnode("dev", filepath.Join(path, "dev"), 0).ReadFile()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(content))
+	}
+
+	disks := make(map[string]struct{})
+
+	for _, entry := range entries {
+		disk := entry.Name()
+		diskPath := filepath.Join(h.Path, disk)
+
+		if _, ok := devs[blockDev(diskPath)]; ok {
+			disks[disk] = struct{}{}
+			continue
+		}
+
+		// Partitions are placed within their disk's directory, named after it
+		// (e.g. "sda1", "nvme0n1p1").
+		parts, err := ios.NewIOnode(disk, diskPath, 0).ReadDirAll()
+		if err != nil {
+			continue
+		}
+
+		for _, part := range parts {
+			if !strings.HasPrefix(part.Name(), disk) || part.Name() == disk {
+				continue
+			}
+			if _, ok := devs[blockDev(filepath.Join(diskPath, part.Name()))]; ok {
+				disks[disk] = struct{}{}
+				break
+			}
+		}
+	}
+
+	return disks, nil
+}
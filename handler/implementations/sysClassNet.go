@@ -0,0 +1,253 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/class/net handler
+//
+// Emulated resources:
+//
+// * /sys/class/net/<iface>/...
+//
+// The network devices displayed by sysfs are those of the network namespace
+// where it was mounted, so the ones of the sys containers can't be reached
+// through the host's sysfs. This handler serves the whole directory out of a
+// sysfs instance mounted (by the nsenter process) within the netns of the
+// process originating each request, so that the container's interfaces and
+// their attributes (e.g. mtu, speed, carrier, statistics/*) are displayed, in
+// line with /proc/net/dev.
+//
+// Interface entries (symlinks into /sys/devices within sysfs) are presented as
+// directories, while the symlinks placed within them (e.g. "device",
+// "subsystem") are omitted, as they lead outside of this directory. Nodes are
+// read-only within sys containers; interfaces are expected to be configured
+// through netlink instead.
+//
+
+type SysClassNet struct {
+	domain.HandlerBase
+}
+
+var SysClassNet_Handler = &SysClassNet{
+	domain.HandlerBase{
+		Name:    "SysClassNet",
+		Path:    "/sys/class/net",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"net": {
+				Kind:    domain.DirEmuResource,
+				Mode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0755)),
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysClassNet) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	responseMsg, err := h.netSysfsRequest(domain.LookupRequest, n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	info := responseMsg.Payload.(domain.FileInfo)
+
+	return info, nil
+}
+
+func (h *SysClassNet) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *SysClassNet) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Sysfs attributes fit within a single read, so we can save some cycles
+	// by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	responseMsg, err := h.netSysfsRequest(domain.ReadFileRequest, n, req)
+	if err != nil {
+		return 0, err
+	}
+
+	data := responseMsg.Payload.(string)
+	if data != "" {
+		data += "\n"
+	}
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *SysClassNet) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *SysClassNet) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	responseMsg, err := h.netSysfsRequest(domain.ReadDirRequest, n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileEntries []os.FileInfo
+
+	for _, entry := range responseMsg.Payload.([]domain.FileInfo) {
+		if entry.Fmode&os.ModeSymlink != 0 {
+			if n.Path() != h.Path {
+				continue
+			}
+			entry.Fmode = os.ModeDir | os.FileMode(0755)
+			entry.FisDir = true
+		}
+		fileEntries = append(fileEntries, entry)
+	}
+
+	return fileEntries, nil
+}
+
+func (h *SysClassNet) GetName() string {
+	return h.Name
+}
+
+func (h *SysClassNet) GetPath() string {
+	return h.Path
+}
+
+func (h *SysClassNet) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysClassNet) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysClassNet) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysClassNet) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysClassNet) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysClassNet) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[filepath.Base(h.Path)]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *SysClassNet) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Performs the given operation over the sysfs instance of the network
+// namespace of the process originating the request (see nsenter's
+// NetSysfsRequest).
+func (h *SysClassNet) netSysfsRequest(
+	op domain.NSenterMsgType,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (*domain.NSenterMessage, error) {
+
+	// Ensure operation is generated from within a registered sys container.
+	if req.Container == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return nil, errors.New("Container not found")
+	}
+
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		req.Pid,
+		&domain.NetNSOnly,
+		&domain.NSenterMessage{
+			Type:  domain.NetSysfsRequest,
+			ReqID: req.ID,
+			Ctx:   req.Ctx,
+			Payload: &domain.NetSysfsReqPayload{
+				Op:   op,
+				Path: n.Path(),
+			},
+		},
+		nil,
+		false,
+	)
+
+	// Launch nsenter-event.
+	if err := nss.SendRequestEvent(event); err != nil {
+		return nil, err
+	}
+
+	// Obtain nsenter-event response.
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return nil, responseMsg.Payload.(error)
+	}
+
+	return responseMsg, nil
+}
@@ -0,0 +1,379 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/devices/system/cpu handler
+//
+// Emulated resources:
+//
+// * /sys/devices/system/cpu/{online,possible,present}
+//
+// Cpu lists reflecting the cpus within the sys container's cpuset, which are
+// presented as a contiguous range starting from cpu0 (e.g. a container
+// restricted to host cpus "2,5,7" is shown cpus "0-2").
+//
+// * /sys/devices/system/cpu/cpuN
+//
+// Per-cpu directories. Only those of the cpus within the container's cpuset are
+// exposed, renumbered as above; their content is served from the directory of
+// the matching host cpu (e.g. cpu1/topology/core_id refers to the host's
+// cpu5/topology/core_id in the example above).
+//
+// Nodes are read-only within sys containers (i.e. cpu hotplug is not allowed),
+// and the rest of the directory's content is displayed as per the host.
+//
+
+var cpuDirRegexp = regexp.MustCompile(`^cpu([0-9]+)$`)
+
+type SysDevicesSystemCpu struct {
+	domain.HandlerBase
+}
+
+var SysDevicesSystemCpu_Handler = &SysDevicesSystemCpu{
+	domain.HandlerBase{
+		Name:    "SysDevicesSystemCpu",
+		Path:    "/sys/devices/system/cpu",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"online": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"possible": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"present": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysDevicesSystemCpu) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// emulated components.
+	if v := h.emuResource(n); v != nil {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	hn, err := h.hostNode(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := hn.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Per-cpu directories are displayed with their container-side name.
+	if info.Name() != resource {
+		return &domain.FileInfo{
+			Fname:    resource,
+			Fsize:    info.Size(),
+			Fmode:    info.Mode(),
+			FmodTime: info.ModTime(),
+			FisDir:   info.IsDir(),
+		}, nil
+	}
+
+	return info, nil
+}
+
+func (h *SysDevicesSystemCpu) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	if h.emuResource(n) != nil {
+		return nil
+	}
+
+	_, err := h.hostNode(n, req)
+
+	return err
+}
+
+func (h *SysDevicesSystemCpu) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	// Sysfs attributes are single-line elements, so we can save some cycles
+	// by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if h.emuResource(n) != nil {
+		cpus, err := h.cpus(req)
+		if err != nil {
+			return 0, err
+		}
+
+		return copyResultBuffer(req.Data, []byte(cpuListString(len(cpus))+"\n"))
+	}
+
+	hn, err := h.hostNode(n, req)
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := hn.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	return copyResultBuffer(req.Data, content)
+}
+
+func (h *SysDevicesSystemCpu) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *SysDevicesSystemCpu) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	hn, err := h.hostNode(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := hn.ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Path() != h.Path {
+		return entries, nil
+	}
+
+	cpus, err := h.cpus(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hostCpus := make(map[string]os.FileInfo)
+
+	var fileEntries []os.FileInfo
+
+	for _, entry := range entries {
+		if cpuDirRegexp.MatchString(entry.Name()) {
+			hostCpus[entry.Name()] = entry
+			continue
+		}
+		fileEntries = append(fileEntries, entry)
+	}
+
+	for i, cpu := range cpus {
+		entry, ok := hostCpus["cpu"+strconv.Itoa(cpu)]
+		if !ok {
+			continue
+		}
+
+		fileEntries = append(fileEntries, &domain.FileInfo{
+			Fname:    "cpu" + strconv.Itoa(i),
+			Fsize:    entry.Size(),
+			Fmode:    entry.Mode(),
+			FmodTime: entry.ModTime(),
+			FisDir:   entry.IsDir(),
+		})
+	}
+
+	return fileEntries, nil
+}
+
+func (h *SysDevicesSystemCpu) GetName() string {
+	return h.Name
+}
+
+func (h *SysDevicesSystemCpu) GetPath() string {
+	return h.Path
+}
+
+func (h *SysDevicesSystemCpu) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysDevicesSystemCpu) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysDevicesSystemCpu) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysDevicesSystemCpu) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysDevicesSystemCpu) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysDevicesSystemCpu) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	if resource := h.emuResource(n); resource != nil {
+		return &resource.Mutex
+	}
+
+	return nil
+}
+
+func (h *SysDevicesSystemCpu) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the emulated resource matching the given node, if any. Notice that
+// only the top-level cpu lists are emulated (e.g. "cpu1/online" is not).
+func (h *SysDevicesSystemCpu) emuResource(n domain.IOnodeIface) *domain.EmuResource {
+
+	if filepath.Dir(n.Path()) != h.Path {
+		return nil
+	}
+
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return resource
+}
+
+// Returns the cpus within the cpuset of the sys container originating the
+// request.
+func (h *SysDevicesSystemCpu) cpus(req *domain.HandlerRequest) ([]int, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return nil, errors.New("Container not found")
+	}
+
+	cpus, err := containerCpus(h.Service.IOService(), cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cpuset of container %s: %v",
+			cntr.ID(), err)
+		return nil, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	return cpus, nil
+}
+
+// Returns the host node backing the given one, which only differs for the
+// nodes placed within the per-cpu directories (e.g. "cpu1/..." may be backed
+// by "cpu5/..."). ENOENT is returned for the cpus that are not exposed.
+func (h *SysDevicesSystemCpu) hostNode(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (domain.IOnodeIface, error) {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil || relPath == "." {
+		return n, nil
+	}
+
+	comps := strings.SplitN(relPath, "/", 2)
+
+	match := cpuDirRegexp.FindStringSubmatch(comps[0])
+	if match == nil {
+		return n, nil
+	}
+
+	cpus, err := h.cpus(req)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := strconv.Atoi(match[1])
+	if err != nil || idx >= len(cpus) {
+		return nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	comps[0] = "cpu" + strconv.Itoa(cpus[idx])
+
+	hostPath := filepath.Join(h.Path, filepath.Join(comps...))
+	if hostPath == n.Path() {
+		return n, nil
+	}
+
+	ios := h.Service.IOService()
+
+	return ios.NewIOnode(n.Name(), hostPath, 0), nil
+}
@@ -0,0 +1,531 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/devices/system/node handler
+//
+// Emulated resources:
+//
+// * /sys/devices/system/node/{online,possible,has_cpu,has_memory,has_normal_memory}
+//
+// Lists of the NUMA nodes exposed to the sys container: those within its
+// cpuset.mems (a single one if unset), renumbered starting from node0.
+//
+// * /sys/devices/system/node/nodeN/{cpulist,meminfo,distance}
+//
+// Per-node attributes. Cpus are displayed as per the container's numbering
+// (see /sys/devices/system/cpu), and the container's memory limit (or the host
+// memory in its absence) is evenly split across the exposed nodes. Distances
+// follow the kernel's defaults (10 local, 20 remote).
+//
+// The whole directory is synthesized, so host nodes are never exposed.
+//
+
+type SysDevicesSystemNode struct {
+	domain.HandlerBase
+}
+
+var SysDevicesSystemNode_Handler = &SysDevicesSystemNode{
+	domain.HandlerBase{
+		Name:    "SysDevicesSystemNode",
+		Path:    "/sys/devices/system/node",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"online": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"possible": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"has_cpu": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"has_memory": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"has_normal_memory": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"*/cpulist": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"*/distance": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"*/meminfo": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.TextFormat,
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysDevicesSystemNode) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if n.Path() == h.Path {
+		return &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    os.ModeDir | os.FileMode(uint32(0755)),
+			FmodTime: time.Now(),
+			FisDir:   true,
+		}, nil
+	}
+
+	idx, v, err := h.resolve(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Per-node directory.
+	if v == nil {
+		info := &domain.FileInfo{
+			Fname:    fmt.Sprintf("node%d", idx),
+			Fmode:    os.ModeDir | os.FileMode(uint32(0755)),
+			FmodTime: time.Now(),
+			FisDir:   true,
+		}
+		return info, nil
+	}
+
+	return &domain.FileInfo{
+		Fname:    resource,
+		Fmode:    v.Mode,
+		FmodTime: time.Now(),
+	}, nil
+}
+
+func (h *SysDevicesSystemNode) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	if n.Path() == h.Path {
+		return nil
+	}
+
+	_, _, err := h.resolve(n, req)
+
+	return err
+}
+
+func (h *SysDevicesSystemNode) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Content is generated at once, so we can save some cycles by returning
+	// right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	idx, v, err := h.resolve(n, req)
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, fuse.IOerror{Code: syscall.EISDIR}
+	}
+
+	topo, err := h.topology(req)
+	if err != nil {
+		return 0, err
+	}
+
+	var data string
+
+	switch resource {
+	case "online", "possible", "has_cpu", "has_memory", "has_normal_memory":
+		data = cpuListString(len(topo.nodeCpus))
+
+	case "cpulist":
+		data = formatIdList(topo.nodeCpus[idx])
+
+	case "distance":
+		var dists []string
+		for i := range topo.nodeCpus {
+			if i == idx {
+				dists = append(dists, "10")
+			} else {
+				dists = append(dists, "20")
+			}
+		}
+		data = strings.Join(dists, " ")
+
+	case "meminfo":
+		total := topo.memTotal / uint64(len(topo.nodeCpus)) / 1024
+		used := topo.memUsed / uint64(len(topo.nodeCpus)) / 1024
+		if used > total {
+			used = total
+		}
+		data = fmt.Sprintf("Node %d MemTotal:       %8d kB\n"+
+			"Node %d MemFree:        %8d kB\n"+
+			"Node %d MemUsed:        %8d kB",
+			idx, total, idx, total-used, idx, used)
+	}
+
+	return copyResultBuffer(req.Data, []byte(data+"\n"))
+}
+
+func (h *SysDevicesSystemNode) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *SysDevicesSystemNode) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	var (
+		fileEntries []os.FileInfo
+		prefix      string
+	)
+
+	if n.Path() == h.Path {
+		topo, err := h.topology(req)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range topo.nodeCpus {
+			fileEntries = append(fileEntries, &domain.FileInfo{
+				Fname:    fmt.Sprintf("node%d", i),
+				Fmode:    os.ModeDir | os.FileMode(uint32(0755)),
+				FmodTime: time.Now(),
+				FisDir:   true,
+			})
+		}
+
+	} else {
+		if _, v, err := h.resolve(n, req); err != nil {
+			return nil, err
+		} else if v != nil {
+			return nil, fuse.IOerror{Code: syscall.ENOTDIR}
+		}
+		prefix = "*/"
+	}
+
+	for key, v := range h.EmuResourceMap {
+		if prefix == "" && strings.Contains(key, "/") {
+			continue
+		}
+		if prefix != "" && !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		fileEntries = append(fileEntries, &domain.FileInfo{
+			Fname:    strings.TrimPrefix(key, prefix),
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		})
+	}
+
+	return fileEntries, nil
+}
+
+func (h *SysDevicesSystemNode) GetName() string {
+	return h.Name
+}
+
+func (h *SysDevicesSystemNode) GetPath() string {
+	return h.Path
+}
+
+func (h *SysDevicesSystemNode) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysDevicesSystemNode) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysDevicesSystemNode) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysDevicesSystemNode) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysDevicesSystemNode) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysDevicesSystemNode) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return nil
+	}
+
+	key := relPath
+	if comps := strings.Split(relPath, "/"); len(comps) == 2 {
+		key = "*/" + comps[1]
+	}
+
+	resource, ok := h.EmuResourceMap[key]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *SysDevicesSystemNode) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// NUMA topology exposed to a sys container.
+type numaTopology struct {
+	nodeCpus [][]int // cpus of each node, as per the container's numbering
+	memTotal uint64  // memory size (bytes)
+	memUsed  uint64  // memory usage (bytes)
+}
+
+// Returns the NUMA topology exposed to the sys container originating the
+// request.
+func (h *SysDevicesSystemNode) topology(req *domain.HandlerRequest) (*numaTopology, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return nil, errors.New("Container not found")
+	}
+
+	ios := h.Service.IOService()
+
+	cpus, err := containerCpus(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cpuset of container %s: %v",
+			cntr.ID(), err)
+		return nil, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	mems, err := containerMems(ios, cntr.InitPid())
+	if err != nil || len(mems) == 0 {
+		mems = []int{0}
+	}
+
+	limit, usage, err := containerMemory(ios, cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the memory cgroup of container %s: %v",
+			cntr.ID(), err)
+		return nil, fuse.IOerror{Code: syscall.EIO}
+	}
+	if limit == 0 {
+		if limit, err = hostMemTotal(ios); err != nil {
+			return nil, err
+		}
+	}
+
+	topo := &numaTopology{
+		memTotal: limit,
+		memUsed:  usage,
+	}
+
+	// With a single node exposed all the cpus belong to it; otherwise cpus are
+	// assigned as per the host's topology.
+	if len(mems) == 1 {
+		all := make([]int, len(cpus))
+		for i := range cpus {
+			all[i] = i
+		}
+		topo.nodeCpus = [][]int{all}
+		return topo, nil
+	}
+
+	renum := cpuRenumbering(cpus)
+
+	for _, mem := range mems {
+		path := filepath.Join(h.Path, fmt.Sprintf("node%d", mem), "cpulist")
+		content, err := ios.NewIOnode("cpulist", path, 0).ReadFile()
+		if err != nil {
+			return nil, err
+		}
+
+		hostCpus, err := parseCpuList(strings.TrimSpace(string(content)))
+		if err != nil {
+			return nil, err
+		}
+
+		var nodeCpus []int
+		for _, cpu := range hostCpus {
+			if idx, ok := renum[cpu]; ok {
+				nodeCpus = append(nodeCpus, idx)
+			}
+		}
+		topo.nodeCpus = append(topo.nodeCpus, nodeCpus)
+	}
+
+	return topo, nil
+}
+
+// Resolves the given node into the index of the NUMA node it belongs to, and
+// the emulated resource it matches (nil for the per-node directories). ENOENT
+// is returned for the nodes not exposed to the sys container.
+func (h *SysDevicesSystemNode) resolve(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, *domain.EmuResource, error) {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return 0, nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	comps := strings.Split(relPath, "/")
+
+	if len(comps) == 1 {
+		if v, ok := h.EmuResourceMap[comps[0]]; ok {
+			return 0, v, nil
+		}
+	}
+
+	if len(comps) > 2 || !strings.HasPrefix(comps[0], "node") {
+		return 0, nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	idx, err := strconv.Atoi(strings.TrimPrefix(comps[0], "node"))
+	if err != nil || idx < 0 {
+		return 0, nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	topo, err := h.topology(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	if idx >= len(topo.nodeCpus) {
+		return 0, nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	if len(comps) == 1 {
+		return idx, nil, nil
+	}
+
+	v, ok := h.EmuResourceMap["*/"+comps[1]]
+	if !ok {
+		return 0, nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	return idx, v, nil
+}
+
+// Returns the "0-<n-1>" list of the first n ids.
+func cpuListString(n int) string {
+	if n <= 1 {
+		return "0"
+	}
+
+	return fmt.Sprintf("0-%d", n-1)
+}
+
+// Formats the given (sorted) ids in the kernel's "0-3,6,8-9" list format.
+func formatIdList(ids []int) string {
+
+	var chunks []string
+
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if i == j {
+			chunks = append(chunks, strconv.Itoa(ids[i]))
+		} else {
+			chunks = append(chunks, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(chunks, ",")
+}
@@ -53,6 +53,18 @@ import (
 // e617c421-0026-4941-9e95-<sys-cntr-id-02>
 // etc.
 //
+// * /sys/class/dmi/id/board_serial
+//
+// Defaults to the (short) container ID, so that the host's serial number is not
+// exposed.
+//
+// * /sys/class/dmi/id/sys_vendor
+//
+// Displayed as per the host by default.
+//
+// Sys containers can be assigned their own values for all of the above at
+// registration time (see domain.ContainerAttrRule).
+//
 
 const (
 	hostUuidLen = 24
@@ -72,6 +84,22 @@ var SysDevicesVirtualDmiId_Handler = &SysDevicesVirtualDmiId{
 			"product_uuid": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0400)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"board_serial": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0400)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"sys_vendor": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.StringFormat,
 				Enabled: true,
 			},
 		},
@@ -143,13 +171,27 @@ func (h *SysDevicesVirtualDmiId) Read(
 	// fetch the information from the host FS.
 	data, ok := cntr.Data(path, resource)
 	if !ok {
-		val, err := fetchFileData(h, n, cntr)
-		if err != nil && err != io.EOF {
-			cntr.Unlock()
-			return 0, err
+		switch resource {
+		case "product_uuid":
+			val, err := fetchFileData(h, n, cntr)
+			if err != nil && err != io.EOF {
+				cntr.Unlock()
+				return 0, err
+			}
+			data = h.GenerateProductUuid(val, cntr)
+
+		case "board_serial":
+			data = formatter.ContainerID{cntr.ID()}.String()
+
+		default:
+			val, err := fetchFileData(h, n, cntr)
+			if err != nil && err != io.EOF {
+				cntr.Unlock()
+				return 0, err
+			}
+			data = val
 		}
 
-		data = h.GenerateProductUuid(val, cntr)
 		cntr.SetData(path, resource, data)
 	}
 
@@ -212,6 +254,10 @@ func (h *SysDevicesVirtualDmiId) GetResourcesList() []string {
 	return resources
 }
 
+func (h *SysDevicesVirtualDmiId) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *SysDevicesVirtualDmiId) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
@@ -0,0 +1,165 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/firmware handler
+//
+// The host's firmware interfaces (e.g. acpi tables, efi variables, raw dmi
+// tables) are masked within sys containers, where /sys/firmware is presented
+// as an empty directory. Software inspecting these nodes (e.g. bootloader
+// installers checking for /sys/firmware/efi) behaves as it would in a system
+// lacking such firmware, instead of operating on the host's.
+//
+
+type SysFirmware struct {
+	domain.HandlerBase
+}
+
+var SysFirmware_Handler = &SysFirmware{
+	domain.HandlerBase{
+		Name:    "SysFirmware",
+		Path:    "/sys/firmware",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"firmware": {
+				Kind:    domain.DirEmuResource,
+				Mode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0755)),
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysFirmware) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.Path() != h.Path {
+		return nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	info := &domain.FileInfo{
+		Fname:    n.Name(),
+		Fmode:    h.EmuResourceMap["firmware"].Mode,
+		FmodTime: time.Now(),
+		FisDir:   true,
+	}
+
+	return info, nil
+}
+
+func (h *SysFirmware) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.Path() != h.Path {
+		return fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	return nil
+}
+
+func (h *SysFirmware) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.ENOENT}
+}
+
+func (h *SysFirmware) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.ENOENT}
+}
+
+func (h *SysFirmware) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.Path() != h.Path {
+		return nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	return nil, nil
+}
+
+func (h *SysFirmware) GetName() string {
+	return h.Name
+}
+
+func (h *SysFirmware) GetPath() string {
+	return h.Path
+}
+
+func (h *SysFirmware) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysFirmware) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysFirmware) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysFirmware) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysFirmware) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysFirmware) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[filepath.Base(h.Path)]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *SysFirmware) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
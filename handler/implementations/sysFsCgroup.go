@@ -0,0 +1,428 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/fs/cgroup handler
+//
+// Emulated resources:
+//
+// * /sys/fs/cgroup/...
+//
+// Presents the cgroup hierarchies rooted at the sys container's own cgroups,
+// be it in a cgroup v1 (i.e. "/sys/fs/cgroup/<controllers>/..."), v2 or hybrid
+// setup. Nodes are mapped to the matching ones within the host hierarchies
+// (e.g. "/sys/fs/cgroup/memory.max" refers to the host's
+// "/sys/fs/cgroup/<container-cgroup>/memory.max" under cgroup v2), so host
+// cgroups placed elsewhere can't be reached.
+//
+// All operations are carried out within the namespaces of the process
+// originating the request, so that pids (e.g. in cgroup.procs) are displayed
+// and written as per its pid-ns, and the kernel's delegation rules are
+// enforced as for its user-ns. Thereby, writes (as well as the creation and
+// removal of cgroups by nested runtimes) are only permitted within the
+// cgroups delegated to the sys container by sysbox-mgr (i.e. chowned to its
+// root user), while the limits set by the host over the container's cgroup
+// remain read-only.
+//
+// Handler is disabled by default: bind-mounting the whole of /sys/fs/cgroup
+// over FUSE makes statfs() report FUSE_SUPER_MAGIC rather than
+// CGROUP2_SUPER_MAGIC / TMPFS_MAGIC, which breaks the cgroup detection of runc,
+// systemd and docker within the sys containers. The rooted view is otherwise
+// provided by the container's cgroup-ns.
+//
+
+type SysFsCgroup struct {
+	domain.HandlerBase
+}
+
+var SysFsCgroup_Handler = &SysFsCgroup{
+	domain.HandlerBase{
+		Name:    "SysFsCgroup",
+		Path:    "/sys/fs/cgroup",
+		Enabled: false,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"cgroup": {
+				Kind:    domain.DirEmuResource,
+				Mode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0755)),
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysFsCgroup) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	hostPath, err := h.hostPath(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseMsg, err := h.nsenterRequest(req, &domain.NSenterMessage{
+		Type:    domain.LookupRequest,
+		ReqID:   req.ID,
+		Payload: &domain.LookupPayload{Entry: hostPath},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Cgroup dirs are displayed with their container-side name (e.g. the
+	// container's root cgroup).
+	info := responseMsg.Payload.(domain.FileInfo)
+	info.Fname = n.Name()
+
+	return info, nil
+}
+
+func (h *SysFsCgroup) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) == 0 {
+		return nil
+	}
+
+	hostPath, err := h.hostPath(n, req)
+	if err != nil {
+		return err
+	}
+
+	// Let the kernel decide whether the node is writable by the requester.
+	_, err = h.nsenterRequest(req, &domain.NSenterMessage{
+		Type:  domain.OpenFileRequest,
+		ReqID: req.ID,
+		Payload: &domain.OpenFilePayload{
+			File:  hostPath,
+			Flags: strconv.Itoa(n.OpenFlags()),
+			Mode:  strconv.Itoa(int(n.OpenMode())),
+		},
+	})
+
+	return err
+}
+
+func (h *SysFsCgroup) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	hostPath, err := h.hostPath(n, req)
+	if err != nil {
+		return 0, err
+	}
+
+	responseMsg, err := h.nsenterRequest(req, &domain.NSenterMessage{
+		Type:    domain.ReadFileRequest,
+		ReqID:   req.ID,
+		Payload: &domain.ReadFilePayload{File: hostPath},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	data := responseMsg.Payload.(string)
+	if data != "" {
+		data += "\n"
+	}
+
+	// Files may not fit in a single FUSE buffer (e.g. large cgroup.procs), so
+	// they are served at the requested offset.
+	if req.Offset >= int64(len(data)) {
+		return 0, io.EOF
+	}
+
+	return copyResultBuffer(req.Data, []byte(data[req.Offset:]))
+}
+
+func (h *SysFsCgroup) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	hostPath, err := h.hostPath(n, req)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = h.nsenterRequest(req, &domain.NSenterMessage{
+		Type:  domain.WriteFileRequest,
+		ReqID: req.ID,
+		Payload: &domain.WriteFilePayload{
+			File:    hostPath,
+			Content: strings.TrimSpace(string(req.Data)),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(req.Data), nil
+}
+
+func (h *SysFsCgroup) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	hostPath, err := h.hostPath(n, req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseMsg, err := h.nsenterRequest(req, &domain.NSenterMessage{
+		Type:    domain.ReadDirRequest,
+		ReqID:   req.ID,
+		Payload: &domain.ReadDirPayload{Dir: hostPath},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var fileEntries []os.FileInfo
+
+	for _, entry := range responseMsg.Payload.([]domain.FileInfo) {
+		// Co-mounted controllers are linked to their hierarchy's dir (e.g. "cpu"
+		// -> "cpu,cpuacct") under cgroup v1; these are presented as dirs.
+		if entry.Fmode&os.ModeSymlink != 0 {
+			entry.Fmode = os.ModeDir | os.FileMode(0555)
+			entry.FisDir = true
+		}
+		fileEntries = append(fileEntries, entry)
+	}
+
+	return fileEntries, nil
+}
+
+func (h *SysFsCgroup) Mkdir(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Mkdir() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	hostPath, err := h.hostPath(n, req)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.nsenterRequest(req, &domain.NSenterMessage{
+		Type:  domain.MkdirRequest,
+		ReqID: req.ID,
+		Payload: &domain.MkdirPayload{
+			Dir:  hostPath,
+			Mode: strconv.Itoa(int(n.OpenMode() & os.ModePerm)),
+		},
+	})
+
+	return err
+}
+
+func (h *SysFsCgroup) Rmdir(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Rmdir() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	hostPath, err := h.hostPath(n, req)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.nsenterRequest(req, &domain.NSenterMessage{
+		Type:    domain.RmdirRequest,
+		ReqID:   req.ID,
+		Payload: &domain.RmdirPayload{Dir: hostPath},
+	})
+
+	return err
+}
+
+func (h *SysFsCgroup) GetName() string {
+	return h.Name
+}
+
+func (h *SysFsCgroup) GetPath() string {
+	return h.Path
+}
+
+func (h *SysFsCgroup) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysFsCgroup) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysFsCgroup) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysFsCgroup) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysFsCgroup) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysFsCgroup) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[filepath.Base(h.Path)]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *SysFsCgroup) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the host path of the given node, as per the cgroups of the sys
+// container originating the request. ENOENT is returned for the hierarchies
+// the container is not part of.
+func (h *SysFsCgroup) hostPath(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (string, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return "", errors.New("Container not found")
+	}
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	paths, err := cgroupPaths(h.Service.IOService(), cntr.InitPid())
+	if err != nil {
+		logrus.Errorf("Could not obtain the cgroups of container %s: %v",
+			cntr.ID(), err)
+		return "", fuse.IOerror{Code: syscall.EIO}
+	}
+
+	// Cgroup v2 (unified hierarchy only).
+	if v2Path, ok := paths[""]; ok && len(paths) == 1 {
+		return filepath.Join(v2Path, relPath), nil
+	}
+
+	// Cgroup v1: the root dir holds one dir per hierarchy.
+	if relPath == "." {
+		return h.Path, nil
+	}
+
+	comps := strings.SplitN(relPath, "/", 2)
+	hier := comps[0]
+
+	var base string
+
+	if v2Path, ok := paths[""]; ok && hier == "unified" {
+		// Hybrid setups mount the unified hierarchy under "unified".
+		base = filepath.Join(h.Path, hier, strings.TrimPrefix(v2Path, cgroupV2Root))
+	} else {
+		// Hierarchies are named after their (co-mounted) controllers, e.g.
+		// "cpu,cpuacct", or after their name (e.g. "systemd").
+		ctrl := strings.Split(hier, ",")[0]
+
+		path, ok := paths[ctrl]
+		if !ok {
+			path, ok = paths["name="+ctrl]
+		}
+		if !ok {
+			return "", fuse.IOerror{Code: syscall.ENOENT}
+		}
+
+		base = filepath.Join(h.Path, hier,
+			strings.TrimPrefix(path, filepath.Join(cgroupV2Root, ctrl)))
+	}
+
+	if len(comps) == 1 {
+		return base, nil
+	}
+
+	return filepath.Join(base, comps[1]), nil
+}
+
+// Dispatches the given request to the namespaces (all but the mount one) of
+// the process originating the request.
+func (h *SysFsCgroup) nsenterRequest(
+	req *domain.HandlerRequest,
+	msg *domain.NSenterMessage) (*domain.NSenterMessage, error) {
+
+	msg.Ctx = req.Ctx
+
+	nss := h.Service.NSenterService()
+	event := nss.NewEvent(
+		req.Pid,
+		&domain.AllNSsButMount,
+		msg,
+		nil,
+		false,
+	)
+
+	// Launch nsenter-event.
+	if err := nss.SendRequestEvent(event); err != nil {
+		return nil, err
+	}
+
+	// Obtain nsenter-event response.
+	responseMsg := nss.ReceiveResponseEvent(event)
+	if responseMsg.Type == domain.ErrorResponse {
+		return nil, responseMsg.Payload.(error)
+	}
+
+	return responseMsg, nil
+}
@@ -0,0 +1,80 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"os"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// /sys/fs/selinux handler
+//
+// Emulated resources:
+//
+// * /sys/fs/selinux/{enforce,mls,policyvers,deny_unknown,reject_unknown,
+//   checkreqprot,status}
+//
+// SELinux status nodes (e.g. enforcing mode, policy version), displayed as
+// per the host.
+//
+// * /sys/fs/selinux/policy_capabilities/...
+//
+// Capabilities of the host's loaded policy, displayed as per the host.
+//
+// The rest of the selinuxfs content (i.e. the policy itself, its booleans and
+// classes, and the transaction nodes utilized to query / load it) is hidden,
+// and all nodes are read-only within sys containers (i.e. the enforcing mode
+// can't be changed). Exposure rules are the ones of the /sys/kernel/security
+// handler (see SysKernelSecurity).
+//
+
+// Dirs & nodes exposed within /sys/fs/selinux, indexed by their relative path.
+// Dirs expose their whole subtree.
+var selinuxfsNodes = map[string]struct{}{
+	"enforce":             {},
+	"mls":                 {},
+	"policyvers":          {},
+	"deny_unknown":        {},
+	"reject_unknown":      {},
+	"checkreqprot":        {},
+	"status":              {},
+	"policy_capabilities": {},
+}
+
+type SysFsSelinux struct {
+	SysKernelSecurity
+}
+
+var SysFsSelinux_Handler = &SysFsSelinux{
+	SysKernelSecurity{
+		HandlerBase: domain.HandlerBase{
+			Name:    "SysFsSelinux",
+			Path:    "/sys/fs/selinux",
+			Enabled: true,
+			EmuResourceMap: map[string]*domain.EmuResource{
+				"selinux": {
+					Kind:    domain.DirEmuResource,
+					Mode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0755)),
+					Enabled: true,
+				},
+			},
+		},
+		nodes: selinuxfsNodes,
+	},
+}
@@ -0,0 +1,62 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"testing"
+
+	"github.com/nestybox/sysbox-fs/mocks"
+)
+
+func TestSysFsSelinux_exposed(t *testing.T) {
+
+	tests := []struct {
+		name string
+		h    *SysKernelSecurity
+		path string
+		want bool
+	}{
+		// Status nodes.
+		{"1", &SysFsSelinux_Handler.SysKernelSecurity, "/sys/fs/selinux", true},
+		{"2", &SysFsSelinux_Handler.SysKernelSecurity, "/sys/fs/selinux/enforce", true},
+		{"3", &SysFsSelinux_Handler.SysKernelSecurity, "/sys/fs/selinux/policy_capabilities/network_peer_controls", true},
+
+		// Policy, booleans & transaction nodes are hidden.
+		{"4", &SysFsSelinux_Handler.SysKernelSecurity, "/sys/fs/selinux/policy", false},
+		{"5", &SysFsSelinux_Handler.SysKernelSecurity, "/sys/fs/selinux/booleans/httpd_can_network_connect", false},
+		{"6", &SysFsSelinux_Handler.SysKernelSecurity, "/sys/fs/selinux/load", false},
+
+		// Nodes outside the handler's path.
+		{"7", &SysFsSelinux_Handler.SysKernelSecurity, "/sys/kernel/security/lsm", false},
+
+		// Securityfs nodes follow their own set.
+		{"8", SysKernelSecurity_Handler, "/sys/kernel/security/apparmor", true},
+		{"9", SysKernelSecurity_Handler, "/sys/kernel/security/apparmor/policy", false},
+		{"10", SysKernelSecurity_Handler, "/sys/kernel/security/enforce", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &mocks.IOnodeIface{}
+			n.On("Path").Return(tt.path)
+
+			if got := tt.h.exposed(n); got != tt.want {
+				t.Errorf("exposed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
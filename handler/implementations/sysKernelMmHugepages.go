@@ -0,0 +1,419 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/kernel/mm/hugepages handler
+//
+// Emulated resources:
+//
+// * /sys/kernel/mm/hugepages/hugepages-<size>kB/nr_hugepages
+//
+// Documentation: Size of the pool of persistent hugepages of the given size.
+//
+// Note: The hugepage pools are system-wide, so they are never resized from
+// within sys containers. Instead, each sys container is presented its own pool
+// size, which it can adjust within the allotment granted by its hugetlb cgroup
+// (and by the host's pool itself). As in the kernel, requests exceeding that
+// allotment end up with the largest pool that can be provided. Sys containers
+// that haven't set their pool size are shown their whole allotment.
+//
+// * /sys/kernel/mm/hugepages/hugepages-<size>kB/free_hugepages
+//
+// Number of hugepages in the sys container's pool that are not yet charged to
+// its hugetlb cgroup.
+//
+// The rest of the nodes are displayed (read-only) as per the host.
+//
+
+const hugepagesPath = "/sys/kernel/mm/hugepages"
+
+var hugepagesDirRegexp = regexp.MustCompile(`^hugepages-([0-9]+)kB$`)
+
+type SysKernelMmHugepages struct {
+	domain.HandlerBase
+}
+
+var SysKernelMmHugepages_Handler = &SysKernelMmHugepages{
+	domain.HandlerBase{
+		Name:    "SysKernelMmHugepages",
+		Path:    hugepagesPath,
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"nr_hugepages": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.IntFormat,
+				Bounds:  &domain.EmuResourceBounds{Min: 0, Max: MaxInt},
+				Enabled: true,
+			},
+			"free_hugepages": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0444)),
+				Policy:  domain.ReadOnlyPolicy,
+				Format:  domain.IntFormat,
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysKernelMmHugepages) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// emulated components.
+	if v, _ := h.emuResource(n); v != nil {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	return n.Stat()
+}
+
+func (h *SysKernelMmHugepages) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) == 0 {
+		return nil
+	}
+
+	// Only the pool sizes can be written.
+	if _, sizeKB := h.emuResource(n); sizeKB != 0 && resource == "nr_hugepages" {
+		return nil
+	}
+
+	return fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *SysKernelMmHugepages) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Sysfs attributes are single-line elements, so we can save some cycles
+	// by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	v, sizeKB := h.emuResource(n)
+	if v == nil {
+		content, err := n.ReadFile()
+		if err != nil {
+			return 0, err
+		}
+
+		return copyResultBuffer(req.Data, content)
+	}
+
+	ios := h.Service.IOService()
+
+	nr, err := hugepagesNr(ios, req, sizeKB)
+	if err != nil {
+		return 0, err
+	}
+
+	if resource == "free_hugepages" {
+		_, usage, err := containerHugetlb(ios, req.Container.InitPid(), sizeKB)
+		if err != nil {
+			logrus.Errorf("Could not obtain the hugetlb usage of container %s: %v",
+				req.Container.ID(), err)
+			return 0, fuse.IOerror{Code: syscall.EIO}
+		}
+
+		pageSize := sizeKB << 10
+		used := int((usage + pageSize - 1) / pageSize)
+
+		if nr -= used; nr < 0 {
+			nr = 0
+		}
+	}
+
+	return copyResultBuffer(req.Data, []byte(strconv.Itoa(nr)+"\n"))
+}
+
+func (h *SysKernelMmHugepages) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	_, sizeKB := h.emuResource(n)
+	if sizeKB == 0 || resource != "nr_hugepages" {
+		return 0, fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return writeHugepagesNr(h.Service.IOService(), req, sizeKB)
+}
+
+func (h *SysKernelMmHugepages) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	return n.ReadDirAll()
+}
+
+func (h *SysKernelMmHugepages) GetName() string {
+	return h.Name
+}
+
+func (h *SysKernelMmHugepages) GetPath() string {
+	return h.Path
+}
+
+func (h *SysKernelMmHugepages) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysKernelMmHugepages) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysKernelMmHugepages) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysKernelMmHugepages) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysKernelMmHugepages) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysKernelMmHugepages) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	if resource, _ := h.emuResource(n); resource != nil {
+		return &resource.Mutex
+	}
+
+	return nil
+}
+
+func (h *SysKernelMmHugepages) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the emulated resource matching the given node, if any, along with
+// the size (in kB) of the hugepages it refers to.
+func (h *SysKernelMmHugepages) emuResource(
+	n domain.IOnodeIface) (*domain.EmuResource, uint64) {
+
+	dir := filepath.Dir(n.Path())
+	if filepath.Dir(dir) != h.Path {
+		return nil, 0
+	}
+
+	match := hugepagesDirRegexp.FindStringSubmatch(filepath.Base(dir))
+	if match == nil {
+		return nil, 0
+	}
+
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil, 0
+	}
+
+	sizeKB, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil || sizeKB == 0 {
+		return nil, 0
+	}
+
+	return resource, sizeKB
+}
+
+// hugepagesNr function returns the size of the pool of hugepages of the given
+// size (in kB) of the sys container originating the request. Pool sizes are
+// kept within the container state, indexed by their sysfs node, so that they
+// are shared with the /proc/sys/vm/nr_hugepages emulation.
+func hugepagesNr(
+	ios domain.IOServiceIface,
+	req *domain.HandlerRequest,
+	sizeKB uint64) (int, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	path := filepath.Join(hugepagesPath, fmt.Sprintf("hugepages-%dkB", sizeKB))
+
+	cntr.Lock()
+	data, ok := cntr.Data(path, "nr_hugepages")
+	cntr.Unlock()
+
+	if ok {
+		return strconv.Atoi(data)
+	}
+
+	return hugepagesAllotment(ios, cntr, sizeKB)
+}
+
+// writeHugepagesNr function sets the size of the pool of hugepages of the given
+// size (in kB) of the sys container originating the request.
+func writeHugepagesNr(
+	ios domain.IOServiceIface,
+	req *domain.HandlerRequest,
+	sizeKB uint64) (int, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	nr, err := strconv.Atoi(strings.TrimSpace(string(req.Data)))
+	if err != nil || nr < 0 {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	allotment, err := hugepagesAllotment(ios, cntr, sizeKB)
+	if err != nil {
+		return 0, err
+	}
+
+	if nr > allotment {
+		nr = allotment
+	}
+
+	path := filepath.Join(hugepagesPath, fmt.Sprintf("hugepages-%dkB", sizeKB))
+
+	cntr.Lock()
+	cntr.SetData(path, "nr_hugepages", strconv.Itoa(nr))
+	cntr.Unlock()
+
+	return len(req.Data), nil
+}
+
+// hugepagesAllotment function returns the largest pool of hugepages of the
+// given size (in kB) that can be granted to the given container, as per its
+// hugetlb cgroup limit and the size of the host's pool.
+func hugepagesAllotment(
+	ios domain.IOServiceIface,
+	cntr domain.ContainerIface,
+	sizeKB uint64) (int, error) {
+
+	path := filepath.Join(hugepagesPath, fmt.Sprintf("hugepages-%dkB", sizeKB))
+
+	content, err := ios.NewIOnode("nr_hugepages", filepath.Join(path, "nr_hugepages"), 0).ReadFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, fuse.IOerror{Code: syscall.ENOENT}
+		}
+		return 0, err
+	}
+
+	hostNr, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		logrus.Errorf("Unexpected content read from file %v, error %v", path, err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	limit, _, err := containerHugetlb(ios, cntr.InitPid(), sizeKB)
+	if err != nil {
+		logrus.Errorf("Could not obtain the hugetlb limit of container %s: %v",
+			cntr.ID(), err)
+		return 0, fuse.IOerror{Code: syscall.EIO}
+	}
+
+	if limit != 0 {
+		if nr := int(limit / (sizeKB << 10)); nr < hostNr {
+			return nr, nil
+		}
+	}
+
+	return hostNr, nil
+}
+
+// defaultHugepageSize function returns the size (in kB) of the host's default
+// hugepages, as displayed in /proc/meminfo.
+func defaultHugepageSize(ios domain.IOServiceIface) (uint64, error) {
+
+	content, err := ios.NewIOnode("meminfo", "/proc/meminfo", 0).ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		// Entry is in "Hugepagesize:       2048 kB" format.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "Hugepagesize:" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	return 0, errors.New("hugepage size not found")
+}
@@ -0,0 +1,284 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/kernel/mm/transparent_hugepage handler
+//
+// Emulated resources:
+//
+// * /sys/kernel/mm/transparent_hugepage/enabled
+// * /sys/kernel/mm/transparent_hugepage/defrag
+// * /sys/kernel/mm/transparent_hugepage/shmem_enabled
+//
+// THP modes are system-wide, and as such, can't be modified from within sys
+// containers. Yet, tuning scripts of several applications (e.g. MongoDB, Redis)
+// write them during startup, and fail if not allowed to. Writes are thereby
+// accepted and kept within the container state (i.e. the host setting is left
+// untouched), as long as they refer to any of the modes supported by the host.
+// Reads display the host's list of modes, with the one selected by the sys
+// container (if any) in brackets:
+//
+// $ cat /sys/kernel/mm/transparent_hugepage/enabled
+// always [madvise] never
+//
+
+type SysKernelMmTransparentHugepage struct {
+	domain.HandlerBase
+}
+
+var SysKernelMmTransparentHugepage_Handler = &SysKernelMmTransparentHugepage{
+	domain.HandlerBase{
+		Name:    "SysKernelMmTransparentHugepage",
+		Path:    "/sys/kernel/mm/transparent_hugepage",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"enabled": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"defrag": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+			"shmem_enabled": {
+				Kind:    domain.FileEmuResource,
+				Mode:    os.FileMode(uint32(0644)),
+				Policy:  domain.StateOnlyPolicy,
+				Format:  domain.StringFormat,
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysKernelMmTransparentHugepage) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Return an artificial fileInfo if looked-up element matches any of the
+	// emulated components.
+	if v, ok := h.EmuResourceMap[resource]; ok {
+		info := &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    v.Mode,
+			FmodTime: time.Now(),
+		}
+
+		return info, nil
+	}
+
+	return n.Stat()
+}
+
+func (h *SysKernelMmTransparentHugepage) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	return nil
+}
+
+func (h *SysKernelMmTransparentHugepage) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// We are dealing with a single-line element being read, so we can save
+	// some cycles by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if _, ok := h.EmuResourceMap[resource]; !ok {
+		return 0, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	path := n.Path()
+	cntr := req.Container
+
+	hostModes, err := fetchFileData(h, n, cntr)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	cntr.Lock()
+	mode, ok := cntr.Data(path, resource)
+	cntr.Unlock()
+
+	data := hostModes
+	if ok {
+		data = selectThpMode(hostModes, mode)
+	}
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+func (h *SysKernelMmTransparentHugepage) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if _, ok := h.EmuResourceMap[resource]; !ok {
+		return 0, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	path := n.Path()
+	cntr := req.Container
+	mode := strings.TrimSpace(string(req.Data))
+
+	hostModes, err := fetchFileData(h, n, cntr)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	// As in the kernel, unknown modes are rejected.
+	if selectThpMode(hostModes, mode) == "" {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	cntr.Lock()
+	cntr.SetData(path, resource, mode)
+	cntr.Unlock()
+
+	return len(req.Data), nil
+}
+
+func (h *SysKernelMmTransparentHugepage) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	return nil, nil
+}
+
+func (h *SysKernelMmTransparentHugepage) GetName() string {
+	return h.Name
+}
+
+func (h *SysKernelMmTransparentHugepage) GetPath() string {
+	return h.Path
+}
+
+func (h *SysKernelMmTransparentHugepage) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysKernelMmTransparentHugepage) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysKernelMmTransparentHugepage) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *SysKernelMmTransparentHugepage) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *SysKernelMmTransparentHugepage) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysKernelMmTransparentHugepage) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[n.Name()]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *SysKernelMmTransparentHugepage) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// selectThpMode function returns the given list of THP modes (e.g. "always
+// [madvise] never") with 'mode' as the selected one, or an empty string if
+// 'mode' is not in the list.
+func selectThpMode(modes string, mode string) string {
+
+	var found bool
+
+	fields := strings.Fields(modes)
+	for i, m := range fields {
+		m = strings.Trim(m, "[]")
+		if m == mode {
+			found = true
+			fields[i] = "[" + m + "]"
+		} else {
+			fields[i] = m
+		}
+	}
+
+	if !found {
+		return ""
+	}
+
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,317 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/kernel/security handler
+//
+// Emulated resources:
+//
+// * /sys/kernel/security/lsm
+//
+// List of the active LSMs, displayed as per the host.
+//
+// * /sys/kernel/security/apparmor/profiles
+//
+// Only the apparmor profiles confining the sys container (i.e. its init
+// process and the process originating the request) are displayed, rather
+// than all the profiles loaded in the host.
+//
+// * /sys/kernel/security/apparmor/features/...
+//
+// Features supported by the host's apparmor module, displayed as per the host.
+//
+// The rest of the securityfs content (e.g. apparmor's policy dir, or IMA
+// measurements) is hidden, and all nodes are read-only within sys containers
+// (i.e. loading policies is not allowed).
+//
+
+// Dirs & nodes exposed within /sys/kernel/security, indexed by their relative
+// path. Dirs expose their whole subtree.
+var securityfsNodes = map[string]struct{}{
+	"lsm":               {},
+	"apparmor":          {},
+	"apparmor/profiles": {},
+	"apparmor/features": {},
+}
+
+type SysKernelSecurity struct {
+	domain.HandlerBase
+
+	// Dirs & nodes exposed within the handler's path (e.g. securityfsNodes).
+	nodes map[string]struct{}
+}
+
+var SysKernelSecurity_Handler = &SysKernelSecurity{
+	HandlerBase: domain.HandlerBase{
+		Name:    "SysKernelSecurity",
+		Path:    "/sys/kernel/security",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"security": {
+				Kind:    domain.DirEmuResource,
+				Mode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0755)),
+				Enabled: true,
+			},
+		},
+	},
+	nodes: securityfsNodes,
+}
+
+func (h *SysKernelSecurity) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if !h.exposed(n) {
+		return nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	return n.Stat()
+}
+
+func (h *SysKernelSecurity) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if !h.exposed(n) {
+		return fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *SysKernelSecurity) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if !h.exposed(n) {
+		return 0, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	if n.Path() == filepath.Join(h.Path, "apparmor/profiles") {
+		return h.readProfiles(n, req)
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	return copyResultBuffer(req.Data, content)
+}
+
+func (h *SysKernelSecurity) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *SysKernelSecurity) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if !h.exposed(n) {
+		return nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	entries, err := n.ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	ios := h.Service.IOService()
+
+	var fileEntries []os.FileInfo
+
+	for _, entry := range entries {
+		child := ios.NewIOnode(entry.Name(), filepath.Join(n.Path(), entry.Name()), 0)
+		if h.exposed(child) {
+			fileEntries = append(fileEntries, entry)
+		}
+	}
+
+	return fileEntries, nil
+}
+
+func (h *SysKernelSecurity) GetName() string {
+	return h.Name
+}
+
+func (h *SysKernelSecurity) GetPath() string {
+	return h.Path
+}
+
+func (h *SysKernelSecurity) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysKernelSecurity) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysKernelSecurity) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysKernelSecurity) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysKernelSecurity) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysKernelSecurity) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[filepath.Base(h.Path)]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *SysKernelSecurity) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns 'true' if the given node is to be displayed within sys containers
+// (see h.nodes). Parent dirs of the exposed nodes are displayed too.
+func (h *SysKernelSecurity) exposed(n domain.IOnodeIface) bool {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return false
+	}
+
+	if relPath == "." {
+		return true
+	}
+
+	for p := relPath; p != "."; p = filepath.Dir(p) {
+		if _, ok := h.nodes[p]; ok {
+			// Dirs expose their whole subtree, unless more specific entries are
+			// defined for them (e.g. "apparmor").
+			return p == relPath || !h.hasExposedChildren(p)
+		}
+	}
+
+	return false
+}
+
+// Returns 'true' if any of the exposed nodes is placed under the given one.
+func (h *SysKernelSecurity) hasExposedChildren(relPath string) bool {
+
+	for p := range h.nodes {
+		if strings.HasPrefix(p, relPath+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readProfiles method displays the apparmor profiles confining the sys
+// container (i.e. its init process and the process originating the request).
+func (h *SysKernelSecurity) readProfiles(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	cntr := req.Container
+
+	// Ensure operation is generated from within a registered sys container.
+	if cntr == nil {
+		logrus.Errorf("Could not find the container originating this request (pid %v)",
+			req.Pid)
+		return 0, errors.New("Container not found")
+	}
+
+	ios := h.Service.IOService()
+
+	// Profiles are displayed in "name (mode)" format, just as in the
+	// processes' apparmor attributes (unconfined processes display
+	// "unconfined" there).
+	confining := make(map[string]struct{})
+
+	for _, pid := range []uint32{cntr.InitPid(), req.Pid} {
+		attrPath := filepath.Join("/proc", strconv.FormatUint(uint64(pid), 10), "attr/current")
+		attr, err := ios.NewIOnode("current", attrPath, 0).ReadFile()
+		if err != nil {
+			continue
+		}
+		if label := strings.TrimSpace(string(attr)); label != "unconfined" {
+			confining[label] = struct{}{}
+		}
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	var result bytes.Buffer
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if _, ok := confining[line]; ok {
+			result.WriteString(line + "\n")
+		}
+	}
+
+	return copyResultBuffer(req.Data, result.Bytes())
+}
@@ -0,0 +1,362 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/module handler
+//
+// Emulated resources:
+//
+// * /sys/module/<module>/parameters/<param>
+//
+// Only the module parameters matching the policy table defined by the operator
+// (see domain.ModuleParamRule) are exposed, either read-only or with virtual
+// write semantics (i.e. values written are kept within the sys container
+// state and the host is left untouched). Parameters served by a dedicated
+// handler (e.g. nf_conntrack's hashsize) are exposed too.
+//
+// Module directories are displayed as per the host, so that the presence of
+// modules can be probed, but only a few of their informational attributes are
+// exposed; the rest of them (e.g. "sections", "notes") reveal host internals.
+//
+
+// Module parameters to expose by default.
+var moduleParamPolicies = struct {
+	sync.RWMutex
+	rules []domain.ModuleParamRule
+}{
+	rules: []domain.ModuleParamRule{
+		{Path: "/sys/module/apparmor/parameters/enabled", Policy: domain.ModuleParamReadOnly},
+	},
+}
+
+// Module attributes exposed to sys containers.
+var moduleAttrs = map[string]struct{}{
+	"parameters": {},
+	"initstate":  {},
+	"version":    {},
+	"srcversion": {},
+}
+
+// SetModuleParamPolicies function overrides the policy table of the module
+// parameters to expose under /sys/module.
+func SetModuleParamPolicies(rules []domain.ModuleParamRule) {
+	moduleParamPolicies.Lock()
+	moduleParamPolicies.rules = rules
+	moduleParamPolicies.Unlock()
+}
+
+// moduleParamPolicy function returns the policy of the given module parameter,
+// or 'false' if it's not to be exposed.
+func moduleParamPolicy(path string) (domain.ModuleParamPolicy, bool) {
+
+	moduleParamPolicies.RLock()
+	defer moduleParamPolicies.RUnlock()
+
+	var match *domain.ModuleParamRule
+
+	for i, rule := range moduleParamPolicies.rules {
+		if !sysctlPathMatch(path, rule.Path) {
+			continue
+		}
+		if match == nil || len(rule.Path) > len(match.Path) {
+			match = &moduleParamPolicies.rules[i]
+		}
+	}
+
+	if match == nil {
+		return "", false
+	}
+
+	return match.Policy, true
+}
+
+type SysModule struct {
+	domain.HandlerBase
+}
+
+var SysModule_Handler = &SysModule{
+	domain.HandlerBase{
+		Name:    "SysModule",
+		Path:    "/sys/module",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"module": {
+				Kind:    domain.DirEmuResource,
+				Mode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0755)),
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysModule) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if !h.exposed(n) {
+		return nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	info, err := n.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parameters are displayed with the permissions granted by their policy.
+	if policy, ok := h.paramPolicy(n); ok {
+		mode := os.FileMode(0444)
+		if policy == domain.ModuleParamWriteVirtual {
+			mode = os.FileMode(0644)
+		}
+
+		return &domain.FileInfo{
+			Fname:    resource,
+			Fmode:    mode,
+			FmodTime: time.Now(),
+		}, nil
+	}
+
+	return info, nil
+}
+
+func (h *SysModule) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if !h.exposed(n) {
+		return fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) == 0 {
+		return nil
+	}
+
+	if policy, ok := h.paramPolicy(n); ok && policy == domain.ModuleParamWriteVirtual {
+		return nil
+	}
+
+	return fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *SysModule) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	// Sysfs attributes are single-line elements, so we can save some cycles
+	// by returning right away if offset is any higher than zero.
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if !h.exposed(n) {
+		return 0, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	if policy, ok := h.paramPolicy(n); ok && policy == domain.ModuleParamWriteVirtual {
+		cntr := req.Container
+
+		cntr.Lock()
+		data, ok := cntr.Data(n.Path(), resource)
+		cntr.Unlock()
+
+		if ok {
+			return copyResultBuffer(req.Data, []byte(data+"\n"))
+		}
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	return copyResultBuffer(req.Data, content)
+}
+
+func (h *SysModule) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	var resource = n.Name()
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, resource)
+
+	if !h.exposed(n) {
+		return 0, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	if policy, ok := h.paramPolicy(n); !ok || policy != domain.ModuleParamWriteVirtual {
+		return 0, fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	cntr := req.Container
+
+	cntr.Lock()
+	cntr.SetData(n.Path(), resource, strings.TrimSpace(string(req.Data)))
+	cntr.Unlock()
+
+	return len(req.Data), nil
+}
+
+func (h *SysModule) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if !h.exposed(n) {
+		return nil, fuse.IOerror{Code: syscall.ENOENT}
+	}
+
+	entries, err := n.ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	ios := h.Service.IOService()
+
+	var fileEntries []os.FileInfo
+
+	for _, entry := range entries {
+		child := ios.NewIOnode(entry.Name(), filepath.Join(n.Path(), entry.Name()), 0)
+		if h.exposed(child) {
+			fileEntries = append(fileEntries, entry)
+		}
+	}
+
+	return fileEntries, nil
+}
+
+func (h *SysModule) GetName() string {
+	return h.Name
+}
+
+func (h *SysModule) GetPath() string {
+	return h.Path
+}
+
+func (h *SysModule) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysModule) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysModule) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysModule) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysModule) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysModule) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[filepath.Base(h.Path)]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *SysModule) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the policy of the given node if it's a module parameter to expose.
+func (h *SysModule) paramPolicy(n domain.IOnodeIface) (domain.ModuleParamPolicy, bool) {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil {
+		return "", false
+	}
+
+	comps := strings.Split(relPath, "/")
+	if len(comps) != 3 || comps[1] != "parameters" {
+		return "", false
+	}
+
+	return moduleParamPolicy(n.Path())
+}
+
+// Returns 'true' if the given node is to be displayed within sys containers:
+// module dirs, their informational attributes, and the parameters matching
+// the policy table (or served by a dedicated handler).
+func (h *SysModule) exposed(n domain.IOnodeIface) bool {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return false
+	}
+
+	comps := strings.Split(relPath, "/")
+
+	switch {
+	case relPath == "." || len(comps) == 1:
+		return true
+
+	case len(comps) == 2:
+		_, ok := moduleAttrs[comps[1]]
+		return ok
+
+	case len(comps) == 3 && comps[1] == "parameters":
+		if _, ok := h.paramPolicy(n); ok {
+			return true
+		}
+		owner, ok := h.Service.LookupHandler(n)
+		return ok && owner.GetPath() != h.Path
+	}
+
+	return false
+}
@@ -49,6 +49,8 @@ var SysModuleNfconntrackParameters_Handler = &SysModuleNfconntrackParameters{
 			"hashsize": {
 				Kind:    domain.FileEmuResource,
 				Mode:    os.FileMode(uint32(0600)),
+				Policy:  domain.PooledMaxPolicy,
+				Format:  domain.IntFormat,
 				Enabled: true,
 			},
 		},
@@ -161,6 +163,10 @@ func (h *SysModuleNfconntrackParameters) GetResourcesList() []string {
 	return resources
 }
 
+func (h *SysModuleNfconntrackParameters) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
 func (h *SysModuleNfconntrackParameters) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
 	resource, ok := h.EmuResourceMap[n.Name()]
 	if !ok {
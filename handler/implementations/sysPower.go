@@ -0,0 +1,209 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// /sys/power handler
+//
+// Power-management nodes are displayed within sys containers as per the host,
+// but read-only, so that power daemons (e.g. systemd-logind, upower) can't
+// suspend or hibernate the host. The nodes advertising the supported sleep
+// states are emulated to report none, letting these daemons conclude that
+// sleep isn't supported rather than failing on their write attempts:
+//
+// * /sys/power/state: empty.
+//
+// * /sys/power/disk: "[disabled]" (i.e. no hibernation support).
+//
+// * /sys/power/mem_sleep: empty.
+//
+
+// Content of the emulated /sys/power nodes.
+var sysPowerNodes = map[string]string{
+	"state":     "",
+	"disk":      "[disabled]",
+	"mem_sleep": "",
+}
+
+type SysPower struct {
+	domain.HandlerBase
+}
+
+var SysPower_Handler = &SysPower{
+	domain.HandlerBase{
+		Name:    "SysPower",
+		Path:    "/sys/power",
+		Enabled: true,
+		EmuResourceMap: map[string]*domain.EmuResource{
+			"power": {
+				Kind:    domain.DirEmuResource,
+				Mode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0755)),
+				Enabled: true,
+			},
+		},
+	},
+}
+
+func (h *SysPower) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	info, err := n.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return readOnlyFileInfo(info), nil
+}
+
+func (h *SysPower) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.OpenFlags()&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return nil
+}
+
+func (h *SysPower) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if req.Offset > 0 {
+		return 0, io.EOF
+	}
+
+	if n.Path() == filepath.Join(h.Path, n.Name()) {
+		if data, ok := sysPowerNodes[n.Name()]; ok {
+			return copyResultBuffer(req.Data, []byte(data+"\n"))
+		}
+	}
+
+	content, err := n.ReadFile()
+	if err != nil {
+		return 0, err
+	}
+
+	return copyResultBuffer(req.Data, content)
+}
+
+func (h *SysPower) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return 0, fuse.IOerror{Code: syscall.EACCES}
+}
+
+func (h *SysPower) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	entries, err := n.ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var fileEntries []os.FileInfo
+
+	for _, entry := range entries {
+		fileEntries = append(fileEntries, readOnlyFileInfo(entry))
+	}
+
+	return fileEntries, nil
+}
+
+func (h *SysPower) GetName() string {
+	return h.Name
+}
+
+func (h *SysPower) GetPath() string {
+	return h.Path
+}
+
+func (h *SysPower) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *SysPower) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *SysPower) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+// The whole directory is served by this handler, so that's the resource to
+// bind-mount into the sys containers.
+func (h *SysPower) GetResourcesList() []string {
+	return []string{h.GetPath()}
+}
+
+func (h *SysPower) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *SysPower) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	resource, ok := h.EmuResourceMap[filepath.Base(h.Path)]
+	if !ok {
+		return nil
+	}
+
+	return &resource.Mutex
+}
+
+func (h *SysPower) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the given file's attributes with the write permissions dropped.
+func readOnlyFileInfo(info os.FileInfo) *domain.FileInfo {
+
+	return &domain.FileInfo{
+		Fname:    info.Name(),
+		Fsize:    info.Size(),
+		Fmode:    info.Mode() &^ 0222,
+		FmodTime: info.ModTime(),
+		FisDir:   info.IsDir(),
+	}
+}
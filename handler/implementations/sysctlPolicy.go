@@ -0,0 +1,117 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nestybox/sysbox-fs/domain"
+)
+
+//
+// Policy table of the non-emulated /proc/sys nodes.
+//
+// Accesses to /proc/sys nodes lacking a dedicated emulation end up in the
+// pass-through handler, which enforces the policy defined by the operator for
+// the matching path (see domain.SysctlPolicyRule). Nodes with no matching rule
+// keep the regular pass-through behavior ("netns-exec").
+//
+
+var sysctlPolicies = struct {
+	sync.RWMutex
+	rules []domain.SysctlPolicyRule
+}{}
+
+// SetSysctlPolicies function installs the policy table to be enforced over the
+// non-emulated /proc/sys nodes.
+func SetSysctlPolicies(rules []domain.SysctlPolicyRule) {
+	sysctlPolicies.Lock()
+	sysctlPolicies.rules = rules
+	sysctlPolicies.Unlock()
+}
+
+// sysctlPolicy function returns the policy to enforce over the given node, or
+// "netns-exec" if none applies.
+func sysctlPolicy(h domain.HandlerIface, n domain.IOnodeIface) domain.SysctlPolicy {
+
+	sysctlPolicies.RLock()
+	defer sysctlPolicies.RUnlock()
+
+	if len(sysctlPolicies.rules) == 0 {
+		return domain.SysctlNetnsExec
+	}
+
+	path := n.Path()
+	if !strings.HasPrefix(path, "/proc/sys/") {
+		return domain.SysctlNetnsExec
+	}
+
+	var match *domain.SysctlPolicyRule
+
+	for i, rule := range sysctlPolicies.rules {
+		if !sysctlPathMatch(path, rule.Path) {
+			continue
+		}
+		if match == nil || len(rule.Path) > len(match.Path) {
+			match = &sysctlPolicies.rules[i]
+		}
+	}
+
+	if match == nil {
+		return domain.SysctlNetnsExec
+	}
+
+	// Leave alone the nodes served by a dedicated emulation, as these ones
+	// reach this handler to carry out their own i/o operations.
+	if owner, ok := h.GetService().LookupHandler(n); ok && emulatedNode(owner, n) {
+		return domain.SysctlNetnsExec
+	}
+
+	return match.Policy
+}
+
+// Returns 'true' if the given node is explicitly emulated by the given handler.
+// Nodes merely matching one of its patterns (e.g. "*") are not, as these are
+// served through the pass-through handler just like the non-emulated ones.
+func emulatedNode(h domain.HandlerIface, n domain.IOnodeIface) bool {
+
+	relPath, err := filepath.Rel(h.GetPath(), n.Path())
+	if err != nil || relPath == "." {
+		return false
+	}
+
+	_, ok := h.GetResourceMap()[relPath]
+
+	return ok
+}
+
+// Returns 'true' if 'path' matches the rule path literally, as a glob pattern,
+// or is placed under it.
+func sysctlPathMatch(path, rulePath string) bool {
+
+	if path == rulePath || strings.HasPrefix(path, rulePath+"/") {
+		return true
+	}
+
+	if match, _ := filepath.Match(rulePath, path); match {
+		return true
+	}
+
+	return false
+}
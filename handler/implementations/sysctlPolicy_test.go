@@ -0,0 +1,110 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/mocks"
+)
+
+func TestSysctlPolicy(t *testing.T) {
+
+	abi := &ProcSysAbi{
+		WildcardBase{domain.HandlerBase{
+			Path: "/proc/sys/abi",
+			EmuResourceMap: map[string]*domain.EmuResource{
+				"vsyscall32": {Kind: domain.FileEmuResource},
+				"*":          {Kind: domain.FileEmuResource},
+			},
+		}},
+	}
+
+	kernel := &ProcSysKernel{
+		domain.HandlerBase{
+			Path: "/proc/sys/kernel",
+			EmuResourceMap: map[string]*domain.EmuResource{
+				"hostname": {Kind: domain.FileEmuResource},
+			},
+		},
+	}
+
+	// Handler owning each node, as per its path.
+	owner := func(n domain.IOnodeIface) domain.HandlerIface {
+		for _, h := range []domain.HandlerIface{abi, kernel} {
+			if strings.HasPrefix(n.Path(), h.GetPath()+"/") {
+				return h
+			}
+		}
+		return PassThrough_Handler
+	}
+
+	hdsMock := &mocks.HandlerServiceIface{}
+	hdsMock.On("LookupHandler", mock.Anything).Return(
+		owner, func(domain.IOnodeIface) bool { return true })
+
+	h := &PassThrough{domain.HandlerBase{Path: "*", Service: hdsMock}}
+
+	SetSysctlPolicies([]domain.SysctlPolicyRule{
+		{Path: "/proc/sys/abi", Policy: domain.SysctlWriteVirtual},
+		{Path: "/proc/sys/kernel", Policy: domain.SysctlWriteDeny},
+		{Path: "/proc/sys/kernel/sched_*", Policy: domain.SysctlReadPassthrough},
+	})
+	defer SetSysctlPolicies(nil)
+
+	tests := []struct {
+		name string
+		path string
+		want domain.SysctlPolicy
+	}{
+		// Nodes explicitly emulated are left alone.
+		{"1", "/proc/sys/kernel/hostname", domain.SysctlNetnsExec},
+		{"2", "/proc/sys/abi/vsyscall32", domain.SysctlNetnsExec},
+
+		// Nodes matching a handler's "*" pattern are subject to the rules.
+		{"3", "/proc/sys/abi/foo", domain.SysctlWriteVirtual},
+
+		// Non-emulated nodes under a handler's path.
+		{"4", "/proc/sys/kernel/foo", domain.SysctlWriteDeny},
+
+		// Longest (glob) rule must prevail.
+		{"5", "/proc/sys/kernel/sched_child_runs_first", domain.SysctlReadPassthrough},
+
+		// No matching rule.
+		{"6", "/proc/sys/vm/foo", domain.SysctlNetnsExec},
+
+		// Nodes outside /proc/sys.
+		{"7", "/sys/kernel/foo", domain.SysctlNetnsExec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &mocks.IOnodeIface{}
+			n.On("Path").Return(tt.path)
+			n.On("Name").Return(filepath.Base(tt.path))
+
+			if got := sysctlPolicy(h, n); got != tt.want {
+				t.Errorf("sysctlPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -20,8 +20,10 @@ import (
 	"errors"
 	"io"
 	"math/rand"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -33,6 +35,8 @@ import (
 const (
 	MaxInt = int(^uint(0) >> 1)
 	MinInt = -MaxInt - 1
+
+	MaxInt32 = int(^uint32(0) >> 1)
 )
 
 func readFileInt(
@@ -77,6 +81,52 @@ func readFileInt(
 	return copyResultBuffer(req.Data, []byte(data))
 }
 
+// readFileIntDefault function behaves like readFileInt(), except that the
+// passed 'def' value is served for resources that are not present in the host
+// FS (e.g. sysctls dropped by recent kernels).
+func readFileIntDefault(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	def int) (int, error) {
+
+	name := n.Name()
+	path := n.Path()
+	cntr := req.Container
+
+	cntr.Lock()
+
+	data, ok := cntr.Data(path, name)
+	if !ok {
+		val, err := fetchFileData(h, n, cntr)
+		if err != nil && err != io.EOF {
+			if !os.IsNotExist(err) {
+				cntr.Unlock()
+				return 0, err
+			}
+			val = strconv.Itoa(def)
+		}
+
+		// High-level verification to ensure that format is the expected one.
+		_, err = strconv.Atoi(val)
+		if err != nil {
+			cntr.Unlock()
+			logrus.Errorf("Unexpected content read from file %v, error %v",
+				n.Path(), err)
+			return 0, fuse.IOerror{Code: syscall.EINVAL}
+		}
+
+		cntr.SetData(path, name, val)
+		data = val
+	}
+
+	cntr.Unlock()
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
 func readFileString(
 	h domain.HandlerIface,
 	n domain.IOnodeIface,
@@ -99,7 +149,7 @@ func readFileString(
 			return 0, err
 		}
 
-		cntr.SetData(path, name, data)
+		cntr.SetData(path, name, val)
 		data = val
 	}
 
@@ -158,6 +208,14 @@ func writeFileMaxInt(
 	cntr.Lock()
 	defer cntr.Unlock()
 
+	// Keep track of the value requested by this container, so that the host
+	// value can be reconciled once the container goes away.
+	if kernelSync {
+		if err := trackPooledValue(h, n, cntr, newMaxInt, true); err != nil {
+			return 0, err
+		}
+	}
+
 	// Check if this resource has been initialized for this container. If not,
 	// push it to the host FS and store it within the container struct.
 	curMax, ok := cntr.Data(path, name)
@@ -218,6 +276,14 @@ func writeFileMinInt(
 	cntr.Lock()
 	defer cntr.Unlock()
 
+	// Keep track of the value requested by this container, so that the host
+	// value can be reconciled once the container goes away.
+	if kernelSync {
+		if err := trackPooledValue(h, n, cntr, newMinInt, false); err != nil {
+			return 0, err
+		}
+	}
+
 	// Check if this resource has been initialized for this container. If not,
 	// push it down to the kernel and store it within the container struct.
 	curMax, ok := cntr.Data(path, name)
@@ -610,6 +676,339 @@ func pushFileString(
 	return nil
 }
 
+// writeFileClampedInt function stores the integer written by the sys container
+// after clamping it to the host value, so that containers can only tighten the
+// host setting. With 'zeroUnlimited' set, a zero value stands for "no limit".
+func writeFileClampedInt(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	min int,
+	zeroUnlimited bool) (int, error) {
+
+	name := n.Name()
+	path := n.Path()
+	cntr := req.Container
+
+	newVal, err := strconv.Atoi(strings.TrimSpace(string(req.Data)))
+	if err != nil || newVal < min {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	hostData, err := fetchFileData(h, n, cntr)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	hostVal, err := strconv.Atoi(hostData)
+	if err != nil {
+		logrus.Errorf("Unexpected content read from file %v, error %v",
+			n.Path(), err)
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	if !(zeroUnlimited && hostVal == 0) {
+		if newVal > hostVal || (zeroUnlimited && newVal == 0) {
+			newVal = hostVal
+		}
+	}
+
+	cntr.Lock()
+	cntr.SetData(path, name, strconv.Itoa(newVal))
+	cntr.Unlock()
+
+	return len(req.Data), nil
+}
+
+// readNetnsFile function reads a network-namespace-scoped resource (e.g. most
+// of the /proc/sys/net sysctls) from within the network namespace of the
+// process originating the request.
+func readNetnsFile(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	return h.GetService().GetPassThroughHandler().Read(n, req)
+}
+
+// readNetnsFileUncached function is a variant of readNetnsFile() meant for
+// resources whose value changes by itself (e.g. counters), and that hence can't
+// be served from the passthrough handler's cache.
+func readNetnsFileUncached(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	if _, ok := h.GetService().GetPassThroughHandler().(*PassThrough); !ok {
+		return readNetnsFile(h, n, req)
+	}
+
+	data, err := fetchNsFile(h, n, req)
+	if err != nil {
+		return 0, err
+	}
+
+	data += "\n"
+
+	return copyResultBuffer(req.Data, []byte(data))
+}
+
+// fetchNsFile function obtains the content of the given resource as displayed
+// within the namespaces (all but the mount one) of the process originating the
+// request. Content is never served from the passthrough handler's cache.
+func fetchNsFile(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (string, error) {
+
+	pt, ok := h.GetService().GetPassThroughHandler().(*PassThrough)
+	if !ok {
+		return "", errors.New("passthrough handler not available")
+	}
+
+	prs := h.GetService().ProcessService()
+	process := prs.ProcessCreate(req.Pid, req.Uid, req.Gid)
+
+	if data, ok := nsFilePrefetch.consume(process, n.Path()); ok {
+		return data, nil
+	}
+
+	return pt.fetchFile(n, process, req)
+}
+
+// prefetchNsFiles function is the batched counterpart of fetchNsFile(): the
+// content of the given resources is obtained through a single nsenter round
+// trip, and held for the subsequent fetchNsFile() calls of the requester's
+// netns (see nsFilePrefetchStore). Meant for handlers whose resources are
+// usually read all at once right after enumerating them (e.g. 'sysctl -a').
+func prefetchNsFiles(
+	h domain.HandlerIface,
+	files []string,
+	req *domain.HandlerRequest) error {
+
+	pt, ok := h.GetService().GetPassThroughHandler().(*PassThrough)
+	if !ok {
+		return errors.New("passthrough handler not available")
+	}
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	prs := h.GetService().ProcessService()
+	process := prs.ProcessCreate(req.Pid, req.Uid, req.Gid)
+
+	results, err := pt.fetchFiles(files, process, req)
+	if err != nil {
+		return err
+	}
+
+	nsFilePrefetch.store(process, results)
+
+	return nil
+}
+
+// Period during which prefetched content can be served.
+const nsFilePrefetchTTL = time.Second
+
+type nsFilePrefetchKey struct {
+	netns domain.Inode
+	path  string
+}
+
+type nsFilePrefetchEntry struct {
+	content string
+	expiry  time.Time
+}
+
+// nsFilePrefetchStore holds the content obtained through prefetchNsFiles(),
+// keyed by the netns of the requester. Entries are served at most once, and
+// only within nsFilePrefetchTTL, so that reads remain (nearly) as fresh as
+// those going through the nsenter path; expired entries are dropped upon every
+// store, and entries of written files upon every write (see pushFile()).
+type nsFilePrefetchStore struct {
+	sync.Mutex
+	entries map[nsFilePrefetchKey]nsFilePrefetchEntry
+}
+
+var nsFilePrefetch = &nsFilePrefetchStore{
+	entries: make(map[nsFilePrefetchKey]nsFilePrefetchEntry),
+}
+
+func (s *nsFilePrefetchStore) store(
+	process domain.ProcessIface,
+	results []domain.ReadFilesResult) {
+
+	netns, err := process.NetNsInode()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	for key, entry := range s.entries {
+		if now.After(entry.expiry) {
+			delete(s.entries, key)
+		}
+	}
+
+	// Files that couldn't be read are left to the regular path, so that their
+	// errors are reported as usual.
+	for _, result := range results {
+		if result.Errno != 0 {
+			continue
+		}
+		s.entries[nsFilePrefetchKey{netns, result.File}] = nsFilePrefetchEntry{
+			content: result.Content,
+			expiry:  now.Add(nsFilePrefetchTTL),
+		}
+	}
+}
+
+func (s *nsFilePrefetchStore) consume(
+	process domain.ProcessIface,
+	path string) (string, bool) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	// Skip the netns lookup in the common (i.e. nothing prefetched) case.
+	if len(s.entries) == 0 {
+		return "", false
+	}
+
+	netns, err := process.NetNsInode()
+	if err != nil {
+		return "", false
+	}
+
+	key := nsFilePrefetchKey{netns, path}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return "", false
+	}
+	delete(s.entries, key)
+
+	if time.Now().After(entry.expiry) {
+		return "", false
+	}
+
+	return entry.content, true
+}
+
+// Drops the prefetched content of the given file within the process' netns, as
+// it's stale once the file is written.
+func (s *nsFilePrefetchStore) drop(process domain.ProcessIface, path string) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.entries) == 0 {
+		return
+	}
+
+	netns, err := process.NetNsInode()
+	if err != nil {
+		return
+	}
+
+	delete(s.entries, nsFilePrefetchKey{netns, path})
+}
+
+// writeNetnsFileInt function validates the integer being written and pushes
+// it to the network namespace of the process originating the request. Value
+// is cached within the container state if the request comes from the sys
+// container's own netns (see passthrough handler).
+func writeNetnsFileInt(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest,
+	min, max int) (int, error) {
+
+	val, err := strconv.Atoi(strings.TrimSpace(string(req.Data)))
+	if err != nil {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	if val < min || val > max {
+		return 0, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	return h.GetService().GetPassThroughHandler().Write(n, req)
+}
+
+// readNetnsFileOrState function reads a resource from within the requester's
+// network namespace, just like readNetnsFile() does. Resources that have only
+// been netns-scoped by recent kernels are not present within non-initial netns
+// in older ones, in which case the per-container virtual state is served.
+func readNetnsFileOrState(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	num, err := readNetnsFile(h, n, req)
+	if err == nil || !isNotExistError(err) {
+		return num, err
+	}
+
+	return readFileString(h, n, req)
+}
+
+// writeNetnsFileOrState function writes an (already validated) resource within
+// the requester's network namespace, falling back to the per-container virtual
+// state if the resource is not netns-scoped by the running kernel (see
+// readNetnsFileOrState()).
+func writeNetnsFileOrState(
+	h domain.HandlerIface,
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	num, err := h.GetService().GetPassThroughHandler().Write(n, req)
+	if err == nil || !isNotExistError(err) {
+		return num, err
+	}
+
+	return writeFileString(h, n, req, false)
+}
+
+func isNotExistError(err error) bool {
+
+	switch e := err.(type) {
+	case fuse.IOerror:
+		return e.Code == syscall.ENOENT
+	case *fuse.IOerror:
+		return e.Code == syscall.ENOENT
+	}
+
+	return false
+}
+
+// parseIntTuple function parses a whitespace-separated list of 'count' integers
+// (e.g. "4096 131072 6291456") and verifies that all of them fall within the
+// [min, max] range.
+func parseIntTuple(data string, count, min, max int) ([]int, error) {
+
+	fields := strings.Fields(data)
+	if len(fields) != count {
+		return nil, fuse.IOerror{Code: syscall.EINVAL}
+	}
+
+	vals := make([]int, count)
+
+	for i, f := range fields {
+		val, err := strconv.Atoi(f)
+		if err != nil || val < min || val > max {
+			return nil, fuse.IOerror{Code: syscall.EINVAL}
+		}
+		vals[i] = val
+	}
+
+	return vals, nil
+}
+
 // copytResultBuffer function copies the obtained 'result' buffer into the 'I/O'
 // buffer supplied by the user, while ensuring that 'I/O' buffer capacity is not
 // exceeded.
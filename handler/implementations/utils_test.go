@@ -0,0 +1,136 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/mocks"
+)
+
+func newPrefetchProcess(netns domain.Inode) *mocks.ProcessIface {
+
+	p := &mocks.ProcessIface{}
+	p.On("NetNsInode").Return(netns, nil)
+
+	return p
+}
+
+func TestNsFilePrefetchStore(t *testing.T) {
+
+	const (
+		f1 = "/proc/sys/net/ipv4/ip_forward"
+		f2 = "/proc/sys/net/ipv4/tcp_syncookies"
+	)
+
+	results := []domain.ReadFilesResult{
+		{File: f1, Content: "1"},
+		{File: f2, Errno: syscall.EACCES},
+	}
+
+	p1 := newPrefetchProcess(1111)
+	p2 := newPrefetchProcess(2222)
+
+	tests := []struct {
+		name    string
+		prepare func(s *nsFilePrefetchStore)
+		process domain.ProcessIface
+		path    string
+		want    string
+		wantOk  bool
+	}{
+		// Prefetched content is served to the requester's netns.
+		{"1", func(s *nsFilePrefetchStore) {
+			s.store(p1, results)
+		}, p1, f1, "1", true},
+
+		// Prefetched content is served only once.
+		{"2", func(s *nsFilePrefetchStore) {
+			s.store(p1, results)
+			s.consume(p1, f1)
+		}, p1, f1, "", false},
+
+		// Content of other netns is never served.
+		{"3", func(s *nsFilePrefetchStore) {
+			s.store(p1, results)
+		}, p2, f1, "", false},
+
+		// Files that couldn't be read are left to the regular path.
+		{"4", func(s *nsFilePrefetchStore) {
+			s.store(p1, results)
+		}, p1, f2, "", false},
+
+		// Expired content isn't served.
+		{"5", func(s *nsFilePrefetchStore) {
+			s.store(p1, results)
+			for key, entry := range s.entries {
+				entry.expiry = time.Now().Add(-time.Millisecond)
+				s.entries[key] = entry
+			}
+		}, p1, f1, "", false},
+
+		// Written files have their content dropped.
+		{"6", func(s *nsFilePrefetchStore) {
+			s.store(p1, results)
+			s.drop(p1, f1)
+		}, p1, f1, "", false},
+
+		// Writes within other netns leave the content in place.
+		{"7", func(s *nsFilePrefetchStore) {
+			s.store(p1, results)
+			s.drop(p2, f1)
+		}, p1, f1, "1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &nsFilePrefetchStore{
+				entries: make(map[nsFilePrefetchKey]nsFilePrefetchEntry),
+			}
+			tt.prepare(s)
+
+			got, ok := s.consume(tt.process, tt.path)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("nsFilePrefetchStore.consume() = (%q, %v), want (%q, %v)",
+					got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestNsFilePrefetchStore_storeDropsExpired(t *testing.T) {
+
+	p := newPrefetchProcess(1111)
+
+	s := &nsFilePrefetchStore{
+		entries: map[nsFilePrefetchKey]nsFilePrefetchEntry{
+			{1111, "/proc/sys/net/core/somaxconn"}: {
+				content: "4096",
+				expiry:  time.Now().Add(-time.Second),
+			},
+		},
+	}
+
+	s.store(p, []domain.ReadFilesResult{{File: "/proc/sys/net/ipv4/ip_forward", Content: "1"}})
+
+	if len(s.entries) != 1 {
+		t.Errorf("nsFilePrefetchStore.store() kept %d entries, want 1", len(s.entries))
+	}
+}
@@ -0,0 +1,212 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package implementations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/fuse"
+)
+
+//
+// WildcardBase handler
+//
+// Reusable handler for subtrees of rarely utilized system-wide attributes
+// (e.g. /proc/sys/abi), whose resources are served as per their emulation
+// policy (see readFileByPolicy() / writeFileByPolicy()). Resources within the
+// handler's EmuResourceMap can be keyed by glob patterns (e.g. "*"), which
+// apply to the nodes lacking a literal entry.
+//
+// The handler's directory is exposed even if not present in the host.
+//
+
+type WildcardBase struct {
+	domain.HandlerBase
+}
+
+func (h *WildcardBase) Lookup(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (os.FileInfo, error) {
+
+	logrus.Debugf("Executing Lookup() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	info, err := h.Service.GetPassThroughHandler().Lookup(n, req)
+	if err == nil || n.Path() != h.Path {
+		return info, err
+	}
+
+	// Expose the handler's directory even if the host doesn't expose it.
+	return &domain.FileInfo{
+		Fname:    n.Name(),
+		Fmode:    os.FileMode(uint32(os.ModeDir)) | os.FileMode(uint32(0555)),
+		FmodTime: time.Now(),
+		FisDir:   true,
+	}, nil
+}
+
+func (h *WildcardBase) Open(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) error {
+
+	logrus.Debugf("Executing Open() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if n.Path() == h.Path {
+		return nil
+	}
+
+	if resource := h.emuResource(n); resource != nil &&
+		resource.Policy == domain.ReadOnlyPolicy &&
+		n.OpenFlags() != syscall.O_RDONLY {
+		return fuse.IOerror{Code: syscall.EACCES}
+	}
+
+	return h.Service.GetPassThroughHandler().Open(n, req)
+}
+
+func (h *WildcardBase) Read(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Read() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if resource := h.emuResource(n); resource != nil {
+		return readFileByPolicy(h, n, req, resource)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Read(n, req)
+}
+
+func (h *WildcardBase) Write(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) (int, error) {
+
+	logrus.Debugf("Executing Write() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	if resource := h.emuResource(n); resource != nil {
+		return writeFileByPolicy(h, n, req, resource)
+	}
+
+	// Refer to generic handler if no node match is found above.
+	return h.Service.GetPassThroughHandler().Write(n, req)
+}
+
+func (h *WildcardBase) ReadDirAll(
+	n domain.IOnodeIface,
+	req *domain.HandlerRequest) ([]os.FileInfo, error) {
+
+	logrus.Debugf("Executing ReadDirAll() for req-id: %#x, handler: %s, resource: %s",
+		req.ID, h.Name, n.Name())
+
+	fileEntries, err := h.Service.GetPassThroughHandler().ReadDirAll(n, req)
+	if err != nil && n.Path() == h.Path {
+		return nil, nil
+	}
+
+	return fileEntries, err
+}
+
+func (h *WildcardBase) GetName() string {
+	return h.Name
+}
+
+func (h *WildcardBase) GetPath() string {
+	return h.Path
+}
+
+func (h *WildcardBase) GetService() domain.HandlerServiceIface {
+	return h.Service
+}
+
+func (h *WildcardBase) GetEnabled() bool {
+	return h.Enabled
+}
+
+func (h *WildcardBase) SetEnabled(b bool) {
+	h.Enabled = b
+}
+
+func (h *WildcardBase) GetResourcesList() []string {
+
+	var resources []string
+
+	for resourceKey, resource := range h.EmuResourceMap {
+		resource.Mutex.Lock()
+		if !resource.Enabled {
+			resource.Mutex.Unlock()
+			continue
+		}
+		resource.Mutex.Unlock()
+
+		resources = append(resources, filepath.Join(h.GetPath(), resourceKey))
+	}
+
+	return resources
+}
+
+func (h *WildcardBase) GetResourceMap() map[string]*domain.EmuResource {
+	return h.EmuResourceMap
+}
+
+func (h *WildcardBase) GetResourceMutex(n domain.IOnodeIface) *sync.Mutex {
+	if resource := h.emuResource(n); resource != nil {
+		return &resource.Mutex
+	}
+
+	return nil
+}
+
+func (h *WildcardBase) SetService(hs domain.HandlerServiceIface) {
+	h.Service = hs
+}
+
+// Returns the emulated resource matching the given node, either literally or
+// through the "*" pattern.
+func (h *WildcardBase) emuResource(n domain.IOnodeIface) *domain.EmuResource {
+
+	relPath, err := filepath.Rel(h.Path, n.Path())
+	if err != nil || relPath == "." {
+		return nil
+	}
+
+	if v, ok := h.EmuResourceMap[relPath]; ok {
+		return v
+	}
+
+	for k, v := range h.EmuResourceMap {
+		if !strings.Contains(k, "*") {
+			continue
+		}
+		if match, _ := filepath.Match(k, relPath); match {
+			return v
+		}
+	}
+
+	return nil
+}
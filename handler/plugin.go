@@ -0,0 +1,160 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"plugin"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/handler/implementations"
+)
+
+//
+// Load the handler plugins declared by the operator in the json file passed at
+// startup time (see domain.HandlerPluginSpec). Example:
+//
+// {
+//   "plugins": [
+//     { "name": "gpu", "kind": "go", "path": "/usr/lib/sysbox/gpu.so" },
+//     { "name": "hwmon", "kind": "process", "path": "/run/sysbox/hwmon.sock",
+//       "nodes": [ "/sys/class/hwmon" ] }
+//   ]
+// }
+//
+func LoadHandlerPlugins(path string) ([]domain.HandlerIface, error) {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg domain.HandlerPluginConfig
+
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid plugins config file %s: %v", path, err)
+	}
+
+	var hdlrs []domain.HandlerIface
+
+	for _, spec := range cfg.Plugins {
+		if !filepath.IsAbs(spec.Path) {
+			return nil, fmt.Errorf("invalid path %q for plugin %s: must be absolute",
+				spec.Path, spec.Name)
+		}
+
+		var (
+			h   []domain.HandlerIface
+			err error
+		)
+
+		switch spec.Kind {
+		case domain.GoHandlerPlugin:
+			h, err = loadGoHandlerPlugin(&spec)
+		case domain.ProcessHandlerPlugin:
+			h, err = loadProcessHandlerPlugin(&spec)
+		default:
+			err = fmt.Errorf("invalid kind %q", spec.Kind)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to load plugin %s: %v", spec.Name, err)
+		}
+
+		logrus.Infof("Loaded plugin %s (%d handlers)", spec.Name, len(h))
+
+		hdlrs = append(hdlrs, h...)
+	}
+
+	return hdlrs, nil
+}
+
+func loadGoHandlerPlugin(spec *domain.HandlerPluginSpec) ([]domain.HandlerIface, error) {
+
+	p, err := plugin.Open(spec.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	sym, err := p.Lookup(domain.HandlerPluginVersionSymbol)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := sym.(*int)
+	if !ok || *version != domain.HandlerPluginAPIVersion {
+		return nil, fmt.Errorf("unsupported plugins API version (expected %d)",
+			domain.HandlerPluginAPIVersion)
+	}
+
+	sym, err = p.Lookup(domain.HandlerPluginSymbol)
+	if err != nil {
+		return nil, err
+	}
+
+	var handlersFunc domain.HandlerPluginFunc
+
+	switch f := sym.(type) {
+	case func() []domain.HandlerIface:
+		handlersFunc = f
+	case *domain.HandlerPluginFunc:
+		handlersFunc = *f
+	default:
+		return nil, fmt.Errorf("symbol %s has unexpected type %T",
+			domain.HandlerPluginSymbol, sym)
+	}
+
+	return handlersFunc(), nil
+}
+
+func loadProcessHandlerPlugin(spec *domain.HandlerPluginSpec) ([]domain.HandlerIface, error) {
+
+	if len(spec.Nodes) == 0 {
+		return nil, fmt.Errorf("no nodes defined")
+	}
+
+	var hdlrs []domain.HandlerIface
+
+	for _, node := range spec.Nodes {
+		if !filepath.IsAbs(node) || filepath.Clean(node) == "/" {
+			return nil, fmt.Errorf("invalid node path %q", node)
+		}
+
+		h := implementations.NewProcessPluginHandler(
+			spec.Name,
+			filepath.Clean(node),
+			spec.Path,
+		)
+
+		// Plugin processes may come up after sysbox-fs, so the API version is
+		// only enforced if the plugin can be reached at this point.
+		if version, err := h.Version(); err != nil {
+			logrus.Warnf("Plugin %s not reachable yet: %v", spec.Name, err)
+		} else if version != domain.HandlerPluginAPIVersion {
+			return nil, fmt.Errorf("unsupported plugins API version %d (expected %d)",
+				version, domain.HandlerPluginAPIVersion)
+		}
+
+		hdlrs = append(hdlrs, h)
+	}
+
+	return hdlrs, nil
+}
@@ -84,6 +84,29 @@ func (_m *ContainerIface) GID() uint32 {
 	return r0
 }
 
+// HandlerOverride provides a mock function with given fields: path
+func (_m *ContainerIface) HandlerOverride(path string) (*domain.HandlerOverride, bool) {
+	ret := _m.Called(path)
+
+	var r0 *domain.HandlerOverride
+	if rf, ok := ret.Get(0).(func(string) *domain.HandlerOverride); ok {
+		r0 = rf(path)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.HandlerOverride)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(path)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // ID provides a mock function with given fields:
 func (_m *ContainerIface) ID() string {
 	ret := _m.Called()
@@ -254,6 +277,22 @@ func (_m *ContainerIface) IsImmutableRoMountpoint(mp string) bool {
 	return r0
 }
 
+// KernelLog provides a mock function with given fields:
+func (_m *ContainerIface) KernelLog() *domain.KernelLog {
+	ret := _m.Called()
+
+	var r0 *domain.KernelLog
+	if rf, ok := ret.Get(0).(func() *domain.KernelLog); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.KernelLog)
+		}
+	}
+
+	return r0
+}
+
 // Lock provides a mock function with given fields:
 func (_m *ContainerIface) Lock() {
 	_m.Called()
@@ -296,6 +335,11 @@ func (_m *ContainerIface) SetData(path string, name string, data string) {
 	_m.Called(path, name, data)
 }
 
+// SetHandlerOverrides provides a mock function with given fields: overrides
+func (_m *ContainerIface) SetHandlerOverrides(overrides []domain.HandlerOverride) {
+	_m.Called(overrides)
+}
+
 // SetInitProc provides a mock function with given fields: pid, uid, gid
 func (_m *ContainerIface) SetInitProc(pid uint32, uid uint32, gid uint32) error {
 	ret := _m.Called(pid, uid, gid)
@@ -310,6 +354,22 @@ func (_m *ContainerIface) SetInitProc(pid uint32, uid uint32, gid uint32) error
 	return r0
 }
 
+// SwapTable provides a mock function with given fields:
+func (_m *ContainerIface) SwapTable() *domain.SwapTable {
+	ret := _m.Called()
+
+	var r0 *domain.SwapTable
+	if rf, ok := ret.Get(0).(func() *domain.SwapTable); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*domain.SwapTable)
+		}
+	}
+
+	return r0
+}
+
 // UID provides a mock function with given fields:
 func (_m *ContainerIface) UID() uint32 {
 	ret := _m.Called()
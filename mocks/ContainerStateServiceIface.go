@@ -164,7 +164,7 @@ func (_m *ContainerStateServiceIface) ProcessService() domain.ProcessServiceIfac
 	return r0
 }
 
-// Setup provides a mock function with given fields: fss, prs, ios, mts
-func (_m *ContainerStateServiceIface) Setup(fss domain.FuseServerServiceIface, prs domain.ProcessServiceIface, ios domain.IOServiceIface, mts domain.MountServiceIface) {
-	_m.Called(fss, prs, ios, mts)
+// Setup provides a mock function with given fields: fss, hds, prs, ios, mts
+func (_m *ContainerStateServiceIface) Setup(fss domain.FuseServerServiceIface, hds domain.HandlerServiceIface, prs domain.ProcessServiceIface, ios domain.IOServiceIface, mts domain.MountServiceIface) {
+	_m.Called(fss, hds, prs, ios, mts)
 }
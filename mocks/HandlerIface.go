@@ -45,15 +45,15 @@ func (_m *HandlerIface) GetPath() string {
 }
 
 // GetResourceMap provides a mock function with given fields:
-func (_m *HandlerIface) GetResourceMap() map[string]domain.EmuResource {
+func (_m *HandlerIface) GetResourceMap() map[string]*domain.EmuResource {
 	ret := _m.Called()
 
-	var r0 map[string]domain.EmuResource
-	if rf, ok := ret.Get(0).(func() map[string]domain.EmuResource); ok {
+	var r0 map[string]*domain.EmuResource
+	if rf, ok := ret.Get(0).(func() map[string]*domain.EmuResource); ok {
 		r0 = rf()
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(map[string]domain.EmuResource)
+			r0 = ret.Get(0).(map[string]*domain.EmuResource)
 		}
 	}
 
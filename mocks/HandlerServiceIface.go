@@ -12,13 +12,13 @@ type HandlerServiceIface struct {
 	mock.Mock
 }
 
-// DisableHandler provides a mock function with given fields: path
-func (_m *HandlerServiceIface) DisableHandler(path string) error {
-	ret := _m.Called(path)
+// DisableHandler provides a mock function with given fields: path, cntrId
+func (_m *HandlerServiceIface) DisableHandler(path string, cntrId string) error {
+	ret := _m.Called(path, cntrId)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(path)
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(path, cntrId)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -26,13 +26,13 @@ func (_m *HandlerServiceIface) DisableHandler(path string) error {
 	return r0
 }
 
-// EnableHandler provides a mock function with given fields: path
-func (_m *HandlerServiceIface) EnableHandler(path string) error {
-	ret := _m.Called(path)
+// EnableHandler provides a mock function with given fields: path, cntrId
+func (_m *HandlerServiceIface) EnableHandler(path string, cntrId string) error {
+	ret := _m.Called(path, cntrId)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(path)
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(path, cntrId)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -100,6 +100,22 @@ func (_m *HandlerServiceIface) GetPassThroughHandler() domain.HandlerIface {
 	return r0
 }
 
+// HandlersCatalog provides a mock function with given fields:
+func (_m *HandlerServiceIface) HandlersCatalog() []domain.HandlerCatalogEntry {
+	ret := _m.Called()
+
+	var r0 []domain.HandlerCatalogEntry
+	if rf, ok := ret.Get(0).(func() []domain.HandlerCatalogEntry); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]domain.HandlerCatalogEntry)
+		}
+	}
+
+	return r0
+}
+
 // HandlersResourcesList provides a mock function with given fields:
 func (_m *HandlerServiceIface) HandlersResourcesList() []string {
 	ret := _m.Called()
@@ -183,6 +199,34 @@ func (_m *HandlerServiceIface) LookupHandler(i domain.IOnodeIface) (domain.Handl
 	return r0, r1
 }
 
+// InvalidateReadCaches provides a mock function with given fields: c
+func (_m *HandlerServiceIface) InvalidateReadCaches(c domain.ContainerIface) {
+	_m.Called(c)
+}
+
+// LookupContainerHandler provides a mock function with given fields: i, c
+func (_m *HandlerServiceIface) LookupContainerHandler(i domain.IOnodeIface, c domain.ContainerIface) (domain.HandlerIface, bool) {
+	ret := _m.Called(i, c)
+
+	var r0 domain.HandlerIface
+	if rf, ok := ret.Get(0).(func(domain.IOnodeIface, domain.ContainerIface) domain.HandlerIface); ok {
+		r0 = rf(i, c)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(domain.HandlerIface)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(domain.IOnodeIface, domain.ContainerIface) bool); ok {
+		r1 = rf(i, c)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // NSenterService provides a mock function with given fields:
 func (_m *HandlerServiceIface) NSenterService() domain.NSenterServiceIface {
 	ret := _m.Called()
@@ -215,6 +259,11 @@ func (_m *HandlerServiceIface) ProcessService() domain.ProcessServiceIface {
 	return r0
 }
 
+// ReconcileEmuResources provides a mock function with given fields: c
+func (_m *HandlerServiceIface) ReconcileEmuResources(c domain.ContainerIface) {
+	_m.Called(c)
+}
+
 // RegisterHandler provides a mock function with given fields: h
 func (_m *HandlerServiceIface) RegisterHandler(h domain.HandlerIface) error {
 	ret := _m.Called(h)
@@ -229,14 +278,28 @@ func (_m *HandlerServiceIface) RegisterHandler(h domain.HandlerIface) error {
 	return r0
 }
 
+// SetDisabledHandlers provides a mock function with given fields: rules
+func (_m *HandlerServiceIface) SetDisabledHandlers(rules []domain.DisabledHandlerRule) error {
+	ret := _m.Called(rules)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]domain.DisabledHandlerRule) error); ok {
+		r0 = rf(rules)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetStateService provides a mock function with given fields: css
 func (_m *HandlerServiceIface) SetStateService(css domain.ContainerStateServiceIface) {
 	_m.Called(css)
 }
 
-// Setup provides a mock function with given fields: hdlrs, ignoreErrors, css, nss, prs, ios
-func (_m *HandlerServiceIface) Setup(hdlrs []domain.HandlerIface, ignoreErrors bool, css domain.ContainerStateServiceIface, nss domain.NSenterServiceIface, prs domain.ProcessServiceIface, ios domain.IOServiceIface) {
-	_m.Called(hdlrs, ignoreErrors, css, nss, prs, ios)
+// Setup provides a mock function with given fields: hdlrs, ignoreErrors, css, nss, prs, ios, attrCfg
+func (_m *HandlerServiceIface) Setup(hdlrs []domain.HandlerIface, ignoreErrors bool, css domain.ContainerStateServiceIface, nss domain.NSenterServiceIface, prs domain.ProcessServiceIface, ios domain.IOServiceIface, attrCfg *domain.EmuResourceAttrConfig) {
+	_m.Called(hdlrs, ignoreErrors, css, nss, prs, ios, attrCfg)
 }
 
 // StateService provides a mock function with given fields:
@@ -172,6 +172,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		break
@@ -191,6 +192,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		break
@@ -210,6 +212,27 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: p,
+		}
+		break
+
+	case domain.ReadFilesResponse:
+		logrus.Debug("Received nsenterEvent readFilesResponse message.")
+
+		var p []domain.ReadFilesResult
+
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		break
@@ -219,6 +242,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: "",
 		}
 		break
@@ -238,6 +262,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		break
@@ -247,6 +272,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: "",
 		}
 		break
@@ -256,6 +282,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: "",
 		}
 		break
@@ -275,6 +302,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		break
@@ -294,6 +322,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		break
@@ -303,6 +332,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: "",
 		}
 		break
@@ -312,12 +342,44 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: "",
+		}
+		break
+
+	case domain.MkdirResponse:
+		logrus.Debug("Received nsenterEvent mkdirResponse message.")
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: "",
+		}
+		break
+
+	case domain.RmdirResponse:
+		logrus.Debug("Received nsenterEvent rmdirResponse message.")
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: "",
+		}
+		break
+
+	case domain.SignalProcsResponse:
+		logrus.Debug("Received nsenterEvent signalProcsResponse message.")
+
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: "",
 		}
 		break
 
 	case domain.ErrorResponse:
-		logrus.Debug("Received nsenterEvent errorResponse message.")
+		logrus.Debugf("Received nsenterEvent errorResponse message for req-id: %#x",
+			nsenterMsg.ReqID)
 
 		var p fuse.IOerror
 
@@ -331,6 +393,7 @@ func (e *NSenterEvent) processResponse(pipe io.Reader) error {
 
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		break
@@ -369,9 +432,15 @@ func (e *NSenterEvent) namespacePaths() []string {
 // access namespaced resources will call this method to invoke nsexec,
 // which will enter the container namespaces that host these resources.
 //
-func (e *NSenterEvent) SendRequest() error {
+func (e *NSenterEvent) SendRequest() (err error) {
 
-	logrus.Debug("Executing nsenterEvent's SendRequest() method")
+	logrus.Debugf("Executing nsenterEvent's SendRequest() method for req-id: %#x",
+		e.ReqMsg.ReqID)
+
+	// Skip the requests already interrupted (e.g. FUSE_INTERRUPT).
+	if e.interrupted() {
+		return fuse.IOerror{Code: syscall.EINTR}
+	}
 
 	// Alert the zombie reaper that nsenter is about to start. Notice that we
 	// skip reaper's services for async requests as, in those cases, the callee
@@ -397,6 +466,27 @@ func (e *NSenterEvent) SendRequest() error {
 		}
 	}()
 
+	// Bound the lifetime of synchronous requests (see requestGuard). Async
+	// requests are bounded by their callees, which sigkill their processes.
+	var guard *requestGuard
+	if !e.Async {
+		guard = newRequestGuard(e.ReqMsg.Ctx, parentPipe)
+		defer guard.stop()
+		defer func() {
+			if err == nil || !guard.aborted() {
+				return
+			}
+			e.reaper.nsenterReapReq()
+			if guard.timedOut() {
+				logrus.Warnf("nsenter request timed out for req-id: %#x (pid %d)",
+					e.ReqMsg.ReqID, e.Pid)
+				err = fuse.IOerror{Code: syscall.ETIMEDOUT}
+			} else {
+				err = fuse.IOerror{Code: syscall.EINTR}
+			}
+		}()
+	}
+
 	// Set the SO_PASSCRED on the socket (so we can pass process credentials across it)
 	socket := int(parentPipe.Fd())
 	err = syscall.SetsockoptInt(socket, syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
@@ -434,6 +524,9 @@ func (e *NSenterEvent) SendRequest() error {
 		logrus.Errorf("Error launching sysbox-fs first child process: %s", err)
 		return errors.New("Error launching sysbox-fs first child process")
 	}
+	if guard != nil {
+		guard.track(cmd.Process)
+	}
 
 	// Send the config to child process.
 	if _, err := io.Copy(e.parentPipe, bytes.NewReader(r.Serialize())); err != nil {
@@ -489,6 +582,9 @@ func (e *NSenterEvent) SendRequest() error {
 		return err
 	}
 	e.Process = process
+	if guard != nil {
+		guard.track(process)
+	}
 
 	//
 	// Transfer the nsenterEvent details to grand-child for processing.
@@ -557,6 +653,15 @@ func (e *NSenterEvent) SendRequest() error {
 	return nil
 }
 
+// Returns true if the request on whose behalf this event is generated has been
+// interrupted.
+func (e *NSenterEvent) interrupted() bool {
+
+	ctx := e.ReqMsg.Ctx
+
+	return ctx != nil && ctx.Err() != nil
+}
+
 func (e *NSenterEvent) ReceiveResponse() *domain.NSenterMessage {
 
 	return e.ResMsg
@@ -708,6 +813,40 @@ func (e *NSenterEvent) processFileReadRequest() error {
 	return nil
 }
 
+func (e *NSenterEvent) processFilesReadRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.ReadFilesPayload)
+
+	results := make([]domain.ReadFilesResult, 0, len(payload.Files))
+
+	// Files that can't be read are reported individually through their errno.
+	for _, file := range payload.Files {
+		result := domain.ReadFilesResult{File: file}
+
+		fileContent, err := ioutil.ReadFile(file)
+		if err != nil {
+			result.Errno = syscall.EIO
+			if pathErr, ok := err.(*os.PathError); ok {
+				if errno, ok := pathErr.Err.(syscall.Errno); ok {
+					result.Errno = errno
+				}
+			}
+		} else {
+			result.Content = strings.TrimSpace(string(fileContent))
+		}
+
+		results = append(results, result)
+	}
+
+	// Create a response message.
+	e.ResMsg = &domain.NSenterMessage{
+		Type:    domain.ReadFilesResponse,
+		Payload: results,
+	}
+
+	return nil
+}
+
 func (e *NSenterEvent) processFileWriteRequest() error {
 
 	payload := e.ReqMsg.Payload.(domain.WriteFilePayload)
@@ -769,6 +908,54 @@ func (e *NSenterEvent) processDirReadRequest() error {
 	return nil
 }
 
+func (e *NSenterEvent) processMkdirRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.MkdirPayload)
+
+	mode, err := strconv.Atoi(payload.Mode)
+	if err == nil {
+		err = os.Mkdir(payload.Dir, os.FileMode(mode))
+	}
+	if err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+
+	// Create a response message.
+	e.ResMsg = &domain.NSenterMessage{
+		Type:    domain.MkdirResponse,
+		Payload: nil,
+	}
+
+	return nil
+}
+
+func (e *NSenterEvent) processRmdirRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.RmdirPayload)
+
+	// Notice that os.Remove() is not utilized here, as it would fall back to
+	// unlink() any non-directory entry.
+	if err := unix.Rmdir(payload.Dir); err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+
+	// Create a response message.
+	e.ResMsg = &domain.NSenterMessage{
+		Type:    domain.RmdirResponse,
+		Payload: nil,
+	}
+
+	return nil
+}
+
 func (e *NSenterEvent) processMountSyscallRequest() error {
 
 	var (
@@ -1038,6 +1225,75 @@ func (e *NSenterEvent) processSleepRequest() error {
 	return nil
 }
 
+func (e *NSenterEvent) processSignalProcsRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.SignalProcsReqPayload)
+
+	// Signal every process within our pid-ns (and its descendants) that we're
+	// allowed to, except the pid-ns init and ourselves. ESRCH merely indicates
+	// that there was no process to signal.
+	err := unix.Kill(-1, syscall.Signal(payload.Signal))
+	if err != nil && err != unix.ESRCH {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+
+	e.ResMsg = &domain.NSenterMessage{
+		Type:    domain.SignalProcsResponse,
+		Payload: "",
+	}
+
+	return nil
+}
+
+func (e *NSenterEvent) processNetSysfsRequest() error {
+
+	payload := e.ReqMsg.Payload.(domain.NetSysfsReqPayload)
+
+	// Network devices displayed by sysfs are those of the netns of the process
+	// mounting it, so a fresh sysfs instance is mounted over /sys within a
+	// private mount-ns of our own (i.e. host's /sys is left untouched).
+	err := unix.Unshare(unix.CLONE_NEWNS)
+	if err == nil {
+		err = unix.Mount("", "/", "", unix.MS_REC|unix.MS_SLAVE, "")
+	}
+	if err == nil {
+		err = unix.Mount("sysfs", "/sys", "sysfs",
+			unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+	}
+	if err != nil {
+		e.ResMsg = &domain.NSenterMessage{
+			Type:    domain.ErrorResponse,
+			Payload: &fuse.IOerror{RcvError: err},
+		}
+		return nil
+	}
+
+	switch payload.Op {
+	case domain.LookupRequest:
+		e.ReqMsg.Payload = domain.LookupPayload{Entry: payload.Path}
+		return e.processLookupRequest()
+
+	case domain.ReadFileRequest:
+		e.ReqMsg.Payload = domain.ReadFilePayload{File: payload.Path}
+		return e.processFileReadRequest()
+
+	case domain.ReadDirRequest:
+		e.ReqMsg.Payload = domain.ReadDirPayload{Dir: payload.Path}
+		return e.processDirReadRequest()
+	}
+
+	e.ResMsg = &domain.NSenterMessage{
+		Type:    domain.ErrorResponse,
+		Payload: &fuse.IOerror{Code: syscall.EINVAL},
+	}
+
+	return nil
+}
+
 // Method in charge of processing all requests generated by sysbox-fs' master
 // instance.
 func (e *NSenterEvent) processRequest(pipe *os.File) error {
@@ -1079,6 +1335,7 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		return e.processLookupRequest()
@@ -1095,6 +1352,7 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		return e.processOpenFileRequest()
@@ -1111,10 +1369,28 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		return e.processFileReadRequest()
 
+	case domain.ReadFilesRequest:
+		var p domain.ReadFilesPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: p,
+		}
+		return e.processFilesReadRequest()
+
 	case domain.WriteFileRequest:
 		var p domain.WriteFilePayload
 		if payload != nil {
@@ -1127,6 +1403,7 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		return e.processFileWriteRequest()
@@ -1143,6 +1420,7 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 		return e.processDirReadRequest()
@@ -1175,6 +1453,7 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 
@@ -1192,6 +1471,7 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 
@@ -1199,7 +1479,8 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 	case domain.MountInfoRequest:
 		e.ReqMsg = &domain.NSenterMessage{
-			Type: nsenterMsg.Type,
+			Type:  nsenterMsg.Type,
+			ReqID: nsenterMsg.ReqID,
 		}
 
 		return e.processMountInfoRequest()
@@ -1216,6 +1497,7 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 
@@ -1233,6 +1515,7 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 
@@ -1250,11 +1533,84 @@ func (e *NSenterEvent) processRequest(pipe *os.File) error {
 
 		e.ReqMsg = &domain.NSenterMessage{
 			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
 			Payload: p,
 		}
 
 		return e.processSleepRequest()
 
+	case domain.SignalProcsRequest:
+		var p domain.SignalProcsReqPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: p,
+		}
+
+		return e.processSignalProcsRequest()
+
+	case domain.MkdirRequest:
+		var p domain.MkdirPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: p,
+		}
+
+		return e.processMkdirRequest()
+
+	case domain.RmdirRequest:
+		var p domain.RmdirPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: p,
+		}
+
+		return e.processRmdirRequest()
+
+	case domain.NetSysfsRequest:
+		var p domain.NetSysfsReqPayload
+		if payload != nil {
+			err := json.Unmarshal(payload, &p)
+			if err != nil {
+				logrus.Error(err)
+				return err
+			}
+		}
+
+		e.ReqMsg = &domain.NSenterMessage{
+			Type:    nsenterMsg.Type,
+			ReqID:   nsenterMsg.ReqID,
+			Payload: p,
+		}
+
+		return e.processNetSysfsRequest()
+
 	default:
 		e.ResMsg = &domain.NSenterMessage{
 			Type:    domain.ErrorResponse,
@@ -1308,6 +1664,11 @@ func Init() (err error) {
 		}
 	}
 
+	// Tag the response with the ID of the request being served.
+	if event.ReqMsg != nil {
+		event.ResMsg.ReqID = event.ReqMsg.ReqID
+	}
+
 	// Encode / push response back to sysbox-main.
 	data, err := json.Marshal(*(event.ResMsg))
 	if err != nil {
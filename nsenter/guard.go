@@ -0,0 +1,141 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package nsenter
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// Deadline of the (synchronous) nsenter requests. Requests exceeding it (e.g.
+// those touching a frozen sys container) fail with ETIMEDOUT, and their nsenter
+// processes are killed. Zero disables it.
+var RequestTimeout time.Duration = 30 * time.Second
+
+//
+// requestGuard kills the processes (and unblocks the pipe) of an nsenter
+// request once the request is interrupted or its deadline expires, so that
+// neither the nsenter processes nor the goroutine serving the request are left
+// behind.
+//
+type requestGuard struct {
+	sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	pipe     *os.File
+	procs    []*os.Process
+	fired    bool
+	stopped  bool
+	finished chan struct{}
+}
+
+func newRequestGuard(parent context.Context, pipe *os.File) *requestGuard {
+
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	g := &requestGuard{
+		pipe:     pipe,
+		finished: make(chan struct{}),
+	}
+
+	if RequestTimeout > 0 {
+		g.ctx, g.cancel = context.WithTimeout(parent, RequestTimeout)
+	} else {
+		g.ctx, g.cancel = context.WithCancel(parent)
+	}
+
+	go g.watch()
+
+	return g
+}
+
+func (g *requestGuard) watch() {
+
+	select {
+	case <-g.ctx.Done():
+	case <-g.finished:
+		return
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	if g.stopped {
+		return
+	}
+	g.fired = true
+
+	logrus.Debugf("Aborting nsenter request: %v", g.ctx.Err())
+
+	for _, p := range g.procs {
+		p.Kill()
+	}
+
+	// Wake up any reader blocked on the pipe.
+	unix.Shutdown(int(g.pipe.Fd()), unix.SHUT_RDWR)
+}
+
+// Registers a process to kill should the request be aborted (right away if
+// that's already the case).
+func (g *requestGuard) track(p *os.Process) {
+
+	g.Lock()
+	defer g.Unlock()
+
+	if g.fired {
+		p.Kill()
+		return
+	}
+
+	g.procs = append(g.procs, p)
+}
+
+// Disarms the guard; must be called before the request's pipe is closed.
+func (g *requestGuard) stop() {
+
+	g.Lock()
+	g.stopped = true
+	g.Unlock()
+
+	close(g.finished)
+	g.cancel()
+}
+
+// Returns true if the request has been aborted due to its deadline.
+func (g *requestGuard) timedOut() bool {
+
+	g.Lock()
+	defer g.Unlock()
+
+	return g.fired && g.ctx.Err() == context.DeadlineExceeded
+}
+
+// Returns true if the request has been aborted for any reason.
+func (g *requestGuard) aborted() bool {
+
+	g.Lock()
+	defer g.Unlock()
+
+	return g.fired
+}
@@ -0,0 +1,148 @@
+//
+// Copyright 2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file contains Sysbox's sethostname and setdomainname syscall trapping &
+// handling code. Sysbox-fs serves /proc/sys/kernel/hostname and
+// /proc/sys/kernel/domainname out of the sys container's state (see the
+// ProcSysKernel handler), so a name changed through these syscalls would not
+// show up in those nodes. Sysbox thereby answers the syscalls itself: the name
+// is set within the sys container's UTS namespace and only recorded in the
+// container state once the kernel has accepted it, so that both remain in sync.
+// Names set from other UTS namespaces (e.g. inner containers) are left to the
+// kernel.
+
+package seccomp
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// Max length of the UTS namespace names (as per the kernel's __NEW_UTS_LEN).
+const utsNameMaxLen = 64
+
+type utsNameSyscallInfo struct {
+	syscallCtx        // syscall generic info
+	resource   string // "hostname" or "domainname"
+	addr       uint64
+	size       uint64
+}
+
+func (ui *utsNameSyscallInfo) processSetUtsName() (*sysResponse, error) {
+
+	t := ui.tracer
+
+	if ui.size > utsNameMaxLen {
+		return t.createErrorResponse(ui.reqId, syscall.EINVAL), nil
+	}
+
+	// Only names set within the sys container's own UTS namespace are
+	// reflected by its state.
+	ui.processInfo = t.service.prs.ProcessCreate(ui.pid, 0, 0)
+	procNs, err := ui.processInfo.NsInodes()
+	if err != nil {
+		return t.createContinueResponse(ui.reqId), nil
+	}
+	initNs, err := ui.cntr.InitProc().NsInodes()
+	if err != nil || procNs["uts"] != initNs["uts"] {
+		return t.createContinueResponse(ui.reqId), nil
+	}
+
+	// As per sethostname(2), cap_sys_admin is required within the user
+	// namespace owning the UTS namespace (i.e. the sys container's one).
+	if procNs["user"] != initNs["user"] || !ui.processInfo.IsSysAdminCapabilitySet() {
+		return t.createErrorResponse(ui.reqId, syscall.EPERM), nil
+	}
+
+	data, err := t.processMemRead(ui.pid, ui.addr, int(ui.size))
+	if err != nil {
+		return t.createErrorResponse(ui.reqId, syscall.EFAULT), nil
+	}
+
+	// Names are read as C strings (e.g. by uname(2)), so anything past a null
+	// character is dropped.
+	name := string(data)
+	for i := range data {
+		if data[i] == 0 {
+			name = string(data[:i])
+			break
+		}
+	}
+
+	logrus.Debugf("Received set%s syscall from pid %d: %s = %v",
+		ui.resource, ui.pid, ui.resource, name)
+
+	err = setUtsNameAt(fmt.Sprintf("/proc/%d/ns/uts", ui.pid), ui.resource, data)
+	if err != nil {
+		logrus.Debugf("Unable to set %s of pid %d: %v", ui.resource, ui.pid, err)
+		return t.createErrorResponse(ui.reqId, err), nil
+	}
+
+	cntr := ui.cntr
+	cntr.Lock()
+	cntr.SetData("/proc/sys/kernel/"+ui.resource, ui.resource, name)
+	cntr.Unlock()
+
+	return t.createSuccessResponse(ui.reqId), nil
+}
+
+// Sets the given name (hostname or domainname) within the UTS namespace at the
+// given path, out of an OS thread temporarily moved into it.
+func setUtsNameAt(nsPath, resource string, name []byte) error {
+
+	runtime.LockOSThread()
+
+	origNs, err := os.Open("/proc/thread-self/ns/uts")
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer origNs.Close()
+
+	targetNs, err := os.Open(nsPath)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	defer targetNs.Close()
+
+	if err := unix.Setns(int(targetNs.Fd()), unix.CLONE_NEWUTS); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+
+	if resource == "hostname" {
+		err = unix.Sethostname(name)
+	} else {
+		err = unix.Setdomainname(name)
+	}
+
+	// Leave the thread locked (i.e. to be terminated along with this goroutine)
+	// should it not make it back to its original UTS namespace.
+	if rerr := unix.Setns(int(origNs.Fd()), unix.CLONE_NEWUTS); rerr != nil {
+		logrus.Errorf("Unable to restore the UTS namespace of thread %d: %v",
+			unix.Gettid(), rerr)
+	} else {
+		runtime.UnlockOSThread()
+	}
+
+	return err
+}
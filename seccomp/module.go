@@ -0,0 +1,183 @@
+//
+// Copyright 2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file contains Sysbox's init_module and finit_module syscall trapping &
+// handling code. Sys containers can't load kernel modules, yet lots of startup
+// scripts (e.g. those of docker, kubelet or iptables) modprobe the modules they
+// depend on and bail out upon failures. Sysbox thereby answers module loads
+// according to the following policy:
+//
+// * Loads from processes lacking cap_sys_module, or of modules with invalid
+//   names, fail with EPERM.
+//
+// * Modules in the EmulatedModules list (i.e. known to be irrelevant or
+//   already taken care of by the host) are reported as loaded.
+//
+// * Modules already loaded on the host are reported as such (EEXIST), which is
+//   what modprobe expects for modules loaded in the meantime.
+//
+// * Any other module load fails with EPERM.
+//
+// The host's modules are never altered.
+
+package seccomp
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	cap "github.com/nestybox/sysbox-libs/capability"
+	"github.com/sirupsen/logrus"
+)
+
+// Modules whose loads are reported as successful inside sys containers.
+var EmulatedModules = []string{
+	"br_netfilter",
+	"bridge",
+	"ip_tables",
+	"ip6_tables",
+	"iptable_filter",
+	"iptable_nat",
+	"nf_conntrack",
+	"nf_nat",
+	"overlay",
+	"veth",
+	"xt_conntrack",
+}
+
+// Valid module names; anything else (e.g. names carrying path elements) is
+// denied before being looked up on the host.
+var moduleNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Max size of the module images passed to init_module(), and of the modinfo
+// sections read out of them to find out their names.
+const (
+	maxModuleImageSize   = 64 << 20
+	maxModuleModinfoSize = 1 << 20
+)
+
+type moduleSyscallInfo struct {
+	syscallCtx // syscall generic info
+	name       string
+}
+
+// Obtains the name of the module whose (uncompressed) image is passed to
+// init_module(), out of its modinfo section. Only the ELF headers and this
+// section are read out of the image.
+func moduleNameFromImage(image io.ReaderAt) (name string, err error) {
+
+	// The image is controlled by the tracee, so parsing errors of any kind
+	// (debug/elf panics on some malformed inputs) must not take sysbox-fs down.
+	defer func() {
+		if r := recover(); r != nil {
+			name, err = "", fmt.Errorf("malformed module image: %v", r)
+		}
+	}()
+
+	f, err := elf.NewFile(image)
+	if err != nil {
+		return "", err
+	}
+
+	sect := f.Section(".modinfo")
+	if sect == nil {
+		return "", fmt.Errorf("no modinfo section")
+	}
+	if sect.Size > maxModuleModinfoSize {
+		return "", fmt.Errorf("modinfo section too large (%d bytes)", sect.Size)
+	}
+
+	modinfo, err := sect.Data()
+	if err != nil {
+		return "", err
+	}
+
+	return moduleNameFromModinfo(modinfo), nil
+}
+
+// Obtains the module name out of the "name=" entry of the given modinfo
+// section, made of null-separated "key=value" entries.
+func moduleNameFromModinfo(modinfo []byte) string {
+
+	for _, entry := range bytes.Split(modinfo, []byte{0}) {
+		if bytes.HasPrefix(entry, []byte("name=")) {
+			return string(entry[len("name="):])
+		}
+	}
+
+	return ""
+}
+
+// Obtains the name of the module whose file is passed to finit_module().
+func moduleNameFromPath(path string) string {
+
+	name := filepath.Base(path)
+
+	for _, ext := range []string{".xz", ".gz", ".zst"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	return strings.TrimSuffix(name, ".ko")
+}
+
+// As per init_module(2), cap_sys_module capability is required. Checked prior to
+// accessing any of the syscall arguments (e.g. the module image).
+func (mi *moduleSyscallInfo) capable() bool {
+
+	if mi.processInfo == nil {
+		mi.processInfo = mi.tracer.service.prs.ProcessCreate(mi.pid, 0, 0)
+	}
+
+	return mi.processInfo.IsCapabilitySet(cap.EFFECTIVE, cap.CAP_SYS_MODULE)
+}
+
+func (mi *moduleSyscallInfo) processModuleLoad() (*sysResponse, error) {
+
+	t := mi.tracer
+
+	if !moduleNameRegexp.MatchString(mi.name) {
+		logrus.Debugf("Denying load of module %q from pid %d: invalid name",
+			mi.name, mi.pid)
+		return t.createErrorResponse(mi.reqId, syscall.EPERM), nil
+	}
+
+	// Module names are interchangeably written with dashes or underscores.
+	name := strings.Replace(mi.name, "-", "_", -1)
+
+	for _, m := range EmulatedModules {
+		if name == strings.Replace(m, "-", "_", -1) {
+			logrus.Debugf("Emulating load of module %s from pid %d", name, mi.pid)
+			return t.createSuccessResponse(mi.reqId), nil
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join("/sys/module", name)); err == nil {
+		logrus.Debugf("Module %s loaded by pid %d is present on the host",
+			name, mi.pid)
+		return t.createErrorResponse(mi.reqId, syscall.EEXIST), nil
+	}
+
+	logrus.Debugf("Denying load of module %q from pid %d", name, mi.pid)
+
+	return t.createErrorResponse(mi.reqId, syscall.EPERM), nil
+}
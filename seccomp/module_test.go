@@ -0,0 +1,99 @@
+//
+// Copyright 2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package seccomp
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+)
+
+// Builds a minimal (relocatable) ELF image carrying the given modinfo section.
+func buildModuleImage(modinfo []byte) []byte {
+
+	shstrtab := []byte("\x00.modinfo\x00.shstrtab\x00")
+
+	modinfoOff := uint64(binary.Size(elf.Header64{}))
+	shstrtabOff := modinfoOff + uint64(len(modinfo))
+	shOff := shstrtabOff + uint64(len(shstrtab))
+
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     shOff,
+		Ehsize:    uint16(binary.Size(elf.Header64{})),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     3,
+		Shstrndx:  2,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	sections := []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Off: modinfoOff, Size: uint64(len(modinfo))},
+		{Name: 10, Type: uint32(elf.SHT_STRTAB), Off: shstrtabOff, Size: uint64(len(shstrtab))},
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, hdr)
+	buf.Write(modinfo)
+	buf.Write(shstrtab)
+	binary.Write(&buf, binary.LittleEndian, sections)
+
+	return buf.Bytes()
+}
+
+func Test_moduleNameFromImage(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		image   []byte
+		want    string
+		wantErr bool
+	}{
+		// Name entry in the middle of the modinfo section.
+		{"1", buildModuleImage([]byte("license=GPL\x00name=br_netfilter\x00vermagic=6.1\x00")),
+			"br_netfilter", false},
+
+		// Name entry leading the modinfo section.
+		{"2", buildModuleImage([]byte("name=overlay\x00license=GPL\x00")), "overlay", false},
+
+		// Modinfo section lacking the name entry.
+		{"3", buildModuleImage([]byte("license=GPL\x00")), "", false},
+
+		// Non-ELF images.
+		{"4", []byte("garbage"), "", true},
+		{"5", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := moduleNameFromImage(bytes.NewReader(tt.image))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("moduleNameFromImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("moduleNameFromImage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
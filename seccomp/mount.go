@@ -383,7 +383,17 @@ func (m *mountSyscallInfo) createSysPayload(
 	mh := m.tracer.service.mts.MountHelper()
 
 	// Sysbox-fs "/sys" bind-mounts.
-	sysBindMounts := mh.SysMounts()
+	var sysBindMounts []string
+	for _, v := range mh.SysMounts() {
+		// Skip the nodes not present in the host's sysfs (e.g. parameters of
+		// modules not loaded), as their bind-mounts would fail the whole
+		// mount.
+		if !domain.FileExists(v) {
+			continue
+		}
+		sysBindMounts = append(sysBindMounts, v)
+	}
+
 	for _, v := range sysBindMounts {
 		relPath := strings.TrimPrefix(v, "/sys")
 
@@ -0,0 +1,80 @@
+//
+// Copyright 2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file contains Sysbox's reboot syscall trapping & handling code. Inside
+// a sys container, reboot(2) must stop or restart the container rather than
+// the host. As per reboot(2), the kernel does exactly that for callers within a
+// non-initial pid namespace: the init process of the caller's pid namespace is
+// killed, and its exit status reflects the requested action (SIGHUP for
+// restarts, SIGINT for halts / power-offs), which is what container managers
+// (and systemd running as the container's init) rely on. Sysbox thereby lets
+// the kernel handle these commands. The Ctrl-Alt-Del commands, which init
+// systems toggle during boot and the kernel rejects within pid namespaces, are
+// accepted as no-ops. All other commands (e.g. kexec, suspend) are denied.
+
+package seccomp
+
+import (
+	"syscall"
+
+	cap "github.com/nestybox/sysbox-libs/capability"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+type rebootSyscallInfo struct {
+	syscallCtx // syscall generic info
+	magic1     uint32
+	magic2     uint32
+	cmd        uint32
+}
+
+func (ri *rebootSyscallInfo) processReboot() (*sysResponse, error) {
+
+	t := ri.tracer
+
+	if ri.magic1 != unix.LINUX_REBOOT_MAGIC1 ||
+		(ri.magic2 != unix.LINUX_REBOOT_MAGIC2 &&
+			ri.magic2 != unix.LINUX_REBOOT_MAGIC2A &&
+			ri.magic2 != unix.LINUX_REBOOT_MAGIC2B &&
+			ri.magic2 != unix.LINUX_REBOOT_MAGIC2C) {
+		return t.createErrorResponse(ri.reqId, syscall.EINVAL), nil
+	}
+
+	ri.processInfo = t.service.prs.ProcessCreate(ri.pid, 0, 0)
+
+	// As per reboot(2), cap_sys_boot capability is required.
+	if !ri.processInfo.IsCapabilitySet(cap.EFFECTIVE, cap.CAP_SYS_BOOT) {
+		return t.createErrorResponse(ri.reqId, syscall.EPERM), nil
+	}
+
+	switch ri.cmd {
+	case unix.LINUX_REBOOT_CMD_RESTART,
+		unix.LINUX_REBOOT_CMD_RESTART2,
+		unix.LINUX_REBOOT_CMD_HALT,
+		unix.LINUX_REBOOT_CMD_POWER_OFF:
+		logrus.Infof("Reboot syscall (cmd %#x) from pid %d: terminating its pid-ns init",
+			ri.cmd, ri.pid)
+		return t.createContinueResponse(ri.reqId), nil
+
+	case unix.LINUX_REBOOT_CMD_CAD_ON,
+		unix.LINUX_REBOOT_CMD_CAD_OFF:
+		logrus.Debugf("Ignoring reboot syscall (cmd %#x) from pid %d", ri.cmd, ri.pid)
+		return t.createSuccessResponse(ri.reqId), nil
+	}
+
+	return t.createErrorResponse(ri.reqId, syscall.EPERM), nil
+}
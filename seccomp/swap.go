@@ -0,0 +1,193 @@
+//
+// Copyright 2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file contains Sysbox's swapon and swapoff syscall trapping & handling
+// code. Sys containers can't alter the host's swap areas, yet some init systems
+// (and swap-management tools) expect these syscalls to succeed. Sysbox thereby
+// emulates them over a per-container swap table (see domain.SwapTable), which
+// is what the container sees through /proc/swaps. The host is never affected.
+
+package seccomp
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+type swapSyscallInfo struct {
+	syscallCtx // syscall generic info
+	path       string
+	flags      int
+}
+
+// Obtains the absolute path of the swap area as seen by the process.
+func (si *swapSyscallInfo) resolvePath() (string, error) {
+
+	path, err := si.processInfo.ResolveProcSelf(si.path)
+	if err != nil {
+		return "", syscall.EACCES
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(si.processInfo.Cwd(), path)
+	}
+
+	return filepath.Clean(path), nil
+}
+
+// Opens the swap area out of the process' root. The lookup is confined to
+// that root (openat2(RESOLVE_IN_ROOT)), so that symlinks within the container
+// can't lead to any host file.
+func (si *swapSyscallInfo) openInRoot(path string) (*os.File, error) {
+
+	rootPath := filepath.Join("/proc", strconv.Itoa(int(si.pid)), "root")
+
+	rootFd, err := unix.Open(rootPath, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(rootFd)
+
+	fd, err := unix.Openat2(rootFd, path, &unix.OpenHow{
+		Flags:   unix.O_RDONLY | unix.O_CLOEXEC | unix.O_NOCTTY,
+		Resolve: unix.RESOLVE_IN_ROOT | unix.RESOLVE_NO_MAGICLINKS,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// Returns true if the given swap area carries a swap signature (as written by
+// mkswap), which is placed at the end of its first page.
+func hasSwapSignature(f *os.File, pageSize int64) bool {
+
+	sig := make([]byte, 10)
+
+	if _, err := f.ReadAt(sig, pageSize-int64(len(sig))); err != nil {
+		return false
+	}
+
+	return string(sig) == "SWAPSPACE2" || string(sig) == "SWAP-SPACE"
+}
+
+func (si *swapSyscallInfo) processSwapon() (*sysResponse, error) {
+
+	t := si.tracer
+	si.processInfo = t.service.prs.ProcessCreate(si.pid, 0, 0)
+
+	// As per swapon(2), cap_sys_admin capability is required.
+	if !si.processInfo.IsSysAdminCapabilitySet() {
+		return t.createErrorResponse(si.reqId, syscall.EPERM), nil
+	}
+
+	path, err := si.resolvePath()
+	if err != nil {
+		return t.createErrorResponse(si.reqId, err), nil
+	}
+
+	if err := si.processInfo.PathAccess(path, 0); err != nil {
+		return t.createErrorResponse(si.reqId, err), nil
+	}
+
+	f, err := si.openInRoot(path)
+	if err != nil {
+		if err == syscall.ENOENT {
+			return t.createErrorResponse(si.reqId, syscall.ENOENT), nil
+		}
+		return t.createErrorResponse(si.reqId, syscall.EACCES), nil
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return t.createErrorResponse(si.reqId, syscall.ENOENT), nil
+	}
+
+	var (
+		kind string
+		size int64
+	)
+
+	switch {
+	case fi.Mode().IsRegular():
+		kind = "file"
+		size = fi.Size()
+
+	case fi.Mode()&os.ModeDevice != 0 && fi.Mode()&os.ModeCharDevice == 0:
+		// Block devices report their size through seeks.
+		kind = "partition"
+		size, err = f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return t.createErrorResponse(si.reqId, syscall.EINVAL), nil
+		}
+
+	default:
+		return t.createErrorResponse(si.reqId, syscall.EINVAL), nil
+	}
+
+	// The first page holds the swap header, which isn't usable.
+	pageSize := int64(os.Getpagesize())
+	if size < 2*pageSize {
+		return t.createErrorResponse(si.reqId, syscall.EINVAL), nil
+	}
+	if !hasSwapSignature(f, pageSize) {
+		return t.createErrorResponse(si.reqId, syscall.EINVAL), nil
+	}
+	sizeKiB := uint64((size/pageSize - 1) * pageSize / 1024)
+
+	err = si.cntr.SwapTable().Swapon(path, kind, sizeKiB, si.flags)
+	if err != nil {
+		return t.createErrorResponse(si.reqId, err), nil
+	}
+
+	logrus.Debugf("Emulated swapon syscall from pid %d: path = %v, flags = %#x",
+		si.pid, path, si.flags)
+
+	return t.createSuccessResponse(si.reqId), nil
+}
+
+func (si *swapSyscallInfo) processSwapoff() (*sysResponse, error) {
+
+	t := si.tracer
+	si.processInfo = t.service.prs.ProcessCreate(si.pid, 0, 0)
+
+	// As per swapoff(2), cap_sys_admin capability is required.
+	if !si.processInfo.IsSysAdminCapabilitySet() {
+		return t.createErrorResponse(si.reqId, syscall.EPERM), nil
+	}
+
+	path, err := si.resolvePath()
+	if err != nil {
+		return t.createErrorResponse(si.reqId, err), nil
+	}
+
+	err = si.cntr.SwapTable().Swapoff(path)
+	if err != nil {
+		return t.createErrorResponse(si.reqId, err), nil
+	}
+
+	logrus.Debugf("Emulated swapoff syscall from pid %d: path = %v", si.pid, path)
+
+	return t.createSuccessResponse(si.reqId), nil
+}
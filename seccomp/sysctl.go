@@ -0,0 +1,134 @@
+//
+// Copyright 2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// This file contains Sysbox's openat2 and _sysctl syscall trapping & handling
+// code. Accesses to /proc/sys inside a sys container are served by sysbox-fs'
+// handlers, as /proc/sys is backed by the sysbox-fs' FUSE mount. There are two
+// ways for processes to reach the kernel's sysctls without going through those
+// handlers:
+//
+// * The legacy _sysctl syscall, which operates on the kernel's binary sysctl
+//   interface and thereby never touches /proc/sys. Kernels >= 5.5 dropped this
+//   syscall altogether; Sysbox returns ENOSYS for it on all kernels.
+//
+// * openat2, whose RESOLVE_* flags alter the way paths are resolved. Calls
+//   targeting /proc/sys are resolved by the kernel through the sys container's
+//   mounts (as openat ones are), and thereby reach the sysbox-fs' handlers;
+//   these are left to the kernel. The exception is RESOLVE_NO_XDEV, which
+//   refuses to cross the sysbox-fs' mountpoints and which sysbox-fs can't
+//   emulate, as the resulting fd would need to be injected into the process.
+//   Sysbox returns EXDEV for these, as the kernel does for any mount crossing.
+//   Notice that ENOSYS is never returned, as libc and language runtimes take it
+//   as openat2 not being supported at all, and stop using it process-wide.
+
+package seccomp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const procSysDir = "/proc/sys"
+
+// Size of the (first version of the) openat2's open_how struct, made of the
+// flags, mode and resolve fields.
+const openHowSizeVer0 = 24
+
+type openat2SyscallInfo struct {
+	syscallCtx // syscall generic info
+	path       string
+	dirFd      int32
+	how        uint64 // open_how struct address
+	howSize    uint64
+}
+
+// Returns the resolve flags of the open_how struct passed to openat2.
+func (oi *openat2SyscallInfo) resolveFlags() (uint64, error) {
+
+	if oi.howSize < openHowSizeVer0 {
+		return 0, fmt.Errorf("invalid open_how size %d", oi.howSize)
+	}
+
+	data, err := oi.tracer.processMemRead(oi.pid, oi.how, openHowSizeVer0)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(data[16:24]), nil
+}
+
+func (oi *openat2SyscallInfo) targetsProcSys(absPath string) bool {
+
+	// Note: as for chown, symlinks leading to /proc/sys aren't resolved, to
+	// avoid slowing down every openat2 syscall.
+
+	absPath = filepath.Clean(absPath)
+
+	return absPath == procSysDir || strings.HasPrefix(absPath, procSysDir+"/")
+}
+
+func (oi *openat2SyscallInfo) processOpenat2() (*sysResponse, error) {
+	var err error
+
+	t := oi.tracer
+	oi.processInfo = t.service.prs.ProcessCreate(oi.pid, 0, 0)
+	path := oi.path
+
+	// Per openat2(2), relative paths are interpreted relative to dirFd, or to
+	// the process' current working dir when dirFd is AT_FDCWD.
+	if !filepath.IsAbs(path) {
+		if oi.dirFd == unix.AT_FDCWD {
+			path = filepath.Join(oi.processInfo.Cwd(), path)
+		} else {
+			dirPath, err := oi.processInfo.GetFd(oi.dirFd)
+			if err != nil {
+				return t.createContinueResponse(oi.reqId), nil
+			}
+			path = filepath.Join(dirPath, path)
+		}
+	}
+
+	// Paths that can't be resolved are left to the kernel, which reports the
+	// appropriate error (if any).
+	path, err = oi.processInfo.ResolveProcSelf(path)
+	if err != nil {
+		return t.createContinueResponse(oi.reqId), nil
+	}
+
+	if !oi.targetsProcSys(path) {
+		return t.createContinueResponse(oi.reqId), nil
+	}
+
+	// Invalid open_how structs are left to the kernel too.
+	resolve, err := oi.resolveFlags()
+	if err != nil {
+		return t.createContinueResponse(oi.reqId), nil
+	}
+
+	if resolve&unix.RESOLVE_NO_XDEV != 0 {
+		logrus.Debugf("Denying openat2 syscall from pid %d crossing sysbox-fs mounts: path = %v",
+			oi.pid, path)
+		return t.createErrorResponse(oi.reqId, syscall.EXDEV), nil
+	}
+
+	return t.createContinueResponse(oi.reqId), nil
+}
@@ -19,6 +19,8 @@ package seccomp
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
@@ -45,6 +47,7 @@ type sysResponse = libseccomp.ScmpNotifResp
 // Slice of supported syscalls to monitor.
 var monitoredSyscalls = []string{
 	"mount",
+	"fsopen",
 	"umount2",
 	"reboot",
 	"swapon",
@@ -52,8 +55,31 @@ var monitoredSyscalls = []string{
 	"chown",
 	"fchown",
 	"fchownat",
+	"openat2",
+	"_sysctl",
+	"sethostname",
+	"setdomainname",
+	"init_module",
+	"finit_module",
 }
 
+// Monitored syscalls that sysbox-runc's seccomp-notify filter only traps as of
+// the versions adding them to it. Their handling must be enabled along with
+// such a sysbox-runc (see ExtraSyscallTraps); otherwise these are handed back
+// to the kernel untouched.
+var extraMonitoredSyscalls = map[string]bool{
+	"fsopen":        true,
+	"openat2":       true,
+	"_sysctl":       true,
+	"sethostname":   true,
+	"setdomainname": true,
+	"init_module":   true,
+	"finit_module":  true,
+}
+
+// Enables the handling of the extraMonitoredSyscalls.
+var ExtraSyscallTraps bool = false
+
 // Seccomp's syscall-monitoring/trapping service struct. External packages
 // will solely rely on this struct for their syscall-monitoring demands.
 type SyscallMonitorService struct {
@@ -434,10 +460,17 @@ func (t *syscallTracer) process(
 	syscallId := req.Data.Syscall
 	syscallStr := t.syscalls[syscallId]
 
+	if extraMonitoredSyscalls[syscallStr] && !ExtraSyscallTraps {
+		return t.createContinueResponse(req.Id)
+	}
+
 	switch syscallStr {
 	case "mount":
 		resp, err = t.processMount(req, fd, cntr)
 
+	case "fsopen":
+		resp, err = t.processFsopen(req, fd, cntr)
+
 	case "umount2":
 		resp, err = t.processUmount(req, fd, cntr)
 
@@ -459,6 +492,24 @@ func (t *syscallTracer) process(
 	case "fchownat":
 		resp, err = t.processFchownat(req, fd, cntr)
 
+	case "openat2":
+		resp, err = t.processOpenat2(req, fd, cntr)
+
+	case "_sysctl":
+		resp, err = t.processSysctl(req, fd, cntr)
+
+	case "sethostname":
+		resp, err = t.processSetUtsName(req, fd, cntr, "hostname")
+
+	case "setdomainname":
+		resp, err = t.processSetUtsName(req, fd, cntr, "domainname")
+
+	case "init_module":
+		resp, err = t.processInitModule(req, fd, cntr)
+
+	case "finit_module":
+		resp, err = t.processFinitModule(req, fd, cntr)
+
 	default:
 		logrus.Warnf("Unsupported syscall notification received (%v) on fd %d pid %d cntr %s",
 			syscallId, fd, req.Pid, formatter.ContainerID{cntrID})
@@ -566,6 +617,36 @@ func (t *syscallTracer) processMount(
 	return mount.process()
 }
 
+func (t *syscallTracer) processFsopen(
+	req *sysRequest,
+	fd int32,
+	cntr domain.ContainerIface) (*sysResponse, error) {
+
+	// We trap fsopen() as procfs / sysfs mounts created through the new mount
+	// API (fsopen / fsmount / move_mount) would otherwise lack the sysbox-fs'
+	// submounts (see processProcMount() and processSysMount()). These are
+	// reported as unsupported, which makes mount tools (e.g. libmount) fall
+	// back to mount(2). All other filesystems are handled by the kernel.
+
+	argPtrs := []uint64{req.Data.Args[0]}
+	args, err := t.processMemParse(req.Pid, argPtrs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 1 {
+		return t.createErrorResponse(req.Id, syscall.EINVAL), nil
+	}
+
+	if args[0] == "proc" || args[0] == "sysfs" {
+		logrus.Debugf("Redirecting fsopen syscall from pid %d to mount: fstype = %v",
+			req.Pid, args[0])
+		return t.createErrorResponse(req.Id, syscall.ENOSYS), nil
+	}
+
+	return t.createContinueResponse(req.Id), nil
+}
+
 func (t *syscallTracer) processUmount(
 	req *sysRequest,
 	fd int32,
@@ -630,6 +711,11 @@ func (t *syscallTracer) processUmount(
 		umount.Target = filepath.Join(umount.cwd, umount.Target)
 	}
 
+	// Mountpoints are matched by their exact path, so non-canonical forms of
+	// the target (e.g. "/proc/sys/", "//proc/./sys") must not escape the
+	// handling of the sysbox-fs managed mounts.
+	umount.Target = filepath.Clean(umount.Target)
+
 	// Process umount syscall.
 	return umount.process()
 }
@@ -743,11 +829,24 @@ func (t *syscallTracer) processFchownat(
 func (t *syscallTracer) processReboot(
 	req *sysRequest,
 	fd int32,
-	cntrID domain.ContainerIface) (*sysResponse, error) {
+	cntr domain.ContainerIface) (*sysResponse, error) {
 
-	logrus.Warnf("Received reboot syscall")
+	logrus.Debugf("Received reboot syscall from pid %d", req.Pid)
 
-	return t.createSuccessResponse(req.Id), nil
+	reboot := &rebootSyscallInfo{
+		syscallCtx: syscallCtx{
+			syscallNum: int32(req.Data.Syscall),
+			reqId:      req.Id,
+			pid:        req.Pid,
+			cntr:       cntr,
+			tracer:     t,
+		},
+		magic1: uint32(req.Data.Args[0]),
+		magic2: uint32(req.Data.Args[1]),
+		cmd:    uint32(req.Data.Args[2]),
+	}
+
+	return reboot.processReboot()
 }
 
 func (t *syscallTracer) processSwapon(
@@ -755,9 +854,31 @@ func (t *syscallTracer) processSwapon(
 	fd int32,
 	cntr domain.ContainerIface) (*sysResponse, error) {
 
-	logrus.Warnf("Received swapon syscall")
+	logrus.Debugf("Received swapon syscall from pid %d", req.Pid)
+
+	argPtrs := []uint64{req.Data.Args[0]}
+	args, err := t.processMemParse(req.Pid, argPtrs)
+	if err != nil {
+		return nil, err
+	}
 
-	return t.createSuccessResponse(req.Id), nil
+	if len(args) < 1 {
+		return t.createErrorResponse(req.Id, syscall.EINVAL), nil
+	}
+
+	swap := &swapSyscallInfo{
+		syscallCtx: syscallCtx{
+			syscallNum: int32(req.Data.Syscall),
+			reqId:      req.Id,
+			pid:        req.Pid,
+			cntr:       cntr,
+			tracer:     t,
+		},
+		path:  args[0],
+		flags: int(req.Data.Args[1]),
+	}
+
+	return swap.processSwapon()
 }
 
 func (t *syscallTracer) processSwapoff(
@@ -765,9 +886,187 @@ func (t *syscallTracer) processSwapoff(
 	fd int32,
 	cntr domain.ContainerIface) (*sysResponse, error) {
 
-	logrus.Warnf("Received swapoff syscall")
+	logrus.Debugf("Received swapoff syscall from pid %d", req.Pid)
+
+	argPtrs := []uint64{req.Data.Args[0]}
+	args, err := t.processMemParse(req.Pid, argPtrs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 1 {
+		return t.createErrorResponse(req.Id, syscall.EINVAL), nil
+	}
+
+	swap := &swapSyscallInfo{
+		syscallCtx: syscallCtx{
+			syscallNum: int32(req.Data.Syscall),
+			reqId:      req.Id,
+			pid:        req.Pid,
+			cntr:       cntr,
+			tracer:     t,
+		},
+		path: args[0],
+	}
+
+	return swap.processSwapoff()
+}
+
+func (t *syscallTracer) processOpenat2(
+	req *sysRequest,
+	fd int32,
+	cntr domain.ContainerIface) (*sysResponse, error) {
+
+	// We trap openat2() to prevent it from bypassing the sysbox-fs' handlers of
+	// /proc/sys (see sysctl.go).
+
+	// Get the path argument
+	argPtrs := []uint64{req.Data.Args[1]}
+	args, err := t.processMemParse(req.Pid, argPtrs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 1 {
+		return t.createErrorResponse(req.Id, syscall.EINVAL), nil
+	}
+
+	openat2 := &openat2SyscallInfo{
+		syscallCtx: syscallCtx{
+			syscallNum: int32(req.Data.Syscall),
+			reqId:      req.Id,
+			pid:        req.Pid,
+			cntr:       cntr,
+			tracer:     t,
+		},
+		path:    args[0],
+		dirFd:   int32(req.Data.Args[0]),
+		how:     req.Data.Args[2],
+		howSize: req.Data.Args[3],
+	}
+
+	return openat2.processOpenat2()
+}
+
+func (t *syscallTracer) processSysctl(
+	req *sysRequest,
+	fd int32,
+	cntr domain.ContainerIface) (*sysResponse, error) {
+
+	// The binary sysctl interface would let processes reach the kernel's
+	// sysctls without going through the sysbox-fs' handlers of /proc/sys (see
+	// sysctl.go); report it as unsupported, as kernels >= 5.5 do.
 
-	return t.createSuccessResponse(req.Id), nil
+	logrus.Debugf("Received _sysctl syscall from pid %d", req.Pid)
+
+	return t.createErrorResponse(req.Id, syscall.ENOSYS), nil
+}
+
+func (t *syscallTracer) processSetUtsName(
+	req *sysRequest,
+	fd int32,
+	cntr domain.ContainerIface,
+	resource string) (*sysResponse, error) {
+
+	// We trap sethostname() and setdomainname() to keep the sysbox-fs' view of
+	// these names in sync with the UTS namespace (see hostname.go).
+
+	utsName := &utsNameSyscallInfo{
+		syscallCtx: syscallCtx{
+			syscallNum: int32(req.Data.Syscall),
+			reqId:      req.Id,
+			pid:        req.Pid,
+			cntr:       cntr,
+			tracer:     t,
+		},
+		resource: resource,
+		addr:     req.Data.Args[0],
+		size:     req.Data.Args[1],
+	}
+
+	return utsName.processSetUtsName()
+}
+
+func (t *syscallTracer) processInitModule(
+	req *sysRequest,
+	fd int32,
+	cntr domain.ContainerIface) (*sysResponse, error) {
+
+	// We trap init_module() and finit_module() to answer module loads as per
+	// sysbox's emulation policy (see module.go).
+
+	logrus.Debugf("Received init_module syscall from pid %d", req.Pid)
+
+	module := &moduleSyscallInfo{
+		syscallCtx: syscallCtx{
+			syscallNum: int32(req.Data.Syscall),
+			reqId:      req.Id,
+			pid:        req.Pid,
+			cntr:       cntr,
+			tracer:     t,
+		},
+	}
+
+	if !module.capable() {
+		return t.createErrorResponse(req.Id, syscall.EPERM), nil
+	}
+
+	addr, size := req.Data.Args[0], req.Data.Args[1]
+	if size == 0 {
+		return t.createErrorResponse(req.Id, syscall.EINVAL), nil
+	}
+	if size > maxModuleImageSize {
+		return t.createErrorResponse(req.Id, syscall.EFBIG), nil
+	}
+	if addr > math.MaxInt64-size {
+		return t.createErrorResponse(req.Id, syscall.EFAULT), nil
+	}
+
+	name := fmt.Sprintf("/proc/%d/mem", req.Pid)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %s", name, err)
+	}
+	defer f.Close()
+
+	// Invalid images are left for the module's name validation to deny.
+	module.name, err = moduleNameFromImage(io.NewSectionReader(f, int64(addr), int64(size)))
+	if err != nil {
+		logrus.Debugf("Unable to parse module image loaded by pid %d: %v", req.Pid, err)
+	}
+
+	return module.processModuleLoad()
+}
+
+func (t *syscallTracer) processFinitModule(
+	req *sysRequest,
+	fd int32,
+	cntr domain.ContainerIface) (*sysResponse, error) {
+
+	logrus.Debugf("Received finit_module syscall from pid %d", req.Pid)
+
+	module := &moduleSyscallInfo{
+		syscallCtx: syscallCtx{
+			syscallNum: int32(req.Data.Syscall),
+			reqId:      req.Id,
+			pid:        req.Pid,
+			cntr:       cntr,
+			tracer:     t,
+		},
+	}
+
+	if !module.capable() {
+		return t.createErrorResponse(req.Id, syscall.EPERM), nil
+	}
+
+	path, err := module.processInfo.GetFd(int32(req.Data.Args[0]))
+	if err != nil {
+		return t.createErrorResponse(req.Id, syscall.EBADF), nil
+	}
+
+	module.name = moduleNameFromPath(path)
+
+	return module.processModuleLoad()
 }
 
 // processMemParser iterates through the tracee process' /proc/pid/mem file to
@@ -811,6 +1110,31 @@ func (t *syscallTracer) processMemParse(pid uint32, argPtrs []uint64) ([]string,
 	return result, nil
 }
 
+// processMemRead reads 'size' bytes at the given address of the tracee process'
+// memory (see processMemParse()). Used for non-string (or non null-terminated)
+// syscall arguments.
+func (t *syscallTracer) processMemRead(pid uint32, addr uint64, size int) ([]byte, error) {
+
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+
+	name := fmt.Sprintf("/proc/%d/mem", pid)
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %s", name, err)
+	}
+	defer f.Close()
+
+	data := make([]byte, size)
+
+	if _, err := f.ReadAt(data, int64(addr)); err != nil {
+		return nil, fmt.Errorf("read of %s at offset %d failed: %s", name, addr, err)
+	}
+
+	return data, nil
+}
+
 func (t *syscallTracer) createSuccessResponse(id uint64) *sysResponse {
 
 	resp := &sysResponse{
@@ -49,6 +49,10 @@ type container struct {
 	extLock         sync.Mutex                  // external lock (exposed via Lock() and Unlock() methods)
 	usernsInode     domain.Inode                // inode associated with the container's user namespace
 	netnsInode      domain.Inode                // inode associated with the container's network namespace
+	overrides       []domain.HandlerOverride    // per-container handler overrides
+	kernelLog       *domain.KernelLog           // container-scoped kernel log
+	swapTable       *domain.SwapTable           // container-scoped swap table
+	nsWatcher       *nsWatcher                  // netns & cgroups change watcher
 }
 
 func newContainer(
@@ -75,6 +79,8 @@ func newContainer(
 		procRoPaths:   procRoPaths,
 		procMaskPaths: procMaskPaths,
 		service:       css,
+		kernelLog:     domain.NewKernelLog(domain.KernelLogSize),
+		swapTable:     domain.NewSwapTable(),
 	}
 
 	return cntr
@@ -148,6 +154,33 @@ func (c *container) Data(path string, name string) (string, bool) {
 	return c.dataStore[path][name], true
 }
 
+func (c *container) HandlerOverride(path string) (*domain.HandlerOverride, bool) {
+	c.intLock.RLock()
+	defer c.intLock.RUnlock()
+
+	for i := range c.overrides {
+		if c.overrides[i].Path == path {
+			return &c.overrides[i], true
+		}
+	}
+
+	return nil, false
+}
+
+func (c *container) KernelLog() *domain.KernelLog {
+	c.intLock.RLock()
+	defer c.intLock.RUnlock()
+
+	return c.kernelLog
+}
+
+func (c *container) SwapTable() *domain.SwapTable {
+	c.intLock.RLock()
+	defer c.intLock.RUnlock()
+
+	return c.swapTable
+}
+
 func (c *container) InitProc() domain.ProcessIface {
 	c.intLock.RLock()
 	defer c.intLock.RUnlock()
@@ -307,6 +340,12 @@ func (c *container) update(src *container) error {
 	c.procMaskPaths = make([]string, len(src.procMaskPaths))
 	copy(c.procMaskPaths, src.procMaskPaths)
 
+	// Overrides are only defined at registration time; updates lacking them
+	// must not wipe out the existing ones.
+	if src.overrides != nil {
+		c.overrides = src.overrides
+	}
+
 	return nil
 }
 
@@ -342,6 +381,13 @@ func (c *container) SetData(path string, name string, data string) {
 	c.dataStore[path][name] = data
 }
 
+func (c *container) SetHandlerOverrides(overrides []domain.HandlerOverride) {
+	c.intLock.Lock()
+	defer c.intLock.Unlock()
+
+	c.overrides = overrides
+}
+
 func (c *container) Lock() {
 	c.extLock.Lock()
 }
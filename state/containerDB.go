@@ -17,6 +17,7 @@
 package state
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -29,6 +30,33 @@ import (
 	"github.com/nestybox/sysbox-libs/formatter"
 )
 
+// Maximum number of fuse-servers being concurrently created by container
+// pre-registrations. Zero (default) stands for no limit.
+var MaxConcurrentPreRegistrations int = 0
+
+// Number of attempts to create the fuse-server of a container being
+// pre-registered, and delay between them.
+var PreRegistrationAttempts int = 3
+var PreRegistrationRetryDelay = 100 * time.Millisecond
+
+// Maximum time a container pre-registration waits for a fuse-server creation
+// slot (see MaxConcurrentPreRegistrations) before giving up.
+var PreRegistrationSlotTimeout = 10 * time.Second
+
+// Operator-defined settings of the sys containers, applied as they register
+// (see domain.ContainerAttrRule).
+var ContainerAttrRules []domain.ContainerAttrRule
+
+var errNoPreRegistrationSlot = errors.New("no fuse-server creation slot available")
+
+// In-flight container pre-registration. Concurrent pre-registrations of the
+// same container (e.g. retried by sysbox-runc upon timeout) wait for, and
+// obtain the outcome of, the one in progress.
+type preRegistration struct {
+	done chan struct{}
+	err  error
+}
+
 type containerStateService struct {
 	sync.RWMutex
 
@@ -42,6 +70,9 @@ type containerStateService struct {
 	// Pointer to the fuse-server service engine.
 	fss domain.FuseServerServiceIface
 
+	// Pointer to the service providing handler-related capabilities.
+	hds domain.HandlerServiceIface
+
 	// Pointer to the service providing process-handling capabilities.
 	prs domain.ProcessServiceIface
 
@@ -50,6 +81,12 @@ type containerStateService struct {
 
 	// Pointer to the service providing mount helper/parser capabilities.
 	mts domain.MountServiceIface
+
+	// Pre-registrations in progress, indexed by container id.
+	preRegs map[string]*preRegistration
+
+	// Fuse-server creation slots (nil if unlimited).
+	preRegSlots chan struct{}
 }
 
 func NewContainerStateService() domain.ContainerStateServiceIface {
@@ -57,6 +94,11 @@ func NewContainerStateService() domain.ContainerStateServiceIface {
 	newCss := &containerStateService{
 		idTable:    make(map[string]*container),
 		netnsTable: make(map[domain.Inode][]*container),
+		preRegs:    make(map[string]*preRegistration),
+	}
+
+	if MaxConcurrentPreRegistrations > 0 {
+		newCss.preRegSlots = make(chan struct{}, MaxConcurrentPreRegistrations)
 	}
 
 	return newCss
@@ -64,11 +106,13 @@ func NewContainerStateService() domain.ContainerStateServiceIface {
 
 func (css *containerStateService) Setup(
 	fss domain.FuseServerServiceIface,
+	hds domain.HandlerServiceIface,
 	prs domain.ProcessServiceIface,
 	ios domain.IOServiceIface,
 	mts domain.MountServiceIface) {
 
 	css.fss = fss
+	css.hds = hds
 	css.prs = prs
 	css.ios = ios
 	css.mts = mts
@@ -109,6 +153,15 @@ func (css *containerStateService) ContainerPreRegister(id, netns string) error {
 
 	css.Lock()
 
+	// Join the pre-registration of this same container if already in progress.
+	if preReg, ok := css.preRegs[id]; ok {
+		css.Unlock()
+		logrus.Debugf("Container pre-registration already in progress: id = %s",
+			formatter.ContainerID{id})
+		<-preReg.done
+		return preReg.err
+	}
+
 	// Ensure that new container's id is not already present.
 	if _, ok := css.idTable[id]; ok {
 		css.Unlock()
@@ -172,19 +225,49 @@ func (css *containerStateService) ContainerPreRegister(id, netns string) error {
 			formatter.ContainerID{id}, cntrSameNetns)
 	}
 
-	err := css.fss.CreateFuseServer(cntr, stateCntr)
+	// The fuse-server is created outside of the service's lock, so that bursts
+	// of container creations don't serialize on each other (nor on any other
+	// container's processing).
+	if css.preRegs == nil {
+		css.preRegs = make(map[string]*preRegistration)
+	}
+	preReg := &preRegistration{done: make(chan struct{})}
+	css.preRegs[id] = preReg
+
+	css.Unlock()
+
+	err := css.createFuseServer(cntr, stateCntr)
+
+	css.Lock()
+	delete(css.preRegs, id)
+	if err != nil {
+		delete(css.idTable, id)
+		css.untrackNetns(cntr)
+	}
+	css.Unlock()
+
 	if err != nil {
-		css.Unlock()
 		logrus.Errorf("Container pre-registration error: unable to initialize fuseServer for container %s: %s",
 			formatter.ContainerID{id}, err)
-		return grpcStatus.Errorf(
-			grpcCodes.Internal,
-			"Initialization error for container-id %s",
-			id,
-		)
+		if err == errNoPreRegistrationSlot {
+			preReg.err = grpcStatus.Errorf(
+				grpcCodes.ResourceExhausted,
+				"Too many concurrent pre-registrations for container-id %s",
+				id,
+			)
+		} else {
+			preReg.err = grpcStatus.Errorf(
+				grpcCodes.Internal,
+				"Initialization error for container-id %s",
+				id,
+			)
+		}
 	}
+	close(preReg.done)
 
-	css.Unlock()
+	if err != nil {
+		return preReg.err
+	}
 
 	logrus.Infof("Container pre-registration completed: id = %s",
 		formatter.ContainerID{id})
@@ -192,6 +275,57 @@ func (css *containerStateService) ContainerPreRegister(id, netns string) error {
 	return nil
 }
 
+// Creates the fuse-server of a container being pre-registered, within the
+// configured concurrency limit, and retrying upon failure. Gives up if no
+// creation slot frees up within PreRegistrationSlotTimeout.
+func (css *containerStateService) createFuseServer(cntr, stateCntr *container) error {
+
+	if css.preRegSlots != nil {
+		timer := time.NewTimer(PreRegistrationSlotTimeout)
+		select {
+		case css.preRegSlots <- struct{}{}:
+			timer.Stop()
+		case <-timer.C:
+			return errNoPreRegistrationSlot
+		}
+		defer func() { <-css.preRegSlots }()
+	}
+
+	var err error
+
+	attempts := PreRegistrationAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			logrus.Warnf("Retrying fuseServer initialization for container %s: %s",
+				formatter.ContainerID{cntr.id}, err)
+			time.Sleep(PreRegistrationRetryDelay)
+		}
+
+		if err = css.fss.CreateFuseServer(cntr, stateCntr); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// Waits for the pre-registration of the given container to complete, if in
+// progress.
+func (css *containerStateService) waitPreRegistration(id string) {
+
+	css.RLock()
+	preReg, ok := css.preRegs[id]
+	css.RUnlock()
+
+	if ok {
+		<-preReg.done
+	}
+}
+
 func (css *containerStateService) ContainerRegister(c domain.ContainerIface) error {
 
 	cntr := c.(*container)
@@ -214,6 +348,10 @@ func (css *containerStateService) ContainerRegister(c domain.ContainerIface) err
 		)
 	}
 
+	// Obtain the handler overrides defined for the container (see
+	// ContainerAttrRules).
+	overrides := domain.ContainerHandlerOverrides(ContainerAttrRules, cntr.id)
+
 	// Update existing container with received attributes.
 	if err := currCntr.update(cntr); err != nil {
 		css.Unlock()
@@ -236,8 +374,18 @@ func (css *containerStateService) ContainerRegister(c domain.ContainerIface) err
 		return grpcStatus.Errorf(grpcCodes.NotFound, err.Error(), cntr.id)
 	}
 
+	currCntr.SetHandlerOverrides(overrides)
+
 	css.Unlock()
 
+	// Drop the container's cached content upon changes to its netns / cgroups.
+	if css.hds != nil {
+		css.watchContainer(currCntr)
+	}
+
+	currCntr.KernelLog().Append(domain.KernelLogInfo,
+		fmt.Sprintf("sysbox-fs: container %s registered", cntr.id))
+
 	logrus.Infof("Container registration completed: %v", cntr.string())
 	return nil
 }
@@ -269,6 +417,21 @@ func (css *containerStateService) ContainerUpdate(c domain.ContainerIface) error
 	currCntr.SetCtime(cntr.ctime)
 	css.Unlock()
 
+	// Cached content may not be accurate anymore. Updates also come along
+	// with cgroup changes (e.g. resources updated, cgroups moved), so the
+	// container's watcher (if any) is re-armed over its current cgroups.
+	if css.hds != nil {
+		css.hds.InvalidateReadCaches(currCntr)
+
+		currCntr.intLock.RLock()
+		watched := currCntr.nsWatcher != nil
+		currCntr.intLock.RUnlock()
+
+		if watched {
+			css.watchContainer(currCntr)
+		}
+	}
+
 	logrus.Debugf("Container update completed: id = %s",
 		formatter.ContainerID{cntr.id})
 
@@ -282,6 +445,10 @@ func (css *containerStateService) ContainerUnregister(c domain.ContainerIface) e
 	logrus.Debugf("Container unregistration started: id = %s",
 		formatter.ContainerID{cntr.id})
 
+	// Containers failing to start may be unregistered while their
+	// pre-registration is still in progress.
+	css.waitPreRegistration(cntr.id)
+
 	css.Lock()
 
 	// Ensure that container's id is already present
@@ -320,6 +487,15 @@ func (css *containerStateService) ContainerUnregister(c domain.ContainerIface) e
 	delete(css.idTable, cntr.id)
 	css.Unlock()
 
+	css.unwatchContainer(cntr)
+
+	// Revert the host values that were driven by this container (if any), and
+	// drop its cached content.
+	if css.hds != nil {
+		css.hds.ReconcileEmuResources(cntr)
+		css.hds.InvalidateReadCaches(cntr)
+	}
+
 	logrus.Infof("Container unregistration completed: id = %s",
 		formatter.ContainerID{cntr.id})
 
@@ -17,6 +17,7 @@
 package state
 
 import (
+	"errors"
 	"io/ioutil"
 	"reflect"
 	"sync"
@@ -28,6 +29,8 @@ import (
 	"github.com/nestybox/sysbox-fs/process"
 	"github.com/nestybox/sysbox-fs/sysio"
 	"github.com/sirupsen/logrus"
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
 )
 
 // Sysbox-fs global services for all state's pkg unit-tests.
@@ -65,6 +68,7 @@ func Test_containerStateService_Setup(t *testing.T) {
 		idTable    map[string]*container
 		netnsTable map[domain.Inode][]*container
 		fss        domain.FuseServerServiceIface
+		hds        domain.HandlerServiceIface
 		prs        domain.ProcessServiceIface
 		ios        domain.IOServiceIface
 		mts        domain.MountServiceIface
@@ -74,12 +78,14 @@ func Test_containerStateService_Setup(t *testing.T) {
 		idTable:    make(map[string]*container),
 		netnsTable: make(map[domain.Inode][]*container),
 		fss:        fss,
+		hds:        hds,
 		prs:        prs,
 		ios:        ios,
 	}
 
 	type args struct {
 		fss domain.FuseServerServiceIface
+		hds domain.HandlerServiceIface
 		prs domain.ProcessServiceIface
 		ios domain.IOServiceIface
 		mts domain.MountServiceIface
@@ -87,6 +93,7 @@ func Test_containerStateService_Setup(t *testing.T) {
 
 	a1 := args{
 		fss: fss,
+		hds: hds,
 		prs: prs,
 		ios: ios,
 		mts: mts,
@@ -110,11 +117,12 @@ func Test_containerStateService_Setup(t *testing.T) {
 				idTable:    tt.fields.idTable,
 				netnsTable: tt.fields.netnsTable,
 				fss:        tt.fields.fss,
+				hds:        tt.fields.hds,
 				prs:        tt.fields.prs,
 				ios:        tt.fields.ios,
 				mts:        tt.fields.mts,
 			}
-			css.Setup(tt.args.fss, tt.args.prs, tt.args.ios, tt.args.mts)
+			css.Setup(tt.args.fss, tt.args.hds, tt.args.prs, tt.args.ios, tt.args.mts)
 		})
 	}
 }
@@ -261,6 +269,16 @@ func Test_containerStateService_ContainerPreRegister(t *testing.T) {
 		service: css,
 	}
 
+	var c3 = &container{
+		id:      "c3",
+		service: css,
+	}
+
+	var c4 = &container{
+		id:      "c4",
+		service: css,
+	}
+
 	type args struct {
 		id  string
 		id2 string
@@ -302,6 +320,39 @@ func Test_containerStateService_ContainerPreRegister(t *testing.T) {
 					"CreateFuseServer", c2, c2).Return(nil)
 			},
 		},
+		{
+			//
+			// Test-case 3: Pre-register a new container whose fuse-server
+			// creation fails once. Retry expected to succeed.
+			//
+			name:    "3",
+			fields:  f1,
+			args:    args{"c3", "c3"},
+			wantErr: false,
+			prepare: func() {
+
+				css.FuseServerService().(*mocks.FuseServerServiceIface).On(
+					"CreateFuseServer", c3, c3).Return(errors.New("mount error")).Once()
+				css.FuseServerService().(*mocks.FuseServerServiceIface).On(
+					"CreateFuseServer", c3, c3).Return(nil)
+			},
+		},
+		{
+			//
+			// Test-case 4: Pre-register a new container whose fuse-server
+			// creation keeps failing. Error expected, and container must not
+			// be left behind.
+			//
+			name:    "4",
+			fields:  f1,
+			args:    args{"c4", "c4"},
+			wantErr: true,
+			prepare: func() {
+
+				css.FuseServerService().(*mocks.FuseServerServiceIface).On(
+					"CreateFuseServer", c4, c4).Return(errors.New("mount error"))
+			},
+		},
 	}
 
 	//
@@ -321,6 +372,39 @@ func Test_containerStateService_ContainerPreRegister(t *testing.T) {
 			}
 		})
 	}
+
+	// Failed pre-registrations must not leave the container behind.
+	if _, ok := css.idTable[c4.id]; ok {
+		t.Errorf("containerStateService.ContainerPreRegister() container %s still present",
+			c4.id)
+	}
+}
+
+func Test_containerStateService_ContainerPreRegister_slotTimeout(t *testing.T) {
+
+	css := &containerStateService{
+		idTable:     make(map[string]*container),
+		netnsTable:  make(map[domain.Inode][]*container),
+		fss:         fss,
+		preRegSlots: make(chan struct{}, 1),
+	}
+
+	// Hold the only fuse-server creation slot.
+	css.preRegSlots <- struct{}{}
+
+	origTimeout := PreRegistrationSlotTimeout
+	PreRegistrationSlotTimeout = 100 * time.Millisecond
+	defer func() { PreRegistrationSlotTimeout = origTimeout }()
+
+	err := css.ContainerPreRegister("c1", "")
+	if grpcStatus.Code(err) != grpcCodes.ResourceExhausted {
+		t.Errorf("containerStateService.ContainerPreRegister() error = %v, want %v",
+			err, grpcCodes.ResourceExhausted)
+	}
+
+	if _, ok := css.idTable["c1"]; ok {
+		t.Errorf("containerStateService.ContainerPreRegister() container c1 still present")
+	}
 }
 
 func Test_containerStateService_ContainerRegister(t *testing.T) {
@@ -767,3 +851,106 @@ func Test_containerStateService_ContainerLookupById(t *testing.T) {
 		})
 	}
 }
+
+func Test_containerStateService_ContainerRegister_overrides(t *testing.T) {
+
+	css := &containerStateService{
+		idTable:    make(map[string]*container),
+		netnsTable: make(map[domain.Inode][]*container),
+		fss:        fss,
+		prs:        prs,
+		ios:        ios,
+		mts:        mts,
+	}
+
+	css.ios.RemoveAllIOnodes()
+
+	tests := []struct {
+		name       string
+		pid        uint32
+		wantPath   string
+		wantValues map[string]string
+	}{
+		// Overrides defined for the container are applied.
+		{
+			name:     "1",
+			pid:      5005,
+			wantPath: "/proc/cpuinfo",
+		},
+
+		// Kernel release is applied as a /proc/sys/kernel override.
+		{
+			name:       "2",
+			pid:        7007,
+			wantPath:   "/proc/sys/kernel",
+			wantValues: map[string]string{"osrelease": "5.4.0-generic"},
+		},
+
+		// DMI identifiers are applied as a dmi/id override.
+		{
+			name:       "3",
+			pid:        8008,
+			wantPath:   "/sys/devices/virtual/dmi/id",
+			wantValues: map[string]string{"board_serial": "node-01"},
+		},
+
+		// No rule matching the container.
+		{
+			name: "4",
+			pid:  6006,
+		},
+	}
+
+	ContainerAttrRules = []domain.ContainerAttrRule{
+		{
+			Id:        "c1",
+			Overrides: []domain.HandlerOverride{{Path: "/proc/cpuinfo", Passthrough: true}},
+		},
+		{
+			Id:            "c2",
+			KernelRelease: "5.4.0-generic",
+		},
+		{
+			Id:    "c3",
+			DmiId: map[string]string{"board_serial": "node-01"},
+		},
+	}
+	defer func() { ContainerAttrRules = nil }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &container{
+				id:       "c" + tt.name,
+				initPid:  tt.pid,
+				initProc: css.prs.ProcessCreate(tt.pid, 0, 0),
+				service:  css,
+			}
+			c.InitProc().CreateNsInodes(123456)
+			css.idTable[c.id] = c
+
+			css.MountService().(*mocks.MountServiceIface).On(
+				"NewMountInfoParser", c, c.initProc, true, true, true).Return(nil, nil)
+
+			if err := css.ContainerRegister(c); err != nil {
+				t.Fatalf("containerStateService.ContainerRegister() error = %v", err)
+			}
+
+			if tt.wantPath == "" {
+				if len(c.overrides) != 0 {
+					t.Errorf("overrides applied to non-matching container")
+				}
+				return
+			}
+
+			ov, ok := c.HandlerOverride(tt.wantPath)
+			if !ok {
+				t.Fatalf("override of %s not applied upon registration", tt.wantPath)
+			}
+			for k, v := range tt.wantValues {
+				if ov.Values[k] != v {
+					t.Errorf("override value of %s = %q, want %q", k, ov.Values[k], v)
+				}
+			}
+		})
+	}
+}
@@ -242,6 +242,98 @@ func Test_container_SetData(t *testing.T) {
 	}
 }
 
+func Test_container_HandlerOverride(t *testing.T) {
+
+	rules := []domain.ContainerAttrRule{
+		{
+			Id: "*",
+			Overrides: []domain.HandlerOverride{
+				{Path: "/proc/cpuinfo", Passthrough: true},
+				{Path: "/proc/sys/kernel", Values: map[string]string{"pid_max": "4096"}},
+			},
+		},
+		{
+			Id: "c1*",
+			Overrides: []domain.HandlerOverride{
+				{Path: "/proc/sys/kernel", Values: map[string]string{"pid_max": "32768"}},
+			},
+		},
+		{
+			Id: "c2",
+			Overrides: []domain.HandlerOverride{
+				{Path: "/proc/uptime", Passthrough: true},
+			},
+		},
+	}
+
+	overrides := domain.ContainerHandlerOverrides(rules, "c1a")
+
+	var cs1 = &container{}
+	cs1.SetHandlerOverrides(overrides)
+
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		// Passthrough override.
+		{"1", "/proc/cpuinfo", true},
+
+		// Values override.
+		{"2", "/proc/sys/kernel", true},
+
+		// Override of a non-matching rule.
+		{"3", "/proc/uptime", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := cs1.HandlerOverride(tt.path); got != tt.want {
+				t.Errorf("container.HandlerOverride() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Later rules prevail, without altering the earlier ones.
+	ov, _ := cs1.HandlerOverride("/proc/sys/kernel")
+	assert.Equal(t, "32768", ov.Values["pid_max"], "override values are not matching")
+	assert.Equal(t, "4096", rules[0].Overrides[1].Values["pid_max"],
+		"rule values are altered")
+
+	// Kernel release is merged into the /proc/sys/kernel override.
+	overrides = domain.ContainerHandlerOverrides([]domain.ContainerAttrRule{
+		{
+			Id: "c1",
+			Overrides: []domain.HandlerOverride{
+				{Path: "/proc/sys/kernel", Values: map[string]string{"pid_max": "32768"}},
+			},
+			KernelRelease: "5.4.0-generic",
+		},
+	}, "c1")
+	assert.Equal(t, 1, len(overrides), "overrides are not merged")
+	assert.Equal(t, "5.4.0-generic", overrides[0].Values["osrelease"],
+		"override values are not matching")
+
+	// DMI identifiers are merged into the dmi/id override.
+	overrides = domain.ContainerHandlerOverrides([]domain.ContainerAttrRule{
+		{
+			Id: "c1",
+			DmiId: map[string]string{
+				"product_uuid": "3d7b0f2e-2b1c-4a3e-9d52-6c0e8f1a7b44",
+				"board_serial": "node-01",
+			},
+		},
+		{
+			Id:    "c1",
+			DmiId: map[string]string{"board_serial": "node-02"},
+		},
+	}, "c1")
+	assert.Equal(t, 1, len(overrides), "overrides are not merged")
+	assert.Equal(t, "node-02", overrides[0].Values["board_serial"],
+		"override values are not matching")
+	assert.Equal(t, 2, len(overrides[0].Values), "override values are not merged")
+}
+
 func Test_container_update(t *testing.T) {
 	type fields struct {
 		id            string
@@ -0,0 +1,360 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/nestybox/sysbox-libs/formatter"
+)
+
+//
+// Namespace-change watchers.
+//
+// Handlers deriving their content from a sys container's netns (e.g. network
+// interfaces) or cgroups (e.g. memory / cpu limits) can have it cached (see
+// domain.ReadCache). Rather than relying on the caches' TTLs alone, the netns
+// and cgroups of every registered container are watched -- through a netlink
+// socket bound to the container's netns, and through inotify watches over its
+// cgroup directories -- so that the container's cached content is dropped as
+// soon as interfaces / addresses are added or removed, or cgroup limits are
+// changed.
+//
+
+// Cgroup (v1) controllers whose limits are reflected by the emulated nodes.
+var watchedCgroupControllers = []string{"cpu", "cpuset", "hugetlb", "memory", "pids"}
+
+// Cgroup files written upon every process migration, and hence not relevant.
+var ignoredCgroupFiles = map[string]bool{
+	"cgroup.procs":   true,
+	"cgroup.threads": true,
+	"tasks":          true,
+}
+
+// Watchers rely on the runtime's netpoller (i.e. their sockets / inotify
+// instances are non-blocking), so that no OS thread is held per container.
+type nsWatcher struct {
+	cntr     *container
+	css      *containerStateService
+	netlink  *os.File
+	inotify  *os.File
+	changes  chan struct{} // pending (coalesced) changes
+	done     chan struct{} // closed upon the watcher's termination
+	stopOnce sync.Once
+}
+
+// Starts watching the netns & cgroups of the given container.
+func (css *containerStateService) watchContainer(cntr *container) {
+
+	css.unwatchContainer(cntr)
+
+	w, err := newNsWatcher(css, cntr)
+	if err != nil {
+		logrus.Warnf("Unable to watch netns / cgroups of container %s: %v",
+			formatter.ContainerID{cntr.id}, err)
+		return
+	}
+
+	cntr.intLock.Lock()
+	cntr.nsWatcher = w
+	cntr.intLock.Unlock()
+
+	w.start()
+}
+
+// Stops watching the netns & cgroups of the given container (if watched).
+func (css *containerStateService) unwatchContainer(cntr *container) {
+
+	cntr.intLock.Lock()
+	w := cntr.nsWatcher
+	cntr.nsWatcher = nil
+	cntr.intLock.Unlock()
+
+	if w != nil {
+		w.stop()
+	}
+}
+
+func newNsWatcher(css *containerStateService, cntr *container) (*nsWatcher, error) {
+
+	pid := cntr.InitPid()
+
+	netlinkFd, err := netlinkSocketAt(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	inotifyFd, err := cgroupInotifyInit(pid)
+	if err != nil {
+		unix.Close(netlinkFd)
+		return nil, err
+	}
+
+	return newNsWatcherFromFds(css, cntr, netlinkFd, inotifyFd), nil
+}
+
+// Creates a watcher out of the given (non-blocking) netlink socket and inotify
+// instance, which it takes ownership of.
+func newNsWatcherFromFds(
+	css *containerStateService,
+	cntr *container,
+	netlinkFd int,
+	inotifyFd int) *nsWatcher {
+
+	return &nsWatcher{
+		cntr:    cntr,
+		css:     css,
+		netlink: os.NewFile(uintptr(netlinkFd), "netlink"),
+		inotify: os.NewFile(uintptr(inotifyFd), "inotify"),
+		changes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+func (w *nsWatcher) start() {
+
+	go w.watch(w.netlink, 16*1024, netlinkChanged)
+	go w.watch(w.inotify, 16*(unix.SizeofInotifyEvent+unix.NAME_MAX+1), inotifyChanged)
+	go w.run()
+}
+
+func (w *nsWatcher) run() {
+
+	for {
+		select {
+		case <-w.done:
+			return
+
+		case <-w.changes:
+			logrus.Debugf("Netns / cgroups of container %s changed: dropping cached content",
+				formatter.ContainerID{w.cntr.id})
+			w.css.hds.InvalidateReadCaches(w.cntr)
+		}
+	}
+}
+
+// Reads the given file's notifications till the watcher is stopped, and flags
+// the ones deemed as changes by the given function.
+func (w *nsWatcher) watch(f *os.File, bufSize int, changed func([]byte) bool) {
+
+	buf := make([]byte, bufSize)
+
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			select {
+			case <-w.done:
+				return
+			default:
+			}
+
+			// Netlink notifications were dropped.
+			if errors.Is(err, unix.ENOBUFS) {
+				w.notify()
+				continue
+			}
+
+			logrus.Warnf("Netns / cgroups watcher of container %s failed: %v",
+				formatter.ContainerID{w.cntr.id}, err)
+			return
+		}
+
+		if changed(buf[:n]) {
+			w.notify()
+		}
+	}
+}
+
+func (w *nsWatcher) notify() {
+
+	select {
+	case w.changes <- struct{}{}:
+	default:
+		// A change is already pending.
+	}
+}
+
+func (w *nsWatcher) stop() {
+
+	w.stopOnce.Do(func() {
+		close(w.done)
+
+		// Closing the files unblocks their readers.
+		w.netlink.Close()
+		w.inotify.Close()
+	})
+}
+
+// As the netlink socket is only subscribed to link & address changes, any
+// notification counts as a change.
+func netlinkChanged(buf []byte) bool {
+	return len(buf) > 0
+}
+
+// Returns true if any of the given inotify events refers to a relevant cgroup
+// file.
+func inotifyChanged(buf []byte) bool {
+
+	changed := false
+
+	for off := 0; off+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		nameStart := off + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(event.Len)
+		if nameEnd > len(buf) {
+			break
+		}
+
+		name := string(bytes.TrimRight(buf[nameStart:nameEnd], "\x00"))
+		if !ignoredCgroupFiles[name] {
+			changed = true
+		}
+
+		off = nameEnd
+	}
+
+	return changed
+}
+
+// Opens a netlink socket within the given netns, subscribed to its link &
+// address changes. The socket remains bound to that netns once created.
+func netlinkSocketAt(nsPath string) (int, error) {
+
+	runtime.LockOSThread()
+
+	origNs, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		runtime.UnlockOSThread()
+		return -1, err
+	}
+	defer origNs.Close()
+
+	targetNs, err := os.Open(nsPath)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return -1, err
+	}
+	defer targetNs.Close()
+
+	if err := unix.Setns(int(targetNs.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return -1, err
+	}
+
+	fd, err := unix.Socket(
+		unix.AF_NETLINK,
+		unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK,
+		unix.NETLINK_ROUTE,
+	)
+	if err == nil {
+		err = unix.Bind(fd, &unix.SockaddrNetlink{
+			Family: unix.AF_NETLINK,
+			Groups: unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR,
+		})
+		if err != nil {
+			unix.Close(fd)
+		}
+	}
+
+	// Leave the thread locked (i.e. to be terminated along with this goroutine)
+	// should it not make it back to its original netns.
+	if rerr := unix.Setns(int(origNs.Fd()), unix.CLONE_NEWNET); rerr != nil {
+		logrus.Errorf("Unable to restore the netns of thread %d: %v",
+			unix.Gettid(), rerr)
+	} else {
+		runtime.UnlockOSThread()
+	}
+
+	if err != nil {
+		return -1, err
+	}
+
+	return fd, nil
+}
+
+// Creates an inotify instance watching the writes to the cgroup files of the
+// given process (as per its /proc/<pid>/cgroup).
+func cgroupInotifyInit(pid uint32) (int, error) {
+
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return -1, err
+	}
+	defer f.Close()
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return -1, err
+	}
+
+	// Entries are in "<id>:<controllers>:<path>" form; the unified (v2) one
+	// carries no controllers.
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		var dir string
+		if fields[1] == "" {
+			dir = filepath.Join("/sys/fs/cgroup", fields[2])
+		} else if watchedCgroupController(fields[1]) {
+			dir = filepath.Join("/sys/fs/cgroup", fields[1], fields[2])
+		} else {
+			continue
+		}
+
+		if _, err := unix.InotifyAddWatch(fd, dir, unix.IN_MODIFY); err != nil &&
+			err != unix.ENOENT {
+			unix.Close(fd)
+			return -1, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+
+	return fd, nil
+}
+
+func watchedCgroupController(controllers string) bool {
+
+	for _, c := range strings.Split(controllers, ",") {
+		for _, wc := range watchedCgroupControllers {
+			if c == wc {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+//
+// Copyright 2019-2020 Nestybox, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/sys/unix"
+
+	"github.com/nestybox/sysbox-fs/domain"
+	"github.com/nestybox/sysbox-fs/mocks"
+)
+
+func Test_nsWatcher(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "nswatcher")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Stand-ins for the netlink socket (a datagram socket-pair) and the cgroup
+	// dirs (a regular dir).
+	sp, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_DGRAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatalf("Unable to create socket-pair: %v", err)
+	}
+	defer unix.Close(sp[1])
+
+	inotifyFd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		t.Fatalf("Unable to create inotify instance: %v", err)
+	}
+	if _, err := unix.InotifyAddWatch(inotifyFd, dir, unix.IN_MODIFY); err != nil {
+		t.Fatalf("Unable to add inotify watch: %v", err)
+	}
+
+	invalidated := make(chan struct{}, 16)
+
+	hdsMock := &mocks.HandlerServiceIface{}
+	hdsMock.On("InvalidateReadCaches", mock.Anything).Run(func(args mock.Arguments) {
+		invalidated <- struct{}{}
+	}).Return()
+
+	css := &containerStateService{
+		idTable:    make(map[string]*container),
+		netnsTable: make(map[domain.Inode][]*container),
+		hds:        hdsMock,
+	}
+	cntr := newContainer("c1", 1001, time.Time{}, 231072, 65535, 231072, 65535,
+		nil, nil, css).(*container)
+
+	w := newNsWatcherFromFds(css, cntr, sp[0], inotifyFd)
+	w.start()
+
+	expect := func(name string, want bool) {
+		select {
+		case <-invalidated:
+			if !want {
+				t.Errorf("%s: unexpected invalidation", name)
+			}
+		case <-time.After(500 * time.Millisecond):
+			if want {
+				t.Errorf("%s: expected invalidation", name)
+			}
+		}
+	}
+
+	// Netlink notifications.
+	if _, err := unix.Write(sp[1], []byte("link")); err != nil {
+		t.Fatalf("Unable to write to socket-pair: %v", err)
+	}
+	expect("netlink", true)
+
+	// Writes to relevant cgroup files.
+	if err := ioutil.WriteFile(filepath.Join(dir, "memory.max"), []byte("1"), 0644); err != nil {
+		t.Fatalf("Unable to write file: %v", err)
+	}
+	expect("memory.max", true)
+
+	// Writes to the files updated upon process migrations are ignored.
+	if err := ioutil.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte("1"), 0644); err != nil {
+		t.Fatalf("Unable to write file: %v", err)
+	}
+	expect("cgroup.procs", false)
+
+	// Nothing is reported once stopped (and stopping twice is harmless).
+	w.stop()
+	w.stop()
+
+	unix.Write(sp[1], []byte("link"))
+	expect("stopped", false)
+}